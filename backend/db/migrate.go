@@ -0,0 +1,81 @@
+package db
+
+import (
+	"database/sql"
+	"embed"
+	"io/fs"
+	"log"
+	"strings"
+	"time"
+)
+
+// schema changes since initial dump, applied in filename order
+//
+//go:embed migrations/*.sql
+var migrations_fs embed.FS
+
+func Migrate(client *sql.DB) error {
+	_, err := client.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version TEXT PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	);`)
+	if err != nil {
+		return err
+	}
+
+	entries, err := fs.ReadDir(migrations_fs, "migrations")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		version := strings.TrimSuffix(entry.Name(), ".sql")
+
+		var applied bool
+		err = client.QueryRow(
+			"SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = ?);",
+			version,
+		).Scan(&applied)
+		if err != nil {
+			return err
+		} else if applied {
+			continue
+		}
+
+		migration, err := migrations_fs.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return err
+		}
+
+		if err = applyMigration(client, version, string(migration)); err != nil {
+			log.Printf("migration %s failed", version)
+			return err
+		}
+		log.Printf("applied migration %s", version)
+	}
+
+	return nil
+}
+
+func applyMigration(client *sql.DB, version string, migration string) error {
+	tx, err := client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(migration); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO schema_migrations VALUES (?, ?);",
+		version,
+		time.Now().UTC().Format("2006-01-02 15:04:05"),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
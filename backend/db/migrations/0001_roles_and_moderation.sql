@@ -0,0 +1,15 @@
+ALTER TABLE Users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+	CHECK (role IN ('user', 'moderator', 'admin'));
+ALTER TABLE Users ADD COLUMN banned_at TEXT;
+ALTER TABLE Users ADD COLUMN ban_reason TEXT;
+
+CREATE TABLE "Moderator Actions" (
+	id TEXT PRIMARY KEY,
+	moderator_id TEXT NOT NULL,
+	action TEXT NOT NULL,
+	target_type TEXT NOT NULL,
+	target_id TEXT NOT NULL,
+	details TEXT,
+	performed_at TEXT NOT NULL
+);
+CREATE INDEX moderator_actions_performed_at_idx ON "Moderator Actions"(performed_at);
@@ -0,0 +1,9 @@
+CREATE TABLE "Login Name History" (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	old_login_name TEXT NOT NULL,
+	new_login_name TEXT NOT NULL,
+	changed_at TEXT NOT NULL,
+	reserved_until TEXT NOT NULL
+);
+CREATE INDEX login_name_history_old_login_name_idx ON "Login Name History"(old_login_name);
@@ -0,0 +1,10 @@
+-- is_block: also stops muted user from liking / copying the muter's links
+CREATE TABLE "User Mutes" (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	muted_id TEXT NOT NULL,
+	is_block INTEGER NOT NULL DEFAULT 0,
+	created TEXT NOT NULL,
+	UNIQUE(user_id, muted_id)
+);
+CREATE INDEX user_mutes_muted_id_idx ON "User Mutes"(muted_id);
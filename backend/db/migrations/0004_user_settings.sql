@@ -0,0 +1,11 @@
+-- NULL / empty columns mean no preference (request params and
+-- endpoint defaults apply as before)
+CREATE TABLE "User Settings" (
+	user_id TEXT PRIMARY KEY,
+	nsfw INTEGER NOT NULL DEFAULT 0,
+	sort_by TEXT,
+	period TEXT,
+	tmap_section_order TEXT,
+	muted_cats TEXT,
+	last_updated TEXT NOT NULL
+);
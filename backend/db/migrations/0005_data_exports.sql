@@ -0,0 +1,14 @@
+-- status: pending, running, ready, failed, expired
+-- download_token required (with id) to download once ready
+CREATE TABLE "Data Exports" (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT,
+	size INTEGER,
+	download_token TEXT,
+	created TEXT NOT NULL,
+	completed TEXT,
+	expires TEXT
+);
+CREATE INDEX data_exports_user_id_idx ON "Data Exports"(user_id);
@@ -0,0 +1,25 @@
+-- status: pending, running, paused (daily link limit reached), done
+CREATE TABLE "Link Imports" (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	format TEXT NOT NULL,
+	status TEXT NOT NULL,
+	created TEXT NOT NULL,
+	last_updated TEXT NOT NULL
+);
+CREATE INDEX link_imports_user_id_idx ON "Link Imports"(user_id);
+
+-- status: pending, added, copied, tagged, skipped, failed
+CREATE TABLE "Link Import Items" (
+	id TEXT PRIMARY KEY,
+	import_id TEXT NOT NULL REFERENCES "Link Imports"(id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	url TEXT NOT NULL,
+	cats TEXT NOT NULL,
+	title TEXT,
+	status TEXT NOT NULL,
+	link_id TEXT,
+	error TEXT,
+	processed TEXT
+);
+CREATE INDEX link_import_items_import_id_idx ON "Link Import Items"(import_id, position);
@@ -0,0 +1,3 @@
+-- starred: copy existing link (otherwise only tagged)
+ALTER TABLE "Link Import Items" ADD COLUMN summary TEXT;
+ALTER TABLE "Link Import Items" ADD COLUMN starred INTEGER NOT NULL DEFAULT 1;
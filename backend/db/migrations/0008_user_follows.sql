@@ -0,0 +1,11 @@
+CREATE TABLE "User Follows" (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	followed_id TEXT NOT NULL,
+	created TEXT NOT NULL,
+	UNIQUE(user_id, followed_id)
+);
+CREATE INDEX user_follows_followed_id_idx ON "User Follows"(followed_id);
+
+-- orders copies in following feed (NULL for copies made before now)
+ALTER TABLE "Link Copies" ADD COLUMN created TEXT;
@@ -0,0 +1,8 @@
+-- cats: alphabetized, matched with AND (like ?cats= filter)
+CREATE TABLE "Cat Subscriptions" (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	cats TEXT NOT NULL,
+	created TEXT NOT NULL,
+	UNIQUE(user_id, cats)
+);
@@ -0,0 +1,29 @@
+-- repeat events on the same target are batched into the recipient's unread
+-- notification (actor_count incremented) until it is marked read
+CREATE TABLE Notifications (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	type TEXT NOT NULL,
+	link_id TEXT NOT NULL,
+	summary_id TEXT NOT NULL DEFAULT '',
+	last_actor_id TEXT NOT NULL,
+	actor_count INTEGER NOT NULL DEFAULT 1,
+	is_read INTEGER NOT NULL DEFAULT 0,
+	created TEXT NOT NULL,
+	last_updated TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX notifications_unread_target
+ON Notifications(user_id, type, link_id, summary_id)
+WHERE is_read = 0;
+
+CREATE INDEX notifications_user_updated
+ON Notifications(user_id, last_updated);
+
+-- no row: notification type enabled
+CREATE TABLE "Notification Preferences" (
+	user_id TEXT NOT NULL,
+	type TEXT NOT NULL,
+	enabled INTEGER NOT NULL,
+	PRIMARY KEY(user_id, type)
+);
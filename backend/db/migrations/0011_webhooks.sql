@@ -0,0 +1,36 @@
+-- cats: alphabetized, matched with AND (like cat subscriptions)
+-- followed_users: also match links submitted by users the owner follows
+CREATE TABLE Webhooks (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	cats TEXT NOT NULL DEFAULT '',
+	followed_users INTEGER NOT NULL DEFAULT 0,
+	is_enabled INTEGER NOT NULL DEFAULT 1,
+	consecutive_failures INTEGER NOT NULL DEFAULT 0,
+	created TEXT NOT NULL
+);
+
+CREATE INDEX webhooks_user ON Webhooks(user_id);
+
+-- response_code: of latest attempt (NULL if no response)
+CREATE TABLE "Webhook Deliveries" (
+	id TEXT PRIMARY KEY,
+	webhook_id TEXT NOT NULL,
+	event TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	status TEXT NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	response_code INTEGER,
+	error TEXT,
+	created TEXT NOT NULL,
+	last_attempted TEXT,
+	next_attempt TEXT NOT NULL
+);
+
+CREATE INDEX webhook_deliveries_pending
+ON "Webhook Deliveries"(status, next_attempt);
+
+CREATE INDEX webhook_deliveries_webhook
+ON "Webhook Deliveries"(webhook_id, created);
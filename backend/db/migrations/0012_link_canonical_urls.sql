@@ -0,0 +1,8 @@
+-- canonical_url: form of url used to detect duplicate links (see
+-- CanonicalizeURL). NULL until backfilled on startup, and left NULL for
+-- existing links whose canonical form another link already has
+-- (i.e., duplicates)
+ALTER TABLE Links ADD COLUMN canonical_url TEXT;
+
+CREATE UNIQUE INDEX links_canonical_url ON Links(canonical_url)
+WHERE canonical_url IS NOT NULL;
@@ -0,0 +1,10 @@
+-- left by merging link from_link_id into to_link_id: from_link_id's tag
+-- and summary pages redirect to to_link_id's
+CREATE TABLE "Link Redirects" (
+	from_link_id TEXT PRIMARY KEY,
+	to_link_id TEXT NOT NULL,
+	merged_by TEXT NOT NULL,
+	merged_at TEXT NOT NULL
+);
+
+CREATE INDEX link_redirects_to ON "Link Redirects"(to_link_id);
@@ -0,0 +1,25 @@
+-- latest health check of each link (none until first checked)
+-- status: ok, redirected, not_found, gone, timeout, error or dead
+-- (after LINK_HEALTH_DEAD_AFTER_FAILURES failed checks in a row)
+-- response_code: NULL if no response
+CREATE TABLE "Link Health" (
+	link_id TEXT PRIMARY KEY,
+	status TEXT NOT NULL,
+	response_code INTEGER,
+	consecutive_failures INTEGER NOT NULL DEFAULT 0,
+	last_checked TEXT NOT NULL,
+	next_check TEXT NOT NULL
+);
+
+CREATE INDEX link_health_next_check ON "Link Health"(next_check);
+
+-- status: result of check (never dead)
+CREATE TABLE "Link Health Checks" (
+	id TEXT PRIMARY KEY,
+	link_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	response_code INTEGER,
+	checked_at TEXT NOT NULL
+);
+
+CREATE INDEX link_health_checks_link ON "Link Health Checks"(link_id, checked_at);
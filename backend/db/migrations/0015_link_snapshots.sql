@@ -0,0 +1,27 @@
+-- archived copies of linked pages, one WARC file (warc_key in archive
+-- store) per ready snapshot
+-- status: ready, blocked (by robots.txt) or failed
+-- captured_at: YYYYMMDDhhmmss (UTC)
+CREATE TABLE "Link Snapshots" (
+	id TEXT PRIMARY KEY,
+	link_id TEXT NOT NULL,
+	url TEXT NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT,
+	warc_key TEXT,
+	size INTEGER NOT NULL DEFAULT 0,
+	captured_at TEXT NOT NULL
+);
+
+CREATE INDEX link_snapshots_link ON "Link Snapshots"(link_id, captured_at);
+
+-- where each archived URL's response record is in snapshot's WARC file
+CREATE TABLE "Link Snapshot Records" (
+	snapshot_id TEXT NOT NULL,
+	url TEXT NOT NULL,
+	content_type TEXT NOT NULL,
+	status_code INTEGER NOT NULL,
+	record_offset INTEGER NOT NULL,
+	record_length INTEGER NOT NULL,
+	PRIMARY KEY (snapshot_id, url)
+);
@@ -0,0 +1,62 @@
+-- full-text search over links: URL (without scheme), global summary, all
+-- user summaries and global cats
+CREATE VIRTUAL TABLE link_search_fts USING fts5(
+	link_id UNINDEXED,
+	url,
+	summary,
+	summaries,
+	cats
+);
+
+INSERT INTO link_search_fts(link_id, url, summary, summaries, cats)
+SELECT
+	l.id,
+	replace(replace(l.url, 'https://', ''), 'http://', ''),
+	COALESCE(l.global_summary, ''),
+	COALESCE((SELECT group_concat(text, ' ') FROM Summaries WHERE link_id = l.id), ''),
+	COALESCE(l.global_cats, '')
+FROM Links l;
+
+CREATE TRIGGER link_search_links_ai AFTER INSERT ON Links BEGIN
+	INSERT INTO link_search_fts(link_id, url, summary, summaries, cats)
+	VALUES (
+		new.id,
+		replace(replace(new.url, 'https://', ''), 'http://', ''),
+		COALESCE(new.global_summary, ''),
+		'',
+		COALESCE(new.global_cats, '')
+	);
+END;
+
+CREATE TRIGGER link_search_links_au AFTER UPDATE OF url, global_summary, global_cats ON Links BEGIN
+	UPDATE link_search_fts
+	SET
+		url = replace(replace(new.url, 'https://', ''), 'http://', ''),
+		summary = COALESCE(new.global_summary, ''),
+		cats = COALESCE(new.global_cats, '')
+	WHERE link_id = old.id;
+END;
+
+CREATE TRIGGER link_search_links_ad AFTER DELETE ON Links BEGIN
+	DELETE FROM link_search_fts WHERE link_id = old.id;
+END;
+
+-- summaries column rebuilt for affected links
+-- (UPDATE covers summaries moved to another link by merges)
+CREATE TRIGGER link_search_summaries_ai AFTER INSERT ON Summaries BEGIN
+	UPDATE link_search_fts
+	SET summaries = COALESCE((SELECT group_concat(text, ' ') FROM Summaries WHERE link_id = new.link_id), '')
+	WHERE link_id = new.link_id;
+END;
+
+CREATE TRIGGER link_search_summaries_au AFTER UPDATE OF text, link_id ON Summaries BEGIN
+	UPDATE link_search_fts
+	SET summaries = COALESCE((SELECT group_concat(text, ' ') FROM Summaries WHERE link_id = link_search_fts.link_id), '')
+	WHERE link_id IN (old.link_id, new.link_id);
+END;
+
+CREATE TRIGGER link_search_summaries_ad AFTER DELETE ON Summaries BEGIN
+	UPDATE link_search_fts
+	SET summaries = COALESCE((SELECT group_concat(text, ' ') FROM Summaries WHERE link_id = old.link_id), '')
+	WHERE link_id = old.link_id;
+END;
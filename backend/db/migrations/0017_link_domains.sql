@@ -0,0 +1,5 @@
+-- domain: registrable domain of url (see LinkDomain), e.g., github.com.
+-- NULL until backfilled on startup; '' if url has none
+ALTER TABLE Links ADD COLUMN domain TEXT;
+
+CREATE INDEX links_domain ON Links(domain);
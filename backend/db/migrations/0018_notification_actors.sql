@@ -0,0 +1,10 @@
+-- distinct users batched into a notification (actor_count is its number
+-- of rows). Notifications batched before this only know their last actor
+CREATE TABLE "Notification Actors" (
+	notification_id TEXT NOT NULL,
+	actor_id TEXT NOT NULL,
+	UNIQUE(notification_id, actor_id)
+);
+
+INSERT INTO "Notification Actors" (notification_id, actor_id)
+SELECT id, last_actor_id FROM Notifications;
@@ -59,6 +59,11 @@ func SetupTestDB() error {
 		return err
 	}
 
+	// apply schema changes made since dump
+	if err = db.Migrate(TestClient); err != nil {
+		return err
+	}
+
 	// switch DB client to TestClient
 	db.Client = TestClient
 	log.Print("switched to test DB client")
@@ -0,0 +1,28 @@
+package error
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// Roles
+	ErrInsufficientRole    error = errors.New("insufficient role for this action")
+	ErrInvalidRole         error = errors.New("invalid role provided (user, moderator or admin)")
+	ErrNoRole              error = errors.New("no role provided")
+	ErrCannotChangeOwnRole error = errors.New("cannot change your own role")
+	// Bans
+	ErrUserBanned         error = errors.New("account banned")
+	ErrUserAlreadyBanned  error = errors.New("user already banned")
+	ErrUserNotBanned      error = errors.New("user not banned")
+	ErrCannotBanModerator error = errors.New("moderators and admins cannot be banned; demote first")
+	// Moderation
+	ErrNoTagForLinkSubmitter error = errors.New("link submitter's tag no longer exists")
+	// Err log
+	ErrNoErrLogFile error = errors.New("FITM_ERR_LOG_FILE env var not set")
+	ErrInvalidLimit error = errors.New("invalid limit provided")
+)
+
+func BanReasonLengthExceedsLimit(limit int) error {
+	return fmt.Errorf("ban reason too long (max %d chars)", limit)
+}
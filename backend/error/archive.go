@@ -0,0 +1,16 @@
+package error
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrDisallowedByRobots      error = errors.New("disallowed by robots.txt")
+	ErrNoSnapshotWithTimestamp error = errors.New("no archived snapshot found with given timestamp")
+	ErrURLNotInSnapshot        error = errors.New("URL not archived in snapshot")
+)
+
+func ErrSnapshotTooLarge(limit int64) error {
+	return fmt.Errorf("page exceeds snapshot size limit (%d bytes)", limit)
+}
@@ -0,0 +1,9 @@
+package error
+
+import "errors"
+
+var (
+	ErrNoDomain          error = errors.New("no domain provided")
+	ErrInvalidDomain     error = errors.New("invalid domain provided")
+	ErrNoLinksWithDomain error = errors.New("no links found with given domain")
+)
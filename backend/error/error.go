@@ -1,6 +1,7 @@
 package error
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/go-chi/render"
@@ -13,6 +14,8 @@ type ErrResponse struct {
 	HTTPStatusCode int   `json:"-"`
 	StatusText string `json:"status"`
 	ErrorText  string `json:"error,omitempty"`
+	// link query syntax errors only
+	Position int `json:"position,omitempty"`
 }
 
 func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
@@ -22,12 +25,19 @@ func (e *ErrResponse) Render(w http.ResponseWriter, r *http.Request) error {
 
 // malformed JSON
 func ErrInvalidRequest(err error) render.Renderer {
-	return &ErrResponse{
+	res := &ErrResponse{
 		Err:            err,
 		HTTPStatusCode: 400,
 		StatusText:     "Invalid request.",
 		ErrorText:      err.Error(),
 	}
+
+	var parse_err *QueryParseError
+	if errors.As(err, &parse_err) {
+		res.Position = parse_err.Pos
+	}
+
+	return res
 }
 
 func ErrUnauthenticated(err error) render.Renderer {
@@ -76,3 +86,21 @@ func Err500(err error) render.Renderer {
 		ErrorText:      err.Error(),
 	}
 }
+
+func Err409(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 409,
+		StatusText:     "Conflict.",
+		ErrorText:      err.Error(),
+	}
+}
+
+func Err410(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 410,
+		StatusText:     "Gone.",
+		ErrorText:      err.Error(),
+	}
+}
@@ -0,0 +1,9 @@
+package error
+
+import (
+	"errors"
+)
+
+var (
+	ErrInvalidLastEventID error = errors.New("invalid Last-Event-ID provided")
+)
@@ -0,0 +1,13 @@
+package error
+
+import (
+	"errors"
+)
+
+var (
+	ErrDataExportInProgress error = errors.New("data export already in progress")
+	ErrNoDataExportWithID   error = errors.New("no data export found with given ID")
+	ErrDataExportNotReady   error = errors.New("data export not ready")
+	ErrDataExportExpired    error = errors.New("data export download link expired: request a new export")
+	ErrInvalidExportToken   error = errors.New("invalid data export download token")
+)
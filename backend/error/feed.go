@@ -0,0 +1,9 @@
+package error
+
+import (
+	"fmt"
+)
+
+func UnsupportedFeedFormat(format string, formats []string) error {
+	return fmt.Errorf("unsupported feed format %q (accepted: %v)", format, formats)
+}
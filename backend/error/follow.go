@@ -0,0 +1,12 @@
+package error
+
+import (
+	"errors"
+)
+
+var (
+	ErrCannotFollowSelf    error = errors.New("cannot follow yourself")
+	ErrUserAlreadyFollowed error = errors.New("user already followed")
+	ErrUserNotFollowed     error = errors.New("user not followed")
+	ErrCannotFollowBlocker error = errors.New("user has blocked you")
+)
@@ -0,0 +1,31 @@
+package error
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoImportFile         error = errors.New("no import file provided (form field: bookmarks)")
+	ErrNoBookmarksFound     error = errors.New("no importable http(s) links found in file")
+	ErrImportInProgress     error = errors.New("link import already in progress")
+	ErrNoLinkImportWithID   error = errors.New("no link import found with given ID")
+	ErrLinkAlreadySubmitted error = errors.New("link already submitted by you")
+	ErrLinkAlreadyImported  error = errors.New("link already tagged by you (and copied, if starred)")
+)
+
+func UnsupportedImportFormat(format string, formats []string) error {
+	return fmt.Errorf("unsupported import format %q (accepted: %v)", format, formats)
+}
+
+func ImportLinksExceedLimit(limit int) error {
+	return fmt.Errorf("too many links in import (max %d)", limit)
+}
+
+func InvalidImportFile(format string, err error) error {
+	return fmt.Errorf("could not read %s import file: %w", format, err)
+}
+
+func MissingImportColumn(column string) error {
+	return fmt.Errorf("import file missing %q column", column)
+}
@@ -0,0 +1,32 @@
+package error
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoJWTKeyID      error = errors.New("JWT key has no kid")
+	ErrNoJWTSigningKey error = errors.New("no JWT signing key configured")
+	ErrInvalidJWT      error = errors.New("invalid JWT")
+)
+
+func ErrUnsupportedJWTAlg(alg string) error {
+	return fmt.Errorf("unsupported JWT algorithm: %s", alg)
+}
+
+func ErrInvalidJWTKeyRetiresAt(kid string) error {
+	return fmt.Errorf("JWT key %s has invalid retires_at (RFC 3339 required)", kid)
+}
+
+func ErrDuplicateJWTKeyID(kid string) error {
+	return fmt.Errorf("duplicate JWT kid: %s", kid)
+}
+
+func ErrNoJWTKeyWithID(kid string) error {
+	return fmt.Errorf("no active JWT key with kid: %s", kid)
+}
+
+func ErrJWTKeyRetired(kid string) error {
+	return fmt.Errorf("JWT key %s is retired", kid)
+}
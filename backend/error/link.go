@@ -7,14 +7,20 @@ import (
 
 var (
 	// Query links
-	ErrInvalidPage       error = errors.New("invalid page provided")
-	ErrInvalidLinkID     error = errors.New("invalid link ID provided")
-	ErrInvalidPeriod     error = errors.New("invalid period provided")
-	ErrInvalidNSFWParams error = errors.New("invalid NSFW params provided")
-	ErrNoLinkID          error = errors.New("no link ID provided")
-	ErrNoLinkWithID      error = errors.New("no link found with given ID")
-	ErrNoCats            error = errors.New("no cats provided")
-	ErrNoPeriod          error = errors.New("no period provided")
+	ErrInvalidPage           error = errors.New("invalid page provided")
+	ErrInvalidLinkID         error = errors.New("invalid link ID provided")
+	ErrInvalidPeriod         error = errors.New("invalid period provided")
+	ErrInvalidNSFWParams     error = errors.New("invalid NSFW params provided")
+	ErrInvalidHideDeadParams error = errors.New("invalid hide_dead params provided")
+	ErrNoLinkID              error = errors.New("no link ID provided")
+	ErrNoLinkWithID          error = errors.New("no link found with given ID")
+	ErrNoCats                error = errors.New("no cats provided")
+	ErrNoPeriod              error = errors.New("no period provided")
+	ErrNoDateRange           error = errors.New("no from or to date provided")
+	ErrInvalidDateRange      error = errors.New("from date must be before to date")
+	ErrPeriodWithDateRange   error = errors.New("cannot combine period with from / to dates")
+	// Search links
+	ErrNoSearchTerms error = errors.New("no search terms provided")
 	// Add link
 	ErrNoURL                 error = errors.New("no URL provided")
 	ErrInvalidURL            error = errors.New("invalid URL provided")
@@ -28,8 +34,17 @@ var (
 	ErrLinkNotCopied         error = errors.New("link not already copied")
 	// Delete link
 	ErrDoesntOwnLink error = errors.New("not your link; cannot delete")
+	// Merge links
+	ErrNoMergeIntoLinkID         error = errors.New("no link ID to merge into provided")
+	ErrCannotMergeLinkIntoItself error = errors.New("cannot merge link into itself")
+	ErrCannotMergeLink           error = errors.New("only admins and the link's submitter can merge it")
+	ErrCannotMergeIntoLink       error = errors.New("can only merge into your own links or links with the same URL")
 )
 
+func ErrInvalidDate(param string, value string) error {
+	return fmt.Errorf("invalid %s date: %q (want ISO date / datetime or e.g. \"90 days ago\")", param, value)
+}
+
 func ErrMaxDailyLinkSubmissionsReached(limit int) error {
 	return fmt.Errorf("you have submitted the max amount of links for today (%d)", limit)
 }
@@ -0,0 +1,15 @@
+package error
+
+import (
+	"errors"
+)
+
+var (
+	ErrCannotMuteSelf         error = errors.New("cannot mute or block yourself")
+	ErrUserAlreadyMuted       error = errors.New("user already muted")
+	ErrUserAlreadyBlocked     error = errors.New("user already blocked")
+	ErrUserNotMuted           error = errors.New("user not muted")
+	ErrUserNotBlocked         error = errors.New("user not blocked")
+	ErrUserBlockedNotMuted    error = errors.New("user is blocked: unblock instead")
+	ErrBlockedByLinkSubmitter error = errors.New("link submitter has blocked you")
+)
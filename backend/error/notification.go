@@ -0,0 +1,15 @@
+package error
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoNotificationWithID              error = errors.New("no notification found with given ID")
+	ErrNoNotificationPreferencesProvided error = errors.New("no notification preferences provided")
+)
+
+func InvalidNotificationType(notification_type string, types []string) error {
+	return fmt.Errorf("invalid notification type %q (accepted: %v)", notification_type, types)
+}
@@ -0,0 +1,14 @@
+package error
+
+import "fmt"
+
+// link query syntax error
+// (Pos is 1-based char offset into query)
+type QueryParseError struct {
+	Pos int
+	Msg string
+}
+
+func (err *QueryParseError) Error() string {
+	return fmt.Sprintf("%s at position %d", err.Msg, err.Pos)
+}
@@ -0,0 +1,16 @@
+package error
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoSettingsProvided      error = errors.New("no settings provided")
+	ErrInvalidSortBy           error = errors.New("invalid sort_by value (accepted: rating, newest)")
+	ErrInvalidTmapSectionOrder error = errors.New("tmap section order must list submitted, tagged and copied once each")
+)
+
+func NumMutedCatsExceedsLimit(limit int) error {
+	return fmt.Errorf("too many muted cats (max %d)", limit)
+}
@@ -0,0 +1,23 @@
+package error
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrBlobNotFound         error = errors.New("blob not found")
+	ErrInvalidBlobKey       error = errors.New("invalid blob key")
+	ErrNoS3Bucket           error = errors.New("no S3 bucket provided (FITM_S3_BUCKET)")
+	ErrNoS3Endpoint         error = errors.New("no S3 endpoint provided (FITM_S3_ENDPOINT)")
+	ErrNoS3Credentials      error = errors.New("no S3 credentials provided (FITM_S3_ACCESS_KEY_ID, FITM_S3_SECRET_ACCESS_KEY)")
+	ErrInvalidPicGCInterval error = errors.New("invalid profile pic GC interval (FITM_PFP_GC_INTERVAL)")
+)
+
+func UnsupportedBlobStore(store string) error {
+	return fmt.Errorf("unsupported blob store %q (accepted: fs, s3)", store)
+}
+
+func S3RequestFailed(method string, key string, status int, body string) error {
+	return fmt.Errorf("S3 %s %s failed with status %d: %s", method, key, status, body)
+}
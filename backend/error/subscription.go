@@ -0,0 +1,16 @@
+package error
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrAlreadySubscribedToCats error = errors.New("already subscribed to cats")
+	ErrNoSubscriptionWithID    error = errors.New("no cat subscription found with given ID")
+	ErrInvalidFeedCursor       error = errors.New("invalid feed cursor provided")
+)
+
+func NumSubscriptionsExceedsLimit(limit int) error {
+	return fmt.Errorf("too many cat subscriptions (max %d)", limit)
+}
@@ -9,10 +9,11 @@ var (
 	// Auth
 	ErrInvalidLogin      error = errors.New("invalid login provided")
 	ErrIncorrectPassword       = errors.New("incorrect password")
-	ErrNoJWTSecretEnv          = errors.New("FITM_JWT_SECRET env var not set")
 	ErrNoLoginName       error = errors.New("no name provided")
 	ErrLoginNameContainsInvalidChars error = errors.New("name contains invalid characters ([a-zA-Z0-9_] allowed)")
 	ErrLoginNameTaken    error = errors.New("login name taken")
+	ErrLoginNameReserved error = errors.New("login name was recently released and is reserved for its previous owner")
+	ErrSameLoginName     error = errors.New("new login name same as current")
 	ErrNoPassword        error = errors.New("no password provided")
 	// Tmap profile
 	ErrAboutHasInvalidChars         error = errors.New("be more descriptive. (not just \\n or \\r)")
@@ -24,6 +25,7 @@ var (
 	ErrCouldNotSaveProfilePic       error = errors.New("could not assign profile pic to user")
 	ErrCouldNotRemoveProfilePic     error = errors.New("could not remove profile pic for user")
 	ErrNoProfilePic                 error = errors.New("no profile pic found for user")
+	ErrProfilePicFormatMismatch     error = errors.New("profile pic contents do not match detected format")
 )
 
 func LoginNameExceedsLowerLimit(limit int) error {
@@ -34,6 +36,10 @@ func LoginNameExceedsUpperLimit(limit int) error {
 	return fmt.Errorf("name too long (max %d chars)", limit)
 }
 
+func LoginNameChangedRecently(next_change_at string) error {
+	return fmt.Errorf("login name changed recently; can change again after %s", next_change_at)
+}
+
 func PasswordExceedsLowerLimit(limit int) error {
 	return fmt.Errorf("password too short (min %d chars)", limit)
 }
@@ -45,3 +51,19 @@ func PasswordExceedsUpperLimit(limit int) error {
 func ProfileAboutLengthExceedsLimit(limit int) error {
 	return fmt.Errorf("about text too long (max %d chars)", limit)
 }
+
+func ProfilePicDimensionsExceedLimit(max_dimension int, max_pixels int) error {
+	return fmt.Errorf("profile pic too large (max %dpx per side, %d pixels total)", max_dimension, max_pixels)
+}
+
+func InvalidProfilePicSize(sizes []int) error {
+	return fmt.Errorf("invalid profile pic size (accepted: %v)", sizes)
+}
+
+func UnsupportedTmapExportFormat(format string, formats []string) error {
+	return fmt.Errorf("unsupported export format %q (accepted: %v)", format, formats)
+}
+
+func InvalidTmapExportFolders(max_folders int) error {
+	return fmt.Errorf("invalid folders provided (accepted: 1-%d)", max_folders)
+}
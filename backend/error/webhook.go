@@ -0,0 +1,21 @@
+package error
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoWebhookFilters      error = errors.New("webhook needs cats and/or followed_users")
+	ErrNoWebhookWithID       error = errors.New("no webhook found with given ID")
+	ErrWebhookHostNotAllowed error = errors.New("webhook URL must resolve to a public address")
+	ErrWebhookAlreadyEnabled error = errors.New("webhook already enabled")
+)
+
+func NumWebhooksExceedsLimit(limit int) error {
+	return fmt.Errorf("too many webhooks (max %d)", limit)
+}
+
+func UnexpectedWebhookResponse(status_code int) error {
+	return fmt.Errorf("unexpected response status %d", status_code)
+}
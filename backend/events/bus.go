@@ -0,0 +1,113 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+// site activity published by handlers after their changes are saved
+var Site = NewBus(mutil.SITE_EVENT_LOG_SIZE, mutil.SITE_EVENT_STREAM_BUFFER)
+
+// Fans events out to subscribers and keeps the latest log_size of them so
+// subscribers can resume after reconnecting.
+// IDs increase by 1 from 1 (reset on restart)
+type Bus struct {
+	mu          sync.Mutex
+	log         []model.SiteEvent
+	log_size    int
+	buffer      int
+	last_id     uint64
+	subscribers map[chan model.SiteEvent]struct{}
+}
+
+func NewBus(log_size int, buffer int) *Bus {
+	return &Bus{
+		log_size:    log_size,
+		buffer:      buffer,
+		subscribers: map[chan model.SiteEvent]struct{}{},
+	}
+}
+
+// Subscribers too far behind to take event are dropped (channel closed)
+func (b *Bus) Publish(event_type string, link_id string, cats string, data interface{}) model.SiteEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.last_id++
+	event := model.SiteEvent{
+		ID:      b.last_id,
+		Type:    event_type,
+		LinkID:  link_id,
+		Cats:    cats,
+		Data:    data,
+		Created: mutil.NEW_LONG_TIMESTAMP(),
+	}
+
+	b.log = append(b.log, event)
+	if len(b.log) > b.log_size {
+		b.log = b.log[len(b.log)-b.log_size:]
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return event
+}
+
+func (b *Bus) Subscribe() (ch <-chan model.SiteEvent, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.subscribe()
+}
+
+// Returns logged events after last_id (all logged events if last_id not in
+// log, e.g., from before restart) and channel of events published after them
+func (b *Bus) SubscribeAfter(last_id uint64) (missed []model.SiteEvent, ch <-chan model.SiteEvent, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch, cancel = b.subscribe()
+	return b.eventsAfter(last_id), ch, cancel
+}
+
+// cancel must be called when done
+// (b.mu held)
+func (b *Bus) subscribe() (<-chan model.SiteEvent, func()) {
+	sub := make(chan model.SiteEvent, b.buffer)
+	b.subscribers[sub] = struct{}{}
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[sub]; ok {
+			delete(b.subscribers, sub)
+			close(sub)
+		}
+	}
+
+	return sub, cancel
+}
+
+// (b.mu held)
+func (b *Bus) eventsAfter(last_id uint64) []model.SiteEvent {
+	if len(b.log) == 0 {
+		return nil
+	}
+
+	oldest_id := b.log[0].ID
+	if last_id < oldest_id || last_id > b.last_id {
+		return append([]model.SiteEvent{}, b.log...)
+	}
+
+	return append([]model.SiteEvent{}, b.log[last_id-oldest_id+1:]...)
+}
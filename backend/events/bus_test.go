@@ -0,0 +1,68 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/julianlk522/fitm/model"
+)
+
+func eventIDs(events []model.SiteEvent) []uint64 {
+	ids := []uint64{}
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestSubscribeResumesFromLog(t *testing.T) {
+	bus := NewBus(3, 10)
+	for i := 0; i < 5; i++ {
+		bus.Publish(model.SITE_EVENT_NEW_LINK, "1", "go", nil)
+	}
+
+	// log holds events 3-5
+	var test_resumes = []struct {
+		LastID  uint64
+		WantIDs []uint64
+	}{
+		{4, []uint64{5}},
+		{5, []uint64{}},
+		// too old
+		{1, []uint64{3, 4, 5}},
+		// from before restart
+		{99, []uint64{3, 4, 5}},
+	}
+
+	for _, tr := range test_resumes {
+		missed, _, cancel := bus.SubscribeAfter(tr.LastID)
+		cancel()
+
+		got := eventIDs(missed)
+		if len(got) != len(tr.WantIDs) {
+			t.Fatalf("last ID %d: got %v, want %v", tr.LastID, got, tr.WantIDs)
+		}
+		for i := range got {
+			if got[i] != tr.WantIDs[i] {
+				t.Fatalf("last ID %d: got %v, want %v", tr.LastID, got, tr.WantIDs)
+			}
+		}
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	bus := NewBus(10, 2)
+	slow, cancel := bus.Subscribe()
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		bus.Publish(model.SITE_EVENT_NEW_LINK, "1", "go", nil)
+	}
+
+	var received int
+	for range slow {
+		received++
+	}
+	if received != 2 {
+		t.Fatalf("got %d events before drop, want 2", received)
+	}
+}
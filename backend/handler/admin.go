@@ -0,0 +1,690 @@
+package handler
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"github.com/julianlk522/fitm/db"
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+	"github.com/julianlk522/fitm/query"
+)
+
+// Links
+func ModeratorDeleteLink(w http.ResponseWriter, r *http.Request) {
+	link_id := chi.URLParam(r, "link_id")
+	if link_id == "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLinkID))
+		return
+	}
+
+	var url, submitted_by string
+	err := db.Client.QueryRow(
+		"SELECT url, submitted_by FROM Links WHERE id = ?;",
+		link_id,
+	).Scan(&url, &submitted_by)
+	if err == sql.ErrNoRows {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLinkWithID))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	tx, err := db.Client.Begin()
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+	defer tx.Rollback()
+
+	if err = util.DeleteLinkWithID(tx, link_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if err = util.LogModeratorAction(
+		tx,
+		req_user_id,
+		mutil.MOD_ACTION_DELETE_LINK,
+		"link",
+		link_id,
+		url+" (submitted by "+submitted_by+")",
+	); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusResetContent)
+}
+
+// replaces cats of the tag added by the link's submitter
+func ModeratorEditLinkCats(w http.ResponseWriter, r *http.Request) {
+	link_id := chi.URLParam(r, "link_id")
+	if link_id == "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLinkID))
+		return
+	}
+
+	request := &model.EditLinkCatsRequest{}
+	if err := render.Bind(r, request); err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	link_exists, err := util.LinkExists(link_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if !link_exists {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLinkWithID))
+		return
+	}
+
+	tag_id, err := util.GetLinkSubmitterTagID(link_id)
+	if err == e.ErrNoTagForLinkSubmitter {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	old_cats, err := util.GetTagCats(tag_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	request.Cats = util.AlphabetizeCats(request.Cats)
+
+	tx, err := db.Client.Begin()
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`UPDATE Tags
+		SET cats = ?, last_updated = ?
+		WHERE id = ?;`,
+		request.Cats,
+		request.LastUpdated,
+		tag_id,
+	)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if err = util.LogModeratorAction(
+		tx,
+		req_user_id,
+		mutil.MOD_ACTION_EDIT_LINK_CATS,
+		"link",
+		link_id,
+		old_cats+" -> "+request.Cats,
+	); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	// after commit: opens its own transaction
+	if err = util.CalculateAndSetGlobalCats(link_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, request)
+}
+
+// Tags
+func ModeratorDeleteTag(w http.ResponseWriter, r *http.Request) {
+	tag_id := chi.URLParam(r, "tag_id")
+	if tag_id == "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoTagID))
+		return
+	}
+
+	tag_exists, err := util.TagExists(tag_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if !tag_exists {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoTagWithID))
+		return
+	}
+
+	// delete the link instead
+	is_only_tag, err := util.IsOnlyTag(tag_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if is_only_tag {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrCantDeleteOnlyTag))
+		return
+	}
+
+	link_id, err := util.GetLinkIDFromTagID(tag_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+	cats, err := util.GetTagCats(tag_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	tx, err := db.Client.Begin()
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM Tags WHERE id = ?;", tag_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if err = util.LogModeratorAction(
+		tx,
+		req_user_id,
+		mutil.MOD_ACTION_DELETE_TAG,
+		"tag",
+		tag_id,
+		"link "+link_id+": "+cats,
+	); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if err = util.CalculateAndSetGlobalCats(link_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Summaries
+func ModeratorDeleteSummary(w http.ResponseWriter, r *http.Request) {
+	summary_id := chi.URLParam(r, "summary_id")
+	if summary_id == "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoSummaryID))
+		return
+	}
+
+	link_id, err := util.GetLinkIDFromSummaryID(summary_id)
+	if err == sql.ErrNoRows {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoSummaryWithID))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	text, err := util.GetSummaryText(summary_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	tx, err := db.Client.Begin()
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM Summaries WHERE id = ?;`, summary_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if err = util.LogModeratorAction(
+		tx,
+		req_user_id,
+		mutil.MOD_ACTION_DELETE_SUMMARY,
+		"summary",
+		summary_id,
+		"link "+link_id+": "+text,
+	); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if err = util.CalculateAndSetGlobalSummary(link_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusResetContent)
+}
+
+// likes are kept since the summary's author didn't change it
+func ModeratorEditSummary(w http.ResponseWriter, r *http.Request) {
+	summary_id := chi.URLParam(r, "summary_id")
+	if summary_id == "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoSummaryID))
+		return
+	}
+
+	request := &model.ModeratorEditSummaryRequest{}
+	if err := render.Bind(r, request); err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	link_id, err := util.GetLinkIDFromSummaryID(summary_id)
+	if err == sql.ErrNoRows {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoSummaryWithID))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	old_text, err := util.GetSummaryText(summary_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	tx, err := db.Client.Begin()
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`UPDATE Summaries SET text = ?, last_updated = ? WHERE id = ?;`,
+		request.Text,
+		request.LastUpdated,
+		summary_id,
+	)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if err = util.LogModeratorAction(
+		tx,
+		req_user_id,
+		mutil.MOD_ACTION_EDIT_SUMMARY,
+		"summary",
+		summary_id,
+		"link "+link_id+": "+old_text,
+	); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if err = util.CalculateAndSetGlobalSummary(link_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, request)
+}
+
+// Users
+func BanUser(w http.ResponseWriter, r *http.Request) {
+	login_name := chi.URLParam(r, "login_name")
+	if login_name == "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLoginName))
+		return
+	}
+
+	request := &model.BanUserRequest{}
+	if err := render.Bind(r, request); err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	user_id, role, err := util.GetUserIDAndRole(login_name)
+	if err == e.ErrNoUserWithLoginName {
+		render.Render(w, r, e.Err404(err))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if util.IsPrivilegedRole(role) {
+		render.Render(w, r, e.ErrUnauthorized(e.ErrCannotBanModerator))
+		return
+	}
+
+	is_banned, err := util.UserIsBanned(login_name)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if is_banned {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrUserAlreadyBanned))
+		return
+	}
+
+	tx, err := db.Client.Begin()
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`UPDATE Users SET banned_at = ?, ban_reason = ? WHERE id = ?;`,
+		request.BannedAt,
+		request.Reason,
+		user_id,
+	)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if err = util.LogModeratorAction(
+		tx,
+		req_user_id,
+		mutil.MOD_ACTION_BAN_USER,
+		"user",
+		user_id,
+		login_name+": "+request.Reason,
+	); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func UnbanUser(w http.ResponseWriter, r *http.Request) {
+	login_name := chi.URLParam(r, "login_name")
+	if login_name == "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLoginName))
+		return
+	}
+
+	user_id, _, err := util.GetUserIDAndRole(login_name)
+	if err == e.ErrNoUserWithLoginName {
+		render.Render(w, r, e.Err404(err))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	is_banned, err := util.UserIsBanned(login_name)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if !is_banned {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrUserNotBanned))
+		return
+	}
+
+	tx, err := db.Client.Begin()
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`UPDATE Users SET banned_at = NULL, ban_reason = NULL WHERE id = ?;`,
+		user_id,
+	)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if err = util.LogModeratorAction(
+		tx,
+		req_user_id,
+		mutil.MOD_ACTION_UNBAN_USER,
+		"user",
+		user_id,
+		login_name,
+	); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// admin only
+func EditUserRole(w http.ResponseWriter, r *http.Request) {
+	login_name := chi.URLParam(r, "login_name")
+	if login_name == "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLoginName))
+		return
+	}
+
+	request := &model.EditRoleRequest{}
+	if err := render.Bind(r, request); err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	req_login_name := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["login_name"].(string)
+	if login_name == req_login_name {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrCannotChangeOwnRole))
+		return
+	}
+
+	user_id, old_role, err := util.GetUserIDAndRole(login_name)
+	if err == e.ErrNoUserWithLoginName {
+		render.Render(w, r, e.Err404(err))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	tx, err := db.Client.Begin()
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`UPDATE Users SET role = ? WHERE id = ?;`, request.Role, user_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if err = util.LogModeratorAction(
+		tx,
+		req_user_id,
+		mutil.MOD_ACTION_EDIT_ROLE,
+		"user",
+		user_id,
+		login_name+": "+old_role+" -> "+request.Role,
+	); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, request)
+}
+
+// Audit log / err log (admin only)
+func GetModeratorActions(w http.ResponseWriter, r *http.Request) {
+	actions_sql := query.NewModeratorActions()
+
+	moderator_params := r.URL.Query().Get("moderator")
+	if moderator_params != "" {
+		actions_sql = actions_sql.FromModerator(moderator_params)
+	}
+
+	page := r.Context().Value(m.PageKey).(int)
+	actions_sql = actions_sql.Page(page)
+
+	if actions_sql.Error != nil {
+		render.Render(w, r, e.ErrInvalidRequest(actions_sql.Error))
+		return
+	}
+
+	actions, err := util.ScanModeratorActions(actions_sql)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, util.PaginateModeratorActions(actions, page))
+}
+
+func GetRecentErrors(w http.ResponseWriter, r *http.Request) {
+	limit := util.ERR_LOG_ENTRIES_DEFAULT_LIMIT
+
+	limit_params := r.URL.Query().Get("limit")
+	if limit_params != "" {
+		var err error
+		limit, err = strconv.Atoi(limit_params)
+		if err != nil || limit < 1 || limit > util.ERR_LOG_ENTRIES_MAX_LIMIT {
+			render.Render(w, r, e.ErrInvalidRequest(e.ErrInvalidLimit))
+			return
+		}
+	}
+
+	entries, err := util.GetRecentErrLogEntries(limit)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, entries)
+}
+
+// Profile pics (admin only)
+// unreferenced by Users.pfp and older than PFP_GC_MIN_AGE
+// (newer may belong to an in-flight upload)
+func GetOrphanedProfilePics(w http.ResponseWriter, r *http.Request) {
+	report, err := util.CollectOrphanedProfilePics(pic_store, false, mutil.PFP_GC_MIN_AGE)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, report)
+}
+
+func RemoveOrphanedProfilePics(w http.ResponseWriter, r *http.Request) {
+	report, err := util.CollectOrphanedProfilePics(pic_store, true, mutil.PFP_GC_MIN_AGE)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, report)
+}
+
+// Runs orphaned profile pic GC every FITM_PFP_GC_INTERVAL (default
+// PFP_GC_DEFAULT_INTERVAL, "0" disables). Orphans are only reported
+// unless FITM_PFP_GC_REMOVE=true.
+func StartProfilePicGC() error {
+	interval := mutil.PFP_GC_DEFAULT_INTERVAL
+	if interval_env := os.Getenv("FITM_PFP_GC_INTERVAL"); interval_env != "" {
+		var err error
+		interval, err = time.ParseDuration(interval_env)
+		if err != nil || interval < 0 {
+			return e.ErrInvalidPicGCInterval
+		}
+	}
+	if interval == 0 {
+		return nil
+	}
+
+	remove := os.Getenv("FITM_PFP_GC_REMOVE") == "true"
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			report, err := util.CollectOrphanedProfilePics(pic_store, remove, mutil.PFP_GC_MIN_AGE)
+			if err != nil {
+				log.Printf("profile pic GC failed: %s", err)
+				continue
+			}
+			log.Printf(
+				"profile pic GC: scanned %d, referenced %d, orphaned %d (%d bytes), removed %d",
+				report.Scanned,
+				report.Referenced,
+				len(report.Orphans),
+				report.OrphanBytes,
+				report.Removed,
+			)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/julianlk522/fitm/db"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+	"github.com/julianlk522/fitm/storage"
+)
+
+const (
+	test_moderator_id         = "mod_test_user"
+	test_moderator_login_name = "mod_test_user"
+)
+
+// routes behind fake moderator claims
+// (otherwise cannot pass URL params without modifying handler implementation)
+func newTestAdminRouter() *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+				"user_id":    test_moderator_id,
+				"login_name": test_moderator_login_name,
+				"role":       "admin",
+			})
+			ctx = context.WithValue(ctx, m.PageKey, 1)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+
+	r.Delete("/admin/links/{link_id}", ModeratorDeleteLink)
+	r.Put("/admin/links/{link_id}/cats", ModeratorEditLinkCats)
+	r.Delete("/admin/tags/{tag_id}", ModeratorDeleteTag)
+	r.Put("/admin/summaries/{summary_id}", ModeratorEditSummary)
+	r.Delete("/admin/summaries/{summary_id}", ModeratorDeleteSummary)
+	r.Post("/admin/users/{login_name}/ban", BanUser)
+	r.Delete("/admin/users/{login_name}/ban", UnbanUser)
+	r.Put("/admin/users/{login_name}/role", EditUserRole)
+	r.Get("/admin/actions", GetModeratorActions)
+	r.Get("/admin/pics/orphans", GetOrphanedProfilePics)
+	r.Delete("/admin/pics/orphans", RemoveOrphanedProfilePics)
+
+	return r
+}
+
+// link submitted by mod_test_submitter with 2 tags and 1 summary
+func insertTestModerationLink(t *testing.T, link_id string) {
+	stmts := []struct {
+		SQL  string
+		Args []interface{}
+	}{
+		{
+			`INSERT OR IGNORE INTO Users (id, login_name, password, created) VALUES (?,?,?,?);`,
+			[]interface{}{"mod_test_submitter", "mod_test_submitter", "x", "2024-01-01"},
+		},
+		{
+			`INSERT OR IGNORE INTO Users (id, login_name, password, created, role) VALUES (?,?,?,?,?);`,
+			[]interface{}{test_moderator_id, test_moderator_login_name, "x", "2024-01-01", "admin"},
+		},
+		{
+			`INSERT INTO Links (id, url, submitted_by, submit_date, global_cats, global_summary, img_url) VALUES (?,?,?,?,?,?,?);`,
+			[]interface{}{link_id, "https://" + link_id + ".example.com", "mod_test_submitter", "2024-01-01 00:00:00", "modtest", "spam", ""},
+		},
+		{
+			`INSERT INTO Tags VALUES (?,?,?,?,?);`,
+			[]interface{}{link_id + "_tag1", link_id, "modtest", "mod_test_submitter", "2024-01-01 00:00:00"},
+		},
+		{
+			`INSERT INTO Tags VALUES (?,?,?,?,?);`,
+			[]interface{}{link_id + "_tag2", link_id, "modtest,other", "jlk", "2024-01-02 00:00:00"},
+		},
+		{
+			`INSERT INTO Summaries VALUES (?,?,?,?,?);`,
+			[]interface{}{link_id + "_summary", "spam", link_id, "mod_test_submitter", "2024-01-01 00:00:00"},
+		},
+	}
+
+	for _, stmt := range stmts {
+		if _, err := db.Client.Exec(stmt.SQL, stmt.Args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// global cats must be ranked in spellfix like any submitted link's
+	if err := util.IncrementSpellfixRanksForCats(nil, []string{"modtest"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestModeratorActions(t *testing.T) {
+	insertTestModerationLink(t, "mod_test_link")
+	r := newTestAdminRouter()
+
+	test_requests := []struct {
+		Method             string
+		Path               string
+		Payload            map[string]string
+		ExpectedStatusCode int
+	}{
+		// invalid cats
+		{http.MethodPut, "/admin/links/mod_test_link/cats", map[string]string{"cats": ""}, 400},
+		{http.MethodPut, "/admin/links/-1/cats", map[string]string{"cats": "fixed"}, 400},
+		{http.MethodPut, "/admin/links/mod_test_link/cats", map[string]string{"cats": "fixed,cats"}, 200},
+		{http.MethodPut, "/admin/summaries/-1", map[string]string{"text": "redacted"}, 400},
+		{http.MethodPut, "/admin/summaries/mod_test_link_summary", map[string]string{"text": ""}, 400},
+		{http.MethodPut, "/admin/summaries/mod_test_link_summary", map[string]string{"text": "redacted"}, 200},
+		{http.MethodDelete, "/admin/tags/mod_test_link_tag2", nil, 204},
+		// only tag left
+		{http.MethodDelete, "/admin/tags/mod_test_link_tag1", nil, 400},
+		{http.MethodDelete, "/admin/summaries/mod_test_link_summary", nil, 205},
+		{http.MethodDelete, "/admin/summaries/mod_test_link_summary", nil, 400},
+		// cannot ban moderators / admins
+		{http.MethodPost, "/admin/users/" + test_moderator_login_name + "/ban", map[string]string{}, 403},
+		{http.MethodPost, "/admin/users/nobody_at_all/ban", map[string]string{}, 404},
+		{http.MethodDelete, "/admin/users/mod_test_submitter/ban", nil, 400},
+		{http.MethodPost, "/admin/users/mod_test_submitter/ban", map[string]string{"reason": "spam"}, 204},
+		{http.MethodPost, "/admin/users/mod_test_submitter/ban", map[string]string{"reason": "spam"}, 400},
+		{http.MethodDelete, "/admin/users/mod_test_submitter/ban", nil, 204},
+		{http.MethodPut, "/admin/users/mod_test_submitter/role", map[string]string{"role": "king"}, 400},
+		{http.MethodPut, "/admin/users/" + test_moderator_login_name + "/role", map[string]string{"role": "user"}, 400},
+		{http.MethodPut, "/admin/users/mod_test_submitter/role", map[string]string{"role": "moderator"}, 200},
+		{http.MethodDelete, "/admin/links/-1", nil, 400},
+		{http.MethodDelete, "/admin/links/mod_test_link", nil, 205},
+	}
+
+	for _, tr := range test_requests {
+		b := new(bytes.Buffer)
+		if tr.Payload != nil {
+			if err := json.NewEncoder(b).Encode(tr.Payload); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		req := httptest.NewRequest(tr.Method, tr.Path, b)
+		req.Header.Set("Content-Type", "application/json")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf(
+				"expected status code %d, got %d (test request %+v)\n%s",
+				tr.ExpectedStatusCode,
+				w.Code,
+				tr,
+				w.Body.String(),
+			)
+		}
+	}
+
+	// every successful action audited
+	var count int
+	if err := db.Client.QueryRow(
+		`SELECT count(*) FROM "Moderator Actions" WHERE moderator_id = ?;`,
+		test_moderator_id,
+	).Scan(&count); err != nil {
+		t.Fatal(err)
+	} else if count != 8 {
+		t.Fatalf("got %d moderator actions, want 8", count)
+	}
+
+	// audit log visible
+	req := httptest.NewRequest(http.MethodGet, "/admin/actions", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 200 {
+		t.Fatalf("expected status code 200, got %d", w.Code)
+	}
+}
+
+func TestOrphanedProfilePics(t *testing.T) {
+	dir := t.TempDir()
+	old_store := pic_store
+	pic_store = storage.NewFSStore(dir)
+	defer func() { pic_store = old_store }()
+
+	orphaned_pfp := util.ProfilePicName(strings.Repeat("ef", 32))
+	old := time.Now().Add(-2 * time.Hour)
+	for _, key := range util.ProfilePicKeys(orphaned_pfp) {
+		if err := pic_store.Put(key, strings.NewReader("x"), "image/jpeg"); err != nil {
+			t.Fatal(err)
+		} else if err = os.Chtimes(dir+"/"+key, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	num_keys := len(util.ProfilePicKeys(orphaned_pfp))
+
+	r := newTestAdminRouter()
+	test_requests := []struct {
+		Method          string
+		ExpectedOrphans int
+		ExpectedRemoved int
+	}{
+		{http.MethodGet, num_keys, 0},
+		{http.MethodDelete, num_keys, num_keys},
+		{http.MethodGet, 0, 0},
+	}
+
+	for _, tr := range test_requests {
+		req := httptest.NewRequest(tr.Method, "/admin/pics/orphans", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != 200 {
+			t.Fatalf("%s: got status %d, want 200 (%s)", tr.Method, w.Code, w.Body.String())
+		}
+
+		var report model.ProfilePicGCReport
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		} else if len(report.Orphans) != tr.ExpectedOrphans || report.Removed != tr.ExpectedRemoved {
+			t.Fatalf(
+				"%s: got %d orphans / %d removed, want %d / %d",
+				tr.Method,
+				len(report.Orphans),
+				report.Removed,
+				tr.ExpectedOrphans,
+				tr.ExpectedRemoved,
+			)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/handler/util"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+	"github.com/julianlk522/fitm/storage"
+)
+
+var archive_store storage.BlobStore
+
+// nudges worker when a link is submitted
+var archive_jobs = make(chan string, 100)
+
+func init() {
+	work_dir, _ := os.Getwd()
+	archive_store = storage.NewFSStore(filepath.Join(work_dir, "db/archives"))
+}
+
+// Lists link's snapshots, or with ?timestamp= (from list, or "latest")
+// replays one: archived page, or archived asset given ?url=
+func GetLinkArchive(w http.ResponseWriter, r *http.Request) {
+	link_id := chi.URLParam(r, "link_id")
+	if link_id == "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLinkID))
+		return
+	}
+
+	link_exists, err := util.LinkExists(link_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if !link_exists {
+		if redirected, err := redirectMergedLink(w, r, "/links/", link_id, "/archive"); err != nil {
+			render.Render(w, r, e.Err500(err))
+		} else if !redirected {
+			render.Render(w, r, e.Err404(e.ErrNoLinkWithID))
+		}
+		return
+	}
+
+	timestamp := r.URL.Query().Get("timestamp")
+	if timestamp == "" {
+		snapshots, err := util.GetLinkSnapshots(link_id)
+		if err != nil {
+			render.Render(w, r, e.Err500(err))
+			return
+		}
+		render.JSON(w, r, snapshots)
+		return
+	}
+
+	snapshot, err := util.GetReadyLinkSnapshot(link_id, timestamp)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if snapshot == nil {
+		render.Render(w, r, e.Err404(e.ErrNoSnapshotWithTimestamp))
+		return
+	}
+
+	target_url := r.URL.Query().Get("url")
+	if target_url == "" {
+		target_url = snapshot.URL
+	}
+	record, err := util.GetLinkSnapshotRecord(snapshot.ID, target_url)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if record == nil {
+		render.Render(w, r, e.Err404(e.ErrURLNotInSnapshot))
+		return
+	}
+
+	body, err := util.ReadLinkSnapshotRecord(archive_store, snapshot, record)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if media_type, _, _ := mime.ParseMediaType(record.ContentType); media_type == "text/html" {
+		archived_urls, err := util.GetLinkSnapshotRecordURLs(snapshot.ID)
+		if err != nil {
+			render.Render(w, r, e.Err500(err))
+			return
+		}
+		if page_url, err := url.Parse(record.URL); err == nil {
+			body = util.RewriteSnapshotHTML(body, page_url, archived_urls, snapshot.Timestamp)
+		}
+	}
+
+	// archived pages can't run scripts or use FITM origin
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Type", record.ContentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if captured, err := time.Parse(model.LINK_SNAPSHOT_TIMESTAMP_LAYOUT, snapshot.Timestamp); err == nil {
+		w.Header().Set("Memento-Datetime", captured.Format(http.TimeFormat))
+	}
+	w.Write(body)
+}
+
+// (queue full: sweep will pick it up)
+func queueLinkSnapshot(link_id string) {
+	select {
+	case archive_jobs <- link_id:
+	default:
+	}
+}
+
+// Archives submitted links in the background and re-archives due ones
+func StartLinkArchiving() error {
+	go func() {
+		ticker := time.NewTicker(mutil.ARCHIVE_SWEEP_INTERVAL)
+		defer ticker.Stop()
+
+		sweepLinkSnapshots()
+
+		for {
+			select {
+			case link_id := <-archive_jobs:
+				if _, err := util.CaptureLinkSnapshot(archive_store, link_id); err != nil {
+					log.Printf("could not archive link %s: %s", link_id, err)
+				}
+			case <-ticker.C:
+				sweepLinkSnapshots()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func sweepLinkSnapshots() {
+	if _, err := util.RunDueLinkSnapshots(archive_store, mutil.ARCHIVE_BATCH_SIZE); err != nil {
+		log.Printf("could not archive due links: %s", err)
+	}
+	if err := util.RemoveOrphanedLinkSnapshots(archive_store); err != nil {
+		log.Printf("could not remove orphaned link snapshots: %s", err)
+	}
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/julianlk522/fitm/db"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+	"github.com/julianlk522/fitm/storage"
+)
+
+func TestGetLinkArchive(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><link rel="stylesheet" href="/style.css"><script>alert(1)</script></html>`))
+	})
+	mux.HandleFunc("/style.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte("p {}"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	default_client := util.ArchiveClient
+	util.ArchiveClient = server.Client()
+	defer func() { util.ArchiveClient = default_client }()
+
+	default_store := archive_store
+	archive_store = storage.NewFSStore(t.TempDir())
+	defer func() { archive_store = default_store }()
+
+	_, err := db.Client.Exec(
+		`INSERT INTO Links (id, url, submitted_by, submit_date, global_cats, global_summary, img_url) VALUES (?,?,?,?,?,?,?);`,
+		"archive_test", server.URL+"/page", test_login_name, "2024-01-01 00:00:00", "archive", "", "",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Client.Exec(`DELETE FROM Links WHERE id = 'archive_test';`)
+	defer db.Client.Exec(`DELETE FROM "Link Snapshots" WHERE link_id = 'archive_test';`)
+
+	snapshot, err := util.CaptureLinkSnapshot(archive_store, "archive_test")
+	if err != nil {
+		t.Fatal(err)
+	} else if snapshot.Status != model.LINK_SNAPSHOT_READY {
+		t.Fatalf("got status %s (%s)", snapshot.Status, snapshot.Error)
+	}
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+				"user_id":    "",
+				"login_name": "",
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Get("/links/{link_id}/archive", GetLinkArchive)
+
+	// list
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/links/archive_test/archive", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code 200, got %d", w.Code)
+	}
+	var snapshots []model.LinkSnapshot
+	if err = json.NewDecoder(w.Body).Decode(&snapshots); err != nil {
+		t.Fatal(err)
+	} else if len(snapshots) != 1 || snapshots[0].Timestamp != snapshot.Timestamp {
+		t.Fatalf("got snapshots %+v", snapshots)
+	}
+
+	style_params := "&url=" + url.QueryEscape(server.URL+"/style.css")
+	var test_requests = []struct {
+		Query              string
+		ExpectedStatusCode int
+		ContentType        string
+		BodyContains       string
+	}{
+		{"?timestamp=" + snapshot.Timestamp, 200, "text/html", "?timestamp=" + snapshot.Timestamp + "&amp;url="},
+		{"?timestamp=latest", 200, "text/html", "<script>alert(1)</script>"},
+		{"?timestamp=latest" + style_params, 200, "text/css", "p {}"},
+		{"?timestamp=20000101000000", 404, "", ""},
+		{"?timestamp=latest&url=" + url.QueryEscape(server.URL+"/other"), 404, "", ""},
+	}
+
+	for _, tr := range test_requests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/links/archive_test/archive"+tr.Query, nil))
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf("%s: expected status code %d, got %d", tr.Query, tr.ExpectedStatusCode, w.Code)
+		} else if w.Code != http.StatusOK {
+			continue
+		}
+
+		body, _ := io.ReadAll(w.Body)
+		if w.Header().Get("Content-Type") != tr.ContentType {
+			t.Fatalf("%s: got content type %s", tr.Query, w.Header().Get("Content-Type"))
+		} else if w.Header().Get("Content-Security-Policy") != "sandbox" {
+			t.Fatalf("%s: replay not sandboxed", tr.Query)
+		} else if !strings.Contains(string(body), tr.BodyContains) {
+			t.Fatalf("%s: body missing %q:\n%s", tr.Query, tr.BodyContains, body)
+		}
+	}
+
+	// unknown link
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/links/-1/archive", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected status code 404 for unknown link, got %d", w.Code)
+	}
+}
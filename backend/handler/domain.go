@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+	"github.com/julianlk522/fitm/query"
+)
+
+// Domains with most links (or ?sort_by=likes)
+// (cats, period or from / to, nsfw filters)
+func GetTopDomains(w http.ResponseWriter, r *http.Request) {
+	domains_sql := query.NewTopDomains()
+
+	cats_params := r.URL.Query().Get("cats")
+	if cats_params != "" {
+		domains_sql = domains_sql.FromCats(strings.Split(cats_params, ","))
+	}
+
+	// period or date range
+	from_params, to_params, err := util.GetDateRangeParams(r)
+	if err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+	period_params := r.URL.Query().Get("period")
+	if from_params != "" || to_params != "" {
+		domains_sql = domains_sql.DuringDateRange(from_params, to_params)
+	} else if period_params != "" && period_params != "all" {
+		domains_sql = domains_sql.DuringPeriod(period_params)
+	}
+
+	sort_params := r.URL.Query().Get("sort_by")
+	if sort_params != "" {
+		domains_sql = domains_sql.SortBy(sort_params)
+	}
+
+	nsfw_params := r.URL.Query().Get("nsfw")
+	if nsfw_params == "true" {
+		domains_sql = domains_sql.NSFW()
+	} else if nsfw_params != "false" && nsfw_params != "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrInvalidNSFWParams))
+		return
+	}
+
+	if domains_sql.Error != nil {
+		render.Render(w, r, e.ErrInvalidRequest(domains_sql.Error))
+		return
+	}
+
+	domains, err := util.ScanTopDomains(domains_sql)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.JSON(w, r, domains)
+}
+
+// Domain's top cats and links
+// (period or from / to, sort_by, nsfw and pagination as GetLinks)
+func GetDomain(w http.ResponseWriter, r *http.Request) {
+	domain, err := util.GetDomainFromParams(chi.URLParam(r, "domain"))
+	if err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	if has_links, err := util.DomainHasLinks(domain); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if !has_links {
+		render.Render(w, r, e.Err404(e.ErrNoLinksWithDomain))
+		return
+	}
+
+	links_sql := query.NewTopLinks().FromDomain(domain)
+	cats_sql := query.NewTopGlobalCatCounts().FromDomain(domain)
+
+	// saved settings apply when params omitted
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	settings := model.NewDefaultUserSettings()
+	if req_user_id != "" {
+		settings, err = util.GetUserSettings(req_user_id)
+		if err != nil {
+			render.Render(w, r, e.Err500(err))
+			return
+		}
+	}
+
+	// period or date range
+	// ("all" or date range overrides saved period)
+	from_params, to_params, err := util.GetDateRangeParams(r)
+	if err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+	period_params := r.URL.Query().Get("period")
+	if from_params != "" || to_params != "" {
+		links_sql = links_sql.DuringDateRange(from_params, to_params)
+		cats_sql = cats_sql.DuringDateRange(from_params, to_params)
+	} else {
+		if period_params == "" {
+			period_params = settings.Period
+		}
+		if period_params != "" && period_params != "all" {
+			links_sql = links_sql.DuringPeriod(period_params)
+			cats_sql = cats_sql.DuringPeriod(period_params)
+		}
+	}
+
+	// sort by
+	sort_params := r.URL.Query().Get("sort_by")
+	if sort_params == "" {
+		sort_params = settings.SortBy
+	}
+	if sort_params != "" {
+		links_sql = links_sql.SortBy(sort_params)
+	}
+
+	// auth fields
+	if req_user_id != "" {
+		links_sql = links_sql.AsSignedInUser(req_user_id)
+	}
+
+	// nsfw
+	var nsfw_params string
+	if r.URL.Query().Get("nsfw") != "" {
+		nsfw_params = r.URL.Query().Get("nsfw")
+	} else if r.URL.Query().Get("NSFW") != "" {
+		nsfw_params = r.URL.Query().Get("NSFW")
+	} else if settings.NSFW {
+		nsfw_params = "true"
+	}
+
+	if nsfw_params == "true" {
+		links_sql = links_sql.NSFW()
+	} else if nsfw_params != "false" && nsfw_params != "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrInvalidNSFWParams))
+		return
+	}
+
+	// pagination
+	page := r.Context().Value(m.PageKey).(int)
+	links_sql = links_sql.Page(page)
+
+	if links_sql.Error != nil {
+		render.Render(w, r, e.ErrInvalidRequest(links_sql.Error))
+		return
+	}
+
+	// scan
+	top_cats, err := util.ScanGlobalCatCounts(cats_sql)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	domain_page := &model.DomainPage{
+		Domain:  domain,
+		TopCats: top_cats,
+	}
+	if req_user_id != "" {
+		links, err := util.ScanLinks[model.LinkSignedIn](links_sql)
+		if err != nil {
+			render.Render(w, r, e.Err500(err))
+			return
+		}
+		domain_page.Links = util.PaginateLinks(links, page)
+	} else {
+		links, err := util.ScanLinks[model.Link](links_sql)
+		if err != nil {
+			render.Render(w, r, e.Err500(err))
+			return
+		}
+		domain_page.Links = util.PaginateLinks(links, page)
+	}
+
+	render.JSON(w, r, domain_page)
+}
+
+// Sets domains of links added before they were stored
+func BackfillLinkDomains() error {
+	num_set, err := util.BackfillLinkDomains()
+	if err != nil {
+		return err
+	}
+
+	if num_set > 0 {
+		log.Printf("set domains of %d links", num_set)
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/julianlk522/fitm/db"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+)
+
+func TestGetTopDomains(t *testing.T) {
+	if _, err := util.BackfillLinkDomains(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Client.Exec(`UPDATE Links SET domain = NULL;`)
+
+	var test_requests = []struct {
+		Query              string
+		ExpectedStatusCode int
+		WantFirst          string
+	}{
+		{"", 200, "github.com"},
+		{"?cats=umvc3", 200, "example.com"},
+		{"?nsfw=true", 200, "example.com"},
+		{"?nsfw=true&sort_by=likes", 200, "github.com"},
+		{"?period=all", 200, "github.com"},
+		{"?to=2024-01-31", 200, "example.com"},
+		{"?from=2024-02&to=2024-02", 200, "github.com"},
+		{"?period=poop", 400, ""},
+		{"?from=2024-02&period=day", 400, ""},
+		{"?from=poop", 400, ""},
+		{"?sort_by=poop", 400, ""},
+		{"?nsfw=maybe", 400, ""},
+	}
+
+	for _, tr := range test_requests {
+		w := httptest.NewRecorder()
+		GetTopDomains(w, httptest.NewRequest(http.MethodGet, "/domains"+tr.Query, nil))
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf("%s: expected status code %d, got %d", tr.Query, tr.ExpectedStatusCode, w.Code)
+		} else if w.Code != http.StatusOK {
+			continue
+		}
+
+		var domains []model.DomainCount
+		if err := json.NewDecoder(w.Body).Decode(&domains); err != nil {
+			t.Fatal(err)
+		} else if len(domains) == 0 || domains[0].Domain != tr.WantFirst {
+			t.Fatalf("%s: got domains %+v, want %s first", tr.Query, domains, tr.WantFirst)
+		}
+	}
+}
+
+func TestGetDomain(t *testing.T) {
+	if _, err := util.BackfillLinkDomains(); err != nil {
+		t.Fatal(err)
+	}
+	defer db.Client.Exec(`UPDATE Links SET domain = NULL;`)
+
+	var test_requests = []struct {
+		Domain             string
+		Query              string
+		UserID             string
+		ExpectedStatusCode int
+		WantIDs            []string
+		WantTopCat         string
+	}{
+		{"github.com", "", "", 200, []string{"2"}, "go"},
+		{"docs.github.com", "", test_user_id, 200, []string{"2"}, "go"},
+		{"example.com", "", "", 200, []string{"1"}, ""},
+		{"example.com", "?nsfw=true&sort_by=newest", "", 200, []string{"3", "1"}, ""},
+		{"example.com", "?period=day", "", 200, []string{}, ""},
+		{"example.com", "?nsfw=true&from=2024-02&to=2024-03", "", 200, []string{"3"}, ""},
+		{"example.com", "?period=poop", "", 400, nil, ""},
+		{"example.com", "?from=2024-02&period=day", "", 400, nil, ""},
+		{"nowhere.example", "", "", 404, nil, ""},
+		{"bad%25domain", "", "", 400, nil, ""},
+	}
+
+	for _, tr := range test_requests {
+		r := chi.NewRouter()
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+					"user_id":    tr.UserID,
+					"login_name": "",
+				})
+				ctx = context.WithValue(ctx, m.PageKey, 1)
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+		})
+		r.Get("/domains/{domain}", GetDomain)
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/domains/"+tr.Domain+tr.Query, nil))
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf("%s%s: expected status code %d, got %d", tr.Domain, tr.Query, tr.ExpectedStatusCode, w.Code)
+		} else if w.Code != http.StatusOK {
+			continue
+		}
+
+		var res struct {
+			Domain  string
+			TopCats []model.CatCount
+			Links   model.PaginatedLinks[model.Link]
+		}
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []string
+		if res.Links.Links != nil {
+			for _, l := range *res.Links.Links {
+				ids = append(ids, l.ID)
+			}
+		}
+		if len(ids) != len(tr.WantIDs) {
+			t.Fatalf("%s%s: got links %v, want %v", tr.Domain, tr.Query, ids, tr.WantIDs)
+		}
+		for i := range ids {
+			if ids[i] != tr.WantIDs[i] {
+				t.Fatalf("%s%s: got links %v, want %v", tr.Domain, tr.Query, ids, tr.WantIDs)
+			}
+		}
+
+		if tr.WantTopCat != "" && (len(res.TopCats) == 0 || res.TopCats[0].Category != tr.WantTopCat) {
+			t.Fatalf("%s: got top cats %+v, want %s first", tr.Domain, res.TopCats, tr.WantTopCat)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+
+	e "github.com/julianlk522/fitm/error"
+	"github.com/julianlk522/fitm/events"
+	util "github.com/julianlk522/fitm/handler/util"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+// Server-sent events: new links, global cats changes and like count changes.
+// ?cats=go,rust: only events for links with all of cats
+// ?nsfw=true: include NSFW links
+// Last-Event-ID header (or ?last_event_id=) resumes after given event
+// if still logged
+func StreamSiteEvents(w http.ResponseWriter, r *http.Request) {
+	var cats []string
+	if cats_params := r.URL.Query().Get("cats"); cats_params != "" {
+		for _, cat := range strings.Split(cats_params, ",") {
+			cats = append(cats, strings.ToLower(strings.TrimSpace(cat)))
+		}
+	}
+
+	nsfw_params := r.URL.Query().Get("nsfw")
+	if nsfw_params == "" {
+		nsfw_params = r.URL.Query().Get("NSFW")
+	}
+	if nsfw_params != "true" && nsfw_params != "false" && nsfw_params != "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrInvalidNSFWParams))
+		return
+	}
+	nsfw := nsfw_params == "true" || slices.Contains(cats, "nsfw")
+
+	last_event_id_params := r.Header.Get("Last-Event-ID")
+	if last_event_id_params == "" {
+		last_event_id_params = r.URL.Query().Get("last_event_id")
+	}
+	var missed []model.SiteEvent
+	var stream <-chan model.SiteEvent
+	var cancel func()
+	if last_event_id_params != "" {
+		last_event_id, err := strconv.ParseUint(last_event_id_params, 10, 64)
+		if err != nil {
+			render.Render(w, r, e.ErrInvalidRequest(e.ErrInvalidLastEventID))
+			return
+		}
+		missed, stream, cancel = events.Site.SubscribeAfter(last_event_id)
+	} else {
+		stream, cancel = events.Site.Subscribe()
+	}
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range missed {
+		if siteEventMatches(event, cats, nsfw) {
+			writeSiteEvent(w, event)
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		log.Printf("could not flush site events: %s", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(mutil.SITE_EVENT_HEARTBEAT_INTERVAL)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case event, ok := <-stream:
+			// dropped for falling behind: client resumes from log
+			if !ok {
+				return
+			} else if !siteEventMatches(event, cats, nsfw) {
+				continue
+			}
+			writeSiteEvent(w, event)
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func siteEventMatches(event model.SiteEvent, cats []string, nsfw bool) bool {
+	event_cats := strings.Split(strings.ToLower(event.Cats), ",")
+	if !nsfw && slices.Contains(event_cats, "nsfw") {
+		return false
+	}
+
+	for _, cat := range cats {
+		if !slices.Contains(event_cats, cat) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func writeSiteEvent(w http.ResponseWriter, event model.SiteEvent) {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		log.Printf("could not encode site event %d: %s", event.ID, err)
+		return
+	}
+
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
+
+// Recalculates link's global cats, publishing cats_changed if they changed
+func setGlobalCatsAndPublish(link_id string) error {
+	previous_cats, err := util.GetLinkGlobalCats(link_id)
+	if err != nil {
+		return err
+	} else if err = util.CalculateAndSetGlobalCats(link_id); err != nil {
+		return err
+	}
+
+	cats, err := util.GetLinkGlobalCats(link_id)
+	if err != nil {
+		return err
+	} else if cats != previous_cats {
+		events.Site.Publish(model.SITE_EVENT_CATS_CHANGED, link_id, cats, model.LinkCatsChange{
+			LinkID:       link_id,
+			PreviousCats: previous_cats,
+			Cats:         cats,
+		})
+	}
+
+	return nil
+}
+
+// (failure logged: like already saved)
+func publishLikeCountChange(link_id string) {
+	cats, err := util.GetLinkGlobalCats(link_id)
+	if err != nil {
+		log.Printf("could not publish like count change for link %s: %s", link_id, err)
+		return
+	}
+
+	like_count, err := util.GetLinkLikeCount(link_id)
+	if err != nil {
+		log.Printf("could not publish like count change for link %s: %s", link_id, err)
+		return
+	}
+
+	events.Site.Publish(model.SITE_EVENT_LIKE_COUNT_CHANGED, link_id, cats, model.LinkLikeCountChange{
+		LinkID:    link_id,
+		LikeCount: like_count,
+	})
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julianlk522/fitm/events"
+	"github.com/julianlk522/fitm/model"
+)
+
+func TestStreamSiteEvents(t *testing.T) {
+	first := events.Site.Publish(model.SITE_EVENT_NEW_LINK, "event_test_1", "go,programming", model.Link{ID: "event_test_1"})
+	events.Site.Publish(model.SITE_EVENT_NEW_LINK, "event_test_2", "cooking", model.Link{ID: "event_test_2"})
+	events.Site.Publish(model.SITE_EVENT_LIKE_COUNT_CHANGED, "event_test_3", "go,NSFW", model.LinkLikeCountChange{LinkID: "event_test_3"})
+	last := events.Site.Publish(model.SITE_EVENT_CATS_CHANGED, "event_test_4", "Go", model.LinkCatsChange{LinkID: "event_test_4"})
+
+	last_event_id := strconv.FormatUint(first.ID-1, 10)
+	test_streams := []struct {
+		Params             string
+		LastEventID        string
+		ExpectedStatusCode int
+		WantLinkIDs        []string
+	}{
+		{"", "x", 400, nil},
+		{"?nsfw=maybe", "", 400, nil},
+		{"?cats=go", last_event_id, 200, []string{"event_test_1", "event_test_4"}},
+		{"?cats=go&nsfw=true", last_event_id, 200, []string{"event_test_1", "event_test_3", "event_test_4"}},
+		{"", strconv.FormatUint(last.ID-1, 10), 200, []string{"event_test_4"}},
+	}
+
+	for _, ts := range test_streams {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		req := httptest.NewRequest(http.MethodGet, "/events"+ts.Params, nil).WithContext(ctx)
+		if ts.LastEventID != "" {
+			req.Header.Set("Last-Event-ID", ts.LastEventID)
+		}
+		w := httptest.NewRecorder()
+		StreamSiteEvents(w, req)
+		cancel()
+
+		if w.Code != ts.ExpectedStatusCode {
+			t.Fatalf(
+				"expected status code %d, got %d (params %s)\n%s",
+				ts.ExpectedStatusCode,
+				w.Code,
+				ts.Params,
+				w.Body.String(),
+			)
+		} else if w.Code != http.StatusOK {
+			continue
+		}
+
+		if content_type := w.Header().Get("Content-Type"); content_type != "text/event-stream" {
+			t.Fatalf("got content type %s, want text/event-stream", content_type)
+		}
+
+		body := w.Body.String()
+		for _, id := range []string{"event_test_1", "event_test_2", "event_test_3", "event_test_4"} {
+			want := false
+			for _, want_id := range ts.WantLinkIDs {
+				want = want || want_id == id
+			}
+			if strings.Contains(body, `"`+id+`"`) != want {
+				t.Fatalf("params %s: got body\n%s\nwant link IDs %v", ts.Params, body, ts.WantLinkIDs)
+			}
+		}
+	}
+}
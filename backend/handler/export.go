@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+	"github.com/julianlk522/fitm/storage"
+)
+
+var export_store storage.BlobStore
+
+// nudges worker when a new export is requested
+var export_jobs = make(chan string, 100)
+
+func init() {
+	work_dir, _ := os.Getwd()
+	export_store = storage.NewFSStore(filepath.Join(work_dir, "db/exports"))
+}
+
+func RequestDataExport(w http.ResponseWriter, r *http.Request) {
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+
+	if is_active, err := util.UserHasActiveDataExport(req_user_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if is_active {
+		render.Render(w, r, e.Err409(e.ErrDataExportInProgress))
+		return
+	}
+
+	export := model.NewDataExport()
+	if err := util.SaveNewDataExport(req_user_id, export); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	// worker sweep picks it up if queue is full
+	select {
+	case export_jobs <- export.ID:
+	default:
+	}
+
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, export)
+}
+
+func GetDataExport(w http.ResponseWriter, r *http.Request) {
+	export_id := chi.URLParam(r, "export_id")
+
+	export, user_id, _, err := util.GetDataExport(export_id)
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+
+	// not revealing others' exports
+	if err == e.ErrNoDataExportWithID || (err == nil && user_id != req_user_id) {
+		render.Render(w, r, e.Err404(e.ErrNoDataExportWithID))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.JSON(w, r, export)
+}
+
+// token from DownloadURL authenticates (no bearer token needed)
+func DownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	export_id := chi.URLParam(r, "export_id")
+
+	export, _, token, err := util.GetDataExport(export_id)
+	if err == e.ErrNoDataExportWithID {
+		render.Render(w, r, e.Err404(err))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	req_token := r.URL.Query().Get("token")
+	if token == "" || subtle.ConstantTimeCompare([]byte(req_token), []byte(token)) != 1 {
+		render.Render(w, r, e.ErrUnauthorized(e.ErrInvalidExportToken))
+		return
+	}
+
+	switch {
+	case export.Status == model.DATA_EXPORT_EXPIRED ||
+		export.Expires <= mutil.NEW_LONG_TIMESTAMP():
+		render.Render(w, r, e.Err410(e.ErrDataExportExpired))
+		return
+	case export.Status != model.DATA_EXPORT_READY:
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrDataExportNotReady))
+		return
+	}
+
+	zip, err := export_store.Get(util.DataExportKey(export_id))
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+	defer zip.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="fitm-export-`+export_id+`.zip"`)
+	w.Header().Set("Cache-Control", "private, no-store")
+	io.Copy(w, zip)
+}
+
+// Runs requested exports in the background and removes expired ones
+func StartDataExports() error {
+	if err := util.RequeueInterruptedDataExports(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(mutil.DATA_EXPORT_SWEEP_INTERVAL)
+		defer ticker.Stop()
+
+		// catch up on exports pending before start
+		sweepDataExports()
+
+		for {
+			select {
+			case export_id := <-export_jobs:
+				if err := util.RunDataExport(export_store, export_id); err != nil {
+					log.Printf("data export %s failed: %s", export_id, err)
+				}
+			case <-ticker.C:
+				sweepDataExports()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func sweepDataExports() {
+	pending_ids, err := util.GetPendingDataExportIDs()
+	if err != nil {
+		log.Printf("could not get pending data exports: %s", err)
+	}
+	for _, export_id := range pending_ids {
+		if err = util.RunDataExport(export_store, export_id); err != nil {
+			log.Printf("data export %s failed: %s", export_id, err)
+		}
+	}
+
+	if err = util.ExpireDataExports(export_store); err != nil {
+		log.Printf("could not expire data exports: %s", err)
+	}
+}
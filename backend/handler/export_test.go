@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+	"github.com/julianlk522/fitm/storage"
+)
+
+func TestDataExport(t *testing.T) {
+	old_store := export_store
+	export_store = storage.NewFSStore(t.TempDir())
+	defer func() { export_store = old_store }()
+
+	r := chi.NewRouter()
+	r.Get("/exports/{export_id}/download", DownloadDataExport)
+	r.Group(func(r chi.Router) {
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+					"user_id":    test_user_id,
+					"login_name": test_login_name,
+				})
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+		})
+		r.Post("/exports", RequestDataExport)
+		r.Get("/exports/{export_id}", GetDataExport)
+	})
+
+	serve := func(method string, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/exports")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("got status %d, want 202\n%s", w.Code, w.Body.String())
+	}
+	var export model.DataExport
+	if err := json.NewDecoder(w.Body).Decode(&export); err != nil {
+		t.Fatal(err)
+	}
+
+	// one at a time
+	if w = serve(http.MethodPost, "/exports"); w.Code != http.StatusConflict {
+		t.Fatalf("got status %d, want 409", w.Code)
+	}
+
+	// not ready yet
+	if w = serve(http.MethodGet, "/exports/"+export.ID+"/download?token="); w.Code != http.StatusForbidden {
+		t.Fatalf("got status %d, want 403", w.Code)
+	}
+
+	// (worker not running in tests)
+	<-export_jobs
+	if err := util.RunDataExport(export_store, export.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	w = serve(http.MethodGet, "/exports/"+export.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", w.Code)
+	}
+	if err := json.NewDecoder(w.Body).Decode(&export); err != nil {
+		t.Fatal(err)
+	} else if export.Status != model.DATA_EXPORT_READY || export.DownloadURL == "" {
+		t.Fatalf("got %+v, want ready with download URL", export)
+	}
+
+	if w = serve(http.MethodGet, "/exports/"+export.ID+"/download?token=wrong"); w.Code != http.StatusForbidden {
+		t.Fatalf("got status %d, want 403", w.Code)
+	}
+	w = serve(http.MethodGet, export.DownloadURL)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200\n%s", w.Code, w.Body.String())
+	} else if w.Header().Get("Content-Type") != "application/zip" || w.Body.Len() == 0 {
+		t.Fatalf("got Content-Type %s and %d bytes, want zip", w.Header().Get("Content-Type"), w.Body.Len())
+	}
+
+	if w = serve(http.MethodGet, "/exports/nonexistent"); w.Code != http.StatusNotFound {
+		t.Fatalf("got status %d, want 404", w.Code)
+	}
+}
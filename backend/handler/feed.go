@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/handler/util"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+// ?format=atom (default) | rss | json, optional cats, nsfw
+func GetLinksFeed(w http.ResponseWriter, r *http.Request) {
+	format, nsfw, err := getFeedParams(r)
+	if err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	var cats []string
+	if cats_params := r.URL.Query().Get("cats"); cats_params != "" {
+		cats = strings.Split(cats_params, ",")
+	}
+
+	feed, err := util.GetLinksFeed(cats, nsfw)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	serveFeed(w, r, format, feed)
+}
+
+// submitted / tagged links, most recent activity first
+func GetTmapFeed(w http.ResponseWriter, r *http.Request) {
+	format, nsfw, err := getFeedParams(r)
+	if err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	login_name := chi.URLParam(r, "login_name")
+	if user_exists, err := util.UserExists(login_name); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if !user_exists {
+		render.Render(w, r, e.Err404(e.ErrNoUserWithLoginName))
+		return
+	}
+
+	feed, err := util.GetTmapFeed(login_name, nsfw)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	serveFeed(w, r, format, feed)
+}
+
+func getFeedParams(r *http.Request) (format string, nsfw bool, err error) {
+	format = r.URL.Query().Get("format")
+	if format == "" {
+		format = util.FEED_FORMAT_ATOM
+	} else if _, err = util.FeedContentType(format); err != nil {
+		return "", false, err
+	}
+
+	switch r.URL.Query().Get("nsfw") {
+	case "true":
+		nsfw = true
+	case "false", "":
+	default:
+		return "", false, e.ErrInvalidNSFWParams
+	}
+
+	return format, nsfw, nil
+}
+
+// ETag / Last-Modified for conditional GET (handled by http.ServeContent)
+func serveFeed(w http.ResponseWriter, r *http.Request, format string, feed *model.Feed) {
+	scheme := "https"
+	if r.TLS == nil && r.Header.Get("X-Forwarded-Proto") != "https" {
+		scheme = "http"
+	}
+	feed.SelfURL = scheme + "://" + r.Host + r.URL.RequestURI()
+
+	var buf bytes.Buffer
+	if err := util.WriteFeed(&buf, format, feed); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	content_type, _ := util.FeedContentType(format)
+	hash := sha256.Sum256(buf.Bytes())
+
+	w.Header().Set("Content-Type", content_type)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(hash[:16])+`"`)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(mutil.FEED_CACHE_MAX_AGE.Seconds())))
+	http.ServeContent(w, r, "", feed.Updated, bytes.NewReader(buf.Bytes()))
+}
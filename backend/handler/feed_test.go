@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestFeeds(t *testing.T) {
+	r := chi.NewRouter()
+	r.Get("/feeds/links", GetLinksFeed)
+	r.Get("/feeds/map/{login_name}", GetTmapFeed)
+
+	var test_requests = []struct {
+		URL                string
+		ExpectedStatusCode int
+	}{
+		{"/feeds/links", 200},
+		{"/feeds/links?format=rss&cats=umvc3", 200},
+		{"/feeds/links?format=json&nsfw=true", 200},
+		{"/feeds/links?format=xml", 400},
+		{"/feeds/links?nsfw=maybe", 400},
+		{"/feeds/map/" + test_login_name, 200},
+		{"/feeds/map/nobody_by_this_name", 404},
+	}
+
+	for _, tr := range test_requests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tr.URL, nil))
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf("%s: expected status code %d, got %d\n%s", tr.URL, tr.ExpectedStatusCode, w.Code, w.Body.String())
+		}
+	}
+
+	// conditional GET
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/feeds/links", nil))
+	etag, last_modified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if etag == "" || last_modified == "" {
+		t.Fatalf("expected ETag and Last-Modified, got %v", w.Header())
+	}
+
+	for _, header := range [][2]string{{"If-None-Match", etag}, {"If-Modified-Since", last_modified}} {
+		req := httptest.NewRequest(http.MethodGet, "/feeds/links", nil)
+		req.Header.Set(header[0], header[1])
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusNotModified {
+			t.Fatalf("%s: expected status code 304, got %d", header[0], w.Code)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/query"
+)
+
+func FollowUser(w http.ResponseWriter, r *http.Request) {
+	followed_id, ok := getFollowTargetID(w, r)
+	if !ok {
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if is_blocked, err := util.UserHasBlockedUser(followed_id, req_user_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if is_blocked {
+		render.Render(w, r, e.ErrUnauthorized(e.ErrCannotFollowBlocker))
+		return
+	}
+
+	if follows, err := util.UserFollows(req_user_id, followed_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if follows {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrUserAlreadyFollowed))
+		return
+	}
+
+	if err := util.FollowUser(req_user_id, followed_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	followed_id, ok := getFollowTargetID(w, r)
+	if !ok {
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if follows, err := util.UserFollows(req_user_id, followed_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if !follows {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrUserNotFollowed))
+		return
+	}
+
+	if err := util.UnfollowUser(req_user_id, followed_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// renders error and returns false if target invalid
+func getFollowTargetID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	login_name := chi.URLParam(r, "login_name")
+	if login_name == "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLoginName))
+		return "", false
+	}
+
+	followed_id, _, err := util.GetUserIDAndRole(login_name)
+	if err == e.ErrNoUserWithLoginName {
+		render.Render(w, r, e.Err404(err))
+		return "", false
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return "", false
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if followed_id == req_user_id {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrCannotFollowSelf))
+		return "", false
+	}
+
+	return followed_id, true
+}
+
+// links submitted, copied or tagged by followed users, newest activity first
+func GetFollowingFeed(w http.ResponseWriter, r *http.Request) {
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	feed_sql := query.NewFollowingFeed(req_user_id)
+
+	// nsfw
+	// (saved setting applies when param omitted)
+	settings, err := util.GetUserSettings(req_user_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	var nsfw_params string
+	if r.URL.Query().Get("nsfw") != "" {
+		nsfw_params = r.URL.Query().Get("nsfw")
+	} else if r.URL.Query().Get("NSFW") != "" {
+		nsfw_params = r.URL.Query().Get("NSFW")
+	} else if settings.NSFW {
+		nsfw_params = "true"
+	}
+
+	if nsfw_params == "true" {
+		feed_sql = feed_sql.NSFW()
+	} else if nsfw_params != "false" && nsfw_params != "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrInvalidNSFWParams))
+		return
+	}
+
+	// pagination
+	page := r.Context().Value(m.PageKey).(int)
+	feed_sql = feed_sql.Page(page)
+
+	links, err := util.ScanFollowingFeed(feed_sql)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.JSON(w, r, util.PaginateFollowingFeed(links, page))
+}
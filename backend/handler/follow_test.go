@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/julianlk522/fitm/db"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+	"github.com/julianlk522/fitm/query"
+)
+
+func TestFollowAndFollowingFeed(t *testing.T) {
+	if _, err := db.Client.Exec(
+		`INSERT INTO Users (id, login_name, password, created) VALUES (?,?,?,?);`,
+		"follow_test_id",
+		"follow_test",
+		"x",
+		"2024-01-01",
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+				"user_id":    "follow_test_id",
+				"login_name": "follow_test",
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Post("/follows/{login_name}", FollowUser)
+	r.Delete("/follows/{login_name}", UnfollowUser)
+	r.With(m.Pagination).Get("/feed", GetFollowingFeed)
+
+	test_requests := []struct {
+		Method             string
+		Path               string
+		ExpectedStatusCode int
+	}{
+		{http.MethodPost, "/follows/follow_test", 400},
+		{http.MethodPost, "/follows/nobody_at_all", 404},
+		{http.MethodDelete, "/follows/test_req_login_name", 400},
+		{http.MethodPost, "/follows/test_req_login_name", 204},
+		{http.MethodPost, "/follows/test_req_login_name", 400},
+		{http.MethodGet, "/feed?nsfw=maybe", 400},
+	}
+
+	for _, tr := range test_requests {
+		req := httptest.NewRequest(tr.Method, tr.Path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf(
+				"expected status code %d, got %d (test request %+v)\n%s",
+				tr.ExpectedStatusCode,
+				w.Code,
+				tr,
+				w.Body.String(),
+			)
+		}
+	}
+
+	profile, err := util.ScanTmapProfile(query.NewTmapProfile("test_req_login_name"))
+	if err != nil {
+		t.Fatal(err)
+	} else if profile.FollowerCount != 1 {
+		t.Fatalf("got follower count %d, want 1", profile.FollowerCount)
+	}
+
+	// test_req_login_name submitted links 2 and 3 (NSFW, newer)
+	var test_feeds = []struct {
+		Path        string
+		WantLinkIDs []string
+	}{
+		{"/feed", []string{"2"}},
+		{"/feed?nsfw=true", []string{"3", "2"}},
+	}
+	for _, tf := range test_feeds {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tf.Path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want 200", tf.Path, w.Code)
+		}
+
+		var feed model.PaginatedFollowingFeed
+		if err := json.NewDecoder(w.Body).Decode(&feed); err != nil {
+			t.Fatal(err)
+		} else if len(*feed.Links) != len(tf.WantLinkIDs) {
+			t.Fatalf("%s: got %+v, want links %v", tf.Path, *feed.Links, tf.WantLinkIDs)
+		}
+		for i, l := range *feed.Links {
+			if l.ID != tf.WantLinkIDs[i] ||
+				l.ActivityBy != "test_req_login_name" ||
+				l.Activity != model.FOLLOWING_ACTIVITY_SUBMITTED {
+				t.Fatalf("%s: got %+v, want link %s", tf.Path, l, tf.WantLinkIDs[i])
+			}
+		}
+	}
+
+	// blocking removes follow and prevents following again
+	if err := util.MuteUser("13", "follow_test_id", true); err != nil {
+		t.Fatal(err)
+	}
+	defer util.UnmuteUser("13", "follow_test_id")
+
+	for _, tr := range []struct {
+		Method             string
+		ExpectedStatusCode int
+	}{
+		{http.MethodDelete, 400},
+		{http.MethodPost, 403},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tr.Method, "/follows/test_req_login_name", nil))
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf("%s after block: expected status code %d, got %d", tr.Method, tr.ExpectedStatusCode, w.Code)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/handler/util"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+// Latest status and recent checks
+func GetLinkHealth(w http.ResponseWriter, r *http.Request) {
+	link_id := chi.URLParam(r, "link_id")
+	if link_id == "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLinkID))
+		return
+	}
+
+	link_exists, err := util.LinkExists(link_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if !link_exists {
+		render.Render(w, r, e.Err404(e.ErrNoLinkWithID))
+		return
+	}
+
+	health, err := util.GetLinkHealth(link_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.JSON(w, r, health)
+}
+
+// Rechecks due links in the background
+func StartLinkHealthChecks() error {
+	go func() {
+		ticker := time.NewTicker(mutil.LINK_HEALTH_SWEEP_INTERVAL)
+		defer ticker.Stop()
+
+		for {
+			num_checked, err := util.RunLinkHealthChecks(mutil.LINK_HEALTH_BATCH_SIZE)
+			if err != nil {
+				log.Printf("link health checks failed: %s", err)
+			}
+			if num_checked > 0 {
+				log.Printf("link health: checked %d links", num_checked)
+			}
+
+			<-ticker.C
+		}
+	}()
+
+	return nil
+}
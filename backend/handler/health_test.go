@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/julianlk522/fitm/db"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+)
+
+func TestGetLinkHealth(t *testing.T) {
+	_, err := db.Client.Exec(
+		`INSERT INTO "Link Health Checks" VALUES ('health_check_1', '1', 'not_found', 404, '2024-01-01 00:00:00');`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Client.Exec(
+		`INSERT INTO "Link Health" VALUES ('1', 'not_found', 404, 1, '2024-01-01 00:00:00', '2024-01-01 01:00:00');`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Client.Exec(`DELETE FROM "Link Health" WHERE link_id = '1';`)
+	defer db.Client.Exec(`DELETE FROM "Link Health Checks" WHERE link_id = '1';`)
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+				"user_id":    "",
+				"login_name": "",
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Get("/links/{link_id}/health", GetLinkHealth)
+
+	var test_links = []struct {
+		LinkID             string
+		ExpectedStatusCode int
+		Status             string
+		NumChecks          int
+	}{
+		{"1", 200, model.LINK_HEALTH_NOT_FOUND, 1},
+		// never checked
+		{"2", 200, "", 0},
+		{"-1", 404, "", 0},
+	}
+
+	for _, tl := range test_links {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/links/"+tl.LinkID+"/health", nil))
+		if w.Code != tl.ExpectedStatusCode {
+			t.Fatalf("link %s: expected status code %d, got %d", tl.LinkID, tl.ExpectedStatusCode, w.Code)
+		} else if w.Code != http.StatusOK {
+			continue
+		}
+
+		var health model.LinkHealth
+		if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+			t.Fatal(err)
+		} else if health.Status != tl.Status || len(health.Checks) != tl.NumChecks {
+			t.Fatalf("link %s: got %+v", tl.LinkID, health)
+		}
+	}
+}
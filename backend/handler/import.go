@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+// nudges worker when a new import is requested
+var import_jobs = make(chan string, 100)
+
+// multipart form: "bookmarks" file, optional "format" (default netscape)
+// and "dry_run" (previews outcome without importing)
+func ImportLinks(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, mutil.IMPORT_MAX_UPLOAD_BYTES)
+	r.ParseMultipartForm(mutil.IMPORT_MAX_UPLOAD_BYTES)
+	file, _, err := r.FormFile("bookmarks")
+	if err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoImportFile))
+		return
+	}
+	defer file.Close()
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = util.IMPORT_FORMAT_NETSCAPE
+	}
+
+	bookmarks, err := util.ParseBookmarks(format, file)
+	if err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	req_login_name := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["login_name"].(string)
+
+	if r.FormValue("dry_run") == "true" {
+		preview, err := util.PreviewLinkImport(format, bookmarks, req_user_id, req_login_name)
+		if err != nil {
+			render.Render(w, r, e.Err500(err))
+			return
+		}
+
+		render.JSON(w, r, preview)
+		return
+	}
+
+	if is_active, err := util.UserHasActiveLinkImport(req_user_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if is_active {
+		render.Render(w, r, e.Err409(e.ErrImportInProgress))
+		return
+	}
+
+	link_import := model.NewLinkImport(format)
+	if err = util.SaveNewLinkImport(req_user_id, link_import, bookmarks); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	// worker sweep picks it up if queue is full
+	select {
+	case import_jobs <- link_import.ID:
+	default:
+	}
+
+	render.Status(r, http.StatusAccepted)
+	render.JSON(w, r, link_import)
+}
+
+// per-URL results included
+func GetLinkImport(w http.ResponseWriter, r *http.Request) {
+	import_id := chi.URLParam(r, "import_id")
+
+	link_import, user_id, err := util.GetLinkImport(import_id, true)
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+
+	// not revealing others' imports
+	if err == e.ErrNoLinkImportWithID || (err == nil && user_id != req_user_id) {
+		render.Render(w, r, e.Err404(e.ErrNoLinkImportWithID))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.JSON(w, r, link_import)
+}
+
+// Runs requested imports in the background and resumes paused ones
+func StartLinkImports() error {
+	if err := util.RequeueInterruptedLinkImports(); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(mutil.IMPORT_SWEEP_INTERVAL)
+		defer ticker.Stop()
+
+		// catch up on imports pending before start
+		sweepLinkImports()
+
+		for {
+			select {
+			case import_id := <-import_jobs:
+				if err := util.RunLinkImport(import_id); err != nil {
+					log.Printf("link import %s failed: %s", import_id, err)
+				}
+			case <-ticker.C:
+				sweepLinkImports()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func sweepLinkImports() {
+	import_ids, err := util.GetResumableLinkImportIDs()
+	if err != nil {
+		log.Printf("could not get resumable link imports: %s", err)
+	}
+	for _, import_id := range import_ids {
+		if err = util.RunLinkImport(import_id); err != nil {
+			log.Printf("link import %s failed: %s", import_id, err)
+		}
+	}
+}
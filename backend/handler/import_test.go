@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+)
+
+func TestImportLinks(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+				"user_id":    test_user_id,
+				"login_name": test_login_name,
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Post("/imports", ImportLinks)
+	r.Get("/imports/{import_id}", GetLinkImport)
+
+	const bookmarks = `<DL><p>
+		<DT><H3>Go</H3>
+		<DL><p>
+			<DT><A HREF="https://github.com/golang/go">Go repo</A>
+		</DL><p>
+	</DL><p>`
+
+	const pins = `[{"href":"https://github.com/golang/go","description":"Go repo","extended":"","tags":"go","toread":"no"}]`
+
+	test_requests := []struct {
+		Format             string
+		File               string
+		DryRun             bool
+		ExpectedStatusCode int
+	}{
+		{"", "", false, 400},
+		{"chrome", bookmarks, false, 400},
+		{"", "<DL><p><DT><A HREF=\"ftp://example.com\">FTP</A></DL>", false, 400},
+		{"pinboard", "not json", false, 400},
+		{"", bookmarks, false, 202},
+		// one at a time
+		{"", bookmarks, false, 409},
+		// dry run allowed during import
+		{"pinboard", pins, true, 200},
+	}
+
+	var link_import model.LinkImport
+	var preview model.LinkImportPreview
+	for _, tr := range test_requests {
+		body := new(bytes.Buffer)
+		mw := multipart.NewWriter(body)
+		if tr.Format != "" {
+			mw.WriteField("format", tr.Format)
+		}
+		if tr.DryRun {
+			mw.WriteField("dry_run", "true")
+		}
+		if tr.File != "" {
+			fw, err := mw.CreateFormFile("bookmarks", "bookmarks.html")
+			if err != nil {
+				t.Fatal(err)
+			}
+			fw.Write([]byte(tr.File))
+		}
+		mw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/imports", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf(
+				"expected status code %d, got %d (test request %+v)\n%s",
+				tr.ExpectedStatusCode,
+				w.Code,
+				tr,
+				w.Body.String(),
+			)
+		}
+		switch w.Code {
+		case http.StatusAccepted:
+			if err := json.NewDecoder(w.Body).Decode(&link_import); err != nil {
+				t.Fatal(err)
+			}
+		case http.StatusOK:
+			if err := json.NewDecoder(w.Body).Decode(&preview); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if len(preview.Items) != 1 || preview.Counts[preview.Items[0].Status] != 1 {
+		t.Fatalf("got preview %+v, want 1 item counted", preview)
+	}
+
+	// (worker not running in tests)
+	<-import_jobs
+
+	req := httptest.NewRequest(http.MethodGet, "/imports/"+link_import.ID, nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", w.Code)
+	}
+
+	var got model.LinkImport
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	} else if len(got.Items) != 1 || got.Items[0].Cats != "Go" || got.Items[0].Status != model.IMPORT_ITEM_PENDING {
+		t.Fatalf("got %+v, want 1 pending item with cats Go", got)
+	}
+}
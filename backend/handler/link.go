@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"database/sql"
 	"log"
 	"net/http"
 
@@ -12,36 +13,89 @@ import (
 
 	"github.com/julianlk522/fitm/db"
 	e "github.com/julianlk522/fitm/error"
+	"github.com/julianlk522/fitm/events"
 	util "github.com/julianlk522/fitm/handler/util"
 	m "github.com/julianlk522/fitm/middleware"
 	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
 	"github.com/julianlk522/fitm/query"
 )
 
 func GetLinks(w http.ResponseWriter, r *http.Request) {
 	links_sql := query.NewTopLinks()
 
+	// saved settings apply when params omitted
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	settings := model.NewDefaultUserSettings()
+	if req_user_id != "" {
+		var err error
+		settings, err = util.GetUserSettings(req_user_id)
+		if err != nil {
+			render.Render(w, r, e.Err500(err))
+			return
+		}
+	}
+
 	// cats
+	var cats []string
 	cats_params := r.URL.Query().Get("cats")
 	if cats_params != "" {
-		cats := strings.Split(cats_params, ",")
+		cats = strings.Split(cats_params, ",")
+	}
+	// (before FromCats escapes reserved chars)
+	muted_cats := util.GetUnrequestedMutedCats(settings, cats)
+
+	if cats_params != "" {
 		links_sql = links_sql.FromCats(cats)
 	}
 
-	// period
+	// muted cats
+	links_sql = links_sql.WithoutCats(muted_cats)
+
+	// link query, e.g., (go OR rust) -beginner domain:github.com
+	if q_params := r.URL.Query().Get("q"); q_params != "" {
+		links_sql = links_sql.MatchingQuery(q_params)
+	}
+
+	// domain
+	if domain_params := r.URL.Query().Get("domain"); domain_params != "" {
+		domain, err := util.GetDomainFromParams(domain_params)
+		if err != nil {
+			render.Render(w, r, e.ErrInvalidRequest(err))
+			return
+		}
+		links_sql = links_sql.FromDomain(domain)
+	}
+
+	// period or date range
+	// ("all" or date range overrides saved period)
+	from_params, to_params, err := util.GetDateRangeParams(r)
+	if err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
 	period_params := r.URL.Query().Get("period")
-	if period_params != "" {
-		links_sql = links_sql.DuringPeriod(period_params)
+	if from_params != "" || to_params != "" {
+		links_sql = links_sql.DuringDateRange(from_params, to_params)
+	} else {
+		if period_params == "" {
+			period_params = settings.Period
+		}
+		if period_params != "" && period_params != "all" {
+			links_sql = links_sql.DuringPeriod(period_params)
+		}
 	}
 
 	// sort by
 	sort_params := r.URL.Query().Get("sort_by")
+	if sort_params == "" {
+		sort_params = settings.SortBy
+	}
 	if sort_params != "" {
 		links_sql = links_sql.SortBy(sort_params)
 	}
 
 	// auth fields
-	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
 	if req_user_id != "" {
 		links_sql = links_sql.AsSignedInUser(req_user_id)
 	}
@@ -52,6 +106,8 @@ func GetLinks(w http.ResponseWriter, r *http.Request) {
 		nsfw_params = r.URL.Query().Get("nsfw")
 	} else if r.URL.Query().Get("NSFW") != "" {
 		nsfw_params = r.URL.Query().Get("NSFW")
+	} else if settings.NSFW {
+		nsfw_params = "true"
 	}
 
 	if nsfw_params == "true" {
@@ -61,6 +117,15 @@ func GetLinks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// dead links
+	hide_dead_params := r.URL.Query().Get("hide_dead")
+	if hide_dead_params == "true" {
+		links_sql = links_sql.WithoutDeadLinks()
+	} else if hide_dead_params != "false" && hide_dead_params != "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrInvalidHideDeadParams))
+		return
+	}
+
 	// pagination
 	page := r.Context().Value(m.PageKey).(int)
 	links_sql = links_sql.Page(page)
@@ -104,28 +169,14 @@ func AddLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if util.IsYouTubeVideoLink(request.NewLink.URL) {
-		if err := util.ObtainYouTubeMetaData(request); err != nil {
-
-			// if unable to get YT metadata, try treating as normal URL
-			// (in case of, e.g., example.com/youtube.com/watch?v=1234
-			// though this should not happen per util.TestIsYouTubeVideoLink cases)
-			if err = util.ObtainURLMetaData(request); err != nil {
-				render.Render(w, r, e.ErrInvalidRequest(err))
-				return
-			}
-		}
-
-	} else {
-		if err := util.ObtainURLMetaData(request); err != nil {
-			render.Render(w, r, e.ErrInvalidRequest(err))
-			return
-		}
+	if err := util.ObtainLinkMetaData(request); err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
 	}
 
 	// verify URL is unique
 	// this comes after ResolveURL() because may mutate slightly
-	if is_duplicate, dupe_link_id := util.LinkAlreadyAdded(request.URL); is_duplicate {
+	if is_duplicate, dupe_link_id := util.ResolvedLinkAlreadyAdded(request); is_duplicate {
 		render.Status(r, http.StatusConflict)
 		render.Render(w, r, e.ErrInvalidRequest(e.ErrDuplicateLink(request.URL, dupe_link_id)))
 		return
@@ -133,108 +184,8 @@ func AddLink(w http.ResponseWriter, r *http.Request) {
 
 	// Verified: add link
 	request.SubmittedBy = req_login_name
-
-	// sort cats
-	request.Cats = util.AlphabetizeCats(request.NewLink.Cats)
-
-	// Start Transaction
-	tx, err := db.Client.Begin()
-	if err != nil {
-		render.Render(w, r, e.Err500(err))
-		return
-	}
-	defer tx.Rollback()
-
-	// insert summary(ies)
-	// (might have user-submitted, auto, or both)
-	// auto summary
-	if request.AutoSummary != "" {
-		_, err := tx.Exec(
-			"INSERT INTO Summaries VALUES(?,?,?,?,?);",
-			uuid.New().String(),
-			request.AutoSummary,
-			request.ID,
-			db.AUTO_SUMMARY_USER_ID,
-			request.SubmitDate,
-		)
-		if err != nil {
-			// continue... no auto summary
-			// but log err
-			log.Print("Error adding auto summary: ", err)
-		} else {
-			request.SummaryCount = 1
-		}
-	}
-
-	// user summary
-	if request.NewLink.Summary != "" {
-		req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
-		_, err := tx.Exec(
-			"INSERT INTO Summaries VALUES(?,?,?,?,?);",
-			uuid.New().String(),
-			request.NewLink.Summary,
-			request.ID,
-			req_user_id,
-			request.SubmitDate,
-		)
-		if err != nil {
-			render.Render(w, r, e.Err500(err))
-			return
-		} else {
-			request.SummaryCount += 1
-		}
-	}
-
-	// insert tag
-	_, err = tx.Exec(
-		"INSERT INTO Tags VALUES(?,?,?,?,?);",
-		uuid.New().String(),
-		request.ID,
-		request.Cats,
-		request.SubmittedBy,
-		request.SubmitDate,
-	)
-	if err != nil {
-		render.Render(w, r, e.Err500(err))
-		return
-	}
-
-	if request.NewLink.Summary != "" {
-		request.Summary = request.NewLink.Summary
-	} else if request.AutoSummary != "" {
-		request.Summary = request.AutoSummary
-	} else {
-		request.Summary = ""
-	}
-
-	// insert link
-	_, err = tx.Exec(
-		"INSERT INTO Links VALUES(?,?,?,?,?,?,?);",
-		request.ID,
-		request.URL,
-		request.SubmittedBy,
-		request.SubmitDate,
-		request.Cats,
-		request.Summary,
-		request.ImgURL,
-	)
-	if err != nil {
-		render.Render(w, r, e.Err500(err))
-		return
-	}
-
-	// increment spellfix ranks
-	err = util.IncrementSpellfixRanksForCats(
-		tx,
-		strings.Split(request.Cats, ","),
-	)
-	if err != nil {
-		render.Render(w, r, e.Err500(err))
-		return
-	}
-
-	// Commit
-	if err = tx.Commit(); err != nil {
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if err := util.SaveNewLink(request, req_user_id); err != nil {
 		render.Render(w, r, e.Err500(err))
 		return
 	}
@@ -250,6 +201,9 @@ func AddLink(w http.ResponseWriter, r *http.Request) {
 		SummaryCount: request.SummaryCount,
 		ImgURL:       request.ImgURL,
 	}
+	queueNewLinkWebhooks(&new_link)
+	queueLinkSnapshot(new_link.ID)
+	events.Site.Publish(model.SITE_EVENT_NEW_LINK, new_link.ID, new_link.Cats, new_link)
 
 	render.Status(r, http.StatusCreated)
 	render.JSON(w, r, new_link)
@@ -277,50 +231,173 @@ func DeleteLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// fetch global cats before deleting
-	// (to properly update spellfix ranks)
-	var gc string
-	err = db.Client.QueryRow("SELECT global_cats FROM Links WHERE id = ?;", request.LinkID).Scan(&gc)
+	// start transaction
+	tx, err := db.Client.Begin()
 	if err != nil {
 		render.Render(w, r, e.Err500(err))
 		return
 	}
+	defer tx.Rollback()
 
-	// start transaction
-	tx, err := db.Client.Begin()
+	// delete and update spellfix
+	if err = util.DeleteLinkWithID(tx, request.LinkID); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	// commit
+	if err = tx.Commit(); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusResetContent)
+}
+
+// Folds link into another (e.g., a duplicate under a different URL):
+// its tags, summaries, likes and copies move to the other link and its
+// tag and summary pages redirect there.
+// Admins or link's submitter only
+// {"into_link_id": "1234"}
+func MergeLink(w http.ResponseWriter, r *http.Request) {
+	link_id := chi.URLParam(r, "link_id")
+	if link_id == "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLinkID))
+		return
+	}
+
+	request := &model.MergeLinkRequest{}
+	if err := render.Bind(r, request); err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	} else if request.IntoLinkID == link_id {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrCannotMergeLinkIntoItself))
+		return
+	}
+
+	var url, submitted_by string
+	err := db.Client.QueryRow(
+		"SELECT url, submitted_by FROM Links WHERE id = ?;",
+		link_id,
+	).Scan(&url, &submitted_by)
+	if err == sql.ErrNoRows {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLinkWithID))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	req_login_name := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["login_name"].(string)
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	req_role, err := util.GetUserRole(req_user_id)
 	if err != nil {
 		render.Render(w, r, e.Err500(err))
 		return
 	}
-	defer tx.Rollback()
+	is_submitter := req_login_name == submitted_by
+	if !is_submitter && req_role != mutil.ROLE_ADMIN {
+		render.Render(w, r, e.ErrUnauthorized(e.ErrCannotMergeLink))
+		return
+	}
 
-	// delete
-	_, err = tx.Exec(
-		"DELETE FROM Links WHERE id = ?;",
-		request.LinkID,
-	)
+	var into_url, into_submitted_by string
+	err = db.Client.QueryRow(
+		"SELECT url, submitted_by FROM Links WHERE id = ?;",
+		request.IntoLinkID,
+	).Scan(&into_url, &into_submitted_by)
+	if err == sql.ErrNoRows {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLinkWithID))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	// non-admins can only merge into their own links or duplicates
+	// (otherwise could inflate others' links' likes, copies, etc.)
+	if req_role != mutil.ROLE_ADMIN && into_submitted_by != req_login_name {
+		canonical_url, err := util.CanonicalizeURL(url)
+		if err != nil {
+			render.Render(w, r, e.ErrUnauthorized(e.ErrCannotMergeIntoLink))
+			return
+		}
+		into_canonical_url, err := util.CanonicalizeURL(into_url)
+		if err != nil || into_canonical_url != canonical_url {
+			render.Render(w, r, e.ErrUnauthorized(e.ErrCannotMergeIntoLink))
+			return
+		}
+	}
+
+	tx, err := db.Client.Begin()
 	if err != nil {
 		render.Render(w, r, e.Err500(err))
 		return
 	}
+	defer tx.Rollback()
 
-	// update spellfix
-	err = util.DecrementSpellfixRanksForCats(
+	if err = util.MergeLinks(
 		tx,
-		strings.Split(gc, ","),
-	)
-	if err != nil {
+		link_id,
+		request.IntoLinkID,
+		req_user_id,
+		request.MergedAt,
+	); err != nil {
 		render.Render(w, r, e.Err500(err))
 		return
 	}
 
-	// commit
+	// admin merging someone else's link
+	if !is_submitter {
+		if err = util.LogModeratorAction(
+			tx,
+			req_user_id,
+			mutil.MOD_ACTION_MERGE_LINK,
+			"link",
+			link_id,
+			url+" (submitted by "+submitted_by+") -> "+request.IntoLinkID,
+		); err != nil {
+			render.Render(w, r, e.Err500(err))
+			return
+		}
+	}
+
 	if err = tx.Commit(); err != nil {
 		render.Render(w, r, e.Err500(err))
 		return
 	}
 
-	w.WriteHeader(http.StatusResetContent)
+	// after commit: open their own transactions
+	if err = setGlobalCatsAndPublish(request.IntoLinkID); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+	if err = util.CalculateAndSetGlobalSummary(request.IntoLinkID); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+	publishLikeCountChange(request.IntoLinkID)
+
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, request)
+}
+
+// Redirects requests for merged link's page (path_prefix + link_id +
+// path_suffix) to the link it was merged into; returns false if link
+// wasn't merged
+func redirectMergedLink(w http.ResponseWriter, r *http.Request, path_prefix string, link_id string, path_suffix string) (bool, error) {
+	to_link_id, err := util.GetLinkRedirect(link_id)
+	if err != nil || to_link_id == "" {
+		return false, err
+	}
+
+	location := path_prefix + to_link_id + path_suffix
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, location, http.StatusMovedPermanently)
+
+	return true, nil
 }
 
 func LikeLink(w http.ResponseWriter, r *http.Request) {
@@ -342,8 +419,17 @@ func LikeLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	is_blocked, err := util.LinkSubmitterHasBlockedUser(link_id, req_user_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if is_blocked {
+		render.Render(w, r, e.ErrUnauthorized(e.ErrBlockedByLinkSubmitter))
+		return
+	}
+
 	new_like_id := uuid.New().String()
-	_, err := db.Client.Exec(
+	_, err = db.Client.Exec(
 		`INSERT INTO "Link Likes" VALUES(?,?,?);`,
 		new_like_id,
 		link_id,
@@ -351,7 +437,13 @@ func LikeLink(w http.ResponseWriter, r *http.Request) {
 	)
 	if err != nil {
 		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if err = util.NotifyLinkSubmitter(link_id, model.NOTIFICATION_LINK_LIKED, req_user_id); err != nil {
+		log.Printf("could not notify link submitter of like on %s: %s", link_id, err)
 	}
+	publishLikeCountChange(link_id)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -378,6 +470,8 @@ func UnlikeLink(w http.ResponseWriter, r *http.Request) {
 		log.Fatal(err)
 	}
 
+	publishLikeCountChange(link_id)
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -402,18 +496,32 @@ func CopyLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	is_blocked, err := util.LinkSubmitterHasBlockedUser(link_id, req_user_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if is_blocked {
+		render.Render(w, r, e.ErrUnauthorized(e.ErrBlockedByLinkSubmitter))
+		return
+	}
+
 	new_copy_id := uuid.New().String()
 
-	_, err := db.Client.Exec(
-		`INSERT INTO "Link Copies" VALUES(?,?,?);`,
+	_, err = db.Client.Exec(
+		`INSERT INTO "Link Copies" (id, link_id, user_id, created) VALUES(?,?,?,?);`,
 		new_copy_id,
 		link_id,
 		req_user_id,
+		mutil.NEW_LONG_TIMESTAMP(),
 	)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if err = util.NotifyLinkSubmitter(link_id, model.NOTIFICATION_LINK_COPIED, req_user_id); err != nil {
+		log.Printf("could not notify link submitter of copy of %s: %s", link_id, err)
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -443,3 +551,22 @@ func UncopyLink(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Sets canonical URLs of links added before they were stored.
+// Links left without one duplicate another link's canonical URL
+func BackfillCanonicalURLs() error {
+	num_set, num_skipped, err := util.BackfillCanonicalURLs()
+	if err != nil {
+		return err
+	}
+
+	if num_set > 0 || num_skipped > 0 {
+		log.Printf(
+			"set canonical URLs of %d links (%d skipped)",
+			num_set,
+			num_skipped,
+		)
+	}
+
+	return nil
+}
@@ -7,9 +7,13 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 
 	"testing"
 
+	"github.com/go-chi/chi/v5"
+
+	"github.com/julianlk522/fitm/db"
 	m "github.com/julianlk522/fitm/middleware"
 )
 
@@ -111,6 +115,78 @@ func TestGetLinks(t *testing.T) {
 			Page:   1,
 			Valid:  true,
 		},
+		// hide_dead params like nsfw
+		{
+			Params: map[string]string{"hide_dead": "true"},
+			Page:   1,
+			Valid:  true,
+		},
+		{
+			Params: map[string]string{"hide_dead": "false"},
+			Page:   1,
+			Valid:  true,
+		},
+		{
+			Params: map[string]string{"hide_dead": "invalid"},
+			Page:   1,
+			Valid:  false,
+		},
+		// link query
+		{
+			Params: map[string]string{
+				"q":    "(go OR umvc3) -flowers domain:github.com likes:>0",
+				"cats": "programming",
+			},
+			Page:  1,
+			Valid: true,
+		},
+		{
+			Params: map[string]string{"q": "(go OR umvc3"},
+			Page:   1,
+			Valid:  false,
+		},
+		{
+			Params: map[string]string{"q": "poster:jlk"},
+			Page:   1,
+			Valid:  false,
+		},
+		// domain
+		{
+			Params: map[string]string{"domain": "github.com"},
+			Page:   1,
+			Valid:  true,
+		},
+		{
+			Params: map[string]string{"domain": "github.com/golang"},
+			Page:   1,
+			Valid:  false,
+		},
+		// date range
+		{
+			Params: map[string]string{"from": "2024-02", "to": "2024-02"},
+			Page:   1,
+			Valid:  true,
+		},
+		{
+			Params: map[string]string{"from": "last 90 days", "sort_by": "newest"},
+			Page:   1,
+			Valid:  true,
+		},
+		{
+			Params: map[string]string{"from": "2024-02", "period": "week"},
+			Page:   1,
+			Valid:  false,
+		},
+		{
+			Params: map[string]string{"to": "last 90 days"},
+			Page:   1,
+			Valid:  false,
+		},
+		{
+			Params: map[string]string{"from": "2024-03", "to": "2024-02"},
+			Page:   1,
+			Valid:  false,
+		},
 	}
 
 	for _, tglr := range test_get_links_requests {
@@ -379,3 +455,185 @@ func TestDeleteLink(t *testing.T) {
 		}
 	}
 }
+
+func TestMergeLink(t *testing.T) {
+	insertTestModerationLink(t, "merge_into")
+	insertTestModerationLink(t, "merge_from")
+
+	// unique to merged link
+	if _, err := db.Client.Exec(
+		`INSERT INTO Tags VALUES (?,?,?,?,?);`,
+		"merge_from_tag3",
+		"merge_from",
+		"modtest,merged",
+		"merge_test_tagger",
+		"2024-01-03 00:00:00",
+	); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Client.Exec(
+		`INSERT INTO "Link Likes" VALUES (?,?,?);`,
+		"merge_from_like",
+		"merge_from",
+		test_user_id,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	// other users' links (+ 1 link of mod_test_submitter with no
+	// tags from others)
+	for _, l := range []struct {
+		ID          string
+		URL         string
+		SubmittedBy string
+	}{
+		{"merge_own", "https://merge-own.example.com", "mod_test_submitter"},
+		{"merge_other", "https://merge-other.example.com", test_login_name},
+		{"merge_dupe", "https://www.merge-own.example.com/?utm_source=x", test_login_name},
+	} {
+		if _, err := db.Client.Exec(
+			`INSERT INTO Links (id, url, submitted_by, submit_date, global_cats, global_summary, img_url) VALUES (?,?,?,?,?,?,?);`,
+			l.ID,
+			l.URL,
+			l.SubmittedBy,
+			"2024-01-01 00:00:00",
+			"modtest",
+			"",
+			"",
+		); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Client.Exec(
+			`INSERT INTO Tags VALUES (?,?,?,?,?);`,
+			l.ID+"_tag",
+			l.ID,
+			"modtest",
+			l.SubmittedBy,
+			"2024-01-01 00:00:00",
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// role read from Users, not claims
+	if _, err := db.Client.Exec(
+		`INSERT OR IGNORE INTO Users (id, login_name, password, created, role) VALUES (?,?,?,?,?);`,
+		"merge_test_merge_test_admin",
+		"merge_test_admin",
+		"x",
+		"2024-01-01",
+		"admin",
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	newMergeRouter := func(login_name string, role string) *chi.Mux {
+		r := chi.NewRouter()
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+					"user_id":    "merge_test_" + login_name,
+					"login_name": login_name,
+					"role":       role,
+				})
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+		})
+		r.Post("/links/{link_id}/merge", MergeLink)
+		r.Get("/tags/{link_id}", GetTagPage)
+		r.Get("/summaries/{link_id}", GetSummaryPage)
+
+		return r
+	}
+
+	test_requests := []struct {
+		LoginName          string
+		Role               string
+		LinkID             string
+		Payload            string
+		ExpectedStatusCode int
+	}{
+		// not link submitter or admin
+		{test_login_name, "moderator", "merge_from", `{"into_link_id": "merge_into"}`, 403},
+		// admin claim but not admin
+		{test_login_name, "admin", "merge_from", `{"into_link_id": "merge_into"}`, 403},
+		// submitter merging into another user's link with different URL
+		{"mod_test_submitter", "user", "merge_own", `{"into_link_id": "merge_other"}`, 403},
+		// same canonical URL
+		{"mod_test_submitter", "user", "merge_own", `{"into_link_id": "merge_dupe"}`, 200},
+		{"merge_test_admin", "admin", "merge_from", `{}`, 400},
+		{"merge_test_admin", "admin", "merge_from", `{"into_link_id": "merge_from"}`, 400},
+		{"merge_test_admin", "admin", "merge_from", `{"into_link_id": "-1"}`, 400},
+		{"merge_test_admin", "admin", "-1", `{"into_link_id": "merge_into"}`, 400},
+		{"merge_test_admin", "admin", "merge_from", `{"into_link_id": "merge_into"}`, 200},
+		// already merged
+		{"merge_test_admin", "admin", "merge_from", `{"into_link_id": "merge_into"}`, 400},
+	}
+
+	for _, tr := range test_requests {
+		req := httptest.NewRequest(
+			http.MethodPost,
+			"/links/"+tr.LinkID+"/merge",
+			strings.NewReader(tr.Payload),
+		)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		newMergeRouter(tr.LoginName, tr.Role).ServeHTTP(w, req)
+
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf(
+				"expected status code %d, got %d (test request %+v)\n%s",
+				tr.ExpectedStatusCode,
+				w.Code,
+				tr,
+				w.Body.String(),
+			)
+		}
+	}
+
+	// second tags of users with tags on both dropped
+	var tag_ids []string
+	rows, err := db.Client.Query(`SELECT id FROM Tags WHERE link_id = 'merge_into' ORDER BY id;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		tag_ids = append(tag_ids, id)
+	}
+	rows.Close()
+	if strings.Join(tag_ids, ",") != "merge_from_tag3,merge_into_tag1,merge_into_tag2" {
+		t.Fatalf("got tags %v", tag_ids)
+	}
+
+	var global_cats string
+	var num_likes, num_summaries int
+	if err = db.Client.QueryRow(
+		`SELECT
+			global_cats,
+			(SELECT count(*) FROM "Link Likes" WHERE link_id = 'merge_into'),
+			(SELECT count(*) FROM Summaries WHERE link_id IN ('merge_into', 'merge_from'))
+		FROM Links
+		WHERE id = 'merge_into';`,
+	).Scan(&global_cats, &num_likes, &num_summaries); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(global_cats, "merged") || num_likes != 1 || num_summaries != 1 {
+		t.Fatalf("got global cats %s, %d likes, %d summaries", global_cats, num_likes, num_summaries)
+	}
+
+	// merged link's pages redirect
+	for _, path := range []string{"/tags/", "/summaries/"} {
+		req := httptest.NewRequest(http.MethodGet, path+"merge_from?page=2", nil)
+		w := httptest.NewRecorder()
+		newMergeRouter("", "").ServeHTTP(w, req)
+
+		if w.Code != http.StatusMovedPermanently {
+			t.Fatalf("%s: expected status code 301, got %d", path, w.Code)
+		} else if location := w.Header().Get("Location"); location != path+"merge_into?page=2" {
+			t.Fatalf("%s: got location %s", path, location)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/query"
+)
+
+// blocks included (with IsBlock set)
+func GetMutedUsers(w http.ResponseWriter, r *http.Request) {
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+
+	muted_sql := query.NewMutedUsers(req_user_id)
+	if r.URL.Query().Get("blocked") == "true" {
+		muted_sql = muted_sql.BlockedOnly()
+	}
+	if muted_sql.Error != nil {
+		render.Render(w, r, e.ErrInvalidRequest(muted_sql.Error))
+		return
+	}
+
+	muted, err := util.ScanMutedUsers(muted_sql)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.JSON(w, r, muted)
+}
+
+func MuteUser(w http.ResponseWriter, r *http.Request) {
+	muteUser(w, r, false)
+}
+
+func UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	unmuteUser(w, r, false)
+}
+
+// muted too, and cannot like or copy req user's links
+func BlockUser(w http.ResponseWriter, r *http.Request) {
+	muteUser(w, r, true)
+}
+
+func UnblockUser(w http.ResponseWriter, r *http.Request) {
+	unmuteUser(w, r, true)
+}
+
+func muteUser(w http.ResponseWriter, r *http.Request, is_block bool) {
+	muted_id, ok := getMuteTargetID(w, r)
+	if !ok {
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	is_muted, is_blocked, err := util.GetMuteStatus(req_user_id, muted_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if is_blocked {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrUserAlreadyBlocked))
+		return
+	} else if is_muted && !is_block {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrUserAlreadyMuted))
+		return
+	}
+
+	if err = util.MuteUser(req_user_id, muted_id, is_block); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func unmuteUser(w http.ResponseWriter, r *http.Request, is_block bool) {
+	muted_id, ok := getMuteTargetID(w, r)
+	if !ok {
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	is_muted, is_blocked, err := util.GetMuteStatus(req_user_id, muted_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	switch {
+	case is_block && !is_blocked:
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrUserNotBlocked))
+		return
+	case !is_block && !is_muted:
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrUserNotMuted))
+		return
+	case !is_block && is_blocked:
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrUserBlockedNotMuted))
+		return
+	}
+
+	if err = util.UnmuteUser(req_user_id, muted_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// renders error and returns false if target invalid
+func getMuteTargetID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	login_name := chi.URLParam(r, "login_name")
+	if login_name == "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLoginName))
+		return "", false
+	}
+
+	muted_id, _, err := util.GetUserIDAndRole(login_name)
+	if err == e.ErrNoUserWithLoginName {
+		render.Render(w, r, e.Err404(err))
+		return "", false
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return "", false
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if muted_id == req_user_id {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrCannotMuteSelf))
+		return "", false
+	}
+
+	return muted_id, true
+}
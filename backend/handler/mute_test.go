@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	m "github.com/julianlk522/fitm/middleware"
+)
+
+func TestMuteAndBlock(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+				"user_id":    test_user_id,
+				"login_name": test_login_name,
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Get("/mutes", GetMutedUsers)
+	r.Post("/mutes/{login_name}", MuteUser)
+	r.Delete("/mutes/{login_name}", UnmuteUser)
+	r.Post("/blocks/{login_name}", BlockUser)
+	r.Delete("/blocks/{login_name}", UnblockUser)
+
+	test_requests := []struct {
+		Method             string
+		Path               string
+		ExpectedStatusCode int
+	}{
+		{http.MethodPost, "/mutes/" + test_login_name, 400},
+		{http.MethodPost, "/mutes/nobody_at_all", 404},
+		{http.MethodDelete, "/mutes/test_req_login_name", 400},
+		{http.MethodPost, "/mutes/test_req_login_name", 204},
+		{http.MethodPost, "/mutes/test_req_login_name", 400},
+		{http.MethodDelete, "/blocks/test_req_login_name", 400},
+		// upgrade mute to block
+		{http.MethodPost, "/blocks/test_req_login_name", 204},
+		{http.MethodPost, "/blocks/test_req_login_name", 400},
+		{http.MethodPost, "/mutes/test_req_login_name", 400},
+		{http.MethodDelete, "/mutes/test_req_login_name", 400},
+		{http.MethodGet, "/mutes", 200},
+	}
+
+	for _, tr := range test_requests {
+		req := httptest.NewRequest(tr.Method, tr.Path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf(
+				"expected status code %d, got %d (test request %+v)\n%s",
+				tr.ExpectedStatusCode,
+				w.Code,
+				tr,
+				w.Body.String(),
+			)
+		}
+	}
+
+	// blocked user cannot like or copy blocker's links
+	blocked := chi.NewRouter()
+	blocked.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+				"user_id":    "13",
+				"login_name": "test_req_login_name",
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	blocked.Post("/links/{link_id}/like", LikeLink)
+	blocked.Post("/links/{link_id}/copy", CopyLink)
+
+	for _, path := range []string{"/links/1/like", "/links/1/copy"} {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		w := httptest.NewRecorder()
+		blocked.ServeHTTP(w, req)
+
+		if w.Code != 403 {
+			t.Fatalf("%s: expected status code 403, got %d", path, w.Code)
+		}
+	}
+
+	// unblock
+	req := httptest.NewRequest(http.MethodDelete, "/blocks/test_req_login_name", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != 204 {
+		t.Fatalf("expected status code 204, got %d", w.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+)
+
+// most recently updated first, with unread count
+func GetNotifications(w http.ResponseWriter, r *http.Request) {
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	page := r.Context().Value(m.PageKey).(int)
+
+	notifications, err := util.GetNotifications(req_user_id, page)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.JSON(w, r, notifications)
+}
+
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	notification_id := chi.URLParam(r, "notification_id")
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+
+	err := util.MarkNotificationRead(req_user_id, notification_id)
+	if err == e.ErrNoNotificationWithID {
+		render.Render(w, r, e.Err404(err))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+
+	if err := util.MarkAllNotificationsRead(req_user_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+
+	preferences, err := util.GetNotificationPreferences(req_user_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.JSON(w, r, preferences)
+}
+
+// {"link_liked": false} disables notifications of that type
+func EditNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	request := &model.EditNotificationPreferencesRequest{}
+	if err := render.Bind(r, request); err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if err := util.SaveNotificationPreferences(req_user_id, request); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	preferences, err := util.GetNotificationPreferences(req_user_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.JSON(w, r, preferences)
+}
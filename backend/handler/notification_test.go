@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/julianlk522/fitm/db"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+)
+
+func TestNotifications(t *testing.T) {
+	// test_req_login_name (user 13) submitted link 2
+	const recipient_id = "13"
+	actors := []string{"notification_test_a", "notification_test_b"}
+	for _, actor := range actors {
+		if _, err := db.Client.Exec(
+			`INSERT INTO Users (id, login_name, password, created) VALUES (?,?,?,?);`,
+			actor,
+			actor,
+			"x",
+			"2024-01-01",
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() {
+		db.Client.Exec(`DELETE FROM Notifications WHERE user_id = ?;`, recipient_id)
+		db.Client.Exec(`DELETE FROM "Notification Preferences" WHERE user_id = ?;`, recipient_id)
+		for _, actor := range actors {
+			db.Client.Exec(`DELETE FROM "Link Likes" WHERE user_id = ?;`, actor)
+			db.Client.Exec(`DELETE FROM "Link Copies" WHERE user_id = ?;`, actor)
+			db.Client.Exec(`DELETE FROM Users WHERE id = ?;`, actor)
+		}
+	}()
+
+	router_for := func(user_id string, login_name string) *chi.Mux {
+		r := chi.NewRouter()
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+					"user_id":    user_id,
+					"login_name": login_name,
+				})
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+		})
+		r.Post("/links/{link_id}/like", LikeLink)
+		r.Post("/links/{link_id}/copy", CopyLink)
+		r.With(m.Pagination).Get("/notifications", GetNotifications)
+		r.Put("/notifications/read", MarkAllNotificationsRead)
+		r.Put("/notifications/{notification_id}/read", MarkNotificationRead)
+		r.Put("/notifications/preferences", EditNotificationPreferences)
+		return r
+	}
+	recipient_router := router_for(recipient_id, "test_req_login_name")
+
+	test_requests := []struct {
+		Router             *chi.Mux
+		Method             string
+		Path               string
+		Payload            string
+		ExpectedStatusCode int
+	}{
+		// batched
+		{router_for(actors[0], actors[0]), http.MethodPost, "/links/2/like", "", 204},
+		{router_for(actors[1], actors[1]), http.MethodPost, "/links/2/like", "", 204},
+		{router_for(actors[0], actors[0]), http.MethodPost, "/links/2/copy", "", 204},
+		{recipient_router, http.MethodPut, "/notifications/preferences", `{}`, 400},
+		{recipient_router, http.MethodPut, "/notifications/preferences", `{"link_poked": false}`, 400},
+		{recipient_router, http.MethodPut, "/notifications/preferences", `{"link_tagged": false}`, 200},
+		{recipient_router, http.MethodPut, "/notifications/nonexistent/read", "", 404},
+	}
+
+	for _, tr := range test_requests {
+		req := httptest.NewRequest(tr.Method, tr.Path, strings.NewReader(tr.Payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		tr.Router.ServeHTTP(w, req)
+
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf(
+				"expected status code %d, got %d (%s %s)\n%s",
+				tr.ExpectedStatusCode,
+				w.Code,
+				tr.Method,
+				tr.Path,
+				w.Body.String(),
+			)
+		}
+	}
+
+	// same actor again
+	if err := util.NotifyLinkSubmitter("2", model.NOTIFICATION_LINK_LIKED, actors[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// disabled type
+	if err := util.NotifyLinkSubmitter("2", model.NOTIFICATION_LINK_TAGGED, actors[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	get_notifications := func() model.PaginatedNotifications {
+		req := httptest.NewRequest(http.MethodGet, "/notifications", nil)
+		w := httptest.NewRecorder()
+		recipient_router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200\n%s", w.Code, w.Body.String())
+		}
+
+		var notifications model.PaginatedNotifications
+		if err := json.NewDecoder(w.Body).Decode(&notifications); err != nil {
+			t.Fatal(err)
+		}
+		return notifications
+	}
+
+	notifications := get_notifications()
+	if notifications.UnreadCount != 2 || len(*notifications.Notifications) != 2 {
+		t.Fatalf("got %+v, want 2 unread notifications", notifications)
+	}
+
+	messages := map[string]string{}
+	var like_notification_id string
+	for _, n := range *notifications.Notifications {
+		messages[n.Type] = n.Message
+		if n.Type == model.NOTIFICATION_LINK_LIKED {
+			like_notification_id = n.ID
+		}
+	}
+	if messages[model.NOTIFICATION_LINK_LIKED] != "2 people liked your link" {
+		t.Fatalf("got like message %q", messages[model.NOTIFICATION_LINK_LIKED])
+	} else if messages[model.NOTIFICATION_LINK_COPIED] != actors[0]+" copied your link" {
+		t.Fatalf("got copy message %q", messages[model.NOTIFICATION_LINK_COPIED])
+	}
+
+	// only recipient can mark read
+	req := httptest.NewRequest(http.MethodPut, "/notifications/"+like_notification_id+"/read", nil)
+	w := httptest.NewRecorder()
+	router_for(actors[0], actors[0]).ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("got status %d, want 404", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	recipient_router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("got status %d, want 204", w.Code)
+	}
+
+	// read notifications no longer batched into
+	if err := util.NotifyLinkSubmitter("2", model.NOTIFICATION_LINK_LIKED, actors[1]); err != nil {
+		t.Fatal(err)
+	}
+	if notifications = get_notifications(); notifications.UnreadCount != 2 || len(*notifications.Notifications) != 3 {
+		t.Fatalf("got %+v, want 2 unread of 3 notifications", notifications)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/notifications/read", nil)
+	w = httptest.NewRecorder()
+	recipient_router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("got status %d, want 204", w.Code)
+	} else if notifications = get_notifications(); notifications.UnreadCount != 0 {
+		t.Fatalf("got %d unread notifications, want 0", notifications.UnreadCount)
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+	"github.com/julianlk522/fitm/query"
+)
+
+// Links matching ?q= in URL, summaries or cats, best matches first
+// (period, nsfw and pagination as GetLinks)
+func SearchLinks(w http.ResponseWriter, r *http.Request) {
+	search_sql := query.NewSearchLinks(r.URL.Query().Get("q"))
+
+	// saved settings apply when params omitted
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	settings := model.NewDefaultUserSettings()
+	if req_user_id != "" {
+		var err error
+		settings, err = util.GetUserSettings(req_user_id)
+		if err != nil {
+			render.Render(w, r, e.Err500(err))
+			return
+		}
+	}
+
+	// period
+	// ("all" overrides saved period)
+	period_params := r.URL.Query().Get("period")
+	if period_params == "" {
+		period_params = settings.Period
+	}
+	if period_params != "" && period_params != "all" {
+		search_sql = search_sql.DuringPeriod(period_params)
+	}
+
+	// auth fields
+	if req_user_id != "" {
+		search_sql = search_sql.AsSignedInUser(req_user_id)
+	}
+
+	// nsfw
+	var nsfw_params string
+	if r.URL.Query().Get("nsfw") != "" {
+		nsfw_params = r.URL.Query().Get("nsfw")
+	} else if r.URL.Query().Get("NSFW") != "" {
+		nsfw_params = r.URL.Query().Get("NSFW")
+	} else if settings.NSFW {
+		nsfw_params = "true"
+	}
+
+	if nsfw_params == "true" {
+		search_sql = search_sql.NSFW()
+	} else if nsfw_params != "false" && nsfw_params != "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrInvalidNSFWParams))
+		return
+	}
+
+	// pagination
+	page := r.Context().Value(m.PageKey).(int)
+	search_sql = search_sql.Page(page)
+
+	if search_sql.Error != nil {
+		render.Render(w, r, e.ErrInvalidRequest(search_sql.Error))
+		return
+	}
+
+	// scan
+	if req_user_id != "" {
+		links, err := util.ScanSearchLinks[model.SearchLinkSignedIn](search_sql)
+		if err != nil {
+			render.Render(w, r, e.Err500(err))
+			return
+		}
+		render.JSON(w, r, util.PaginateLinks(links, page))
+	} else {
+		links, err := util.ScanSearchLinks[model.SearchLink](search_sql)
+		if err != nil {
+			render.Render(w, r, e.Err500(err))
+			return
+		}
+		render.JSON(w, r, util.PaginateLinks(links, page))
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+)
+
+func TestSearchLinks(t *testing.T) {
+	var test_requests = []struct {
+		Query              string
+		UserID             string
+		ExpectedStatusCode int
+		WantIDs            []string
+	}{
+		{"", "", 400, nil},
+		{"?q=", "", 400, nil},
+		{"?q=golang", "", 200, []string{"2"}},
+		{"?q=golang", test_user_id, 200, []string{"2"}},
+		{"?q=golang&period=day", "", 200, []string{}},
+		{"?q=golang&period=poop", "", 400, nil},
+		{"?q=nsfw", "", 200, []string{}},
+		{"?q=nsfw&nsfw=true", "", 200, []string{"3"}},
+		{"?q=nsfw&nsfw=maybe", "", 400, nil},
+	}
+
+	for _, tr := range test_requests {
+		r := httptest.NewRequest(http.MethodGet, "/search"+tr.Query, nil)
+		ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+			"user_id":    tr.UserID,
+			"login_name": "",
+		})
+		ctx = context.WithValue(ctx, m.PageKey, 1)
+
+		w := httptest.NewRecorder()
+		SearchLinks(w, r.WithContext(ctx))
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf("%s: expected status code %d, got %d", tr.Query, tr.ExpectedStatusCode, w.Code)
+		} else if w.Code != http.StatusOK {
+			continue
+		}
+
+		var res model.PaginatedLinks[model.SearchLink]
+		if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []string
+		if res.Links != nil {
+			for _, l := range *res.Links {
+				ids = append(ids, l.ID)
+				if !strings.Contains(l.Snippet, "<mark>") {
+					t.Fatalf("%s: snippet %q not highlighted", tr.Query, l.Snippet)
+				}
+			}
+		}
+		if strings.Join(ids, ",") != strings.Join(tr.WantIDs, ",") {
+			t.Fatalf("%s: got links %v, want %v", tr.Query, ids, tr.WantIDs)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/julianlk522/fitm/db"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+)
+
+func TestEditSettings(t *testing.T) {
+	const settings_user_id = "13"
+	defer db.Client.Exec(`DELETE FROM "User Settings" WHERE user_id = ?;`, settings_user_id)
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+				"user_id":    settings_user_id,
+				"login_name": "test_req_login_name",
+			})
+			ctx = context.WithValue(ctx, m.PageKey, 1)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Get("/settings", GetSettings)
+	r.Put("/settings", EditSettings)
+	r.Get("/links", GetLinks)
+
+	test_requests := []struct {
+		Payload            string
+		ExpectedStatusCode int
+	}{
+		{`{}`, 400},
+		{`{"sort_by": "oldest"}`, 400},
+		{`{"period": "decade"}`, 400},
+		{`{"tmap_section_order": "submitted,tagged"}`, 400},
+		{`{"tmap_section_order": "submitted,tagged,tagged"}`, 400},
+		{`{"muted_cats": "a,a"}`, 400},
+		{`{"muted_cats": "` + strings.Repeat("a", 31) + `"}`, 400},
+		{`{"nsfw": true, "sort_by": "newest", "period": "year"}`, 200},
+		// partial update
+		{`{"tmap_section_order": "copied,submitted,tagged", "muted_cats": "umvc3"}`, 200},
+	}
+
+	for _, tr := range test_requests {
+		req := httptest.NewRequest(http.MethodPut, "/settings", strings.NewReader(tr.Payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf(
+				"expected status code %d, got %d (payload %s)\n%s",
+				tr.ExpectedStatusCode,
+				w.Code,
+				tr.Payload,
+				w.Body.String(),
+			)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/settings", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var settings model.UserSettings
+	if err := json.NewDecoder(w.Body).Decode(&settings); err != nil {
+		t.Fatal(err)
+	}
+	want := model.UserSettings{
+		NSFW:             true,
+		SortBy:           "newest",
+		Period:           "year",
+		TmapSectionOrder: "copied,submitted,tagged",
+		MutedCats:        "umvc3",
+	}
+	if settings != want {
+		t.Fatalf("got %+v, want %+v", settings, want)
+	}
+
+	// muted cats hidden unless requested
+	for _, cats := range []string{"", "umvc3"} {
+		req = httptest.NewRequest(http.MethodGet, "/links?period=all&cats="+cats, nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != 200 {
+			t.Fatalf("got status %d, want 200\n%s", w.Code, w.Body.String())
+		}
+
+		var links model.PaginatedLinks[model.LinkSignedIn]
+		if err := json.NewDecoder(w.Body).Decode(&links); err != nil {
+			t.Fatal(err)
+		}
+		if links.Links == nil || len(*links.Links) == 0 {
+			t.Fatalf("no links returned for cats %q", cats)
+		}
+		for _, l := range *links.Links {
+			has_muted_cat := strings.Contains(","+strings.ToLower(l.Cats)+",", ",umvc3,")
+			if cats == "" && has_muted_cat {
+				t.Fatalf("link %s has muted cat (cats %s)", l.ID, l.Cats)
+			} else if cats == "umvc3" && !has_muted_cat {
+				t.Fatalf("link %s missing requested cat (cats %s)", l.ID, l.Cats)
+			}
+		}
+	}
+}
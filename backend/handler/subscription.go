@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+func GetCatSubscriptions(w http.ResponseWriter, r *http.Request) {
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+
+	subscriptions, err := util.GetCatSubscriptions(req_user_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.JSON(w, r, subscriptions)
+}
+
+// {"cats": "go,concurrency"} matches links with all of cats
+func SubscribeToCats(w http.ResponseWriter, r *http.Request) {
+	request := &model.NewCatSubscriptionRequest{}
+	if err := render.Bind(r, request); err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	// sort cats so combinations in any order are the same subscription
+	request.Cats = util.AlphabetizeCats(request.Cats)
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if is_subscribed, err := util.UserIsSubscribedToCats(req_user_id, request.Cats); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if is_subscribed {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrAlreadySubscribedToCats))
+		return
+	}
+
+	if num_subscriptions, err := util.GetNumCatSubscriptions(req_user_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if num_subscriptions >= mutil.CAT_SUBSCRIPTIONS_LIMIT {
+		render.Render(w, r, e.ErrInvalidRequest(e.NumSubscriptionsExceedsLimit(mutil.CAT_SUBSCRIPTIONS_LIMIT)))
+		return
+	}
+
+	if err := util.SaveCatSubscription(req_user_id, request); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, model.CatSubscription{
+		ID:      request.ID,
+		Cats:    request.Cats,
+		Created: request.Created,
+	})
+}
+
+func UnsubscribeFromCats(w http.ResponseWriter, r *http.Request) {
+	subscription_id := chi.URLParam(r, "subscription_id")
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+
+	err := util.DeleteCatSubscription(req_user_id, subscription_id)
+	if err == e.ErrNoSubscriptionWithID {
+		render.Render(w, r, e.Err404(err))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// new links matching any subscription, each marked with those it matched
+// ?since=<Cursor from previous response> for only links after it
+func GetSubscriptionFeed(w http.ResponseWriter, r *http.Request) {
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+
+	subscriptions, err := util.GetCatSubscriptions(req_user_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	since := r.URL.Query().Get("since")
+	if len(*subscriptions) == 0 {
+		render.JSON(w, r, &model.SubscriptionFeed{
+			Links:  &[]model.SubscriptionFeedLink{},
+			Cursor: since,
+		})
+		return
+	}
+
+	feed_sql := util.NewSubscriptionFeedQuery(req_user_id, *subscriptions)
+
+	if since != "" {
+		submit_date, link_id, err := util.DecodeFeedCursor(since)
+		if err != nil {
+			render.Render(w, r, e.ErrInvalidRequest(err))
+			return
+		}
+		feed_sql = feed_sql.Since(submit_date, link_id)
+	}
+
+	// nsfw
+	// (saved setting applies when param omitted)
+	settings, err := util.GetUserSettings(req_user_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	var nsfw_params string
+	if r.URL.Query().Get("nsfw") != "" {
+		nsfw_params = r.URL.Query().Get("nsfw")
+	} else if r.URL.Query().Get("NSFW") != "" {
+		nsfw_params = r.URL.Query().Get("NSFW")
+	} else if settings.NSFW {
+		nsfw_params = "true"
+	}
+
+	if nsfw_params == "true" {
+		feed_sql = feed_sql.NSFW()
+	} else if nsfw_params != "false" && nsfw_params != "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrInvalidNSFWParams))
+		return
+	}
+
+	if feed_sql.Error != nil {
+		render.Render(w, r, e.ErrInvalidRequest(feed_sql.Error))
+		return
+	}
+
+	feed, err := util.ScanSubscriptionFeed(feed_sql, *subscriptions, since != "")
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	// nothing new: keep client's place
+	if feed.Cursor == "" {
+		feed.Cursor = since
+	}
+
+	render.JSON(w, r, feed)
+}
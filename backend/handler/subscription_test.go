@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/julianlk522/fitm/db"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+)
+
+func TestCatSubscriptions(t *testing.T) {
+	if _, err := db.Client.Exec(
+		`INSERT INTO Users (id, login_name, password, created) VALUES (?,?,?,?);`,
+		"subscription_test_id",
+		"subscription_test",
+		"x",
+		"2024-01-01",
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+				"user_id":    "subscription_test_id",
+				"login_name": "subscription_test",
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Get("/subscriptions", GetCatSubscriptions)
+	r.Post("/subscriptions", SubscribeToCats)
+	r.Delete("/subscriptions/{subscription_id}", UnsubscribeFromCats)
+	r.Get("/subscriptions/feed", GetSubscriptionFeed)
+
+	var subscription model.CatSubscription
+	for _, tr := range []struct {
+		Cats               string
+		ExpectedStatusCode int
+	}{
+		{"", 400},
+		{"go,go", 400},
+		{"umvc3", 201},
+		// same combination in another order
+		{"programming,go", 201},
+		{"go,programming", 400},
+	} {
+		pl, _ := json.Marshal(map[string]string{"cats": tr.Cats})
+		req := httptest.NewRequest(http.MethodPost, "/subscriptions", bytes.NewReader(pl))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf("%q: expected status code %d, got %d\n%s", tr.Cats, tr.ExpectedStatusCode, w.Code, w.Body.String())
+		} else if w.Code == http.StatusCreated {
+			if err := json.NewDecoder(w.Body).Decode(&subscription); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if subscription.Cats != "go,programming" {
+		t.Fatalf("got subscription cats %s, want go,programming", subscription.Cats)
+	}
+
+	get_feed := func(path string, expected_status_code int) model.SubscriptionFeed {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != expected_status_code {
+			t.Fatalf("%s: expected status code %d, got %d\n%s", path, expected_status_code, w.Code, w.Body.String())
+		}
+
+		var feed model.SubscriptionFeed
+		if w.Code == http.StatusOK {
+			if err := json.NewDecoder(w.Body).Decode(&feed); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return feed
+	}
+
+	// link 1 (umvc3) older than link 2 (go,programming)
+	feed := get_feed("/subscriptions/feed", 200)
+	matched := map[string][]string{}
+	var order []string
+	for _, l := range *feed.Links {
+		matched[l.ID] = l.MatchedSubscriptions
+		order = append(order, l.ID)
+	}
+	if len(matched["1"]) != 1 || matched["1"][0] != "umvc3" ||
+		len(matched["2"]) != 1 || matched["2"][0] != "go,programming" {
+		t.Fatalf("got matches %v", matched)
+	}
+	for i := 1; i < len(order); i++ {
+		if order[i-1] == "1" && order[i] == "2" {
+			t.Fatalf("expected newest first, got %v", order)
+		}
+	}
+
+	// nothing since newest
+	since_newest := get_feed("/subscriptions/feed?since="+feed.Cursor, 200)
+	if len(*since_newest.Links) != 0 || since_newest.Cursor != feed.Cursor || since_newest.HasMore {
+		t.Fatalf("got %+v, want no links and same cursor", since_newest)
+	}
+
+	since_link_1 := get_feed("/subscriptions/feed?since="+util.EncodeFeedCursor("2024-01-01 00:00:00", "1"), 200)
+	found := false
+	for _, l := range *since_link_1.Links {
+		found = found || l.ID == "2"
+		if l.ID == "1" {
+			t.Fatal("expected link at cursor to be excluded")
+		}
+	}
+	if !found {
+		t.Fatalf("got %+v, want link 2", since_link_1)
+	}
+
+	get_feed("/subscriptions/feed?since=not_a_cursor", 400)
+	get_feed("/subscriptions/feed?nsfw=maybe", 400)
+
+	for _, expected_status_code := range []int{204, 404} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/subscriptions/"+subscription.ID, nil))
+		if w.Code != expected_status_code {
+			t.Fatalf("expected status code %d, got %d", expected_status_code, w.Code)
+		}
+	}
+}
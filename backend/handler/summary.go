@@ -2,6 +2,7 @@ package handler
 
 import (
 	"database/sql"
+	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -27,7 +28,11 @@ func GetSummaryPage(w http.ResponseWriter, r *http.Request) {
 		render.Render(w, r, e.Err500(err))
 		return
 	} else if !link_exists {
-		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLinkWithID))
+		if redirected, err := redirectMergedLink(w, r, "/summaries/", link_id, ""); err != nil {
+			render.Render(w, r, e.Err500(err))
+		} else if !redirected {
+			render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLinkWithID))
+		}
 		return
 	}
 
@@ -266,6 +271,10 @@ func LikeSummary(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err = util.NotifySummarySubmitter(summary_id, model.NOTIFICATION_SUMMARY_LIKED, req_user_id); err != nil {
+		log.Printf("could not notify summary submitter of like on %s: %s", summary_id, err)
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
 
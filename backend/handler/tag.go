@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"log"
 	"net/http"
 	"strings"
 
@@ -27,7 +28,11 @@ func GetTagPage(w http.ResponseWriter, r *http.Request) {
 		render.Render(w, r, e.ErrInvalidRequest(err))
 		return
 	} else if !link_exists {
-		render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLinkWithID))
+		if redirected, err := redirectMergedLink(w, r, "/tags/", link_id, ""); err != nil {
+			render.Render(w, r, e.Err500(err))
+		} else if !redirected {
+			render.Render(w, r, e.ErrInvalidRequest(e.ErrNoLinkWithID))
+		}
 		return
 	}
 
@@ -52,6 +57,9 @@ func GetTagPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	tag_rankings_sql := query.NewTagRankings(link_id).Public()
+	if req_user_id != "" {
+		tag_rankings_sql = tag_rankings_sql.AsSignedInUser(req_user_id)
+	}
 	if tag_rankings_sql.Error != nil {
 		render.Render(w, r, e.ErrInvalidRequest(tag_rankings_sql.Error))
 		return
@@ -101,8 +109,15 @@ func GetTopGlobalCats(w http.ResponseWriter, r *http.Request) {
 		global_cats_sql = global_cats_sql.SubcatsOfCats(cats_params)
 	}
 
+	from_params, to_params, err := util.GetDateRangeParams(r)
+	if err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
 	period_params := r.URL.Query().Get("period")
-	if period_params != "" {
+	if from_params != "" || to_params != "" {
+		global_cats_sql = global_cats_sql.DuringDateRange(from_params, to_params)
+	} else if period_params != "" {
 		global_cats_sql = global_cats_sql.DuringPeriod(period_params)
 	}
 
@@ -114,7 +129,7 @@ func GetTopGlobalCats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if global_cats_sql.Error != nil {
-		render.Render(w, r, e.Err500(global_cats_sql.Error))
+		render.Render(w, r, e.ErrInvalidRequest(global_cats_sql.Error))
 		return
 	}
 
@@ -179,8 +194,15 @@ func GetTopContributors(w http.ResponseWriter, r *http.Request) {
 		contributors_sql = contributors_sql.FromCats(cats)
 	}
 
+	from_params, to_params, err := util.GetDateRangeParams(r)
+	if err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
 	period_params := r.URL.Query().Get("period")
-	if period_params != "" {
+	if from_params != "" || to_params != "" {
+		contributors_sql = contributors_sql.DuringDateRange(from_params, to_params)
+	} else if period_params != "" {
 		contributors_sql = contributors_sql.DuringPeriod(period_params)
 	}
 
@@ -235,11 +257,16 @@ func AddTag(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = util.CalculateAndSetGlobalCats(tag_data.LinkID); err != nil {
+	if err = setGlobalCatsAndPublish(tag_data.LinkID); err != nil {
 		render.Render(w, r, e.ErrInvalidRequest(err))
 		return
 	}
 
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if err = util.NotifyLinkSubmitter(tag_data.LinkID, model.NOTIFICATION_LINK_TAGGED, req_user_id); err != nil {
+		log.Printf("could not notify link submitter of tag on %s: %s", tag_data.LinkID, err)
+	}
+
 	render.Status(r, http.StatusCreated)
 	render.JSON(w, r, tag_data)
 }
@@ -282,7 +309,7 @@ func EditTag(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		render.Render(w, r, e.Err500(err))
 		return
-	} else if err = util.CalculateAndSetGlobalCats(link_id); err != nil {
+	} else if err = setGlobalCatsAndPublish(link_id); err != nil {
 		render.Render(w, r, e.Err500(err))
 		return
 	}
@@ -344,7 +371,7 @@ func DeleteTag(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// set global cats
-	if err = util.CalculateAndSetGlobalCats(link_id); err != nil {
+	if err = setGlobalCatsAndPublish(link_id); err != nil {
 		render.Render(w, r, e.Err500(err))
 		return
 	}
@@ -19,6 +19,88 @@ import (
 // 	test_requests := []struct
 // }
 
+func TestGetTopGlobalCatsDateRange(t *testing.T) {
+	var test_requests = []struct {
+		Query              string
+		ExpectedStatusCode int
+		WantCats           []string
+		NotWantCats        []string
+	}{
+		{"?from=2024-01&to=2024-01", 200, []string{"flowers", "umvc3"}, []string{"go"}},
+		{"?from=2024-02-01&to=2024-02-01", 200, []string{"go", "programming"}, []string{"umvc3"}},
+		{"?from=2024-01&period=week", 400, nil, nil},
+		{"?from=whenever", 400, nil, nil},
+	}
+
+	for _, tr := range test_requests {
+		w := httptest.NewRecorder()
+		GetTopGlobalCats(w, httptest.NewRequest(http.MethodGet, "/cats"+tr.Query, nil))
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf("%s: expected status code %d, got %d", tr.Query, tr.ExpectedStatusCode, w.Code)
+		} else if w.Code != http.StatusOK {
+			continue
+		}
+
+		var counts []model.CatCount
+		if err := json.NewDecoder(w.Body).Decode(&counts); err != nil {
+			t.Fatal(err)
+		}
+		cats := map[string]bool{}
+		for _, c := range counts {
+			cats[c.Category] = true
+		}
+		for _, cat := range tr.WantCats {
+			if !cats[cat] {
+				t.Fatalf("%s: cat %s missing from %v", tr.Query, cat, counts)
+			}
+		}
+		for _, cat := range tr.NotWantCats {
+			if cats[cat] {
+				t.Fatalf("%s: cat %s outside range returned", tr.Query, cat)
+			}
+		}
+	}
+}
+
+func TestGetTopContributorsDateRange(t *testing.T) {
+	var test_requests = []struct {
+		Query              string
+		ExpectedStatusCode int
+		WantContributor    string
+		NotWantContributor string
+	}{
+		{"?from=2024-01&to=2024-01", 200, test_login_name, "test_req_login_name"},
+		{"?cats=go&from=2024-02-01", 200, "test_req_login_name", test_login_name},
+		{"?to=2024-01&period=all", 400, "", ""},
+		{"?to=2024-13", 400, "", ""},
+	}
+
+	for _, tr := range test_requests {
+		w := httptest.NewRecorder()
+		GetTopContributors(w, httptest.NewRequest(http.MethodGet, "/contributors"+tr.Query, nil))
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf("%s: expected status code %d, got %d", tr.Query, tr.ExpectedStatusCode, w.Code)
+		} else if w.Code != http.StatusOK {
+			continue
+		}
+
+		var contributors []model.Contributor
+		if err := json.NewDecoder(w.Body).Decode(&contributors); err != nil {
+			t.Fatal(err)
+		}
+		var found bool
+		for _, c := range contributors {
+			if c.LoginName == tr.NotWantContributor {
+				t.Fatalf("%s: contributor %s outside range returned", tr.Query, c.LoginName)
+			}
+			found = found || c.LoginName == tr.WantContributor
+		}
+		if !found {
+			t.Fatalf("%s: contributor %s missing from %+v", tr.Query, tr.WantContributor, contributors)
+		}
+	}
+}
+
 func TestAddTag(t *testing.T) {
 	test_tag_requests := []struct {
 		Payload map[string]string
@@ -1,9 +1,10 @@
 package handler
 
 import (
-	"image"
+	"database/sql"
 	"io"
 	"log"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -16,20 +17,28 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
 
-	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/julianlk522/fitm/db"
 	e "github.com/julianlk522/fitm/error"
 	m "github.com/julianlk522/fitm/middleware"
 	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+	"github.com/julianlk522/fitm/storage"
 )
 
 var pic_dir string
+var pic_store storage.BlobStore
 
 func init() {
 	work_dir, _ := os.Getwd()
 	pic_dir = filepath.Join(work_dir, "db/profile-pics")
+
+	var err error
+	pic_store, err = storage.NewBlobStoreFromEnv(pic_dir)
+	if err != nil {
+		log.Fatal(err)
+	}
 }
 
 // Auth
@@ -46,6 +55,15 @@ func SignUp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	is_reserved, err := util.LoginNameReserved(signup_data.Auth.LoginName, "")
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if is_reserved {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrLoginNameReserved))
+		return
+	}
+
 	pw_hash, err := bcrypt.GenerateFromPassword(
 		[]byte(signup_data.Auth.Password),
 		bcrypt.DefaultCost,
@@ -55,7 +73,8 @@ func SignUp(w http.ResponseWriter, r *http.Request) {
 	}
 
 	_, err = db.Client.Exec(
-		`INSERT INTO users VALUES (?,?,?,?,?,?)`,
+		`INSERT INTO Users (id, login_name, password, about, pfp, created) 
+		VALUES (?,?,?,?,?,?)`,
 		signup_data.ID,
 		signup_data.Auth.LoginName,
 		pw_hash,
@@ -94,6 +113,15 @@ func LogIn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	is_banned, err := util.UserIsBanned(login_data.LoginName)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if is_banned {
+		render.Render(w, r, e.ErrUnauthorized(e.ErrUserBanned))
+		return
+	}
+
 	token, err := util.GetJWTFromLoginName(login_data.Auth.LoginName)
 	if err != nil {
 		render.Render(w, r, e.Err500(err))
@@ -104,6 +132,74 @@ func LogIn(w http.ResponseWriter, r *http.Request) {
 	util.RenderJWT(token, w, r)
 }
 
+// new token returned since old one carries previous login name
+func EditLoginName(w http.ResponseWriter, r *http.Request) {
+	edit_login_name_data := &model.EditLoginNameRequest{}
+	if err := render.Bind(r, edit_login_name_data); err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	old_login_name, err := util.GetLoginNameFromUserID(req_user_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if old_login_name == edit_login_name_data.LoginName {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrSameLoginName))
+		return
+	}
+
+	if next_change_at, err := util.GetNextLoginNameChangeTime(req_user_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if next_change_at != "" {
+		render.Render(w, r, e.ErrInvalidRequest(e.LoginNameChangedRecently(next_change_at)))
+		return
+	}
+
+	if util.LoginNameTaken(edit_login_name_data.LoginName) {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrLoginNameTaken))
+		return
+	}
+
+	is_reserved, err := util.LoginNameReserved(edit_login_name_data.LoginName, req_user_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if is_reserved {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrLoginNameReserved))
+		return
+	}
+
+	if err = util.RenameUser(edit_login_name_data, req_user_id, old_login_name); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	token, err := util.GetJWTFromLoginName(edit_login_name_data.LoginName)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.Status(r, http.StatusOK)
+	util.RenderJWT(token, w, r)
+}
+
+// public keys for verifying FITM-issued JWTs
+func GetJWKS(w http.ResponseWriter, r *http.Request) {
+	jwks, err := m.TokenAuth.PublicJWKS()
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, jwks)
+}
+
 // Treasure map
 func EditAbout(w http.ResponseWriter, r *http.Request) {
 	edit_about_data := &model.EditAboutRequest{}
@@ -127,36 +223,56 @@ func EditAbout(w http.ResponseWriter, r *http.Request) {
 }
 
 func GetProfilePic(w http.ResponseWriter, r *http.Request) {
-	// (from backend/db/profile-pics/{file_name})
+	// (from pic_store: backend/db/profile-pics/ or S3)
 
 	var file_name string = chi.URLParam(r, "file_name")
-	path := pic_dir + "/" + file_name
+	key := file_name
+	cache_control := "public, max-age=86400"
+
+	// processed pics: serve requested rendition
+	// (content-addressed so never changes)
+	if hash := util.GetProfilePicHash(file_name); hash != "" {
+		size, err := util.ParseProfilePicSize(r.URL.Query().Get("size"))
+		if err != nil {
+			render.Render(w, r, e.ErrInvalidRequest(err))
+			return
+		}
+
+		key = util.ProfilePicRenditionName(hash, size)
+		cache_control = "public, max-age=31536000, immutable"
+	}
 
-	if _, err := os.Stat(path); err != nil {
+	pic, err := pic_store.Get(key)
+	if err == e.ErrBlobNotFound || err == e.ErrInvalidBlobKey {
 		render.Render(w, r, e.ErrInvalidRequest(e.ErrProfilePicNotFound))
 		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
 	}
+	defer pic.Close()
 
-	http.ServeFile(w, r, path)
+	if content_type := mime.TypeByExtension(filepath.Ext(key)); content_type != "" {
+		w.Header().Set("Content-Type", content_type)
+	}
+	w.Header().Set("Cache-Control", cache_control)
+	io.Copy(w, pic)
 }
 
 func UploadProfilePic(w http.ResponseWriter, r *http.Request) {
 
 	// Get file (up to 10MB)
-	r.ParseMultipartForm(10 << 20)
-	file, handler, err := r.FormFile("pic")
+	r.Body = http.MaxBytesReader(w, r.Body, mutil.PFP_MAX_UPLOAD_BYTES)
+	r.ParseMultipartForm(mutil.PFP_MAX_UPLOAD_BYTES)
+	file, _, err := r.FormFile("pic")
 	if err != nil {
 		render.Render(w, r, e.ErrInvalidRequest(err))
 		return
 	}
 	defer file.Close()
 
-	if !strings.Contains(handler.Header.Get("Content-Type"), "image") {
-		render.Render(w, r, e.ErrInvalidRequest(e.ErrInvalidFileType))
-		return
-	}
-
-	img, _, err := image.Decode(file)
+	// format sniffed from contents: client filename / Content-Type ignored
+	img, err := util.DecodeProfilePic(file)
 	if err != nil {
 		render.Render(w, r, e.ErrInvalidRequest(err))
 		return
@@ -169,37 +285,45 @@ func UploadProfilePic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	extension := filepath.Ext(handler.Filename)
-	unique_name := uuid.New().String() + extension
-	full_path := pic_dir + "/" + unique_name
-
-	dst, err := os.Create(full_path)
+	hash, renditions, err := util.ProcessProfilePic(img)
 	if err != nil {
-		// Note: if, for some reason, the directory at pic_dir's path
-		// doesn't exist, this will fail
-		// shouldn't matter but just for posterity
-		render.Render(w, r, e.Err500(e.ErrCouldNotCreateProfilePic))
+		render.Render(w, r, e.Err500(err))
 		return
 	}
-	defer dst.Close()
-
-	// Restore img file cursor to start
-	file.Seek(0, 0)
 
-	// Save to new file
-	if _, err := io.Copy(dst, file); err != nil {
-		render.Render(w, r, e.Err500(e.ErrCouldNotCopyProfilePic))
+	if err = util.SaveProfilePicRenditions(pic_store, hash, renditions); err != nil {
+		render.Render(w, r, e.Err500(err))
 		return
 	}
 
 	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
-	_, err = db.Client.Exec(`UPDATE Users SET pfp = ? WHERE id = ?`, unique_name, req_user_id)
+	var old_pfp sql.NullString
+	err = db.Client.QueryRow(`SELECT pfp FROM Users WHERE id = ?`, req_user_id).Scan(&old_pfp)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	new_pfp := util.ProfilePicName(hash)
+	_, err = db.Client.Exec(
+		`UPDATE Users SET pfp = ? WHERE id = ?`,
+		new_pfp,
+		req_user_id,
+	)
 	if err != nil {
 		render.Render(w, r, e.Err500(e.ErrCouldNotSaveProfilePic))
 		return
 	}
 
-	http.ServeFile(w, r, full_path)
+	// replaced pic (GC catches it if this fails)
+	if old_pfp.Valid && old_pfp.String != new_pfp {
+		if err = util.DeleteProfilePicIfUnreferenced(pic_store, old_pfp.String); err != nil {
+			log.Printf("could not delete replaced profile pic %s: %s", old_pfp.String, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Write(renditions[mutil.PFP_DEFAULT_SIZE])
 }
 
 func DeleteProfilePic(w http.ResponseWriter, r *http.Request) {
@@ -211,14 +335,13 @@ func DeleteProfilePic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get file path before deleting
+	// Get file name before deleting
 	var pfp string
 	err := db.Client.QueryRow(`SELECT pfp FROM Users WHERE id = ?`, req_user_id).Scan(&pfp)
 	if err != nil {
 		render.Render(w, r, e.Err500(err))
 		return
 	}
-	pfp_path := pic_dir + "/" + pfp
 
 	// Delete from DB
 	_, err = db.Client.Exec(
@@ -230,17 +353,11 @@ func DeleteProfilePic(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Confirm file at path exists
-	if _, err := os.Stat(pfp_path); err == nil {
-
-		// Delete from filesystem
-		err = os.Remove(pfp_path)
-		if err != nil {
-			render.Render(w, r, e.Err500(e.ErrCouldNotRemoveProfilePic))
-			return
-		}
-	} else {
-		log.Print("pfp was not present on filesystem at saved path")
+	// Delete from pic store
+	if err = util.DeleteProfilePicIfUnreferenced(pic_store, pfp); err != nil {
+		log.Printf("could not delete profile pic %s: %s", pfp, err)
+		render.Render(w, r, e.Err500(e.ErrCouldNotRemoveProfilePic))
+		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
@@ -258,6 +375,22 @@ func GetTreasureMap(w http.ResponseWriter, r *http.Request) {
 		render.Render(w, r, e.ErrInvalidRequest(err))
 		return
 	} else if !user_exists {
+
+		// old login name: send to current one
+		// (not 301: name may be claimed by someone else after cooldown)
+		renamed_login_name, err := util.GetRenamedLoginName(login_name)
+		if err != nil {
+			render.Render(w, r, e.Err500(err))
+			return
+		} else if renamed_login_name != "" {
+			redirect_url := "/map/" + renamed_login_name
+			if r.URL.RawQuery != "" {
+				redirect_url += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, redirect_url, http.StatusFound)
+			return
+		}
+
 		render.Render(w, r, e.Err404(e.ErrNoUserWithLoginName))
 		return
 	}
@@ -278,3 +411,66 @@ func GetTreasureMap(w http.ResponseWriter, r *http.Request) {
 
 	render.JSON(w, r, tmap)
 }
+
+// ?format=bookmarks (default) | opml | markdown
+// ?folders=N: folders for top N cats (default 12), other links unfiled
+// cats / nsfw params and viewer's settings apply as in GetTreasureMap
+func ExportTreasureMap(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = util.TMAP_EXPORT_BOOKMARKS
+	}
+	content_type, extension, err := util.TmapExportFileType(format)
+	if err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+	folder_limit, err := util.GetTmapExportFolderLimit(r.URL.Query().Get("folders"))
+	if err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	var login_name string = chi.URLParam(r, "login_name")
+	user_exists, err := util.UserExists(login_name)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if !user_exists {
+		renamed_login_name, err := util.GetRenamedLoginName(login_name)
+		if err != nil {
+			render.Render(w, r, e.Err500(err))
+			return
+		} else if renamed_login_name != "" {
+			http.Redirect(w, r, "/map/"+renamed_login_name+"/export?"+r.URL.RawQuery, http.StatusFound)
+			return
+		}
+
+		render.Render(w, r, e.Err404(e.ErrNoUserWithLoginName))
+		return
+	}
+
+	var tmap interface{}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if req_user_id != "" {
+		tmap, err = util.GetTmapForUser[model.TmapLinkSignedIn](login_name, r)
+	} else {
+		tmap, err = util.GetTmapForUser[model.TmapLink](login_name, r)
+	}
+	if err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", content_type)
+	w.Header().Set("Content-Disposition", `attachment; filename="fitm-`+login_name+`.`+extension+`"`)
+	export_opts := &model.TmapCatCountsOpts{Limit: folder_limit}
+	if cats_params := r.URL.Query().Get("cats"); cats_params != "" {
+		export_opts.OmittedCats = strings.Split(cats_params, ",")
+	}
+
+	if err = util.WriteTmapExport(w, format, util.NewTmapExport(login_name, tmap, export_opts)); err != nil {
+		log.Printf("could not write tmap export for %s: %s", login_name, err)
+	}
+}
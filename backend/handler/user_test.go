@@ -5,6 +5,9 @@ import (
 	"context"
 	"encoding/json"
 
+	"github.com/go-chi/chi/v5"
+
+	"github.com/julianlk522/fitm/db"
 	m "github.com/julianlk522/fitm/middleware"
 
 	"io"
@@ -237,3 +240,171 @@ func TestDeleteProfilePic(t *testing.T) {
 func TestGetTreasureMap(t *testing.T) {
 	// TODO
 }
+
+func TestExportTreasureMap(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+				"user_id":    "",
+				"login_name": "",
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	router.Get("/map/{login_name}/export", ExportTreasureMap)
+
+	var test_requests = []struct {
+		URL                 string
+		ExpectedStatusCode  int
+		ExpectedContentType string
+	}{
+		{"/map/" + test_login_name + "/export", 200, "text/html; charset=utf-8"},
+		{"/map/" + test_login_name + "/export?format=opml&cats=umvc3", 200, "text/x-opml; charset=utf-8"},
+		{"/map/" + test_login_name + "/export?format=markdown&nsfw=true", 200, "text/markdown; charset=utf-8"},
+		{"/map/" + test_login_name + "/export?format=markdown&folders=1", 200, "text/markdown; charset=utf-8"},
+		{"/map/" + test_login_name + "/export?format=csv", 400, ""},
+		{"/map/" + test_login_name + "/export?folders=0", 400, ""},
+		{"/map/" + test_login_name + "/export?nsfw=maybe", 400, ""},
+		{"/map/nobody_by_this_name/export", 404, ""},
+	}
+
+	for _, tr := range test_requests {
+		r := httptest.NewRequest(http.MethodGet, tr.URL, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf("%s: expected status code %d, got %d\n%s", tr.URL, tr.ExpectedStatusCode, w.Code, w.Body.String())
+		} else if tr.ExpectedContentType != "" && w.Header().Get("Content-Type") != tr.ExpectedContentType {
+			t.Fatalf("%s: got content type %s, want %s", tr.URL, w.Header().Get("Content-Type"), tr.ExpectedContentType)
+		}
+	}
+}
+
+func TestEditLoginName(t *testing.T) {
+	_, err := db.Client.Exec(
+		`INSERT INTO Users (id, login_name, password, created) VALUES (?,?,?,?);`,
+		"rename_test_id",
+		"rename_test",
+		"x",
+		"2024-01-01",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Client.Exec(
+		`INSERT INTO Links (id, url, submitted_by, submit_date, global_cats, global_summary, img_url) VALUES (?,?,?,?,?,?,?);`,
+		"rename_test_link",
+		"https://rename.example.com",
+		"rename_test",
+		"2024-01-01 00:00:00",
+		"rename",
+		"",
+		"",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type rename_request struct {
+		LoginName          string
+		ExpectedStatusCode int
+	}
+	send := func(tr rename_request) {
+		pl, _ := json.Marshal(map[string]string{"login_name": tr.LoginName})
+		r := httptest.NewRequest(
+			http.MethodPut,
+			"/login_name",
+			bytes.NewReader(pl),
+		)
+		r.Header.Set("Content-Type", "application/json")
+
+		ctx := context.Background()
+		jwt_claims := map[string]interface{}{
+			"user_id":    "rename_test_id",
+			"login_name": "rename_test",
+		}
+		ctx = context.WithValue(ctx, m.JWTClaimsKey, jwt_claims)
+		r = r.WithContext(ctx)
+
+		w := httptest.NewRecorder()
+		EditLoginName(w, r)
+		res := w.Result()
+		defer res.Body.Close()
+
+		if res.StatusCode != tr.ExpectedStatusCode {
+			text, _ := io.ReadAll(res.Body)
+			t.Fatalf(
+				"expected status code %d, got %d (test request %+v)\n%s",
+				tr.ExpectedStatusCode,
+				res.StatusCode,
+				tr,
+				text,
+			)
+		}
+	}
+
+	var test_requests = []rename_request{
+		// invalid
+		{"", 400},
+		{"r", 400},
+		{"rename test", 400},
+		// same as current
+		{"rename_test", 400},
+		// taken
+		{test_login_name, 400},
+		{"renamed_test", 200},
+		// changed too recently
+		{"renamed_again", 400},
+		{"rename_test", 400},
+	}
+	for _, tr := range test_requests {
+		send(tr)
+	}
+
+	// cooldown passed: name still reserved for previous owner
+	if _, err = db.Client.Exec(
+		`UPDATE "Login Name History" SET changed_at = ? WHERE user_id = ?;`,
+		"2024-01-01 00:00:00",
+		"rename_test_id",
+	); err != nil {
+		t.Fatal(err)
+	}
+	send(rename_request{"rename_test", 200})
+
+	// denormalized submitted_by updated
+	var submitted_by string
+	err = db.Client.QueryRow(
+		"SELECT submitted_by FROM Links WHERE id = ?;",
+		"rename_test_link",
+	).Scan(&submitted_by)
+	if err != nil {
+		t.Fatal(err)
+	} else if submitted_by != "rename_test" {
+		t.Fatalf("got submitted_by %s, want rename_test", submitted_by)
+	}
+
+	// old treasure map URL redirects
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+				"user_id":    "",
+				"login_name": "",
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	router.Get("/map/{login_name}", GetTreasureMap)
+
+	r := httptest.NewRequest(http.MethodGet, "/map/renamed_test?cats=rename", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("expected status code 302, got %d", w.Code)
+	} else if loc := w.Header().Get("Location"); loc != "/map/rename_test?cats=rename" {
+		t.Fatalf("got redirect location %s, want /map/rename_test?cats=rename", loc)
+	}
+}
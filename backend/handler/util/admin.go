@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"database/sql"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/julianlk522/fitm/db"
+	e "github.com/julianlk522/fitm/error"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+	"github.com/julianlk522/fitm/query"
+)
+
+const (
+	ERR_LOG_ENTRIES_DEFAULT_LIMIT = 50
+	ERR_LOG_ENTRIES_MAX_LIMIT     = 500
+	// only the end of the err log is read
+	ERR_LOG_TAIL_BYTES = 1 << 20
+)
+
+// Audit
+func LogModeratorAction(tx *sql.Tx, moderator_id string, action string, target_type string, target_id string, details string) error {
+	_, err := tx.Exec(
+		`INSERT INTO "Moderator Actions" VALUES (?,?,?,?,?,?,?);`,
+		uuid.New().String(),
+		moderator_id,
+		action,
+		target_type,
+		target_id,
+		details,
+		mutil.NEW_LONG_TIMESTAMP(),
+	)
+
+	return err
+}
+
+func ScanModeratorActions(actions_sql *query.ModeratorActions) (*[]model.ModeratorAction, error) {
+	rows, err := db.Client.Query(actions_sql.Text, actions_sql.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := []model.ModeratorAction{}
+	for rows.Next() {
+		var a model.ModeratorAction
+		if err := rows.Scan(
+			&a.ID,
+			&a.Moderator,
+			&a.Action,
+			&a.TargetType,
+			&a.TargetID,
+			&a.Details,
+			&a.PerformedAt,
+		); err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+
+	return &actions, nil
+}
+
+func PaginateModeratorActions(actions *[]model.ModeratorAction, page int) *model.PaginatedModeratorActions {
+	if len(*actions) == query.MODERATOR_ACTIONS_PAGE_LIMIT+1 {
+		sliced := (*actions)[0:query.MODERATOR_ACTIONS_PAGE_LIMIT]
+		return &model.PaginatedModeratorActions{
+			Actions:  &sliced,
+			NextPage: page + 1,
+		}
+	}
+
+	return &model.PaginatedModeratorActions{
+		Actions:  actions,
+		NextPage: -1,
+	}
+}
+
+// Edit link cats
+func GetLinkSubmitterTagID(link_id string) (string, error) {
+	var tag_id sql.NullString
+	err := db.Client.QueryRow(`SELECT t.id
+		FROM Tags t
+		INNER JOIN Links l ON l.id = t.link_id
+		WHERE l.id = ?
+		AND t.submitted_by = l.submitted_by;`,
+		link_id,
+	).Scan(&tag_id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", e.ErrNoTagForLinkSubmitter
+		}
+		return "", err
+	}
+
+	return tag_id.String, nil
+}
+
+func GetTagCats(tag_id string) (string, error) {
+	var cats string
+	err := db.Client.QueryRow("SELECT cats FROM Tags WHERE id = ?;", tag_id).Scan(&cats)
+	if err != nil {
+		return "", err
+	}
+
+	return cats, nil
+}
+
+// Edit / delete summary
+func GetSummaryText(summary_id string) (string, error) {
+	var text string
+	err := db.Client.QueryRow("SELECT text FROM Summaries WHERE id = ?;", summary_id).Scan(&text)
+	if err != nil {
+		return "", err
+	}
+
+	return text, nil
+}
+
+// Ban / unban, edit role
+func GetUserIDAndRole(login_name string) (string, string, error) {
+	var id, role sql.NullString
+	err := db.Client.QueryRow(
+		"SELECT id, role FROM Users WHERE login_name = ?;",
+		login_name,
+	).Scan(&id, &role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", e.ErrNoUserWithLoginName
+		}
+		return "", "", err
+	}
+
+	return id.String, role.String, nil
+}
+
+// current role (JWT "role" claim may be stale)
+func GetUserRole(user_id string) (string, error) {
+	var role sql.NullString
+	err := db.Client.QueryRow(
+		"SELECT role FROM Users WHERE id = ?;",
+		user_id,
+	).Scan(&role)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	return role.String, nil
+}
+
+func UserIsBanned(login_name string) (bool, error) {
+	var banned_at sql.NullString
+	err := db.Client.QueryRow(
+		"SELECT banned_at FROM Users WHERE login_name = ?;",
+		login_name,
+	).Scan(&banned_at)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return banned_at.Valid, nil
+}
+
+func IsPrivilegedRole(role string) bool {
+	return role == mutil.ROLE_MODERATOR || role == mutil.ROLE_ADMIN
+}
+
+// Err log
+func GetRecentErrLogEntries(limit int) (*[]model.ErrLogEntry, error) {
+	log_path := os.Getenv("FITM_ERR_LOG_FILE")
+	if log_path == "" {
+		return nil, e.ErrNoErrLogFile
+	}
+
+	f, err := os.Open(log_path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size() > ERR_LOG_TAIL_BYTES {
+		if _, err = f.Seek(info.Size()-ERR_LOG_TAIL_BYTES, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	tail, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseErrLogEntries(string(tail), limit), nil
+}
+
+// Entries written by SplitLogEntry look like:
+// 2024/01/01 00:00:00 Err: 404 GET /path 127.0.0.1 12B 1ms
+// Status Text: {...}
+// (newest returned first)
+func ParseErrLogEntries(log_text string, limit int) *[]model.ErrLogEntry {
+	entries := []model.ErrLogEntry{}
+
+	var current *model.ErrLogEntry
+	for _, line := range strings.Split(log_text, "\n") {
+		if i := strings.Index(line, " Err: "); i != -1 {
+			if current != nil {
+				entries = append(entries, *current)
+			}
+			current = &model.ErrLogEntry{
+				Timestamp: line[:i],
+				Entry:     line[i+len(" Err: "):],
+			}
+		} else if current != nil && strings.HasPrefix(line, "Status Text: ") {
+			current.StatusText = strings.TrimPrefix(line, "Status Text: ")
+		}
+	}
+	if current != nil {
+		entries = append(entries, *current)
+	}
+
+	// newest first
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return &entries
+}
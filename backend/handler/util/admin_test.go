@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"testing"
+)
+
+func TestParseErrLogEntries(t *testing.T) {
+	log_text := `2024/01/01 00:00:00 Err: 400 POST /links 127.0.0.1 60B 1.2ms
+Status Text: {"status":"Invalid request.","error":"no URL provided"}
+
+2024/01/01 00:00:01 Err: 404 GET /pic/x.png 127.0.0.1 58B 1ms
+Status Text: {"status":"Resource not found."}
+
+2024/01/01 00:00:02 Err: 401 POST /tags 127.0.0.1 13B 1ms
+Status Text: Unauthorized
+`
+
+	var test_limits = []struct {
+		Limit              int
+		ExpectedCount      int
+		ExpectedFirstEntry string
+	}{
+		{1, 1, "401 POST /tags 127.0.0.1 13B 1ms"},
+		{2, 2, "401 POST /tags 127.0.0.1 13B 1ms"},
+		{50, 3, "401 POST /tags 127.0.0.1 13B 1ms"},
+	}
+
+	for _, tl := range test_limits {
+		entries := *ParseErrLogEntries(log_text, tl.Limit)
+		if len(entries) != tl.ExpectedCount {
+			t.Fatalf("got %d entries, want %d", len(entries), tl.ExpectedCount)
+		} else if entries[0].Entry != tl.ExpectedFirstEntry {
+			t.Fatalf("got first entry %s, want %s", entries[0].Entry, tl.ExpectedFirstEntry)
+		} else if entries[0].Timestamp != "2024/01/01 00:00:02" {
+			t.Fatalf("got timestamp %s, want 2024/01/01 00:00:02", entries[0].Timestamp)
+		}
+	}
+
+	entries := *ParseErrLogEntries(log_text, 50)
+	if entries[2].StatusText != `{"status":"Invalid request.","error":"no URL provided"}` {
+		t.Fatalf("got status text %s", entries[2].StatusText)
+	}
+
+	// partial first entry from reading log tail
+	entries = *ParseErrLogEntries(`tatus Text: cut off
+`+log_text, 50)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+}
+
+func TestUserIsBanned(t *testing.T) {
+	_, err := TestClient.Exec(
+		`INSERT OR IGNORE INTO Users (id, login_name, password, created, banned_at) VALUES (?,?,?,?,?);`,
+		"banned_test_user",
+		"banned_test_user",
+		"x",
+		"2024-01-01",
+		"2024-01-02 00:00:00",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var test_users = []struct {
+		LoginName string
+		IsBanned  bool
+	}{
+		{test_login_name, false},
+		{"banned_test_user", true},
+		{"nonexistent_user", false},
+	}
+
+	for _, u := range test_users {
+		is_banned, err := UserIsBanned(u.LoginName)
+		if err != nil {
+			t.Fatal(err)
+		} else if is_banned != u.IsBanned {
+			t.Fatalf("user %s: got banned %t, want %t", u.LoginName, is_banned, u.IsBanned)
+		}
+	}
+}
+
+func TestGetUserIDAndRole(t *testing.T) {
+	id, role, err := GetUserIDAndRole(test_login_name)
+	if err != nil {
+		t.Fatal(err)
+	} else if id != test_user_id {
+		t.Fatalf("got id %s, want %s", id, test_user_id)
+	} else if role == "" {
+		t.Fatal("expected role to be set")
+	}
+
+	if _, _, err = GetUserIDAndRole("nonexistent_user"); err == nil {
+		t.Fatal("expected error for nonexistent user")
+	}
+}
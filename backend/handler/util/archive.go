@@ -0,0 +1,675 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/html"
+
+	"github.com/julianlk522/fitm/db"
+	e "github.com/julianlk522/fitm/error"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+	"github.com/julianlk522/fitm/storage"
+	"github.com/julianlk522/fitm/warc"
+)
+
+// (replaced in tests)
+var ArchiveClient = newPublicOnlyClient(mutil.ARCHIVE_TIMEOUT, true)
+
+// tag -> attribute with URL of asset archived with page
+var archive_asset_attrs = map[string]string{
+	"img":    "src",
+	"script": "src",
+	"source": "src",
+	"link":   "href",
+}
+
+// <link rel> values whose href is archived
+var archive_link_rels = []string{"stylesheet", "icon", "shortcut", "apple-touch-icon"}
+
+func LinkSnapshotKey(link_id string, snapshot_id string) string {
+	return link_id + "/" + snapshot_id + ".warc.gz"
+}
+
+// Get snapshots
+// Latest first
+func GetLinkSnapshots(link_id string) ([]model.LinkSnapshot, error) {
+	rows, err := db.Client.Query(
+		`SELECT
+			s.id,
+			s.link_id,
+			s.url,
+			s.status,
+			COALESCE(s.error, ''),
+			COALESCE(s.warc_key, ''),
+			s.size,
+			s.captured_at,
+			(SELECT count(*) FROM "Link Snapshot Records" WHERE snapshot_id = s.id)
+		FROM "Link Snapshots" s
+		WHERE s.link_id = ?
+		ORDER BY s.captured_at DESC;`,
+		link_id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []model.LinkSnapshot{}
+	for rows.Next() {
+		var s model.LinkSnapshot
+		if err := rows.Scan(
+			&s.ID,
+			&s.LinkID,
+			&s.URL,
+			&s.Status,
+			&s.Error,
+			&s.WARCKey,
+			&s.Size,
+			&s.Timestamp,
+			&s.NumRecords,
+		); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// Ready snapshot captured at timestamp ("latest" for most recent)
+// (nil if none)
+func GetReadyLinkSnapshot(link_id string, timestamp string) (*model.LinkSnapshot, error) {
+	snapshots, err := GetLinkSnapshots(link_id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range snapshots {
+		if s.Status == model.LINK_SNAPSHOT_READY &&
+			(timestamp == "latest" || s.Timestamp == timestamp) {
+			return &s, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// (nil if URL not archived in snapshot)
+func GetLinkSnapshotRecord(snapshot_id string, record_url string) (*model.LinkSnapshotRecord, error) {
+	var r model.LinkSnapshotRecord
+	err := db.Client.QueryRow(
+		`SELECT url, content_type, status_code, record_offset, record_length
+		FROM "Link Snapshot Records"
+		WHERE snapshot_id = ? AND url = ?;`,
+		snapshot_id,
+		record_url,
+	).Scan(&r.URL, &r.ContentType, &r.StatusCode, &r.Offset, &r.Length)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func GetLinkSnapshotRecordURLs(snapshot_id string) (map[string]bool, error) {
+	rows, err := db.Client.Query(
+		`SELECT url FROM "Link Snapshot Records" WHERE snapshot_id = ?;`,
+		snapshot_id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := map[string]bool{}
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, err
+		}
+		urls[u] = true
+	}
+
+	return urls, rows.Err()
+}
+
+// Returns archived response body
+func ReadLinkSnapshotRecord(store storage.BlobStore, snapshot *model.LinkSnapshot, record *model.LinkSnapshotRecord) ([]byte, error) {
+	rc, err := store.Get(snapshot.WARCKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	if _, err = io.CopyN(io.Discard, rc, record.Offset); err != nil {
+		return nil, err
+	}
+	warc_record, err := warc.ReadRecord(io.LimitReader(rc, record.Length))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := warc_record.HTTPResponse()
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// Points archived assets' URLs to their replay (?timestamp=...&url=...)
+// and makes other URLs absolute so they lead to the live site.
+// Everything else left as is
+func RewriteSnapshotHTML(body []byte, page_url *url.URL, archived map[string]bool, timestamp string) []byte {
+	var out bytes.Buffer
+	z := html.NewTokenizer(bytes.NewReader(body))
+
+	for {
+		token_type := z.Next()
+		if token_type == html.ErrorToken {
+			return out.Bytes()
+		}
+
+		raw := append([]byte{}, z.Raw()...)
+		if token_type != html.StartTagToken && token_type != html.SelfClosingTagToken {
+			out.Write(raw)
+			continue
+		}
+
+		t := z.Token()
+		rewritten := false
+		for i, attr := range t.Attr {
+			if attr.Key != "src" && attr.Key != "href" {
+				continue
+			}
+
+			// (in-page anchors still work)
+			val := strings.TrimSpace(attr.Val)
+			if strings.HasPrefix(val, "#") {
+				continue
+			}
+			ref, err := page_url.Parse(val)
+			if err != nil || (ref.Scheme != "http" && ref.Scheme != "https") {
+				continue
+			}
+
+			asset_ref := *ref
+			asset_ref.Fragment = ""
+			if archived[asset_ref.String()] && archive_asset_attrs[t.Data] == attr.Key {
+				t.Attr[i].Val = "?" + url.Values{
+					"timestamp": {timestamp},
+					"url":       {asset_ref.String()},
+				}.Encode()
+			} else {
+				t.Attr[i].Val = ref.String()
+			}
+			rewritten = true
+		}
+
+		if rewritten {
+			out.WriteString(t.String())
+		} else {
+			out.Write(raw)
+		}
+	}
+}
+
+// Capture
+type linkToSnapshot struct {
+	ID  string
+	URL string
+}
+
+// Links never archived first, then those archived longest ago
+// (dead links skipped)
+func getLinksDueForSnapshot(limit int) ([]linkToSnapshot, error) {
+	cutoff := time.Now().UTC().Add(-mutil.ARCHIVE_INTERVAL).Format(model.LINK_SNAPSHOT_TIMESTAMP_LAYOUT)
+	rows, err := db.Client.Query(
+		`SELECT l.id, l.url
+		FROM Links l
+		LEFT JOIN (
+			SELECT link_id, max(captured_at) AS last_captured
+			FROM "Link Snapshots"
+			GROUP BY link_id
+		) s ON s.link_id = l.id
+		WHERE (s.last_captured IS NULL OR s.last_captured <= ?)
+		AND l.id NOT IN (
+			SELECT link_id FROM "Link Health" WHERE status = ?
+		)
+		ORDER BY s.last_captured IS NOT NULL, s.last_captured, l.submit_date
+		LIMIT ?;`,
+		cutoff,
+		model.LINK_HEALTH_DEAD,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []linkToSnapshot
+	for rows.Next() {
+		var l linkToSnapshot
+		if err := rows.Scan(&l.ID, &l.URL); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+
+	return links, rows.Err()
+}
+
+// Archives up to limit due links one at a time.
+// Returns number of snapshots captured (including blocked / failed)
+func RunDueLinkSnapshots(store storage.BlobStore, limit int) (int, error) {
+	links, err := getLinksDueForSnapshot(limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var num_captured int
+	for _, l := range links {
+		if _, err = captureLinkSnapshot(store, l.ID, l.URL); err != nil {
+			return num_captured, err
+		}
+		num_captured++
+	}
+
+	return num_captured, nil
+}
+
+// Snapshots link's page and first-party assets into a WARC file.
+// Pages disallowed by robots.txt or over ARCHIVE_MAX_SNAPSHOT_SIZE are
+// saved as blocked / failed snapshots (error only if saving fails)
+func CaptureLinkSnapshot(store storage.BlobStore, link_id string) (*model.LinkSnapshot, error) {
+	var link_url string
+	err := db.Client.QueryRow("SELECT url FROM Links WHERE id = ?;", link_id).Scan(&link_url)
+	if err == sql.ErrNoRows {
+		return nil, e.ErrNoLinkWithID
+	} else if err != nil {
+		return nil, err
+	}
+
+	return captureLinkSnapshot(store, link_id, link_url)
+}
+
+func captureLinkSnapshot(store storage.BlobStore, link_id string, link_url string) (*model.LinkSnapshot, error) {
+	if !strings.Contains(link_url, "://") {
+		link_url = "https://" + link_url
+	}
+
+	snapshot := &model.LinkSnapshot{
+		ID:        uuid.New().String(),
+		LinkID:    link_id,
+		URL:       link_url,
+		Timestamp: time.Now().UTC().Format(model.LINK_SNAPSHOT_TIMESTAMP_LAYOUT),
+	}
+
+	var warc_file bytes.Buffer
+	records, err := captureWARC(&warc_file, link_url)
+	switch {
+	case errors.Is(err, e.ErrDisallowedByRobots):
+		snapshot.Status = model.LINK_SNAPSHOT_BLOCKED
+		snapshot.Error = err.Error()
+	case err != nil:
+		snapshot.Status = model.LINK_SNAPSHOT_FAILED
+		snapshot.Error = err.Error()
+	default:
+		snapshot.Status = model.LINK_SNAPSHOT_READY
+		snapshot.Size = int64(warc_file.Len())
+		snapshot.NumRecords = len(records)
+		snapshot.WARCKey = LinkSnapshotKey(link_id, snapshot.ID)
+		if err = store.Put(snapshot.WARCKey, &warc_file, "application/warc"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = saveLinkSnapshot(snapshot, records); err != nil {
+		return nil, err
+	}
+	if err = pruneLinkSnapshots(store, link_id); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// Writes WARC file with page and its first-party assets allowed by
+// robots.txt (of page's final host after redirects, and of each asset's
+// host), up to ARCHIVE_MAX_SNAPSHOT_SIZE in total
+func captureWARC(w io.Writer, page_url string) ([]model.LinkSnapshotRecord, error) {
+	u, err := url.Parse(page_url)
+	if err != nil {
+		return nil, err
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, InvalidURLError(page_url)
+	}
+
+	// each host's rules fetched once
+	robots_by_host := map[string]*RobotsRules{}
+	robots_allowed := func(target *url.URL) bool {
+		host := target.Scheme + "://" + target.Host
+		robots, ok := robots_by_host[host]
+		if !ok {
+			robots = getRobotsRules(target)
+			robots_by_host[host] = robots
+		}
+		return robots.Allowed(target.RequestURI())
+	}
+
+	if !robots_allowed(u) {
+		return nil, e.ErrDisallowedByRobots
+	}
+
+	resp, body, err := fetchForSnapshot(page_url, mutil.ARCHIVE_MAX_SNAPSHOT_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	// redirected: final page must be allowed by its own host's rules
+	if !robots_allowed(resp.Request.URL) {
+		return nil, e.ErrDisallowedByRobots
+	}
+
+	ww := warc.NewWriter(w)
+	info := warc.NewWarcinfoRecord(
+		map[string]string{
+			"software":   "FITM",
+			"format":     "WARC File Format 1.1",
+			"http-agent": mutil.FITM_BOT_USER_AGENT,
+			"robots":     "obey",
+		},
+		[]string{"software", "format", "http-agent", "robots"},
+	)
+	if _, _, err = ww.WriteRecord(info); err != nil {
+		return nil, err
+	}
+
+	var records []model.LinkSnapshotRecord
+	write := func(target_url string, resp *http.Response, body []byte) error {
+		offset, length, err := ww.WriteRecord(warc.NewResponseRecord(target_url, resp, body, time.Now()))
+		if err != nil {
+			return err
+		}
+
+		records = append(records, model.LinkSnapshotRecord{
+			URL:         target_url,
+			ContentType: resp.Header.Get("Content-Type"),
+			StatusCode:  resp.StatusCode,
+			Offset:      offset,
+			Length:      length,
+		})
+		return nil
+	}
+
+	if err = write(page_url, resp, body); err != nil {
+		return nil, err
+	}
+
+	media_type, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if media_type != "text/html" {
+		return records, nil
+	}
+
+	remaining := mutil.ARCHIVE_MAX_SNAPSHOT_SIZE - int64(len(body))
+	for _, asset_url := range firstPartyAssetURLs(resp.Request.URL, body) {
+		asset, err := url.Parse(asset_url)
+		if err != nil || !robots_allowed(asset) {
+			continue
+		}
+
+		// (too large assets skipped: smaller ones may still fit)
+		asset_resp, asset_body, err := fetchForSnapshot(asset_url, remaining)
+		if err != nil || asset_resp.StatusCode < 200 || asset_resp.StatusCode > 299 {
+			continue
+		}
+
+		if err = write(asset_url, asset_resp, asset_body); err != nil {
+			return nil, err
+		}
+		remaining -= int64(len(asset_body))
+	}
+
+	return records, nil
+}
+
+// Error if body exceeds limit
+func fetchForSnapshot(target_url string, limit int64) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(http.MethodGet, target_url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", mutil.FITM_BOT_USER_AGENT)
+
+	resp, err := ArchiveClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, nil, err
+	} else if int64(len(body)) > limit {
+		return nil, nil, e.ErrSnapshotTooLarge(mutil.ARCHIVE_MAX_SNAPSHOT_SIZE)
+	}
+
+	return resp, body, nil
+}
+
+// Missing robots.txt (4xx) allows everything; unreachable (5xx, network
+// error) disallows everything
+func getRobotsRules(page_url *url.URL) *RobotsRules {
+	robots_url := &url.URL{Scheme: page_url.Scheme, Host: page_url.Host, Path: "/robots.txt"}
+
+	req, err := http.NewRequest(http.MethodGet, robots_url.String(), nil)
+	if err != nil {
+		return DisallowAllRobotsRules
+	}
+	req.Header.Set("User-Agent", mutil.FITM_BOT_USER_AGENT)
+
+	resp, err := ArchiveClient.Do(req)
+	if err != nil {
+		return DisallowAllRobotsRules
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		return ParseRobots(resp.Body)
+	case resp.StatusCode >= 400 && resp.StatusCode <= 499:
+		return AllowAllRobotsRules
+	default:
+		return DisallowAllRobotsRules
+	}
+}
+
+// Stylesheets, icons, scripts and images on page's host (ignoring www.),
+// up to ARCHIVE_MAX_ASSETS
+func firstPartyAssetURLs(page_url *url.URL, body []byte) []string {
+	page_host := strings.TrimPrefix(strings.ToLower(page_url.Hostname()), "www.")
+
+	var asset_urls []string
+	seen := map[string]bool{}
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for len(asset_urls) < mutil.ARCHIVE_MAX_ASSETS {
+		token_type := z.Next()
+		if token_type == html.ErrorToken {
+			break
+		} else if token_type != html.StartTagToken && token_type != html.SelfClosingTagToken {
+			continue
+		}
+
+		t := z.Token()
+		attr_key, ok := archive_asset_attrs[t.Data]
+		if !ok {
+			continue
+		}
+
+		var ref_val string
+		is_archived_rel := t.Data != "link"
+		for _, attr := range t.Attr {
+			switch attr.Key {
+			case attr_key:
+				ref_val = strings.TrimSpace(attr.Val)
+			case "rel":
+				for _, rel := range strings.Fields(strings.ToLower(attr.Val)) {
+					for _, archived_rel := range archive_link_rels {
+						if rel == archived_rel {
+							is_archived_rel = true
+						}
+					}
+				}
+			}
+		}
+		if ref_val == "" || !is_archived_rel {
+			continue
+		}
+
+		ref, err := page_url.Parse(ref_val)
+		if err != nil ||
+			(ref.Scheme != "http" && ref.Scheme != "https") ||
+			strings.TrimPrefix(strings.ToLower(ref.Hostname()), "www.") != page_host {
+			continue
+		}
+		ref.Fragment = ""
+
+		if asset_url := ref.String(); !seen[asset_url] {
+			seen[asset_url] = true
+			asset_urls = append(asset_urls, asset_url)
+		}
+	}
+
+	return asset_urls
+}
+
+func saveLinkSnapshot(snapshot *model.LinkSnapshot, records []model.LinkSnapshotRecord) error {
+	tx, err := db.Client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO "Link Snapshots" VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?);`,
+		snapshot.ID,
+		snapshot.LinkID,
+		snapshot.URL,
+		snapshot.Status,
+		snapshot.Error,
+		snapshot.WARCKey,
+		snapshot.Size,
+		snapshot.Timestamp,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		_, err = tx.Exec(
+			`INSERT INTO "Link Snapshot Records" VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING;`,
+			snapshot.ID,
+			r.URL,
+			r.ContentType,
+			r.StatusCode,
+			r.Offset,
+			r.Length,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Keeps latest ARCHIVE_SNAPSHOTS_PER_LINK ready snapshots and latest
+// blocked / failed one
+func pruneLinkSnapshots(store storage.BlobStore, link_id string) error {
+	snapshots, err := GetLinkSnapshots(link_id)
+	if err != nil {
+		return err
+	}
+
+	var num_ready, num_unready int
+	var to_delete []model.LinkSnapshot
+	for _, s := range snapshots {
+		if s.Status == model.LINK_SNAPSHOT_READY {
+			num_ready++
+			if num_ready > mutil.ARCHIVE_SNAPSHOTS_PER_LINK {
+				to_delete = append(to_delete, s)
+			}
+		} else {
+			num_unready++
+			if num_unready > 1 {
+				to_delete = append(to_delete, s)
+			}
+		}
+	}
+
+	return deleteLinkSnapshots(store, to_delete)
+}
+
+// Removes snapshots of links since deleted
+// (merged links' snapshots belong to the link they were merged into)
+func RemoveOrphanedLinkSnapshots(store storage.BlobStore) error {
+	rows, err := db.Client.Query(
+		`SELECT id, COALESCE(warc_key, '')
+		FROM "Link Snapshots"
+		WHERE link_id NOT IN (SELECT id FROM Links);`,
+	)
+	if err != nil {
+		return err
+	}
+
+	var orphans []model.LinkSnapshot
+	for rows.Next() {
+		var s model.LinkSnapshot
+		if err = rows.Scan(&s.ID, &s.WARCKey); err != nil {
+			rows.Close()
+			return err
+		}
+		orphans = append(orphans, s)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	return deleteLinkSnapshots(store, orphans)
+}
+
+func deleteLinkSnapshots(store storage.BlobStore, snapshots []model.LinkSnapshot) error {
+	for _, s := range snapshots {
+		if s.WARCKey != "" {
+			if err := store.Delete(s.WARCKey); err != nil {
+				return err
+			}
+		}
+
+		for _, stmt := range []string{
+			`DELETE FROM "Link Snapshot Records" WHERE snapshot_id = ?;`,
+			`DELETE FROM "Link Snapshots" WHERE id = ?;`,
+		} {
+			if _, err := db.Client.Exec(stmt, s.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/julianlk522/fitm/model"
+	"github.com/julianlk522/fitm/storage"
+)
+
+const test_archive_page = `<html><head>
+<link rel="stylesheet" href="/style.css">
+<link rel="alternate" href="/feed.xml">
+<script src="/private/app.js"></script>
+<script>if (1 < 2) {}</script>
+</head><body>
+<a href="#top">top</a>
+<a href="/about">about</a>
+<img src="img.png">
+<img src="https://elsewhere.example.com/x.png">
+</body></html>`
+
+func newTestArchiveServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("User-agent: *\nDisallow: /private"))
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(test_archive_page))
+	})
+	mux.HandleFunc("/private/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("disallowed path %s requested", r.URL.Path)
+	})
+	mux.HandleFunc("/style.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		w.Write([]byte("body { color: red; }"))
+	})
+	mux.HandleFunc("/img.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png"))
+	})
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("non-asset link requested")
+	})
+
+	// other host disallowing everything (redirected to)
+	disallowing_server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(test_archive_page))
+	}))
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, disallowing_server.URL+"/page", http.StatusMovedPermanently)
+	})
+
+	server := httptest.NewServer(mux)
+
+	default_client := ArchiveClient
+	ArchiveClient = server.Client()
+	t.Cleanup(func() {
+		ArchiveClient = default_client
+		server.Close()
+		disallowing_server.Close()
+	})
+
+	return server
+}
+
+func TestCaptureLinkSnapshot(t *testing.T) {
+	server := newTestArchiveServer(t)
+	store := storage.NewFSStore(t.TempDir())
+
+	for _, l := range []struct{ ID, URL string }{
+		{"archive_test", server.URL + "/page"},
+		{"archive_test_private", server.URL + "/private/page"},
+		{"archive_test_moved", server.URL + "/moved"},
+	} {
+		_, err := TestClient.Exec(
+			`INSERT INTO Links (id, url, submitted_by, submit_date, global_cats, global_summary, img_url) VALUES (?,?,?,?,?,?,?);`,
+			l.ID, l.URL, test_login_name, "2024-01-01 00:00:00", "archive", "", "",
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// disallowed by robots.txt
+	snapshot, err := CaptureLinkSnapshot(store, "archive_test_private")
+	if err != nil {
+		t.Fatal(err)
+	} else if snapshot.Status != model.LINK_SNAPSHOT_BLOCKED {
+		t.Fatalf("got status %s for disallowed page", snapshot.Status)
+	}
+
+	// redirected to host disallowing it
+	snapshot, err = CaptureLinkSnapshot(store, "archive_test_moved")
+	if err != nil {
+		t.Fatal(err)
+	} else if snapshot.Status != model.LINK_SNAPSHOT_BLOCKED {
+		t.Fatalf("got status %s for page redirected to disallowed host", snapshot.Status)
+	}
+
+	snapshot, err = CaptureLinkSnapshot(store, "archive_test")
+	if err != nil {
+		t.Fatal(err)
+	} else if snapshot.Status != model.LINK_SNAPSHOT_READY {
+		t.Fatalf("got status %s (%s)", snapshot.Status, snapshot.Error)
+	}
+
+	// page and first-party assets allowed by robots.txt
+	archived_urls, err := GetLinkSnapshotRecordURLs(snapshot.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/page", "/style.css", "/img.png"} {
+		if !archived_urls[server.URL+path] {
+			t.Fatalf("%s not archived (archived: %v)", path, archived_urls)
+		}
+	}
+	if len(archived_urls) != 3 {
+		t.Fatalf("expected 3 archived URLs, got %v", archived_urls)
+	}
+
+	latest, err := GetReadyLinkSnapshot("archive_test", "latest")
+	if err != nil {
+		t.Fatal(err)
+	} else if latest == nil || latest.ID != snapshot.ID || latest.NumRecords != 3 {
+		t.Fatalf("got latest snapshot %+v", latest)
+	}
+	if s, err := GetReadyLinkSnapshot("archive_test", "20000101000000"); err != nil {
+		t.Fatal(err)
+	} else if s != nil {
+		t.Fatal("got snapshot for wrong timestamp")
+	}
+
+	record, err := GetLinkSnapshotRecord(snapshot.ID, server.URL+"/style.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ReadLinkSnapshotRecord(store, latest, record)
+	if err != nil {
+		t.Fatal(err)
+	} else if string(body) != "body { color: red; }" || record.ContentType != "text/css" {
+		t.Fatalf("got %s record %q", record.ContentType, body)
+	}
+
+	// deleted link's snapshots removed
+	if _, err = TestClient.Exec(`DELETE FROM Links WHERE id LIKE 'archive_test%';`); err != nil {
+		t.Fatal(err)
+	} else if err = RemoveOrphanedLinkSnapshots(store); err != nil {
+		t.Fatal(err)
+	}
+	if snapshots, err := GetLinkSnapshots("archive_test"); err != nil {
+		t.Fatal(err)
+	} else if len(snapshots) != 0 {
+		t.Fatalf("got %d snapshots of deleted link", len(snapshots))
+	}
+	if exists, err := store.Exists(latest.WARCKey); err != nil {
+		t.Fatal(err)
+	} else if exists {
+		t.Fatal("WARC file of deleted link not removed")
+	}
+}
+
+func TestRewriteSnapshotHTML(t *testing.T) {
+	page_url, _ := url.Parse("https://example.com/blog/page")
+	archived := map[string]bool{
+		"https://example.com/style.css":    true,
+		"https://example.com/blog/img.png": true,
+	}
+
+	rewritten := string(RewriteSnapshotHTML([]byte(test_archive_page), page_url, archived, "20240101000000"))
+
+	for _, want := range []string{
+		`href="?timestamp=20240101000000&amp;url=https%3A%2F%2Fexample.com%2Fstyle.css"`,
+		`src="?timestamp=20240101000000&amp;url=https%3A%2F%2Fexample.com%2Fblog%2Fimg.png"`,
+		// not archived: live site
+		`href="https://example.com/about"`,
+		`src="https://example.com/private/app.js"`,
+		`src="https://elsewhere.example.com/x.png"`,
+		// untouched
+		`<a href="#top">`,
+		`<script>if (1 < 2) {}</script>`,
+	} {
+		if !strings.Contains(rewritten, want) {
+			t.Fatalf("rewritten page missing %s:\n%s", want, rewritten)
+		}
+	}
+}
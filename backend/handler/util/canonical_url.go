@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"database/sql"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/idna"
+
+	"github.com/julianlk522/fitm/db"
+)
+
+// query params only identifying where visitor came from
+var tracking_params = []string{
+	"_ga",
+	"_gl",
+	"_hsenc",
+	"_hsmi",
+	"dclid",
+	"fbclid",
+	"gbraid",
+	"gclid",
+	"igshid",
+	"mc_cid",
+	"mc_eid",
+	"mkt_tok",
+	"msclkid",
+	"oly_anon_id",
+	"oly_enc_id",
+	"ref_src",
+	"twclid",
+	"vero_id",
+	"wbraid",
+	"yclid",
+}
+
+var tracking_param_prefixes = []string{
+	"utm_",
+}
+
+// lowercases and converts IDNs to punycode
+// (lenient about, e.g., underscores in subdomains)
+var host_profile = idna.New(
+	idna.MapForLookup(),
+	idna.StrictDomainName(false),
+	idna.Transitional(false),
+)
+
+// Returns form of URL used to detect duplicate links: https, lowercase
+// punycode host without www. or default port, path without trailing
+// slash, no fragment or tracking params and remaining params sorted.
+// YouTube video URLs are reduced to youtube.com/watch?v={id}
+func CanonicalizeURL(raw_url string) (string, error) {
+	raw_url = strings.TrimSpace(raw_url)
+	if !strings.Contains(raw_url, "://") {
+		raw_url = "https://" + raw_url
+	}
+
+	u, err := url.Parse(raw_url)
+	if err != nil {
+		return "", err
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", InvalidURLError(raw_url)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return "", InvalidURLError(raw_url)
+	}
+	if net.ParseIP(host) == nil {
+		if ascii_host, err := host_profile.ToASCII(host); err == nil {
+			host = ascii_host
+		}
+		host = strings.TrimPrefix(host, "www.")
+	}
+
+	path := strings.TrimRight(u.EscapedPath(), "/")
+	query := u.RawQuery
+
+	switch host {
+	case "youtu.be":
+		host, path, query = "youtube.com", "/watch", "v="+strings.TrimPrefix(path, "/")
+	case "youtube.com", "m.youtube.com":
+		host = "youtube.com"
+		if path == "/watch" {
+			if v := u.Query().Get("v"); v != "" {
+				query = "v=" + url.QueryEscape(v)
+			}
+		}
+	}
+
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") {
+		// IPv6
+		host = "[" + host + "]"
+	}
+
+	canonical_url := "https://" + host + path
+	if query = canonicalizeQuery(query); query != "" {
+		canonical_url += "?" + query
+	}
+
+	return canonical_url, nil
+}
+
+// Removes empty and tracking params and sorts the rest
+// (encoding left as is)
+func canonicalizeQuery(raw_query string) string {
+	var params []string
+	for _, param := range strings.Split(raw_query, "&") {
+		if param == "" {
+			continue
+		}
+
+		key, _, _ := strings.Cut(param, "=")
+		if unescaped_key, err := url.QueryUnescape(key); err == nil {
+			key = unescaped_key
+		}
+		if isTrackingParam(strings.ToLower(key)) {
+			continue
+		}
+
+		params = append(params, param)
+	}
+	slices.Sort(params)
+
+	return strings.Join(params, "&")
+}
+
+func isTrackingParam(key string) bool {
+	if slices.Contains(tracking_params, key) {
+		return true
+	}
+	for _, prefix := range tracking_param_prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns canonical form of page's <link rel="canonical"> href if it
+// points to the same site (cross-site canonicals are ignored since any
+// page could otherwise claim to be a duplicate of another link)
+func CanonicalURLFromLinkTag(page_url *url.URL, href string) (string, bool) {
+	if href == "" {
+		return "", false
+	}
+
+	ref, err := page_url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+
+	canonical_url, err := CanonicalizeURL(ref.String())
+	if err != nil {
+		return "", false
+	}
+	page_canonical_url, err := CanonicalizeURL(page_url.String())
+	if err != nil {
+		return "", false
+	}
+
+	if canonicalURLHost(canonical_url) != canonicalURLHost(page_canonical_url) {
+		return "", false
+	}
+
+	return canonical_url, true
+}
+
+func canonicalURLHost(canonical_url string) string {
+	u, err := url.Parse(canonical_url)
+	if err != nil {
+		return ""
+	}
+
+	return u.Host
+}
+
+// Sets canonical_url for links added before it existed, skipping those
+// whose canonical form another link already has (earliest submitted kept)
+// Returns number of links set and skipped
+func BackfillCanonicalURLs() (int, int, error) {
+	rows, err := db.Client.Query(
+		`SELECT id, url
+		FROM Links
+		WHERE canonical_url IS NULL
+		ORDER BY submit_date, id;`,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	type link_url struct {
+		ID  string
+		URL string
+	}
+	var links []link_url
+	for rows.Next() {
+		var l link_url
+		if err = rows.Scan(&l.ID, &l.URL); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		links = append(links, l)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	var num_set, num_skipped int
+	for _, l := range links {
+		canonical_url, err := CanonicalizeURL(l.URL)
+		if err != nil {
+			num_skipped++
+			continue
+		}
+
+		var res sql.Result
+		res, err = db.Client.Exec(
+			`UPDATE Links
+			SET canonical_url = ?
+			WHERE id = ?
+			AND NOT EXISTS (
+				SELECT 1 FROM Links WHERE canonical_url = ?
+			);`,
+			canonical_url,
+			l.ID,
+			canonical_url,
+		)
+		if err != nil {
+			return num_set, num_skipped, err
+		}
+
+		if num_rows, err := res.RowsAffected(); err != nil {
+			return num_set, num_skipped, err
+		} else if num_rows == 0 {
+			num_skipped++
+		} else {
+			num_set++
+		}
+	}
+
+	return num_set, num_skipped, nil
+}
@@ -0,0 +1,19 @@
+package handler
+
+import (
+	"net/http"
+
+	e "github.com/julianlk522/fitm/error"
+)
+
+// from / to params (see query.GetDateRangeClause)
+// (can't be combined with period)
+func GetDateRangeParams(r *http.Request) (from string, to string, err error) {
+	from = r.URL.Query().Get("from")
+	to = r.URL.Query().Get("to")
+	if (from != "" || to != "") && r.URL.Query().Get("period") != "" {
+		return "", "", e.ErrPeriodWithDateRange
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/julianlk522/fitm/db"
+	e "github.com/julianlk522/fitm/error"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+	"github.com/julianlk522/fitm/query"
+)
+
+// Sets domain for links added before it was stored (empty if URL has none)
+// Returns number of links set
+func BackfillLinkDomains() (int, error) {
+	rows, err := db.Client.Query(`SELECT id, url FROM Links WHERE domain IS NULL;`)
+	if err != nil {
+		return 0, err
+	}
+
+	type link_url struct {
+		ID  string
+		URL string
+	}
+	var links []link_url
+	for rows.Next() {
+		var l link_url
+		if err = rows.Scan(&l.ID, &l.URL); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		links = append(links, l)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.Client.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, l := range links {
+		_, err = tx.Exec(
+			`UPDATE Links SET domain = ? WHERE id = ?;`,
+			mutil.LinkDomain(l.URL),
+			l.ID,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(links), tx.Commit()
+}
+
+// domain param may be any host (reduced to its registrable domain)
+func GetDomainFromParams(domain_params string) (string, error) {
+	if strings.TrimSpace(domain_params) == "" {
+		return "", e.ErrNoDomain
+	}
+
+	domain, ok := mutil.NormalizeDomain(domain_params)
+	if !ok {
+		return "", e.ErrInvalidDomain
+	}
+
+	return domain, nil
+}
+
+func ScanTopDomains(domains_sql *query.TopDomains) (*[]model.DomainCount, error) {
+	if domains_sql.Error != nil {
+		return nil, domains_sql.Error
+	}
+
+	rows, err := db.Client.Query(domains_sql.Text, domains_sql.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []model.DomainCount{}
+	for rows.Next() {
+		var d model.DomainCount
+		if err = rows.Scan(&d.Domain, &d.LinkCount, &d.LikeCount); err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+
+	return &domains, rows.Err()
+}
+
+func DomainHasLinks(domain string) (bool, error) {
+	var has_links bool
+	err := db.Client.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM Links WHERE domain = ?);`,
+		domain,
+	).Scan(&has_links)
+
+	return has_links, err
+}
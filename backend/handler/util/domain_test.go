@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+
+	e "github.com/julianlk522/fitm/error"
+)
+
+func TestBackfillLinkDomains(t *testing.T) {
+	_, err := TestClient.Exec(
+		`INSERT INTO Links (id, url, submitted_by, submit_date, global_cats, global_summary, img_url)
+		VALUES ('domain_backfill', 'https://blog.domain-backfill.co.uk/post', ?, '2024-01-01 00:00:00', 'backfill', '', '');`,
+		test_login_name,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer TestClient.Exec(`DELETE FROM Links WHERE id = 'domain_backfill';`)
+
+	if num_set, err := BackfillLinkDomains(); err != nil {
+		t.Fatal(err)
+	} else if num_set < 1 {
+		t.Fatalf("got %d set, want at least 1", num_set)
+	}
+
+	var domain sql.NullString
+	if err := TestClient.QueryRow(
+		`SELECT domain FROM Links WHERE id = 'domain_backfill';`,
+	).Scan(&domain); err != nil {
+		t.Fatal(err)
+	} else if domain.String != "domain-backfill.co.uk" {
+		t.Fatalf("got domain %q, want domain-backfill.co.uk", domain.String)
+	}
+
+	// none left to set
+	if num_set, err := BackfillLinkDomains(); err != nil {
+		t.Fatal(err)
+	} else if num_set != 0 {
+		t.Fatalf("got %d set on second run, want 0", num_set)
+	}
+}
+
+func TestGetDomainFromParams(t *testing.T) {
+	var test_params = []struct {
+		Params string
+		Want   string
+		Err    error
+	}{
+		{"github.com", "github.com", nil},
+		{" Docs.GitHub.com ", "github.com", nil},
+		{"", "", e.ErrNoDomain},
+		{"github.com/golang", "", e.ErrInvalidDomain},
+		{"%", "", e.ErrInvalidDomain},
+	}
+
+	for _, tp := range test_params {
+		got, err := GetDomainFromParams(tp.Params)
+		if err != tp.Err {
+			t.Fatalf("%q: got error %v, want %v", tp.Params, err, tp.Err)
+		} else if got != tp.Want {
+			t.Fatalf("%q: got %q, want %q", tp.Params, got, tp.Want)
+		}
+	}
+}
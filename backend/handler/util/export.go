@@ -0,0 +1,511 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/julianlk522/fitm/db"
+	e "github.com/julianlk522/fitm/error"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+	"github.com/julianlk522/fitm/query"
+	"github.com/julianlk522/fitm/storage"
+)
+
+// Request data export
+func UserHasActiveDataExport(user_id string) (bool, error) {
+	var is_active bool
+	err := db.Client.QueryRow(
+		`SELECT EXISTS(
+			SELECT 1 FROM "Data Exports"
+			WHERE user_id = ?
+			AND status IN (?,?)
+		);`,
+		user_id,
+		model.DATA_EXPORT_PENDING,
+		model.DATA_EXPORT_RUNNING,
+	).Scan(&is_active)
+	if err != nil {
+		return false, err
+	}
+
+	return is_active, nil
+}
+
+func SaveNewDataExport(user_id string, export *model.DataExport) error {
+	_, err := db.Client.Exec(
+		`INSERT INTO "Data Exports" (id, user_id, status, created) VALUES (?,?,?,?);`,
+		export.ID,
+		user_id,
+		export.Status,
+		export.Created,
+	)
+
+	return err
+}
+
+// Get data export
+// also returns owner's user_id and download token ("" until ready)
+func GetDataExport(export_id string) (*model.DataExport, string, string, error) {
+	var export model.DataExport
+	var user_id string
+	var export_err, completed, expires, token sql.NullString
+	var size sql.NullInt64
+
+	err := db.Client.QueryRow(
+		`SELECT id, user_id, status, error, size, download_token, created, completed, expires
+		FROM "Data Exports"
+		WHERE id = ?;`,
+		export_id,
+	).Scan(
+		&export.ID,
+		&user_id,
+		&export.Status,
+		&export_err,
+		&size,
+		&token,
+		&export.Created,
+		&completed,
+		&expires,
+	)
+	if err == sql.ErrNoRows {
+		return nil, "", "", e.ErrNoDataExportWithID
+	} else if err != nil {
+		return nil, "", "", err
+	}
+
+	export.Error = export_err.String
+	export.Size = size.Int64
+	export.Completed = completed.String
+	export.Expires = expires.String
+
+	if export.Status == model.DATA_EXPORT_READY {
+		export.DownloadURL = "/exports/" + export.ID + "/download?token=" + token.String
+	}
+
+	return &export, user_id, token.String, nil
+}
+
+func DataExportKey(export_id string) string {
+	return export_id + ".zip"
+}
+
+// Run data export
+func RunDataExport(store storage.BlobStore, export_id string) error {
+	var user_id string
+	err := db.Client.QueryRow(
+		`UPDATE "Data Exports"
+		SET status = ?
+		WHERE id = ? AND status = ?
+		RETURNING user_id;`,
+		model.DATA_EXPORT_RUNNING,
+		export_id,
+		model.DATA_EXPORT_PENDING,
+	).Scan(&user_id)
+
+	// already claimed
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	size, err := buildDataExport(store, export_id, user_id)
+	if err != nil {
+		if _, db_err := db.Client.Exec(
+			`UPDATE "Data Exports" SET status = ?, error = ?, completed = ? WHERE id = ?;`,
+			model.DATA_EXPORT_FAILED,
+			err.Error(),
+			mutil.NEW_LONG_TIMESTAMP(),
+			export_id,
+		); db_err != nil {
+			log.Printf("could not mark data export %s failed: %s", export_id, db_err)
+		}
+		return err
+	}
+
+	token, err := newDownloadToken()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Client.Exec(
+		`UPDATE "Data Exports"
+		SET status = ?, size = ?, download_token = ?, completed = ?, expires = ?
+		WHERE id = ?;`,
+		model.DATA_EXPORT_READY,
+		size,
+		token,
+		mutil.NEW_LONG_TIMESTAMP(),
+		time.Now().Add(mutil.DATA_EXPORT_TTL).Format("2006-01-02 15:04:05"),
+		export_id,
+	)
+
+	return err
+}
+
+func buildDataExport(store storage.BlobStore, export_id string, user_id string) (int64, error) {
+	data, err := CollectUserData(user_id)
+	if err != nil {
+		return 0, err
+	}
+
+	var buf bytes.Buffer
+	if err = WriteDataExportZip(&buf, data); err != nil {
+		return 0, err
+	}
+	size := int64(buf.Len())
+
+	if err = store.Put(DataExportKey(export_id), &buf, "application/zip"); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+func newDownloadToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func CollectUserData(user_id string) (*model.UserDataExport, error) {
+	login_name, err := GetLoginNameFromUserID(user_id)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &model.UserDataExport{
+		ExportedAt:   mutil.NEW_LONG_TIMESTAMP(),
+		Tags:         []model.ExportedTag{},
+		Summaries:    []model.ExportedSummary{},
+		LinkLikes:    []model.ExportedLinkLike{},
+		SummaryLikes: []model.ExportedSummaryLike{},
+	}
+
+	data.Profile, err = ScanTmapProfile(query.NewTmapProfile(login_name))
+	if err != nil {
+		return nil, err
+	}
+
+	// tmap links, NSFW included and no settings applied
+	data.Submitted, err = ScanTmapLinks[model.TmapLinkSignedIn](
+		query.NewTmapSubmitted(login_name).AsSignedInUser(user_id).NSFW().Query,
+	)
+	if err != nil {
+		return nil, err
+	}
+	data.Copied, err = ScanTmapLinks[model.TmapLinkSignedIn](
+		query.NewTmapCopied(login_name).AsSignedInUser(user_id).NSFW().Query,
+	)
+	if err != nil {
+		return nil, err
+	}
+	data.Tagged, err = ScanTmapLinks[model.TmapLinkSignedIn](
+		query.NewTmapTagged(login_name).AsSignedInUser(user_id).NSFW().Query,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// tags
+	rows, err := db.Client.Query(
+		`SELECT t.id, t.link_id, l.url, t.cats, t.last_updated
+		FROM Tags t
+		INNER JOIN Links l ON l.id = t.link_id
+		WHERE t.submitted_by = ?
+		ORDER BY t.last_updated DESC;`,
+		login_name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var t model.ExportedTag
+		if err = rows.Scan(&t.ID, &t.LinkID, &t.URL, &t.Cats, &t.LastUpdated); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		data.Tags = append(data.Tags, t)
+	}
+	rows.Close()
+
+	// summaries
+	rows, err = db.Client.Query(
+		`SELECT s.id, s.link_id, l.url, s.text, s.last_updated
+		FROM Summaries s
+		INNER JOIN Links l ON l.id = s.link_id
+		WHERE s.submitted_by = ?
+		ORDER BY s.last_updated DESC;`,
+		user_id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var s model.ExportedSummary
+		if err = rows.Scan(&s.ID, &s.LinkID, &s.URL, &s.Text, &s.LastUpdated); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		data.Summaries = append(data.Summaries, s)
+	}
+	rows.Close()
+
+	// link likes
+	rows, err = db.Client.Query(
+		`SELECT ll.link_id, l.url
+		FROM "Link Likes" ll
+		INNER JOIN Links l ON l.id = ll.link_id
+		WHERE ll.user_id = ?;`,
+		user_id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var ll model.ExportedLinkLike
+		if err = rows.Scan(&ll.LinkID, &ll.URL); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		data.LinkLikes = append(data.LinkLikes, ll)
+	}
+	rows.Close()
+
+	// summary likes
+	rows, err = db.Client.Query(
+		`SELECT sl.summary_id, s.link_id, l.url, s.text
+		FROM "Summary Likes" sl
+		INNER JOIN Summaries s ON s.id = sl.summary_id
+		INNER JOIN Links l ON l.id = s.link_id
+		WHERE sl.user_id = ?;`,
+		user_id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var sl model.ExportedSummaryLike
+		if err = rows.Scan(&sl.SummaryID, &sl.LinkID, &sl.URL, &sl.Text); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		data.SummaryLikes = append(data.SummaryLikes, sl)
+	}
+	rows.Close()
+
+	return data, nil
+}
+
+// data.json with everything, plus one CSV per section
+func WriteDataExportZip(w io.Writer, data *model.UserDataExport) error {
+	zw := zip.NewWriter(w)
+
+	json_file, err := zw.Create("data.json")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(json_file)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(data); err != nil {
+		return err
+	}
+
+	profile_records := [][]string{{
+		data.Profile.LoginName,
+		data.Profile.About,
+		data.Profile.PFP,
+		data.Profile.Created,
+	}}
+	if err = writeCSV(zw, "profile.csv", []string{"login_name", "about", "pfp", "created"}, profile_records); err != nil {
+		return err
+	}
+
+	for name, links := range map[string]*[]model.TmapLinkSignedIn{
+		"submitted_links.csv": data.Submitted,
+		"copied_links.csv":    data.Copied,
+		"tagged_links.csv":    data.Tagged,
+	} {
+		if err = writeCSV(zw, name, TMAP_LINK_CSV_HEADER, tmapLinkCSVRecords(links)); err != nil {
+			return err
+		}
+	}
+
+	var records [][]string
+	for _, t := range data.Tags {
+		records = append(records, []string{t.ID, t.LinkID, t.URL, t.Cats, t.LastUpdated})
+	}
+	if err = writeCSV(zw, "tags.csv", []string{"id", "link_id", "url", "cats", "last_updated"}, records); err != nil {
+		return err
+	}
+
+	records = nil
+	for _, s := range data.Summaries {
+		records = append(records, []string{s.ID, s.LinkID, s.URL, s.Text, s.LastUpdated})
+	}
+	if err = writeCSV(zw, "summaries.csv", []string{"id", "link_id", "url", "text", "last_updated"}, records); err != nil {
+		return err
+	}
+
+	records = nil
+	for _, ll := range data.LinkLikes {
+		records = append(records, []string{ll.LinkID, ll.URL})
+	}
+	if err = writeCSV(zw, "link_likes.csv", []string{"link_id", "url"}, records); err != nil {
+		return err
+	}
+
+	records = nil
+	for _, sl := range data.SummaryLikes {
+		records = append(records, []string{sl.SummaryID, sl.LinkID, sl.URL, sl.Text})
+	}
+	if err = writeCSV(zw, "summary_likes.csv", []string{"summary_id", "link_id", "url", "text"}, records); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+var TMAP_LINK_CSV_HEADER = []string{
+	"id",
+	"url",
+	"submitted_by",
+	"submit_date",
+	"cats",
+	"cats_from_user",
+	"summary",
+	"summary_count",
+	"like_count",
+	"tag_count",
+	"img_url",
+	"is_liked",
+	"is_copied",
+}
+
+func tmapLinkCSVRecords(links *[]model.TmapLinkSignedIn) [][]string {
+	var records [][]string
+	for _, l := range *links {
+		records = append(records, []string{
+			l.ID,
+			l.URL,
+			l.SubmittedBy,
+			l.SubmitDate,
+			l.Cats,
+			strconv.FormatBool(l.CatsFromUser),
+			l.Summary,
+			strconv.Itoa(l.SummaryCount),
+			strconv.FormatInt(l.LikeCount, 10),
+			strconv.Itoa(l.TagCount),
+			l.ImgURL,
+			strconv.FormatBool(l.IsLiked),
+			strconv.FormatBool(l.IsCopied),
+		})
+	}
+
+	return records
+}
+
+func writeCSV(zw *zip.Writer, name string, header []string, records [][]string) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(f)
+	if err = cw.Write(header); err != nil {
+		return err
+	}
+	if err = cw.WriteAll(records); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+// Data export worker
+// pending exports in creation order
+func GetPendingDataExportIDs() ([]string, error) {
+	rows, err := db.Client.Query(
+		`SELECT id FROM "Data Exports" WHERE status = ? ORDER BY created;`,
+		model.DATA_EXPORT_PENDING,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// exports left running by a previous process are retried
+func RequeueInterruptedDataExports() error {
+	_, err := db.Client.Exec(
+		`UPDATE "Data Exports" SET status = ? WHERE status = ?;`,
+		model.DATA_EXPORT_PENDING,
+		model.DATA_EXPORT_RUNNING,
+	)
+
+	return err
+}
+
+// removes zips past their expiry
+func ExpireDataExports(store storage.BlobStore) error {
+	rows, err := db.Client.Query(
+		`SELECT id FROM "Data Exports" WHERE status = ? AND expires <= ?;`,
+		model.DATA_EXPORT_READY,
+		mutil.NEW_LONG_TIMESTAMP(),
+	)
+	if err != nil {
+		return err
+	}
+
+	var expired_ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		expired_ids = append(expired_ids, id)
+	}
+	rows.Close()
+
+	for _, id := range expired_ids {
+		if err = store.Delete(DataExportKey(id)); err != nil {
+			return err
+		}
+
+		_, err = db.Client.Exec(
+			`UPDATE "Data Exports" SET status = ?, download_token = NULL WHERE id = ?;`,
+			model.DATA_EXPORT_EXPIRED,
+			id,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/julianlk522/fitm/model"
+	"github.com/julianlk522/fitm/storage"
+)
+
+func TestWriteDataExportZip(t *testing.T) {
+	data, err := CollectUserData(test_user_id)
+	if err != nil {
+		t.Fatal(err)
+	} else if data.Profile.LoginName != test_login_name {
+		t.Fatalf("got profile %s, want %s", data.Profile.LoginName, test_login_name)
+	}
+
+	var buf bytes.Buffer
+	if err = WriteDataExportZip(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// CSV rows (excluding header) per file
+	var want_rows = map[string]int{
+		"data.json":           -1,
+		"profile.csv":         1,
+		"submitted_links.csv": len(*data.Submitted),
+		"copied_links.csv":    len(*data.Copied),
+		"tagged_links.csv":    len(*data.Tagged),
+		"tags.csv":            len(data.Tags),
+		"summaries.csv":       len(data.Summaries),
+		"link_likes.csv":      len(data.LinkLikes),
+		"summary_likes.csv":   len(data.SummaryLikes),
+	}
+	if len(zr.File) != len(want_rows) {
+		t.Fatalf("got %d files, want %d", len(zr.File), len(want_rows))
+	}
+
+	for _, f := range zr.File {
+		rows, ok := want_rows[f.Name]
+		if !ok {
+			t.Fatalf("unexpected file %s", f.Name)
+		} else if rows == -1 {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(rc).ReadAll()
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		} else if len(records)-1 != rows {
+			t.Fatalf("%s: got %d rows, want %d", f.Name, len(records)-1, rows)
+		}
+	}
+}
+
+func TestRunDataExport(t *testing.T) {
+	store := storage.NewFSStore(t.TempDir())
+
+	export := model.NewDataExport()
+	if err := SaveNewDataExport(test_req_user_id, export); err != nil {
+		t.Fatal(err)
+	}
+	if is_active, err := UserHasActiveDataExport(test_req_user_id); err != nil {
+		t.Fatal(err)
+	} else if !is_active {
+		t.Fatal("expected pending export to be active")
+	}
+
+	if err := RunDataExport(store, export.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	ready, user_id, token, err := GetDataExport(export.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if ready.Status != model.DATA_EXPORT_READY {
+		t.Fatalf("got status %s, want %s (%s)", ready.Status, model.DATA_EXPORT_READY, ready.Error)
+	} else if user_id != test_req_user_id || token == "" || ready.DownloadURL == "" {
+		t.Fatalf("got user %s, token %q, URL %q", user_id, token, ready.DownloadURL)
+	}
+
+	if exists, err := store.Exists(DataExportKey(export.ID)); err != nil || !exists {
+		t.Fatalf("expected zip to exist (err %v)", err)
+	}
+
+	// already claimed: no-op
+	if err = RunDataExport(store, export.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// expire
+	if _, err = TestClient.Exec(
+		`UPDATE "Data Exports" SET expires = '2000-01-01 00:00:00' WHERE id = ?;`,
+		export.ID,
+	); err != nil {
+		t.Fatal(err)
+	}
+	if err = ExpireDataExports(store); err != nil {
+		t.Fatal(err)
+	}
+
+	expired, _, token, err := GetDataExport(export.ID)
+	if err != nil {
+		t.Fatal(err)
+	} else if expired.Status != model.DATA_EXPORT_EXPIRED || token != "" {
+		t.Fatalf("got status %s, token %q, want expired with no token", expired.Status, token)
+	}
+	if exists, _ := store.Exists(DataExportKey(export.ID)); exists {
+		t.Fatal("expected zip to be removed")
+	}
+}
@@ -0,0 +1,421 @@
+package handler
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/julianlk522/fitm/db"
+	e "github.com/julianlk522/fitm/error"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+	"github.com/julianlk522/fitm/query"
+)
+
+const (
+	FEED_FORMAT_ATOM = "atom"
+	FEED_FORMAT_RSS  = "rss"
+	FEED_FORMAT_JSON = "json"
+)
+
+type feedFormat struct {
+	ContentType string
+	Write       func(io.Writer, *model.Feed) error
+}
+
+var feed_formats = map[string]feedFormat{
+	FEED_FORMAT_ATOM: {"application/atom+xml; charset=utf-8", writeAtomFeed},
+	FEED_FORMAT_RSS:  {"application/rss+xml; charset=utf-8", writeRSSFeed},
+	FEED_FORMAT_JSON: {"application/feed+json; charset=utf-8", writeJSONFeed},
+}
+
+func FeedFormats() []string {
+	formats := []string{}
+	for format := range feed_formats {
+		formats = append(formats, format)
+	}
+	slices.Sort(formats)
+
+	return formats
+}
+
+func FeedContentType(format string) (string, error) {
+	f, ok := feed_formats[format]
+	if !ok {
+		return "", e.UnsupportedFeedFormat(format, FeedFormats())
+	}
+
+	return f.ContentType, nil
+}
+
+func WriteFeed(w io.Writer, format string, feed *model.Feed) error {
+	f, ok := feed_formats[format]
+	if !ok {
+		return e.UnsupportedFeedFormat(format, FeedFormats())
+	}
+
+	return f.Write(w, feed)
+}
+
+// Links feed
+// newest links, optionally only those with all of cats
+func GetLinksFeed(cats []string, nsfw bool) (*model.Feed, error) {
+	feed := &model.Feed{
+		Title:   "FITM: new links",
+		HomeURL: mutil.FITM_SITE_URL + "/top?sort_by=newest",
+	}
+
+	links_sql := query.NewTopLinks()
+	if len(cats) > 0 {
+		feed.Title = "FITM: new links in " + strings.Join(cats, ", ")
+		feed.HomeURL += "&cats=" + url.QueryEscape(strings.Join(cats, ","))
+
+		// FromCats escapes in place
+		escaped_cats := make([]string, len(cats))
+		copy(escaped_cats, cats)
+		links_sql = links_sql.FromCats(escaped_cats)
+	}
+	links_sql = links_sql.SortBy("newest")
+	if nsfw {
+		links_sql = links_sql.NSFW()
+	}
+	if links_sql.Error != nil {
+		return nil, links_sql.Error
+	}
+
+	links, err := ScanLinks[model.Link](links_sql)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range *links {
+		feed.Entries = append(feed.Entries, model.FeedEntry{
+			LinkID:      l.ID,
+			URL:         l.URL,
+			Summary:     l.Summary,
+			Cats:        feedEntryCats(l.Cats),
+			SubmittedBy: l.SubmittedBy,
+			ImgURL:      l.ImgURL,
+			Updated:     parseFeedTimestamp(l.SubmitDate),
+		})
+	}
+	setFeedUpdated(feed)
+
+	return feed, nil
+}
+
+// Tmap feed
+func GetTmapFeed(login_name string, nsfw bool) (*model.Feed, error) {
+	feed := &model.Feed{
+		Title:   "FITM: " + login_name + "'s treasure map",
+		HomeURL: mutil.FITM_SITE_URL + "/map/" + url.PathEscape(login_name),
+	}
+
+	activity_sql := query.NewTmapActivity(login_name)
+	if nsfw {
+		activity_sql = activity_sql.NSFW()
+	}
+
+	rows, err := db.Client.Query(activity_sql.Text, activity_sql.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry model.FeedEntry
+		var cats, activity_date string
+		if err = rows.Scan(
+			&entry.LinkID,
+			&entry.URL,
+			&entry.SubmittedBy,
+			&cats,
+			&entry.Summary,
+			&entry.ImgURL,
+			&activity_date,
+		); err != nil {
+			return nil, err
+		}
+		entry.Cats = feedEntryCats(cats)
+		entry.Updated = parseFeedTimestamp(activity_date)
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	setFeedUpdated(feed)
+
+	return feed, nil
+}
+
+func feedEntryCats(cats string) []string {
+	if cats == "" {
+		return nil
+	}
+
+	return strings.Split(cats, ",")
+}
+
+// DB timestamps are local time (see NEW_LONG_TIMESTAMP)
+func parseFeedTimestamp(timestamp string) time.Time {
+	for _, layout := range []string{"2006-01-02 15:04:05", time.RFC3339, "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, timestamp, time.Local); err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func setFeedUpdated(feed *model.Feed) {
+	for _, entry := range feed.Entries {
+		if entry.Updated.After(feed.Updated) {
+			feed.Updated = entry.Updated
+		}
+	}
+}
+
+// links have no title: summary if any, else URL
+func feedEntryTitle(entry model.FeedEntry) string {
+	if entry.Summary != "" {
+		return entry.Summary
+	}
+
+	return entry.URL
+}
+
+func feedEntryPermalink(entry model.FeedEntry) string {
+	return mutil.FITM_SITE_URL + "/summary/" + entry.LinkID
+}
+
+func feedUserURL(login_name string) string {
+	return mutil.FITM_SITE_URL + "/map/" + url.PathEscape(login_name)
+}
+
+// Atom (RFC 4287)
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Links      []atomLink     `xml:"link"`
+	Author     atomAuthor     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+func writeAtomFeed(w io.Writer, feed *model.Feed) error {
+	doc := atomFeed{
+		Title:   feed.Title,
+		ID:      feed.HomeURL,
+		Updated: feed.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Href: feed.SelfURL},
+			{Rel: "alternate", Href: feed.HomeURL},
+		},
+	}
+
+	for _, entry := range feed.Entries {
+		atom_entry := atomEntry{
+			Title:   feedEntryTitle(entry),
+			ID:      feedEntryPermalink(entry),
+			Updated: entry.Updated.UTC().Format(time.RFC3339),
+			Links: []atomLink{
+				{Rel: "alternate", Href: entry.URL},
+				{Rel: "related", Href: feedEntryPermalink(entry)},
+			},
+			Author: atomAuthor{
+				Name: entry.SubmittedBy,
+				URI:  feedUserURL(entry.SubmittedBy),
+			},
+			Summary: entry.Summary,
+		}
+		if entry.ImgURL != "" {
+			atom_entry.Links = append(atom_entry.Links, atomLink{Rel: "enclosure", Href: entry.ImgURL})
+		}
+		for _, cat := range entry.Cats {
+			atom_entry.Categories = append(atom_entry.Categories, atomCategory{Term: cat})
+		}
+
+		doc.Entries = append(doc.Entries, atom_entry)
+	}
+
+	return writeXMLFeed(w, doc)
+}
+
+// RSS 2.0
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	MediaNS string     `xml:"xmlns:media,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	SelfLink      rssLink   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string       `xml:"title"`
+	Link        string       `xml:"link"`
+	GUID        string       `xml:"guid"`
+	PubDate     string       `xml:"pubDate"`
+	Creator     string       `xml:"dc:creator"`
+	Categories  []string     `xml:"category"`
+	Description string       `xml:"description,omitempty"`
+	Thumbnail   *rssMediaURL `xml:"media:thumbnail,omitempty"`
+}
+
+type rssMediaURL struct {
+	URL string `xml:"url,attr"`
+}
+
+func writeRSSFeed(w io.Writer, feed *model.Feed) error {
+	doc := rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		MediaNS: "http://search.yahoo.com/mrss/",
+		Channel: rssChannel{
+			Title:       feed.Title,
+			Link:        feed.HomeURL,
+			Description: feed.Title,
+			SelfLink: rssLink{
+				Rel:  "self",
+				Href: feed.SelfURL,
+				Type: "application/rss+xml",
+			},
+		},
+	}
+	if !feed.Updated.IsZero() {
+		doc.Channel.LastBuildDate = feed.Updated.Format(time.RFC1123Z)
+	}
+
+	for _, entry := range feed.Entries {
+		item := rssItem{
+			Title:       feedEntryTitle(entry),
+			Link:        entry.URL,
+			GUID:        feedEntryPermalink(entry),
+			PubDate:     entry.Updated.Format(time.RFC1123Z),
+			Creator:     entry.SubmittedBy,
+			Categories:  entry.Cats,
+			Description: entry.Summary,
+		}
+		if entry.ImgURL != "" {
+			item.Thumbnail = &rssMediaURL{URL: entry.ImgURL}
+		}
+
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	return writeXMLFeed(w, doc)
+}
+
+func writeXMLFeed(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// JSON Feed 1.1 (https://www.jsonfeed.org/version/1.1/)
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID           string           `json:"id"`
+	URL          string           `json:"url"`
+	Title        string           `json:"title"`
+	ContentText  string           `json:"content_text"`
+	Image        string           `json:"image,omitempty"`
+	DateModified string           `json:"date_modified"`
+	Tags         []string         `json:"tags,omitempty"`
+	Authors      []jsonFeedAuthor `json:"authors"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+func writeJSONFeed(w io.Writer, feed *model.Feed) error {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       feed.Title,
+		HomePageURL: feed.HomeURL,
+		FeedURL:     feed.SelfURL,
+		Items:       []jsonFeedItem{},
+	}
+
+	for _, entry := range feed.Entries {
+		doc.Items = append(doc.Items, jsonFeedItem{
+			ID:           feedEntryPermalink(entry),
+			URL:          entry.URL,
+			Title:        feedEntryTitle(entry),
+			ContentText:  entry.Summary,
+			Image:        entry.ImgURL,
+			DateModified: entry.Updated.UTC().Format(time.RFC3339),
+			Tags:         entry.Cats,
+			Authors: []jsonFeedAuthor{{
+				Name: entry.SubmittedBy,
+				URL:  feedUserURL(entry.SubmittedBy),
+			}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
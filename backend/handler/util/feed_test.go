@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/julianlk522/fitm/model"
+)
+
+func TestGetLinksFeed(t *testing.T) {
+	var test_feeds = []struct {
+		Cats      []string
+		NSFW      bool
+		WantURL   string
+		WantNoURL string
+	}{
+		{nil, false, "https://github.com/golang/go", "https://nsfw.example.com"},
+		{nil, true, "https://nsfw.example.com", ""},
+		{[]string{"umvc3"}, false, "https://www.example.com", "https://github.com/golang/go"},
+	}
+
+	for _, tf := range test_feeds {
+		feed, err := GetLinksFeed(tf.Cats, tf.NSFW)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		found, found_excluded := false, false
+		for _, entry := range feed.Entries {
+			found = found || entry.URL == tf.WantURL
+			found_excluded = found_excluded || entry.URL == tf.WantNoURL
+		}
+		if !found || found_excluded {
+			t.Fatalf("%+v: got entries %+v", tf, feed.Entries)
+		}
+	}
+}
+
+func TestGetTmapFeed(t *testing.T) {
+	feed, err := GetTmapFeed("test_req_login_name", false)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(feed.Entries) == 0 || feed.Entries[0].URL != "https://github.com/golang/go" {
+		t.Fatalf("got entries %+v", feed.Entries)
+	} else if feed.Updated.IsZero() {
+		t.Fatal("expected feed updated time")
+	}
+
+	// cats as categories
+	if cats := feed.Entries[0].Cats; len(cats) != 2 || cats[0] != "go" {
+		t.Fatalf("got cats %v", cats)
+	}
+}
+
+func TestWriteFeed(t *testing.T) {
+	feed := &model.Feed{
+		Title:   "test feed",
+		HomeURL: "https://fitm.online/top",
+		SelfURL: "https://api.fitm.online/feeds/links",
+		Entries: []model.FeedEntry{{
+			LinkID:      "1",
+			URL:         "https://www.example.com/?a=1&b=2",
+			Summary:     "a <summary>",
+			Cats:        []string{"umvc3", "flowers"},
+			SubmittedBy: "jlk",
+			ImgURL:      "https://www.example.com/img.png",
+			Updated:     parseFeedTimestamp("2024-01-01 00:00:00"),
+		}},
+	}
+
+	for _, format := range FeedFormats() {
+		var buf bytes.Buffer
+		if err := WriteFeed(&buf, format, feed); err != nil {
+			t.Fatal(err)
+		}
+
+		switch format {
+		case FEED_FORMAT_ATOM:
+			var doc atomFeed
+			if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			} else if len(doc.Entries) != 1 || len(doc.Entries[0].Categories) != 2 || doc.Entries[0].Summary != "a <summary>" {
+				t.Fatalf("got atom %+v", doc)
+			}
+		case FEED_FORMAT_RSS:
+			var doc rssFeed
+			if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			} else if len(doc.Channel.Items) != 1 || doc.Channel.Items[0].Link != "https://www.example.com/?a=1&b=2" {
+				t.Fatalf("got rss %+v", doc)
+			}
+		case FEED_FORMAT_JSON:
+			var doc jsonFeed
+			if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+				t.Fatal(err)
+			} else if len(doc.Items) != 1 || doc.Items[0].Image != "https://www.example.com/img.png" || doc.Items[0].Authors[0].Name != "jlk" {
+				t.Fatalf("got json feed %+v", doc)
+			}
+		}
+	}
+
+	if err := WriteFeed(&bytes.Buffer{}, "xml", feed); err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+}
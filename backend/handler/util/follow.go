@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/julianlk522/fitm/db"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+	"github.com/julianlk522/fitm/query"
+)
+
+// Follow / unfollow
+func UserFollows(user_id string, followed_id string) (bool, error) {
+	var follows bool
+	err := db.Client.QueryRow(
+		`SELECT EXISTS(
+			SELECT 1 FROM "User Follows" WHERE user_id = ? AND followed_id = ?
+		);`,
+		user_id,
+		followed_id,
+	).Scan(&follows)
+	if err != nil {
+		return false, err
+	}
+
+	return follows, nil
+}
+
+func UserHasBlockedUser(user_id string, blocked_id string) (bool, error) {
+	_, is_blocked, err := GetMuteStatus(user_id, blocked_id)
+	return is_blocked, err
+}
+
+func FollowUser(user_id string, followed_id string) error {
+	_, err := db.Client.Exec(
+		`INSERT INTO "User Follows" VALUES (?,?,?,?)
+		ON CONFLICT(user_id, followed_id) DO NOTHING;`,
+		uuid.New().String(),
+		user_id,
+		followed_id,
+		mutil.NEW_LONG_TIMESTAMP(),
+	)
+
+	return err
+}
+
+func UnfollowUser(user_id string, followed_id string) error {
+	_, err := db.Client.Exec(
+		`DELETE FROM "User Follows" WHERE user_id = ? AND followed_id = ?;`,
+		user_id,
+		followed_id,
+	)
+
+	return err
+}
+
+// Following feed
+func ScanFollowingFeed(feed_sql *query.FollowingFeed) (*[]model.FollowingFeedLink, error) {
+	rows, err := db.Client.Query(feed_sql.Text, feed_sql.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []model.FollowingFeedLink{}
+	for rows.Next() {
+		var l model.FollowingFeedLink
+		if err := rows.Scan(
+			&l.ID,
+			&l.URL,
+			&l.SubmittedBy,
+			&l.SubmitDate,
+			&l.Cats,
+			&l.Summary,
+			&l.SummaryCount,
+			&l.TagCount,
+			&l.LikeCount,
+			&l.ImgURL,
+			&l.HealthStatus,
+			&l.IsLiked,
+			&l.IsCopied,
+			&l.ActivityBy,
+			&l.Activity,
+			&l.ActivityDate,
+		); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+
+	return &links, nil
+}
+
+func PaginateFollowingFeed(links *[]model.FollowingFeedLink, page int) *model.PaginatedFollowingFeed {
+	if len(*links) == query.LINKS_PAGE_LIMIT+1 {
+		sliced := (*links)[0:query.LINKS_PAGE_LIMIT]
+		return &model.PaginatedFollowingFeed{
+			Links:    &sliced,
+			NextPage: page + 1,
+		}
+	}
+
+	return &model.PaginatedFollowingFeed{
+		Links:    links,
+		NextPage: -1,
+	}
+}
@@ -0,0 +1,383 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/julianlk522/fitm/db"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+// (replaced in tests)
+var LinkHealthClient = newPublicOnlyClient(mutil.LINK_HEALTH_TIMEOUT, true)
+
+// hosts that answered 429 / 503 -> time to resume checking their links
+var link_health_host_backoff = struct {
+	sync.Mutex
+	until map[string]time.Time
+}{until: map[string]time.Time{}}
+
+// Get health
+func GetLinkHealth(link_id string) (*model.LinkHealth, error) {
+	health := &model.LinkHealth{
+		LinkID: link_id,
+		Checks: []model.LinkHealthCheck{},
+	}
+	err := db.Client.QueryRow(
+		`SELECT status, consecutive_failures, last_checked, next_check
+		FROM "Link Health"
+		WHERE link_id = ?;`,
+		link_id,
+	).Scan(
+		&health.Status,
+		&health.ConsecutiveFailures,
+		&health.LastChecked,
+		&health.NextCheck,
+	)
+	// never checked
+	if err == sql.ErrNoRows {
+		return health, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Client.Query(
+		`SELECT status, COALESCE(response_code, 0), checked_at
+		FROM "Link Health Checks"
+		WHERE link_id = ?
+		ORDER BY checked_at DESC, rowid DESC;`,
+		link_id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c model.LinkHealthCheck
+		if err := rows.Scan(&c.Status, &c.ResponseCode, &c.CheckedAt); err != nil {
+			return nil, err
+		}
+		health.Checks = append(health.Checks, c)
+	}
+
+	return health, rows.Err()
+}
+
+func FilterDeadTmapLinks[T model.TmapLink | model.TmapLinkSignedIn](links *[]T) *[]T {
+	filtered := []T{}
+	for _, link := range *links {
+		var status string
+		switch l := any(link).(type) {
+		case model.TmapLinkSignedIn:
+			status = l.HealthStatus
+		case model.TmapLink:
+			status = l.HealthStatus
+		}
+
+		if status != model.LINK_HEALTH_DEAD {
+			filtered = append(filtered, link)
+		}
+	}
+
+	return &filtered
+}
+
+// Run checks
+type dueLinkHealthCheck struct {
+	LinkID string
+	URL    string
+}
+
+// Links never checked come first, then those due longest
+// Links of backed-off hosts are left out (still due once backoff ends) so
+// they can't fill every batch
+func getDueLinkHealthChecks(limit int) ([]dueLinkHealthCheck, error) {
+	rows, err := db.Client.Query(
+		`SELECT l.id, l.url
+		FROM Links l
+		LEFT JOIN "Link Health" lh ON lh.link_id = l.id
+		WHERE lh.next_check IS NULL OR lh.next_check <= ?
+		ORDER BY lh.next_check IS NOT NULL, lh.next_check, l.submit_date;`,
+		mutil.NEW_LONG_TIMESTAMP(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checks []dueLinkHealthCheck
+	for len(checks) < limit && rows.Next() {
+		var c dueLinkHealthCheck
+		if err := rows.Scan(&c.LinkID, &c.URL); err != nil {
+			return nil, err
+		}
+		if linkHealthHostBackedOff(linkHealthHost(c.URL)) {
+			continue
+		}
+		checks = append(checks, c)
+	}
+
+	return checks, rows.Err()
+}
+
+// Checks up to limit due links: links of the same host one at a time
+// LINK_HEALTH_HOST_DELAY apart, up to LINK_HEALTH_MAX_CONCURRENT_HOSTS
+// hosts at once. Hosts answering 429 / 503 are skipped for
+// LINK_HEALTH_HOST_BACKOFF (their links stay due but aren't selected).
+// Returns number of links checked
+func RunLinkHealthChecks(limit int) (int, error) {
+	due, err := getDueLinkHealthChecks(limit)
+	if err != nil {
+		return 0, err
+	}
+
+	var hosts []string
+	checks_by_host := map[string][]dueLinkHealthCheck{}
+	for _, c := range due {
+		host := linkHealthHost(c.URL)
+		if _, ok := checks_by_host[host]; !ok {
+			hosts = append(hosts, host)
+		}
+		checks_by_host[host] = append(checks_by_host[host], c)
+	}
+
+	var (
+		mu          sync.Mutex
+		wg          sync.WaitGroup
+		num_checked int
+		errs        []error
+	)
+	sem := make(chan struct{}, mutil.LINK_HEALTH_MAX_CONCURRENT_HOSTS)
+
+	for _, host := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(host string, checks []dueLinkHealthCheck) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			for i, c := range checks {
+				if i > 0 {
+					time.Sleep(mutil.LINK_HEALTH_HOST_DELAY)
+				}
+
+				status, response_code, retry_later := CheckLinkHealth(c.URL)
+				if retry_later {
+					backOffLinkHealthHost(host)
+					return
+				}
+
+				err := RecordLinkHealthCheck(c.LinkID, status, response_code, mutil.NEW_LONG_TIMESTAMP())
+
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				} else {
+					num_checked++
+				}
+				mu.Unlock()
+			}
+		}(host, checks_by_host[host])
+	}
+	wg.Wait()
+
+	return num_checked, errors.Join(errs...)
+}
+
+// Requests URL as FITM-Bot and classifies response (see LINK_HEALTH_*).
+// 401 / 403 etc. count as ok since the page exists but blocks bots.
+// retry_later if host is rate limiting or temporarily unavailable
+// (nothing should be recorded)
+func CheckLinkHealth(link_url string) (status string, response_code int, retry_later bool) {
+	full_url := link_url
+	if !strings.Contains(full_url, "://") {
+		full_url = "https://" + full_url
+	}
+
+	req, err := http.NewRequest(http.MethodGet, full_url, nil)
+	if err != nil {
+		return model.LINK_HEALTH_ERROR, 0, false
+	}
+	req.Header.Set("User-Agent", mutil.FITM_BOT_USER_AGENT)
+
+	resp, err := LinkHealthClient.Do(req)
+	if err != nil {
+		var net_err net.Error
+		if errors.Is(err, context.DeadlineExceeded) ||
+			(errors.As(err, &net_err) && net_err.Timeout()) {
+			return model.LINK_HEALTH_TIMEOUT, 0, false
+		}
+		return model.LINK_HEALTH_ERROR, 0, false
+	}
+	resp.Body.Close()
+
+	switch code := resp.StatusCode; {
+	case code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable:
+		return "", code, true
+	case code == http.StatusNotFound:
+		return model.LINK_HEALTH_NOT_FOUND, code, false
+	case code == http.StatusGone:
+		return model.LINK_HEALTH_GONE, code, false
+	case code >= 500:
+		return model.LINK_HEALTH_ERROR, code, false
+	case linkHealthRedirected(full_url, resp.Request.URL):
+		return model.LINK_HEALTH_REDIRECTED, code, false
+	default:
+		return model.LINK_HEALTH_OK, code, false
+	}
+}
+
+// Whether final URL is a different page
+// (not just, e.g., http -> https or added trailing slash)
+func linkHealthRedirected(link_url string, final_url *url.URL) bool {
+	if final_url == nil {
+		return false
+	}
+
+	canonical_url, err := CanonicalizeURL(link_url)
+	if err != nil {
+		return false
+	}
+	final_canonical_url, err := CanonicalizeURL(final_url.String())
+	if err != nil {
+		return false
+	}
+
+	return canonical_url != final_canonical_url
+}
+
+// Saves check to history (keeping latest LINK_HEALTH_HISTORY_LIMIT) and
+// updates link's status. Failed checks are retried with exponential
+// backoff; link is marked dead after LINK_HEALTH_DEAD_AFTER_FAILURES
+// in a row (but still checked every LINK_HEALTH_CHECK_INTERVAL in case
+// it comes back)
+func RecordLinkHealthCheck(link_id string, status string, response_code int, checked_at string) error {
+	tx, err := db.Client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO "Link Health Checks" VALUES (?, ?, ?, NULLIF(?, 0), ?);`,
+		uuid.New().String(),
+		link_id,
+		status,
+		response_code,
+		checked_at,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`DELETE FROM "Link Health Checks"
+		WHERE link_id = ?
+		AND id NOT IN (
+			SELECT id
+			FROM "Link Health Checks"
+			WHERE link_id = ?
+			ORDER BY checked_at DESC, rowid DESC
+			LIMIT ?
+		);`,
+		link_id,
+		link_id,
+		mutil.LINK_HEALTH_HISTORY_LIMIT,
+	)
+	if err != nil {
+		return err
+	}
+
+	var consecutive_failures int
+	err = tx.QueryRow(
+		`SELECT consecutive_failures FROM "Link Health" WHERE link_id = ?;`,
+		link_id,
+	).Scan(&consecutive_failures)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	next_check_delay := mutil.LINK_HEALTH_CHECK_INTERVAL
+	if model.LinkHealthCheckFailed(status) {
+		consecutive_failures++
+		if consecutive_failures >= mutil.LINK_HEALTH_DEAD_AFTER_FAILURES {
+			status = model.LINK_HEALTH_DEAD
+		} else if retry_delay := mutil.LINK_HEALTH_RETRY_BASE_DELAY << (consecutive_failures - 1); retry_delay < next_check_delay {
+			next_check_delay = retry_delay
+		}
+	} else {
+		consecutive_failures = 0
+	}
+
+	checked_at_time, err := time.ParseInLocation("2006-01-02 15:04:05", checked_at, time.Local)
+	if err != nil {
+		return err
+	}
+	next_check := checked_at_time.Add(next_check_delay).Format("2006-01-02 15:04:05")
+
+	_, err = tx.Exec(
+		`INSERT INTO "Link Health" VALUES (?, ?, NULLIF(?, 0), ?, ?, ?)
+		ON CONFLICT(link_id) DO UPDATE SET
+			status = excluded.status,
+			response_code = excluded.response_code,
+			consecutive_failures = excluded.consecutive_failures,
+			last_checked = excluded.last_checked,
+			next_check = excluded.next_check;`,
+		link_id,
+		status,
+		response_code,
+		consecutive_failures,
+		checked_at,
+		next_check,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func linkHealthHost(link_url string) string {
+	if !strings.Contains(link_url, "://") {
+		link_url = "https://" + link_url
+	}
+
+	u, err := url.Parse(link_url)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+func linkHealthHostBackedOff(host string) bool {
+	link_health_host_backoff.Lock()
+	defer link_health_host_backoff.Unlock()
+
+	until, ok := link_health_host_backoff.until[host]
+	if !ok {
+		return false
+	} else if time.Now().After(until) {
+		delete(link_health_host_backoff.until, host)
+		return false
+	}
+
+	return true
+}
+
+func backOffLinkHealthHost(host string) {
+	link_health_host_backoff.Lock()
+	defer link_health_host_backoff.Unlock()
+
+	link_health_host_backoff.until[host] = time.Now().Add(mutil.LINK_HEALTH_HOST_BACKOFF)
+}
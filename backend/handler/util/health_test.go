@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+func TestCheckLinkHealth(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") == "" {
+			t.Error("no user agent")
+		}
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/slash", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/slash/", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/slash/", func(w http.ResponseWriter, r *http.Request) {})
+	for path, code := range map[string]int{
+		"/forbidden": http.StatusForbidden,
+		"/missing":   http.StatusNotFound,
+		"/gone":      http.StatusGone,
+		"/broken":    http.StatusInternalServerError,
+		"/busy":      http.StatusTooManyRequests,
+	} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		})
+	}
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// loopback addresses rejected by default client
+	if status, _, _ := CheckLinkHealth(server.URL + "/ok"); status != model.LINK_HEALTH_ERROR {
+		t.Fatalf("got status %s for loopback address", status)
+	}
+
+	default_client := LinkHealthClient
+	LinkHealthClient = server.Client()
+	LinkHealthClient.Timeout = 50 * time.Millisecond
+	defer func() { LinkHealthClient = default_client }()
+
+	var test_paths = []struct {
+		Path         string
+		Status       string
+		ResponseCode int
+		RetryLater   bool
+	}{
+		{"/ok", model.LINK_HEALTH_OK, 200, false},
+		{"/moved", model.LINK_HEALTH_REDIRECTED, 200, false},
+		{"/slash", model.LINK_HEALTH_OK, 200, false},
+		{"/forbidden", model.LINK_HEALTH_OK, 403, false},
+		{"/missing", model.LINK_HEALTH_NOT_FOUND, 404, false},
+		{"/gone", model.LINK_HEALTH_GONE, 410, false},
+		{"/broken", model.LINK_HEALTH_ERROR, 500, false},
+		{"/busy", "", 429, true},
+		{"/slow", model.LINK_HEALTH_TIMEOUT, 0, false},
+	}
+
+	for _, tp := range test_paths {
+		status, response_code, retry_later := CheckLinkHealth(server.URL + tp.Path)
+		if status != tp.Status || response_code != tp.ResponseCode || retry_later != tp.RetryLater {
+			t.Fatalf(
+				"%s: got %q, %d, %t, want %q, %d, %t",
+				tp.Path,
+				status,
+				response_code,
+				retry_later,
+				tp.Status,
+				tp.ResponseCode,
+				tp.RetryLater,
+			)
+		}
+	}
+}
+
+func TestRecordLinkHealthCheck(t *testing.T) {
+	const link_id = "health_test"
+	defer TestClient.Exec(`DELETE FROM "Link Health" WHERE link_id = ?;`, link_id)
+	defer TestClient.Exec(`DELETE FROM "Link Health Checks" WHERE link_id = ?;`, link_id)
+
+	checked := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	record := func(status string, response_code int) *model.LinkHealth {
+		checked = checked.Add(time.Minute)
+		if err := RecordLinkHealthCheck(
+			link_id,
+			status,
+			response_code,
+			checked.Format("2006-01-02 15:04:05"),
+		); err != nil {
+			t.Fatal(err)
+		}
+
+		health, err := GetLinkHealth(link_id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return health
+	}
+
+	// retried sooner after each failure until dead
+	for i := 1; i <= mutil.LINK_HEALTH_DEAD_AFTER_FAILURES; i++ {
+		health := record(model.LINK_HEALTH_NOT_FOUND, 404)
+		if health.ConsecutiveFailures != i {
+			t.Fatalf("got %d consecutive failures, want %d", health.ConsecutiveFailures, i)
+		}
+
+		want_status := model.LINK_HEALTH_NOT_FOUND
+		want_delay := mutil.LINK_HEALTH_RETRY_BASE_DELAY << (i - 1)
+		if i == mutil.LINK_HEALTH_DEAD_AFTER_FAILURES {
+			want_status = model.LINK_HEALTH_DEAD
+			want_delay = mutil.LINK_HEALTH_CHECK_INTERVAL
+		}
+		if health.Status != want_status {
+			t.Fatalf("check %d: got status %s, want %s", i, health.Status, want_status)
+		} else if want_next_check := checked.Add(want_delay).Format("2006-01-02 15:04:05"); health.NextCheck != want_next_check {
+			t.Fatalf("check %d: got next check %s, want %s", i, health.NextCheck, want_next_check)
+		}
+	}
+
+	// recovers
+	health := record(model.LINK_HEALTH_OK, 200)
+	if health.Status != model.LINK_HEALTH_OK || health.ConsecutiveFailures != 0 {
+		t.Fatalf("got status %s, %d failures after ok check", health.Status, health.ConsecutiveFailures)
+	} else if health.Checks[0].Status != model.LINK_HEALTH_OK || health.Checks[1].Status != model.LINK_HEALTH_NOT_FOUND {
+		t.Fatalf("checks not latest first: %+v", health.Checks[:2])
+	}
+
+	// history limited
+	for range mutil.LINK_HEALTH_HISTORY_LIMIT {
+		health = record(model.LINK_HEALTH_OK, 200)
+	}
+	if len(health.Checks) != mutil.LINK_HEALTH_HISTORY_LIMIT {
+		t.Fatalf("got %d checks, want %d", len(health.Checks), mutil.LINK_HEALTH_HISTORY_LIMIT)
+	}
+}
+
+// backed-off host's links can't fill the batch
+func TestGetDueLinkHealthChecksSkipsBackedOffHosts(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		if _, err := TestClient.Exec(
+			`INSERT INTO Links (id, url, submitted_by, submit_date, global_cats, global_summary, img_url) VALUES (?,?,?,?,?,?,?);`,
+			fmt.Sprintf("health_backoff_%d", i),
+			fmt.Sprintf("https://health-backoff.example.com/%d", i),
+			test_login_name,
+			"2000-01-01 00:00:00",
+			"test",
+			"",
+			"",
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer TestClient.Exec(`DELETE FROM Links WHERE id LIKE 'health_backoff_%';`)
+
+	// oldest never-checked links due first
+	due, err := getDueLinkHealthChecks(2)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(due) != 2 || due[0].LinkID != "health_backoff_0" {
+		t.Fatalf("got due %+v, want health_backoff_0 first", due)
+	}
+
+	backOffLinkHealthHost("health-backoff.example.com")
+	defer func() {
+		link_health_host_backoff.Lock()
+		delete(link_health_host_backoff.until, "health-backoff.example.com")
+		link_health_host_backoff.Unlock()
+	}()
+
+	due, err = getDueLinkHealthChecks(2)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(due) != 2 {
+		t.Fatalf("got %d due links, want 2", len(due))
+	}
+	for _, c := range due {
+		if linkHealthHost(c.URL) == "health-backoff.example.com" {
+			t.Fatalf("got due link %s of backed-off host", c.LinkID)
+		}
+	}
+}
+
+func TestGetLinkHealthNeverChecked(t *testing.T) {
+	health, err := GetLinkHealth("1")
+	if err != nil {
+		t.Fatal(err)
+	} else if health.Status != "" || len(health.Checks) != 0 {
+		t.Fatalf("got %+v for unchecked link", health)
+	}
+}
+
+func TestFilterDeadTmapLinks(t *testing.T) {
+	var links []model.TmapLink
+	for i, status := range []string{"", model.LINK_HEALTH_OK, model.LINK_HEALTH_DEAD, model.LINK_HEALTH_TIMEOUT} {
+		links = append(links, model.TmapLink{
+			Link: model.Link{ID: fmt.Sprint(i), HealthStatus: status},
+		})
+	}
+
+	filtered := FilterDeadTmapLinks(&links)
+	if len(*filtered) != 3 {
+		t.Fatalf("got %d links, want 3", len(*filtered))
+	}
+	for _, l := range *filtered {
+		if l.HealthStatus == model.LINK_HEALTH_DEAD {
+			t.Fatalf("dead link %s not filtered", l.ID)
+		}
+	}
+}
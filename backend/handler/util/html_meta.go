@@ -2,6 +2,7 @@ package handler
 
 import (
 	"io"
+	"strings"
 
 	"golang.org/x/net/html"
 )
@@ -15,6 +16,7 @@ type HTMLMeta struct {
 	OGAuthor      string
 	OGPublisher   string
 	OGSiteName    string
+	Canonical     string // <link rel="canonical"> href
 }
 
 func ExtractMetaFromHTMLTokens(resp io.Reader) (hm HTMLMeta) {
@@ -34,6 +36,8 @@ func ExtractMetaFromHTMLTokens(resp io.Reader) (hm HTMLMeta) {
 				title_tag = true
 			} else if t.Data == "meta" {
 				AssignTokenPropertyToHTMLMeta(t, &hm)
+			} else if t.Data == "link" && hm.Canonical == "" {
+				hm.Canonical = ExtractCanonicalHref(t)
 			}
 		case html.TextToken:
 			if title_tag {
@@ -94,3 +98,26 @@ func ExtractMetaProperty(t html.Token, prop string) (content string, ok bool) {
 
 	return
 }
+
+// rel may list several space-separated types
+func ExtractCanonicalHref(t html.Token) string {
+	var is_canonical bool
+	var href string
+	for _, attr := range t.Attr {
+		switch attr.Key {
+		case "rel":
+			for _, rel := range strings.Fields(attr.Val) {
+				if strings.EqualFold(rel, "canonical") {
+					is_canonical = true
+				}
+			}
+		case "href":
+			href = attr.Val
+		}
+	}
+
+	if !is_canonical {
+		return ""
+	}
+	return href
+}
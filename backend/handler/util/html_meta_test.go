@@ -161,3 +161,18 @@ func TestFullExtraction(t *testing.T) {
 		t.Error("Expected og:site_name to be", ogSiteName, ", but was:", hm.OGSiteName)
 	}
 }
+
+func TestCanonical(t *testing.T) {
+	href := "https://example.com/posts/1"
+	mp := NewMockPage(`<html><head>
+		<link rel="stylesheet" href="/style.css">
+		<link rel="Canonical alternate" href="` + href + `">
+		<link rel="canonical" href="https://example.com/other">
+	</head></html>`)
+
+	hm := ExtractMetaFromHTMLTokens(&mp)
+
+	if hm.Canonical != href {
+		t.Error("Expected canonical to be", href, ", but was:", hm.Canonical)
+	}
+}
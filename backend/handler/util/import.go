@@ -0,0 +1,633 @@
+package handler
+
+import (
+	"database/sql"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/julianlk522/fitm/db"
+	e "github.com/julianlk522/fitm/error"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+const (
+	IMPORT_FORMAT_NETSCAPE  = "netscape"
+	IMPORT_FORMAT_DELICIOUS = "delicious"
+	IMPORT_FORMAT_PINBOARD  = "pinboard"
+	IMPORT_FORMAT_RAINDROP  = "raindrop"
+	IMPORT_FORMAT_POCKET    = "pocket"
+)
+
+// each returns bookmarks with cats already mapped via BookmarkCats
+var bookmark_parsers = map[string]func(io.Reader) ([]model.ImportedBookmark, error){
+	IMPORT_FORMAT_NETSCAPE:  ParseNetscapeBookmarks,
+	IMPORT_FORMAT_DELICIOUS: ParseDeliciousBookmarks,
+	IMPORT_FORMAT_PINBOARD:  ParsePinboardBookmarks,
+	IMPORT_FORMAT_RAINDROP:  ParseRaindropBookmarks,
+	IMPORT_FORMAT_POCKET:    ParsePocketBookmarks,
+}
+
+func ImportFormats() []string {
+	formats := []string{}
+	for format := range bookmark_parsers {
+		formats = append(formats, format)
+	}
+	slices.Sort(formats)
+
+	return formats
+}
+
+// Request import
+// duplicate URLs within the file are dropped
+func ParseBookmarks(format string, r io.Reader) ([]model.ImportedBookmark, error) {
+	parse, ok := bookmark_parsers[format]
+	if !ok {
+		return nil, e.UnsupportedImportFormat(format, ImportFormats())
+	}
+
+	bookmarks, err := parse(r)
+	if err != nil {
+		return nil, e.InvalidImportFile(format, err)
+	}
+
+	seen := map[string]bool{}
+	unique := []model.ImportedBookmark{}
+	for _, b := range bookmarks {
+		if seen[b.URL] {
+			continue
+		}
+		seen[b.URL] = true
+
+		b.Summary = ImportedSummary(b.Summary)
+		unique = append(unique, b)
+	}
+
+	switch {
+	case len(unique) == 0:
+		return nil, e.ErrNoBookmarksFound
+	case len(unique) > mutil.IMPORT_MAX_LINKS:
+		return nil, e.ImportLinksExceedLimit(mutil.IMPORT_MAX_LINKS)
+	}
+
+	return unique, nil
+}
+
+// header name (lowercased) -> index; errors if any required is missing
+func csvColumns(header []string, required ...string) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		columns[name] = i
+	}
+
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, e.MissingImportColumn(name)
+		}
+	}
+
+	return columns, nil
+}
+
+// "" if column absent from header or short record
+func csvField(record []string, columns map[string]int, name string) string {
+	i, ok := columns[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+
+	return strings.TrimSpace(record[i])
+}
+
+// pending or running (paused imports only wait on the daily link limit,
+// so don't block new ones)
+func UserHasActiveLinkImport(user_id string) (bool, error) {
+	var is_active bool
+	err := db.Client.QueryRow(
+		`SELECT EXISTS(
+			SELECT 1 FROM "Link Imports"
+			WHERE user_id = ?
+			AND status IN (?,?)
+		);`,
+		user_id,
+		model.IMPORT_PENDING,
+		model.IMPORT_RUNNING,
+	).Scan(&is_active)
+	if err != nil {
+		return false, err
+	}
+
+	return is_active, nil
+}
+
+func SaveNewLinkImport(user_id string, link_import *model.LinkImport, bookmarks []model.ImportedBookmark) error {
+	tx, err := db.Client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO "Link Imports" VALUES (?,?,?,?,?,?);`,
+		link_import.ID,
+		user_id,
+		link_import.Format,
+		link_import.Status,
+		link_import.Created,
+		link_import.LastUpdated,
+	)
+	if err != nil {
+		return err
+	}
+
+	for i, b := range bookmarks {
+		_, err = tx.Exec(
+			`INSERT INTO "Link Import Items" (id, import_id, position, url, cats, title, status, summary, starred)
+			VALUES (?,?,?,?,?,?,?,?,?);`,
+			uuid.New().String(),
+			link_import.ID,
+			i,
+			b.URL,
+			b.Cats,
+			b.Title,
+			model.IMPORT_ITEM_PENDING,
+			b.Summary,
+			b.Starred,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	link_import.Counts[model.IMPORT_ITEM_PENDING] = len(bookmarks)
+
+	return tx.Commit()
+}
+
+// Get import
+// also returns owner's user_id
+func GetLinkImport(import_id string, with_items bool) (*model.LinkImport, string, error) {
+	link_import := &model.LinkImport{Counts: map[string]int{}}
+	var user_id string
+
+	err := db.Client.QueryRow(
+		`SELECT id, user_id, format, status, created, last_updated
+		FROM "Link Imports"
+		WHERE id = ?;`,
+		import_id,
+	).Scan(
+		&link_import.ID,
+		&user_id,
+		&link_import.Format,
+		&link_import.Status,
+		&link_import.Created,
+		&link_import.LastUpdated,
+	)
+	if err == sql.ErrNoRows {
+		return nil, "", e.ErrNoLinkImportWithID
+	} else if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := db.Client.Query(
+		`SELECT url, cats, COALESCE(title,''), COALESCE(summary,''), starred, status, COALESCE(link_id,''), COALESCE(error,''), COALESCE(processed,'')
+		FROM "Link Import Items"
+		WHERE import_id = ?
+		ORDER BY position;`,
+		import_id,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item model.LinkImportItem
+		if err = rows.Scan(
+			&item.URL,
+			&item.Cats,
+			&item.Title,
+			&item.Summary,
+			&item.Starred,
+			&item.Status,
+			&item.LinkID,
+			&item.Error,
+			&item.Processed,
+		); err != nil {
+			return nil, "", err
+		}
+
+		link_import.Counts[item.Status]++
+		if with_items {
+			link_import.Items = append(link_import.Items, item)
+		}
+	}
+
+	return link_import, user_id, nil
+}
+
+// Run import
+// Items are processed in file order and saved one at a time, so an
+// interrupted or paused import picks up where it left off. New links past
+// the daily limit stay pending and the import is paused until the limit
+// allows more. Items are read in batches of IMPORT_ITEM_BATCH_SIZE.
+func RunLinkImport(import_id string) error {
+	var user_id, prev_status string
+	err := db.Client.QueryRow(
+		`SELECT user_id, status FROM "Link Imports" WHERE id = ?;`,
+		import_id,
+	).Scan(&user_id, &prev_status)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	} else if prev_status != model.IMPORT_PENDING && prev_status != model.IMPORT_PAUSED {
+		return nil
+	}
+
+	res, err := db.Client.Exec(
+		`UPDATE "Link Imports"
+		SET status = ?, last_updated = ?
+		WHERE id = ? AND status = ?;`,
+		model.IMPORT_RUNNING,
+		mutil.NEW_LONG_TIMESTAMP(),
+		import_id,
+		prev_status,
+	)
+	if err != nil {
+		return err
+	}
+
+	// already claimed
+	if num_rows, err := res.RowsAffected(); err != nil {
+		return err
+	} else if num_rows == 0 {
+		return nil
+	}
+
+	login_name, err := GetLoginNameFromUserID(user_id)
+	if err != nil {
+		return err
+	}
+
+	// a paused import's remaining items were already deferred, so once
+	// the limit defers one again the rest are not due yet
+	stop_at_limit := prev_status == model.IMPORT_PAUSED
+
+	type pending_item struct {
+		ID       string
+		Position int
+		model.ImportedBookmark
+	}
+
+	num_deferred := 0
+	last_position := -1
+	for {
+		rows, err := db.Client.Query(
+			`SELECT id, position, url, cats, COALESCE(summary,''), starred
+			FROM "Link Import Items"
+			WHERE import_id = ? AND status = ? AND position > ?
+			ORDER BY position
+			LIMIT ?;`,
+			import_id,
+			model.IMPORT_ITEM_PENDING,
+			last_position,
+			mutil.IMPORT_ITEM_BATCH_SIZE,
+		)
+		if err != nil {
+			return err
+		}
+		var items []pending_item
+		for rows.Next() {
+			var item pending_item
+			if err = rows.Scan(&item.ID, &item.Position, &item.URL, &item.Cats, &item.Summary, &item.Starred); err != nil {
+				rows.Close()
+				return err
+			}
+			items = append(items, item)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		if len(items) == 0 {
+			break
+		}
+
+		for _, item := range items {
+			last_position = item.Position
+
+			status, link_id, item_err := ImportLink(item.ImportedBookmark, user_id, login_name)
+			if status == model.IMPORT_ITEM_PENDING {
+				num_deferred++
+				if stop_at_limit {
+					break
+				}
+				continue
+			}
+
+			var err_text sql.NullString
+			if item_err != nil {
+				err_text = sql.NullString{String: item_err.Error(), Valid: true}
+			}
+			_, err = db.Client.Exec(
+				`UPDATE "Link Import Items"
+				SET status = ?, link_id = ?, error = ?, processed = ?
+				WHERE id = ?;`,
+				status,
+				sql.NullString{String: link_id, Valid: link_id != ""},
+				err_text,
+				mutil.NEW_LONG_TIMESTAMP(),
+				item.ID,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		if num_deferred > 0 && stop_at_limit {
+			break
+		}
+	}
+
+	final_status := model.IMPORT_DONE
+	if num_deferred > 0 {
+		final_status = model.IMPORT_PAUSED
+	}
+	_, err = db.Client.Exec(
+		`UPDATE "Link Imports" SET status = ?, last_updated = ? WHERE id = ?;`,
+		final_status,
+		mutil.NEW_LONG_TIMESTAMP(),
+		import_id,
+	)
+
+	return err
+}
+
+// Existing URLs are tagged (and copied if starred), new ones submitted.
+// IMPORT_ITEM_PENDING returned if the daily link limit defers it.
+func ImportLink(b model.ImportedBookmark, user_id string, login_name string) (status string, link_id string, err error) {
+	if link_id = findExistingLinkID(b.URL); link_id != "" {
+		return importExistingLink(link_id, b, user_id, login_name, false)
+	}
+
+	if at_limit, err := UserHasSubmittedMaxDailyLinks(login_name); err != nil {
+		return model.IMPORT_ITEM_FAILED, "", err
+	} else if at_limit {
+		return model.IMPORT_ITEM_PENDING, "", nil
+	}
+
+	request := &model.NewLinkRequest{
+		NewLink: &model.NewLink{
+			URL:     b.URL,
+			Cats:    b.Cats,
+			Summary: b.Summary,
+		},
+	}
+	if err = request.Bind(nil); err != nil {
+		return model.IMPORT_ITEM_FAILED, "", err
+	}
+	if err = ObtainLinkMetaData(request); err != nil {
+		return model.IMPORT_ITEM_FAILED, "", err
+	}
+
+	// resolved URL may match an existing link
+	if is_duplicate, link_id := ResolvedLinkAlreadyAdded(request); is_duplicate {
+		return importExistingLink(link_id, b, user_id, login_name, false)
+	}
+
+	request.SubmittedBy = login_name
+	if err = SaveNewLink(request, user_id); err != nil {
+		return model.IMPORT_ITEM_FAILED, "", err
+	}
+
+	return model.IMPORT_ITEM_ADDED, request.ID, nil
+}
+
+// Dry run: predicted outcome per bookmark, nothing saved
+// (URLs aren't resolved, so a new URL that redirects to an existing link
+// is predicted as added)
+func PreviewLinkImport(format string, bookmarks []model.ImportedBookmark, user_id string, login_name string) (*model.LinkImportPreview, error) {
+	num_today, err := GetNumLinksSubmittedToday(login_name)
+	if err != nil {
+		return nil, err
+	}
+	remaining_new := MAX_DAILY_LINKS - num_today
+
+	preview := &model.LinkImportPreview{
+		Format: format,
+		Counts: map[string]int{},
+		Items:  []model.LinkImportItem{},
+	}
+
+	for _, b := range bookmarks {
+		item := model.LinkImportItem{
+			URL:     b.URL,
+			Cats:    b.Cats,
+			Title:   b.Title,
+			Summary: b.Summary,
+			Starred: b.Starred,
+		}
+
+		var item_err error
+		if link_id := findExistingLinkID(b.URL); link_id != "" {
+			item.Status, item.LinkID, item_err = importExistingLink(link_id, b, user_id, login_name, true)
+		} else if remaining_new > 0 {
+			item.Status = model.IMPORT_ITEM_ADDED
+			remaining_new--
+		} else {
+			item.Status = model.IMPORT_ITEM_PENDING
+		}
+		if item_err != nil {
+			item.Error = item_err.Error()
+		}
+
+		preview.Counts[item.Status]++
+		preview.Items = append(preview.Items, item)
+	}
+
+	return preview, nil
+}
+
+// AddLink stores URLs without trailing slash
+func findExistingLinkID(url string) string {
+	if is_duplicate, link_id := LinkAlreadyAdded(url); is_duplicate {
+		return link_id
+	} else if is_duplicate, link_id = LinkAlreadyAdded(strings.TrimSuffix(url, "/")); is_duplicate {
+		return link_id
+	}
+
+	return ""
+}
+
+// Tags link, copies it if starred and adds summary if user has none
+// (only checks if dry_run)
+func importExistingLink(link_id string, b model.ImportedBookmark, user_id string, login_name string, dry_run bool) (string, string, error) {
+	if UserSubmittedLink(login_name, link_id) {
+		return model.IMPORT_ITEM_SKIPPED, link_id, e.ErrLinkAlreadySubmitted
+	}
+
+	is_blocked, err := LinkSubmitterHasBlockedUser(link_id, user_id)
+	if err != nil {
+		return model.IMPORT_ITEM_FAILED, link_id, err
+	} else if is_blocked {
+		return model.IMPORT_ITEM_FAILED, link_id, e.ErrBlockedByLinkSubmitter
+	}
+
+	should_copy := b.Starred && !UserHasCopiedLink(user_id, link_id)
+
+	already_tagged, err := UserHasTaggedLink(login_name, link_id)
+	if err != nil {
+		return model.IMPORT_ITEM_FAILED, link_id, err
+	}
+	should_tag := !already_tagged
+
+	should_summarize := false
+	if b.Summary != "" {
+		_, err = GetIDOfUserSummaryForLink(user_id, link_id)
+		if err == sql.ErrNoRows {
+			should_summarize = true
+		} else if err != nil {
+			return model.IMPORT_ITEM_FAILED, link_id, err
+		}
+	}
+
+	if !should_copy && !should_tag && !should_summarize {
+		return model.IMPORT_ITEM_SKIPPED, link_id, e.ErrLinkAlreadyImported
+	}
+
+	status := model.IMPORT_ITEM_TAGGED
+	if should_copy {
+		status = model.IMPORT_ITEM_COPIED
+	}
+	if dry_run {
+		return status, link_id, nil
+	}
+
+	// all or nothing so a failed item can be retried
+	tx, err := db.Client.Begin()
+	if err != nil {
+		return model.IMPORT_ITEM_FAILED, link_id, err
+	}
+	defer tx.Rollback()
+
+	if should_copy {
+		if _, err = tx.Exec(
+			`INSERT INTO "Link Copies" (id, link_id, user_id, created) VALUES(?,?,?,?);`,
+			uuid.New().String(),
+			link_id,
+			user_id,
+			mutil.NEW_LONG_TIMESTAMP(),
+		); err != nil {
+			return model.IMPORT_ITEM_FAILED, link_id, err
+		}
+	}
+
+	if should_tag {
+		if _, err = tx.Exec(
+			"INSERT INTO Tags VALUES(?,?,?,?,?);",
+			uuid.New().String(),
+			link_id,
+			AlphabetizeCats(b.Cats),
+			login_name,
+			mutil.NEW_LONG_TIMESTAMP(),
+		); err != nil {
+			return model.IMPORT_ITEM_FAILED, link_id, err
+		}
+	}
+
+	if should_summarize {
+		if _, err = tx.Exec(
+			`INSERT INTO Summaries VALUES (?,?,?,?,?);`,
+			uuid.New().String(),
+			b.Summary,
+			link_id,
+			user_id,
+			mutil.NEW_LONG_TIMESTAMP(),
+		); err != nil {
+			return model.IMPORT_ITEM_FAILED, link_id, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return model.IMPORT_ITEM_FAILED, link_id, err
+	}
+
+	// after commit: open their own transactions
+	if should_tag {
+		if err = CalculateAndSetGlobalCats(link_id); err != nil {
+			return model.IMPORT_ITEM_FAILED, link_id, err
+		}
+	}
+	if should_summarize {
+		if err = CalculateAndSetGlobalSummary(link_id); err != nil {
+			return model.IMPORT_ITEM_FAILED, link_id, err
+		}
+	}
+
+	return status, link_id, nil
+}
+
+// Service description as summary: whitespace collapsed, double quotes
+// replaced (as in NewLinkRequest) and cut to SUMMARY_CHAR_LIMIT
+func ImportedSummary(description string) string {
+	summary := mutil.TrimExcessAndTrailingSpaces(description)
+	summary = strings.ReplaceAll(summary, "\"", "'")
+
+	return truncateToLimit(summary, mutil.SUMMARY_CHAR_LIMIT)
+}
+
+// Import worker
+// pending imports and paused imports whose user is under the daily link
+// limit again, in creation order
+func GetResumableLinkImportIDs() ([]string, error) {
+	rows, err := db.Client.Query(
+		`SELECT li.id
+		FROM "Link Imports" li
+		INNER JOIN Users u ON u.id = li.user_id
+		WHERE li.status = ?
+		OR (
+			li.status = ?
+			AND (
+				SELECT count(*)
+				FROM Links
+				WHERE submitted_by = u.login_name
+				AND submit_date >= date('now', '-1 days')
+			) < ?
+		)
+		ORDER BY li.created;`,
+		model.IMPORT_PENDING,
+		model.IMPORT_PAUSED,
+		MAX_DAILY_LINKS,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// imports left running by a previous process are resumed
+func RequeueInterruptedLinkImports() error {
+	_, err := db.Client.Exec(
+		`UPDATE "Link Imports" SET status = ? WHERE status = ?;`,
+		model.IMPORT_PENDING,
+		model.IMPORT_RUNNING,
+	)
+
+	return err
+}
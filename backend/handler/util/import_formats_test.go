@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/julianlk522/fitm/model"
+)
+
+func checkImportedBookmarks(t *testing.T, got []model.ImportedBookmark, want []model.ImportedBookmark) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d bookmarks, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Fatalf("bookmark %d: got %+v, want %+v", i, got[i], w)
+		}
+	}
+}
+
+func TestParsePinboardBookmarks(t *testing.T) {
+	const pins = `[
+		{"href":"https://go.dev/","description":"Go","extended":"The Go site","tags":"golang programming","toread":"no"},
+		{"href":"https://later.example.com","description":"Later","extended":"","tags":"","toread":"yes"},
+		{"href":"ftp://files.example.com","description":"FTP","extended":"","tags":"","toread":"no"}
+	]`
+
+	bookmarks, err := ParsePinboardBookmarks(strings.NewReader(pins))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkImportedBookmarks(t, bookmarks, []model.ImportedBookmark{
+		{URL: "https://go.dev/", Title: "Go", Cats: "golang,programming", Summary: "The Go site", Starred: true},
+		{URL: "https://later.example.com", Title: "Later", Cats: "imported"},
+	})
+
+	if _, err = ParsePinboardBookmarks(strings.NewReader(`{"not":"a list"}`)); err == nil {
+		t.Fatal("expected error for malformed JSON")
+	}
+}
+
+func TestParseRaindropBookmarks(t *testing.T) {
+	const raindrops = "id,title,note,excerpt,url,folder,tags,created,cover,highlights,favorite\n" +
+		`1,Go,My note,Page excerpt,https://go.dev,Dev/Go,"golang, tools",2024-01-01,,,true` + "\n" +
+		`2,Later,,Page excerpt,https://later.example.com,Unsorted,,2024-01-01,,,false` + "\n"
+
+	bookmarks, err := ParseRaindropBookmarks(strings.NewReader(raindrops))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkImportedBookmarks(t, bookmarks, []model.ImportedBookmark{
+		{URL: "https://go.dev", Title: "Go", Cats: "Dev,Go,golang,tools", Summary: "My note", Starred: true},
+		{URL: "https://later.example.com", Title: "Later", Cats: "imported", Summary: "Page excerpt"},
+	})
+
+	if _, err = ParseRaindropBookmarks(strings.NewReader("id,title\n1,Go\n")); err == nil {
+		t.Fatal("expected error for missing url column")
+	}
+}
+
+func TestParsePocketBookmarks(t *testing.T) {
+	const pocket_html = `<!DOCTYPE html>
+<html><head><title>Pocket Export</title></head><body>
+<h1>Unread</h1>
+<ul>
+<li><a href="https://unread.example.com" time_added="1700000000" tags="news,tech">Unread article</a></li>
+</ul>
+<h1>Read Archive</h1>
+<ul>
+<li><a href="https://read.example.com" time_added="1700000000" tags="">Read article</a></li>
+</ul>
+</body></html>`
+
+	const pocket_csv = "\ufefftitle,url,time_added,tags,status\n" +
+		"Unread article,https://unread.example.com,1700000000,news|tech,unread\n" +
+		"Read article,https://read.example.com,1700000000,,archive\n"
+
+	want := []model.ImportedBookmark{
+		{URL: "https://unread.example.com", Title: "Unread article", Cats: "news,tech"},
+		{URL: "https://read.example.com", Title: "Read article", Cats: "imported", Starred: true},
+	}
+
+	for _, export := range []string{pocket_html, pocket_csv} {
+		bookmarks, err := ParsePocketBookmarks(strings.NewReader(export))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkImportedBookmarks(t, bookmarks, want)
+	}
+}
+
+func TestParseBookmarksSummaries(t *testing.T) {
+	const pins = `[{"href":"https://go.dev/","description":"Go","extended":"  The \"Go\"\n site ","tags":"","toread":"no"}]`
+
+	bookmarks, err := ParseBookmarks(IMPORT_FORMAT_PINBOARD, strings.NewReader(pins))
+	if err != nil {
+		t.Fatal(err)
+	} else if bookmarks[0].Summary != "The 'Go' site" {
+		t.Fatalf("got summary %q", bookmarks[0].Summary)
+	}
+}
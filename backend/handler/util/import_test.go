@@ -0,0 +1,294 @@
+package handler
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+const (
+	test_import_user_id    = "import_test_user"
+	test_import_login_name = "import_test_user"
+)
+
+func insertTestImportUser(t *testing.T) {
+	if _, err := TestClient.Exec(
+		`INSERT OR IGNORE INTO Users (id, login_name, password, created) VALUES (?,?,?,?);`,
+		test_import_user_id,
+		test_import_login_name,
+		"x",
+		"2024-01-01",
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportLink(t *testing.T) {
+	insertTestImportUser(t)
+
+	var test_imports = []struct {
+		URL        string
+		Starred    bool
+		LoginName  string
+		WantStatus string
+		WantLinkID string
+		WantErr    bool
+	}{
+		// trailing slash stripped to match
+		{"https://github.com/golang/go/", true, test_import_login_name, model.IMPORT_ITEM_COPIED, "2", false},
+		{"https://github.com/golang/go", true, test_import_login_name, model.IMPORT_ITEM_SKIPPED, "2", true},
+		// not starred: tagged only
+		{"https://nsfw.example.com", false, test_import_login_name, model.IMPORT_ITEM_TAGGED, "3", false},
+		{"https://www.example.com", true, "jlk", model.IMPORT_ITEM_SKIPPED, "1", true},
+	}
+
+	for _, ti := range test_imports {
+		user_id := test_import_user_id
+		if ti.LoginName == "jlk" {
+			user_id = test_user_id
+		}
+
+		status, link_id, err := ImportLink(model.ImportedBookmark{
+			URL:     ti.URL,
+			Cats:    "go,imported",
+			Summary: "imported summary",
+			Starred: ti.Starred,
+		}, user_id, ti.LoginName)
+		if status != ti.WantStatus || link_id != ti.WantLinkID || (err != nil) != ti.WantErr {
+			t.Fatalf(
+				"%s: got %s / %s / %v, want %s / %s / err %t",
+				ti.URL,
+				status,
+				link_id,
+				err,
+				ti.WantStatus,
+				ti.WantLinkID,
+				ti.WantErr,
+			)
+		}
+	}
+
+	if !UserHasCopiedLink(test_import_user_id, "2") {
+		t.Fatal("expected link to be copied")
+	} else if tagged, err := UserHasTaggedLink(test_import_login_name, "2"); err != nil || !tagged {
+		t.Fatalf("expected link to be tagged (err %v)", err)
+	} else if _, err = GetIDOfUserSummaryForLink(test_import_user_id, "2"); err != nil {
+		t.Fatalf("expected summary to be added (err %v)", err)
+	} else if UserHasCopiedLink(test_import_user_id, "3") {
+		t.Fatal("expected unstarred link not to be copied")
+	}
+}
+
+// failed item leaves nothing behind and can be retried
+func TestImportLinkRollsBackOnFailure(t *testing.T) {
+	insertTestImportUser(t)
+
+	stmts := []string{
+		`INSERT INTO Links (id, url, submitted_by, submit_date, global_cats, global_summary, img_url) VALUES ('import_tx_test', 'https://import-tx.example.com', 'jlk', '2024-01-01 00:00:00', 'test', '', '');`,
+		`INSERT INTO Tags VALUES ('import_tx_test_tag', 'import_tx_test', 'test', 'jlk', '2024-01-01 00:00:00');`,
+		`CREATE TRIGGER import_tx_test_fail BEFORE INSERT ON Summaries
+		WHEN NEW.text = 'fail'
+		BEGIN SELECT RAISE(ABORT, 'summary insert failed'); END;`,
+	}
+	for _, stmt := range stmts {
+		if _, err := TestClient.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer TestClient.Exec(`DROP TRIGGER IF EXISTS import_tx_test_fail;`)
+
+	b := model.ImportedBookmark{
+		URL:     "https://import-tx.example.com",
+		Cats:    "go,imported",
+		Summary: "fail",
+		Starred: true,
+	}
+	if status, _, err := ImportLink(b, test_import_user_id, test_import_login_name); err == nil || status != model.IMPORT_ITEM_FAILED {
+		t.Fatalf("got %s / %v, want failed", status, err)
+	}
+	if UserHasCopiedLink(test_import_user_id, "import_tx_test") {
+		t.Fatal("expected copy to be rolled back")
+	} else if tagged, err := UserHasTaggedLink(test_import_login_name, "import_tx_test"); err != nil || tagged {
+		t.Fatalf("expected tag to be rolled back (err %v)", err)
+	}
+
+	b.Summary = "retried"
+	if status, _, err := ImportLink(b, test_import_user_id, test_import_login_name); err != nil || status != model.IMPORT_ITEM_COPIED {
+		t.Fatalf("retry: got %s / %v, want copied", status, err)
+	}
+}
+
+func TestPreviewLinkImport(t *testing.T) {
+	insertTestImportUser(t)
+
+	bookmarks := []model.ImportedBookmark{
+		{URL: "https://www.example.com", Cats: "a", Starred: true},
+		{URL: "https://preview-new-1.example.com", Cats: "a", Starred: true},
+		{URL: "https://preview-new-2.example.com", Cats: "a", Starred: true},
+	}
+
+	// 1 link left today
+	for i := 0; i < MAX_DAILY_LINKS-1; i++ {
+		if _, err := TestClient.Exec(
+			`INSERT OR IGNORE INTO Links (id, url, submitted_by, submit_date, global_cats, global_summary, img_url) VALUES (?,?,?,?,?,?,?);`,
+			fmt.Sprintf("import_preview_%d", i),
+			fmt.Sprintf("https://import-preview.example.com/%d", i),
+			test_import_login_name,
+			mutil.NEW_LONG_TIMESTAMP(),
+			"test",
+			"",
+			"",
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer TestClient.Exec(`DELETE FROM Links WHERE id LIKE 'import_preview_%';`)
+
+	preview, err := PreviewLinkImport(IMPORT_FORMAT_PINBOARD, bookmarks, test_import_user_id, test_import_login_name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want_statuses := []string{model.IMPORT_ITEM_COPIED, model.IMPORT_ITEM_ADDED, model.IMPORT_ITEM_PENDING}
+	for i, item := range preview.Items {
+		if item.Status != want_statuses[i] {
+			t.Fatalf("item %d: got status %s, want %s (%s)", i, item.Status, want_statuses[i], item.Error)
+		}
+	}
+
+	// nothing saved
+	if UserHasCopiedLink(test_import_user_id, "1") {
+		t.Fatal("expected dry run not to copy link")
+	} else if is_duplicate, _ := LinkAlreadyAdded("https://preview-new-1.example.com"); is_duplicate {
+		t.Fatal("expected dry run not to add link")
+	}
+}
+
+// new links beyond the daily limit pause the import
+func TestRunLinkImportPausesAtDailyLimit(t *testing.T) {
+	insertTestImportUser(t)
+	for i := 0; i < MAX_DAILY_LINKS; i++ {
+		if _, err := TestClient.Exec(
+			`INSERT OR IGNORE INTO Links (id, url, submitted_by, submit_date, global_cats, global_summary, img_url) VALUES (?,?,?,?,?,?,?);`,
+			fmt.Sprintf("import_limit_%d", i),
+			fmt.Sprintf("https://import-limit.example.com/%d", i),
+			test_import_login_name,
+			mutil.NEW_LONG_TIMESTAMP(),
+			"test",
+			"",
+			"",
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer TestClient.Exec(`DELETE FROM Links WHERE id LIKE 'import_limit_%';`)
+
+	link_import := model.NewLinkImport(IMPORT_FORMAT_NETSCAPE)
+	if err := SaveNewLinkImport(test_import_user_id, link_import, []model.ImportedBookmark{
+		{URL: "https://not-yet-added.example.com", Cats: "new"},
+		{URL: "https://nsfw.example.com", Cats: "NSFW", Starred: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunLinkImport(link_import.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	result, user_id, err := GetLinkImport(link_import.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	} else if user_id != test_import_user_id {
+		t.Fatalf("got user %s, want %s", user_id, test_import_user_id)
+	} else if result.Status != model.IMPORT_PAUSED {
+		t.Fatalf("got status %s, want %s", result.Status, model.IMPORT_PAUSED)
+	}
+
+	// existing link still processed
+	want_statuses := []string{model.IMPORT_ITEM_PENDING, model.IMPORT_ITEM_COPIED}
+	for i, item := range result.Items {
+		if item.Status != want_statuses[i] {
+			t.Fatalf("item %d: got status %s, want %s (%s)", i, item.Status, want_statuses[i], item.Error)
+		}
+	}
+
+	if is_active, err := UserHasActiveLinkImport(test_import_user_id); err != nil {
+		t.Fatal(err)
+	} else if is_active {
+		t.Fatal("expected paused import not to block new imports")
+	}
+
+	// resumable only once under the limit again
+	if isResumableLinkImport(t, link_import.ID) {
+		t.Fatal("expected paused import not to be resumable at daily limit")
+	}
+	if _, err = TestClient.Exec(`DELETE FROM Links WHERE id = 'import_limit_0';`); err != nil {
+		t.Fatal(err)
+	}
+	if !isResumableLinkImport(t, link_import.ID) {
+		t.Fatal("expected paused import to be resumable")
+	}
+}
+
+// resumed import stops at first deferred item instead of re-reading the rest
+func TestRunLinkImportResumeStopsAtDailyLimit(t *testing.T) {
+	insertTestImportUser(t)
+	for i := 0; i < MAX_DAILY_LINKS; i++ {
+		if _, err := TestClient.Exec(
+			`INSERT OR IGNORE INTO Links (id, url, submitted_by, submit_date, global_cats, global_summary, img_url) VALUES (?,?,?,?,?,?,?);`,
+			fmt.Sprintf("import_resume_%d", i),
+			fmt.Sprintf("https://import-resume.example.com/%d", i),
+			test_import_login_name,
+			mutil.NEW_LONG_TIMESTAMP(),
+			"test",
+			"",
+			"",
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer TestClient.Exec(`DELETE FROM Links WHERE id LIKE 'import_resume_%';`)
+
+	link_import := model.NewLinkImport(IMPORT_FORMAT_NETSCAPE)
+	if err := SaveNewLinkImport(test_import_user_id, link_import, []model.ImportedBookmark{
+		{URL: "https://resume-new-1.example.com", Cats: "new"},
+		{URL: "https://resume-new-2.example.com", Cats: "new"},
+		{URL: "https://nsfw.example.com", Cats: "NSFW", Starred: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TestClient.Exec(
+		`UPDATE "Link Imports" SET status = ? WHERE id = ?;`,
+		model.IMPORT_PAUSED,
+		link_import.ID,
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunLinkImport(link_import.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	result, _, err := GetLinkImport(link_import.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	} else if result.Status != model.IMPORT_PAUSED {
+		t.Fatalf("got status %s, want %s", result.Status, model.IMPORT_PAUSED)
+	} else if result.Counts[model.IMPORT_ITEM_PENDING] != 3 {
+		t.Fatalf("got counts %v, want 3 pending", result.Counts)
+	}
+}
+
+func isResumableLinkImport(t *testing.T, import_id string) bool {
+	t.Helper()
+
+	ids, err := GetResumableLinkImportIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return slices.Contains(ids, import_id)
+}
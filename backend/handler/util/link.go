@@ -1,11 +1,13 @@
 package handler
 
 import (
+	"log"
 	"strings"
 
 	"github.com/julianlk522/fitm/db"
 	e "github.com/julianlk522/fitm/error"
 	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
 	"github.com/julianlk522/fitm/query"
 
 	"database/sql"
@@ -14,11 +16,21 @@ import (
 	"net/http"
 
 	"github.com/go-chi/render"
+	"github.com/google/uuid"
 )
 
 const MAX_DAILY_LINKS = 50
 
 func UserHasSubmittedMaxDailyLinks(login_name string) (bool, error) {
+	count, err := GetNumLinksSubmittedToday(login_name)
+	if err != nil {
+		return false, err
+	}
+
+	return count >= MAX_DAILY_LINKS, nil
+}
+
+func GetNumLinksSubmittedToday(login_name string) (int, error) {
 	var count int
 	err := db.Client.QueryRow(`SELECT count(*)
 		FROM Links
@@ -27,10 +39,10 @@ func UserHasSubmittedMaxDailyLinks(login_name string) (bool, error) {
 		login_name,
 	).Scan(&count)
 	if err != nil {
-		return false, err
+		return 0, err
 	}
 
-	return count >= MAX_DAILY_LINKS, nil
+	return count, nil
 }
 
 func RenderZeroLinks(w http.ResponseWriter, r *http.Request) {
@@ -67,6 +79,7 @@ func ScanLinks[T model.Link | model.LinkSignedIn](get_links_sql *query.TopLinks)
 				&i.TagCount,
 				&i.LikeCount,
 				&i.ImgURL,
+				&i.HealthStatus,
 			)
 			if err != nil {
 				return nil, err
@@ -92,6 +105,7 @@ func ScanLinks[T model.Link | model.LinkSignedIn](get_links_sql *query.TopLinks)
 				&i.TagCount,
 				&i.LikeCount,
 				&i.ImgURL,
+				&i.HealthStatus,
 				&i.IsLiked,
 				&i.IsCopied,
 			); err != nil {
@@ -107,7 +121,7 @@ func ScanLinks[T model.Link | model.LinkSignedIn](get_links_sql *query.TopLinks)
 	return links.(*[]T), nil
 }
 
-func PaginateLinks[T model.LinkSignedIn | model.Link](links *[]T, page int) interface{} {
+func PaginateLinks[T model.LinkSignedIn | model.Link | model.SearchLink | model.SearchLinkSignedIn](links *[]T, page int) interface{} {
 	if links == nil || len(*links) == 0 {
 		return &model.PaginatedLinks[model.Link]{NextPage: -1}
 	}
@@ -140,9 +154,18 @@ func ObtainURLMetaData(request *model.NewLinkRequest) error {
 	// remove trailing slash
 	request.URL = strings.TrimSuffix(request.URL, "/")
 
+	request.CanonicalURL, err = CanonicalizeURL(request.URL)
+	if err != nil {
+		return err
+	}
+
 	if resp.StatusCode != http.StatusForbidden {
 		meta := ExtractMetaFromHTMLTokens(resp.Body)
 		AssignMetadata(meta, request)
+
+		if canonical_url, ok := CanonicalURLFromLinkTag(resp.Request.URL, meta.Canonical); ok {
+			request.CanonicalURL = canonical_url
+		}
 	}
 
 	return nil
@@ -159,7 +182,7 @@ func GetResolvedURLResponse(url string) (*http.Response, error) {
 			continue
 		}
 
-		req.Header.Set("User-Agent", "FITM-Bot (https://fitm.online/about#retrieving-metadata)")
+		req.Header.Set("User-Agent", mutil.FITM_BOT_USER_AGENT)
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil || resp.StatusCode == http.StatusNotFound {
 			continue
@@ -204,10 +227,20 @@ func IsRedirect(status_code int) bool {
 	return status_code > 299 && status_code < 400
 }
 
+// Matches url or its canonical form
 func LinkAlreadyAdded(url string) (bool, string) {
 	var id sql.NullString
 
-	err := db.Client.QueryRow("SELECT id FROM Links WHERE url = ?", url).Scan(&id)
+	canonical_url, err := CanonicalizeURL(url)
+	if err != nil {
+		canonical_url = url
+	}
+
+	err = db.Client.QueryRow(
+		"SELECT id FROM Links WHERE url = ? OR canonical_url = ?",
+		url,
+		canonical_url,
+	).Scan(&id)
 
 	if err == nil && id.Valid {
 		return true, id.String
@@ -216,6 +249,17 @@ func LinkAlreadyAdded(url string) (bool, string) {
 	}
 }
 
+// Matches resolved URL or page's own canonical URL (from ObtainLinkMetaData)
+func ResolvedLinkAlreadyAdded(request *model.NewLinkRequest) (bool, string) {
+	if is_duplicate, link_id := LinkAlreadyAdded(request.URL); is_duplicate {
+		return true, link_id
+	} else if request.CanonicalURL != "" {
+		return LinkAlreadyAdded(request.CanonicalURL)
+	}
+
+	return false, ""
+}
+
 func IncrementSpellfixRanksForCats(tx *sql.Tx, cats []string) error {
 
 	// exec as part of transaction if tx is not nil
@@ -283,6 +327,36 @@ func IncrementSpellfixRanksForCats(tx *sql.Tx, cats []string) error {
 }
 
 // Delete link
+func DeleteLinkWithID(tx *sql.Tx, link_id string) error {
+
+	// fetch global cats before deleting
+	// (to properly update spellfix ranks)
+	var gc string
+	err := tx.QueryRow("SELECT global_cats FROM Links WHERE id = ?;", link_id).Scan(&gc)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"DELETE FROM Links WHERE id = ?;",
+		link_id,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{`"Link Health"`, `"Link Health Checks"`} {
+		if _, err = tx.Exec("DELETE FROM "+table+" WHERE link_id = ?;", link_id); err != nil {
+			return err
+		}
+	}
+
+	return DecrementSpellfixRanksForCats(
+		tx,
+		strings.Split(gc, ","),
+	)
+}
+
 func DecrementSpellfixRanksForCats(tx *sql.Tx, cats []string) error {
 
 	// exec as part of transaction if tx is not nil
@@ -347,6 +421,19 @@ func UserHasLikedLink(user_id string, link_id string) bool {
 	return err == nil && l.Valid
 }
 
+func GetLinkLikeCount(link_id string) (int64, error) {
+	var like_count int64
+	err := db.Client.QueryRow(
+		`SELECT count(*) FROM "Link Likes" WHERE link_id = ?;`,
+		link_id,
+	).Scan(&like_count)
+	if err != nil {
+		return 0, err
+	}
+
+	return like_count, nil
+}
+
 // Copy link
 func UserHasCopiedLink(user_id string, link_id string) bool {
 	var l sql.NullString
@@ -360,3 +447,134 @@ func UserHasCopiedLink(user_id string, link_id string) bool {
 
 	return err == nil && l.Valid
 }
+
+// Add link (also used by imports)
+func ObtainLinkMetaData(request *model.NewLinkRequest) error {
+	if IsYouTubeVideoLink(request.NewLink.URL) {
+		if err := ObtainYouTubeMetaData(request); err != nil {
+
+			// if unable to get YT metadata, try treating as normal URL
+			// (in case of, e.g., example.com/youtube.com/watch?v=1234
+			// though this should not happen per util.TestIsYouTubeVideoLink cases)
+			return ObtainURLMetaData(request)
+		}
+
+		var err error
+		request.CanonicalURL, err = CanonicalizeURL(request.URL)
+		return err
+	}
+
+	return ObtainURLMetaData(request)
+}
+
+// inserts link with its submitter's tag and any summaries
+// (request.SubmittedBy must be set)
+func SaveNewLink(request *model.NewLinkRequest, user_id string) error {
+
+	// sort cats
+	request.Cats = AlphabetizeCats(request.NewLink.Cats)
+
+	// Start Transaction
+	tx, err := db.Client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// insert summary(ies)
+	// (might have user-submitted, auto, or both)
+	// auto summary
+	if request.AutoSummary != "" {
+		_, err := tx.Exec(
+			"INSERT INTO Summaries VALUES(?,?,?,?,?);",
+			uuid.New().String(),
+			request.AutoSummary,
+			request.ID,
+			db.AUTO_SUMMARY_USER_ID,
+			request.SubmitDate,
+		)
+		if err != nil {
+			// continue... no auto summary
+			// but log err
+			log.Print("Error adding auto summary: ", err)
+		} else {
+			request.SummaryCount = 1
+		}
+	}
+
+	// user summary
+	if request.NewLink.Summary != "" {
+		_, err := tx.Exec(
+			"INSERT INTO Summaries VALUES(?,?,?,?,?);",
+			uuid.New().String(),
+			request.NewLink.Summary,
+			request.ID,
+			user_id,
+			request.SubmitDate,
+		)
+		if err != nil {
+			return err
+		}
+		request.SummaryCount += 1
+	}
+
+	// insert tag
+	_, err = tx.Exec(
+		"INSERT INTO Tags VALUES(?,?,?,?,?);",
+		uuid.New().String(),
+		request.ID,
+		request.Cats,
+		request.SubmittedBy,
+		request.SubmitDate,
+	)
+	if err != nil {
+		return err
+	}
+
+	if request.NewLink.Summary != "" {
+		request.Summary = request.NewLink.Summary
+	} else if request.AutoSummary != "" {
+		request.Summary = request.AutoSummary
+	} else {
+		request.Summary = ""
+	}
+
+	// insert link
+	_, err = tx.Exec(
+		`INSERT INTO Links (
+			id,
+			url,
+			submitted_by,
+			submit_date,
+			global_cats,
+			global_summary,
+			img_url,
+			canonical_url,
+			domain
+		)
+		VALUES (?,?,?,?,?,?,?,?,?);`,
+		request.ID,
+		request.URL,
+		request.SubmittedBy,
+		request.SubmitDate,
+		request.Cats,
+		request.Summary,
+		request.ImgURL,
+		sql.NullString{String: request.CanonicalURL, Valid: request.CanonicalURL != ""},
+		mutil.LinkDomain(request.URL),
+	)
+	if err != nil {
+		return err
+	}
+
+	// increment spellfix ranks
+	err = IncrementSpellfixRanksForCats(
+		tx,
+		strings.Split(request.Cats, ","),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
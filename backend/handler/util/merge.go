@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"database/sql"
+
+	"github.com/julianlk522/fitm/db"
+)
+
+// Folds link from_link_id into into_link_id, then deletes it and leaves
+// a redirect. Where a user has a tag, summary, like or copy on both, the
+// into link's is kept (summary likes move to it). Likes and copies by the
+// into link's submitter are dropped.
+// Global cats and summary of into_link_id are left to caller to
+// recalculate after commit
+func MergeLinks(tx *sql.Tx, from_link_id string, into_link_id string, merged_by string, merged_at string) error {
+
+	// Tags
+	_, err := tx.Exec(
+		`DELETE FROM Tags
+		WHERE link_id = ?
+		AND submitted_by IN (
+			SELECT submitted_by FROM Tags WHERE link_id = ?
+		);`,
+		from_link_id,
+		into_link_id,
+	)
+	if err != nil {
+		return err
+	}
+
+	// tags_au trigger only covers cats so user_cats_fts updated manually
+	for _, table := range []string{"Tags", "user_cats_fts"} {
+		_, err = tx.Exec(
+			"UPDATE "+table+" SET link_id = ? WHERE link_id = ?;",
+			into_link_id,
+			from_link_id,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Summaries
+	if err = mergeSummariesOfSameUsers(tx, from_link_id, into_link_id); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"UPDATE Summaries SET link_id = ? WHERE link_id = ?;",
+		into_link_id,
+		from_link_id,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Likes, copies
+	for _, table := range []string{`"Link Likes"`, `"Link Copies"`} {
+		_, err = tx.Exec(
+			`DELETE FROM `+table+`
+			WHERE link_id = ?
+			AND (
+				user_id IN (
+					SELECT user_id FROM `+table+` WHERE link_id = ?
+				)
+				OR user_id IN (
+					SELECT u.id
+					FROM Users u
+					INNER JOIN Links l ON l.submitted_by = u.login_name
+					WHERE l.id = ?
+				)
+			);`,
+			from_link_id,
+			into_link_id,
+			into_link_id,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"UPDATE "+table+" SET link_id = ? WHERE link_id = ?;",
+			into_link_id,
+			from_link_id,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Snapshots
+	_, err = tx.Exec(
+		`UPDATE "Link Snapshots" SET link_id = ? WHERE link_id = ?;`,
+		into_link_id,
+		from_link_id,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Redirects (including to from_link_id from earlier merges)
+	_, err = tx.Exec(
+		`UPDATE "Link Redirects" SET to_link_id = ? WHERE to_link_id = ?;`,
+		into_link_id,
+		from_link_id,
+	)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`INSERT INTO "Link Redirects" VALUES (?,?,?,?);`,
+		from_link_id,
+		into_link_id,
+		merged_by,
+		merged_at,
+	)
+	if err != nil {
+		return err
+	}
+
+	var from_canonical_url sql.NullString
+	err = tx.QueryRow(
+		"SELECT canonical_url FROM Links WHERE id = ?;",
+		from_link_id,
+	).Scan(&from_canonical_url)
+	if err != nil {
+		return err
+	}
+
+	// delete and update spellfix
+	if err = DeleteLinkWithID(tx, from_link_id); err != nil {
+		return err
+	}
+
+	// so from link's URL variants are still detected as duplicates
+	if from_canonical_url.Valid {
+		_, err = tx.Exec(
+			`UPDATE Links
+			SET canonical_url = ?
+			WHERE id = ? AND canonical_url IS NULL;`,
+			from_canonical_url.String,
+			into_link_id,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Moves likes of from link's summaries by users who also summarized into
+// link to their into link summary (unless already liked), then deletes them
+func mergeSummariesOfSameUsers(tx *sql.Tx, from_link_id string, into_link_id string) error {
+	rows, err := tx.Query(
+		`SELECT f.id, i.id
+		FROM Summaries f
+		INNER JOIN Summaries i ON i.submitted_by = f.submitted_by
+		WHERE f.link_id = ? AND i.link_id = ?;`,
+		from_link_id,
+		into_link_id,
+	)
+	if err != nil {
+		return err
+	}
+
+	// from summary ID -> into summary ID
+	summary_ids := map[string]string{}
+	for rows.Next() {
+		var from_summary_id, into_summary_id string
+		if err = rows.Scan(&from_summary_id, &into_summary_id); err != nil {
+			rows.Close()
+			return err
+		}
+		summary_ids[from_summary_id] = into_summary_id
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for from_summary_id, into_summary_id := range summary_ids {
+		_, err = tx.Exec(
+			`UPDATE "Summary Likes"
+			SET summary_id = ?
+			WHERE summary_id = ?
+			AND user_id NOT IN (
+				SELECT user_id FROM "Summary Likes" WHERE summary_id = ?
+			);`,
+			into_summary_id,
+			from_summary_id,
+			into_summary_id,
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, stmt := range []string{
+			`DELETE FROM "Summary Likes" WHERE summary_id = ?;`,
+			`DELETE FROM Summaries WHERE id = ?;`,
+		} {
+			if _, err = tx.Exec(stmt, from_summary_id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ID of link that link_id was merged into ("" if none)
+func GetLinkRedirect(link_id string) (string, error) {
+	var to_link_id string
+	err := db.Client.QueryRow(
+		`SELECT to_link_id FROM "Link Redirects" WHERE from_link_id = ?;`,
+		link_id,
+	).Scan(&to_link_id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return to_link_id, nil
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestMergeLinks(t *testing.T) {
+	stmts := []struct {
+		SQL  string
+		Args []interface{}
+	}{
+		{
+			`INSERT INTO Links (id, url, submitted_by, submit_date, global_cats, global_summary, img_url) VALUES (?,?,?,?,?,?,?);`,
+			[]interface{}{"merge_a", "https://merge.example.com/a", test_login_name, "2024-01-01 00:00:00", "merge", "", ""},
+		},
+		{
+			`INSERT INTO Links (id, url, submitted_by, submit_date, global_cats, global_summary, img_url, canonical_url) VALUES (?,?,?,?,?,?,?,?);`,
+			[]interface{}{"merge_b", "https://merge.example.com/b", "test_req_login_name", "2024-01-02 00:00:00", "merge", "", "", "https://merge.example.com/b"},
+		},
+		// both summarized by jlk
+		{`INSERT INTO Summaries VALUES (?,?,?,?,?);`, []interface{}{"merge_a_summary", "a", "merge_a", test_user_id, "2024-01-01 00:00:00"}},
+		{`INSERT INTO Summaries VALUES (?,?,?,?,?);`, []interface{}{"merge_b_summary", "b", "merge_b", test_user_id, "2024-01-02 00:00:00"}},
+		{`INSERT INTO Summaries VALUES (?,?,?,?,?);`, []interface{}{"merge_b_summary_2", "b2", "merge_b", test_req_user_id, "2024-01-02 00:00:00"}},
+		// user 13 liked both jlk summaries, user 14 only b's
+		{`INSERT INTO "Summary Likes" VALUES (?,?,?);`, []interface{}{"merge_sl_1", "merge_a_summary", test_req_user_id}},
+		{`INSERT INTO "Summary Likes" VALUES (?,?,?);`, []interface{}{"merge_sl_2", "merge_b_summary", test_req_user_id}},
+		{`INSERT INTO "Summary Likes" VALUES (?,?,?);`, []interface{}{"merge_sl_3", "merge_b_summary", "14"}},
+		// jlk cannot like own link a
+		{`INSERT INTO "Link Likes" VALUES (?,?,?);`, []interface{}{"merge_ll_1", "merge_b", test_user_id}},
+		{`INSERT INTO "Link Copies" VALUES (?,?,?,?);`, []interface{}{"merge_lc_1", "merge_a", "14", "2024-01-01 00:00:00"}},
+		{`INSERT INTO "Link Copies" VALUES (?,?,?,?);`, []interface{}{"merge_lc_2", "merge_b", "14", "2024-01-01 00:00:00"}},
+		// earlier merge into b
+		{`INSERT INTO "Link Redirects" VALUES (?,?,?,?);`, []interface{}{"merge_c", "merge_b", test_user_id, "2024-01-03 00:00:00"}},
+	}
+	for _, stmt := range stmts {
+		if _, err := TestClient.Exec(stmt.SQL, stmt.Args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := IncrementSpellfixRanksForCats(nil, []string{"merge"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := TestClient.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err = MergeLinks(tx, "merge_b", "merge_a", test_user_id, "2024-01-04 00:00:00"); err != nil {
+		t.Fatal(err)
+	} else if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var counts = []struct {
+		SQL  string
+		Want int
+	}{
+		{`SELECT count(*) FROM Links WHERE id = 'merge_b';`, 0},
+		{`SELECT count(*) FROM Summaries WHERE link_id = 'merge_a';`, 2},
+		{`SELECT count(*) FROM "Summary Likes" WHERE summary_id = 'merge_a_summary';`, 2},
+		{`SELECT count(*) FROM "Summary Likes" WHERE summary_id = 'merge_b_summary';`, 0},
+		{`SELECT count(*) FROM "Link Likes" WHERE link_id IN ('merge_a', 'merge_b');`, 0},
+		{`SELECT count(*) FROM "Link Copies" WHERE link_id IN ('merge_a', 'merge_b');`, 1},
+	}
+	for _, c := range counts {
+		var count int
+		if err = TestClient.QueryRow(c.SQL).Scan(&count); err != nil {
+			t.Fatal(err)
+		} else if count != c.Want {
+			t.Fatalf("%s: got %d, want %d", c.SQL, count, c.Want)
+		}
+	}
+
+	for _, from_link_id := range []string{"merge_b", "merge_c"} {
+		if to_link_id, err := GetLinkRedirect(from_link_id); err != nil {
+			t.Fatal(err)
+		} else if to_link_id != "merge_a" {
+			t.Fatalf("%s: got redirect to %q, want merge_a", from_link_id, to_link_id)
+		}
+	}
+
+	// b's canonical URL carried over
+	var canonical_url sql.NullString
+	if err = TestClient.QueryRow(
+		`SELECT canonical_url FROM Links WHERE id = 'merge_a';`,
+	).Scan(&canonical_url); err != nil {
+		t.Fatal(err)
+	} else if canonical_url.String != "https://merge.example.com/b" {
+		t.Fatalf("got canonical URL %q", canonical_url.String)
+	}
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"github.com/julianlk522/fitm/db"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+	"github.com/julianlk522/fitm/query"
+)
+
+// Get mutes
+func ScanMutedUsers(muted_sql *query.MutedUsers) (*[]model.MutedUser, error) {
+	rows, err := db.Client.Query(muted_sql.Text, muted_sql.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	muted := []model.MutedUser{}
+	for rows.Next() {
+		var mu model.MutedUser
+		if err := rows.Scan(&mu.LoginName, &mu.IsBlock, &mu.Created); err != nil {
+			return nil, err
+		}
+		muted = append(muted, mu)
+	}
+
+	return &muted, nil
+}
+
+// Mute / block, unmute / unblock
+func GetMuteStatus(user_id string, muted_id string) (is_muted bool, is_block bool, err error) {
+	err = db.Client.QueryRow(
+		`SELECT is_block FROM "User Mutes" WHERE user_id = ? AND muted_id = ?;`,
+		user_id,
+		muted_id,
+	).Scan(&is_block)
+	if err == sql.ErrNoRows {
+		return false, false, nil
+	} else if err != nil {
+		return false, false, err
+	}
+
+	return true, is_block, nil
+}
+
+// blocking an already-muted user upgrades the existing mute
+// (and removes blocked user's follow)
+func MuteUser(user_id string, muted_id string, is_block bool) error {
+	if is_block {
+		if err := UnfollowUser(muted_id, user_id); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Client.Exec(
+		`INSERT INTO "User Mutes" VALUES (?,?,?,?,?)
+		ON CONFLICT(user_id, muted_id) DO UPDATE SET is_block = excluded.is_block;`,
+		uuid.New().String(),
+		user_id,
+		muted_id,
+		is_block,
+		mutil.NEW_LONG_TIMESTAMP(),
+	)
+
+	return err
+}
+
+func UnmuteUser(user_id string, muted_id string) error {
+	_, err := db.Client.Exec(
+		`DELETE FROM "User Mutes" WHERE user_id = ? AND muted_id = ?;`,
+		user_id,
+		muted_id,
+	)
+
+	return err
+}
+
+// Like / copy link
+func LinkSubmitterHasBlockedUser(link_id string, user_id string) (bool, error) {
+	var is_blocked bool
+	err := db.Client.QueryRow(
+		`SELECT EXISTS(
+			SELECT 1
+			FROM "User Mutes" um
+			INNER JOIN Users u ON u.id = um.user_id
+			INNER JOIN Links l ON l.submitted_by = u.login_name
+			WHERE l.id = ?
+			AND um.muted_id = ?
+			AND um.is_block = 1
+		);`,
+		link_id,
+		user_id,
+	).Scan(&is_blocked)
+	if err != nil {
+		return false, err
+	}
+
+	return is_blocked, nil
+}
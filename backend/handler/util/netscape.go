@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+// top-level browser folders that say nothing about the links inside
+var bookmark_root_folders = []string{
+	"bookmarks",
+	"bookmarks bar",
+	"bookmarks toolbar",
+	"bookmarks menu",
+	"favorites bar",
+	"other bookmarks",
+	"mobile bookmarks",
+}
+
+// Netscape bookmark file (exported by every major browser):
+// folders are <H3> followed by a nested <DL>, links are <A HREF>
+// with optional TAGS attribute (Firefox) and optional <DD> description
+// after
+func ParseNetscapeBookmarks(r io.Reader) ([]model.ImportedBookmark, error) {
+	return parseNetscapeBookmarks(r, false)
+}
+
+// Delicious export: Netscape format without folders where TAGS are the
+// cats and PRIVATE="1" marks bookmarks not shared publicly (skipped, since
+// links are public)
+func ParseDeliciousBookmarks(r io.Reader) ([]model.ImportedBookmark, error) {
+	return parseNetscapeBookmarks(r, true)
+}
+
+func parseNetscapeBookmarks(r io.Reader, is_delicious bool) ([]model.ImportedBookmark, error) {
+	z := html.NewTokenizer(r)
+
+	var bookmarks []model.ImportedBookmark
+
+	// folder names for open <DL>s ("" for unnamed / skipped)
+	var folders []string
+	var pending_folder string
+	var in_folder_name bool
+
+	var current *model.ImportedBookmark
+	var current_tags []string
+
+	// index of bookmark a following <DD> describes
+	described := -1
+	var in_description bool
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return bookmarks, nil
+			}
+			return nil, z.Err()
+
+		case html.StartTagToken:
+			name, has_attrs := z.TagName()
+			if string(name) != "dd" {
+				described = -1
+				in_description = false
+			}
+
+			switch string(name) {
+			case "dd":
+				in_description = described >= 0
+
+			case "h3":
+				in_folder_name = true
+				pending_folder = ""
+
+				// Chrome / Firefox toolbar folder
+				for has_attrs {
+					var key, val []byte
+					key, val, has_attrs = z.TagAttr()
+					if string(key) == "personal_toolbar_folder" && string(val) == "true" {
+						in_folder_name = false
+					}
+				}
+
+			case "dl":
+				folders = append(folders, pending_folder)
+				pending_folder = ""
+
+			case "a":
+				var href, tags string
+				var is_private bool
+				for has_attrs {
+					var key, val []byte
+					key, val, has_attrs = z.TagAttr()
+					switch string(key) {
+					case "href":
+						href = strings.TrimSpace(string(val))
+					case "tags":
+						tags = string(val)
+					case "private":
+						is_private = string(val) == "1"
+					}
+				}
+
+				if !IsImportableURL(href) || (is_delicious && is_private) {
+					continue
+				}
+				current = &model.ImportedBookmark{URL: href, Starred: true}
+				current_tags = strings.Split(tags, ",")
+			}
+
+		case html.TextToken:
+			if in_folder_name {
+				pending_folder += string(z.Text())
+			} else if in_description {
+				bookmarks[described].Summary += string(z.Text())
+			} else if current != nil {
+				current.Title += string(z.Text())
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "h3":
+				in_folder_name = false
+			case "dl":
+				described = -1
+				in_description = false
+				if len(folders) > 0 {
+					folders = folders[:len(folders)-1]
+				}
+			case "a":
+				if current != nil {
+					current.Title = strings.TrimSpace(current.Title)
+					if is_delicious {
+						current.Cats = BookmarkCats(current_tags)
+					} else {
+						current.Cats = BookmarkCats(slices.Concat(folders, current_tags))
+					}
+					bookmarks = append(bookmarks, *current)
+					described = len(bookmarks) - 1
+					current = nil
+				}
+			}
+		}
+	}
+}
+
+func IsImportableURL(url string) bool {
+	lower := strings.ToLower(url)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// Builds cats from folder path segments / tags, in order, within
+// NUM_CATS_LIMIT and CAT_CHAR_LIMIT (IMPORT_DEFAULT_CAT if none)
+func BookmarkCats(candidates []string) string {
+	var cats []string
+
+	for _, cat := range candidates {
+		cat = strings.ReplaceAll(cat, ",", " ")
+		cat = mutil.TrimExcessAndTrailingSpaces(cat)
+		if cat == "" || isBookmarkRootFolder(cat) {
+			continue
+		}
+		if strings.EqualFold(cat, "nsfw") {
+			cat = "NSFW"
+		}
+		cat = truncateToLimit(cat, mutil.CAT_CHAR_LIMIT)
+
+		duplicate := false
+		for _, existing := range cats {
+			if strings.EqualFold(existing, cat) {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		cats = append(cats, cat)
+		if len(cats) == mutil.NUM_CATS_LIMIT {
+			break
+		}
+	}
+
+	if len(cats) == 0 {
+		return mutil.IMPORT_DEFAULT_CAT
+	}
+
+	return AlphabetizeCats(strings.Join(cats, ","))
+}
+
+func isBookmarkRootFolder(folder string) bool {
+	for _, root := range bookmark_root_folders {
+		if strings.EqualFold(folder, root) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// to limit bytes without splitting a multibyte char
+func truncateToLimit(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+
+	return strings.TrimSpace(s[:cut])
+}
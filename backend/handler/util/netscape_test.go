@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+const test_netscape_bookmarks = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1700000000" PERSONAL_TOOLBAR_FOLDER="true">Bookmarks bar</H3>
+    <DL><p>
+        <DT><A HREF="https://example.com/toolbar" ADD_DATE="1700000000">Toolbar link</A>
+        <DT><H3>Programming</H3>
+        <DL><p>
+            <DT><A HREF="https://go.dev" TAGS="golang,Programming">The Go  Programming Language</A>
+            <DD>Build simple, secure, scalable systems
+            <DT><H3>Rust, etc.</H3>
+            <DL><p>
+                <DT><A HREF="https://www.rust-lang.org">Rust</A>
+            </DL><p>
+        </DL><p>
+        <DT><A HREF="javascript:alert(1)">Bookmarklet</A>
+        <DT><A HREF="place:sort=8">Firefox smart folder</A>
+    </DL><p>
+    <DT><H3>Other Bookmarks</H3>
+    <DL><p>
+        <DT><A HREF="https://nsfw.example.com" TAGS="nsfw">NSFW</A>
+    </DL><p>
+</DL><p>
+`
+
+func TestParseNetscapeBookmarks(t *testing.T) {
+	bookmarks, err := ParseNetscapeBookmarks(strings.NewReader(test_netscape_bookmarks))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want = []struct {
+		URL     string
+		Title   string
+		Cats    string
+		Summary string
+	}{
+		{"https://example.com/toolbar", "Toolbar link", mutil.IMPORT_DEFAULT_CAT, ""},
+		{"https://go.dev", "The Go  Programming Language", "golang,Programming", "Build simple, secure, scalable systems"},
+		{"https://www.rust-lang.org", "Rust", "Programming,Rust etc.", ""},
+		{"https://nsfw.example.com", "NSFW", "NSFW", ""},
+	}
+
+	if len(bookmarks) != len(want) {
+		t.Fatalf("got %d bookmarks, want %d: %+v", len(bookmarks), len(want), bookmarks)
+	}
+	for i, w := range want {
+		b := bookmarks[i]
+		if b.URL != w.URL || b.Title != w.Title || b.Cats != w.Cats ||
+			strings.TrimSpace(b.Summary) != w.Summary || !b.Starred {
+			t.Fatalf("bookmark %d: got %+v, want %+v", i, b, w)
+		}
+	}
+}
+
+func TestParseDeliciousBookmarks(t *testing.T) {
+	const delicious = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+<DT><A HREF="https://go.dev" ADD_DATE="1200000000" PRIVATE="0" TAGS="golang,programming">Go</A>
+<DD>The Go site
+<DT><A HREF="https://diary.example.com" ADD_DATE="1200000000" PRIVATE="1" TAGS="personal">Diary</A>
+<DT><A HREF="https://untagged.example.com" ADD_DATE="1200000000" PRIVATE="0" TAGS="">Untagged</A>
+</DL><p>
+`
+
+	bookmarks, err := ParseDeliciousBookmarks(strings.NewReader(delicious))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkImportedBookmarks(t, bookmarks, []model.ImportedBookmark{
+		{URL: "https://go.dev", Title: "Go", Cats: "golang,programming", Summary: "The Go site\n", Starred: true},
+		{URL: "https://untagged.example.com", Title: "Untagged", Cats: mutil.IMPORT_DEFAULT_CAT, Starred: true},
+	})
+}
+
+func TestBookmarkCats(t *testing.T) {
+	var many_cats []string
+	for i := 0; i < mutil.NUM_CATS_LIMIT+5; i++ {
+		many_cats = append(many_cats, "cat"+strings.Repeat("x", i))
+	}
+
+	var test_cats = []struct {
+		Candidates []string
+		Want       string
+	}{
+		{[]string{}, mutil.IMPORT_DEFAULT_CAT},
+		{[]string{"", "  ", "Bookmarks Menu"}, mutil.IMPORT_DEFAULT_CAT},
+		{[]string{"a", "A", "b"}, "a,b"},
+		{[]string{"a,b"}, "a b"},
+		{[]string{"nsfw"}, "NSFW"},
+		{[]string{strings.Repeat("a", mutil.CAT_CHAR_LIMIT+5)}, strings.Repeat("a", mutil.CAT_CHAR_LIMIT)},
+		// multibyte char straddling limit dropped whole
+		{[]string{strings.Repeat("a", mutil.CAT_CHAR_LIMIT-1) + "é"}, strings.Repeat("a", mutil.CAT_CHAR_LIMIT-1)},
+		{many_cats, strings.Join(many_cats[:mutil.NUM_CATS_LIMIT], ",")},
+	}
+
+	for _, tc := range test_cats {
+		got := BookmarkCats(tc.Candidates)
+		if got != tc.Want {
+			t.Fatalf("%v: got %q, want %q", tc.Candidates, got, tc.Want)
+		}
+		if mutil.HasTooLongCats(got) || mutil.HasTooManyCats(got) || mutil.HasDuplicateCats(got) {
+			t.Fatalf("%v: got invalid cats %q", tc.Candidates, got)
+		}
+	}
+}
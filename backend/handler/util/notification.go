@@ -0,0 +1,312 @@
+package handler
+
+import (
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"github.com/julianlk522/fitm/db"
+	e "github.com/julianlk522/fitm/error"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+// Notify
+func NotifyLinkSubmitter(link_id string, notification_type string, actor_id string) error {
+	var submitter_id string
+	err := db.Client.QueryRow(
+		`SELECT u.id
+		FROM Links l
+		INNER JOIN Users u ON u.login_name = l.submitted_by
+		WHERE l.id = ?;`,
+		link_id,
+	).Scan(&submitter_id)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return notify(submitter_id, notification_type, link_id, "", actor_id)
+}
+
+func NotifySummarySubmitter(summary_id string, notification_type string, actor_id string) error {
+	var submitter_id, link_id string
+	err := db.Client.QueryRow(
+		`SELECT submitted_by, link_id FROM Summaries WHERE id = ?;`,
+		summary_id,
+	).Scan(&submitter_id, &link_id)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return notify(submitter_id, notification_type, link_id, summary_id, actor_id)
+}
+
+// No notification for own actions, actions by muted users, the auto
+// summary user or disabled notification types.
+// Otherwise batched into recipient's unread notification for the same
+// target, if any (counting each actor once)
+func notify(user_id string, notification_type string, link_id string, summary_id string, actor_id string) error {
+	if user_id == actor_id || user_id == db.AUTO_SUMMARY_USER_ID {
+		return nil
+	}
+
+	if is_muted, _, err := GetMuteStatus(user_id, actor_id); err != nil {
+		return err
+	} else if is_muted {
+		return nil
+	}
+
+	if enabled, err := NotificationTypeEnabled(user_id, notification_type); err != nil {
+		return err
+	} else if !enabled {
+		return nil
+	}
+
+	tx, err := db.Client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := mutil.NEW_LONG_TIMESTAMP()
+	if _, err = tx.Exec(
+		`INSERT INTO Notifications
+		(id, user_id, type, link_id, summary_id, last_actor_id, created, last_updated)
+		VALUES (?,?,?,?,?,?,?,?)
+		ON CONFLICT(user_id, type, link_id, summary_id) WHERE is_read = 0 DO UPDATE SET
+			last_actor_id = excluded.last_actor_id,
+			last_updated = excluded.last_updated;`,
+		uuid.New().String(),
+		user_id,
+		notification_type,
+		link_id,
+		summary_id,
+		actor_id,
+		now,
+		now,
+	); err != nil {
+		return err
+	}
+
+	var notification_id string
+	if err = tx.QueryRow(
+		`SELECT id
+		FROM Notifications
+		WHERE user_id = ?
+		AND type = ?
+		AND link_id = ?
+		AND summary_id = ?
+		AND is_read = 0;`,
+		user_id,
+		notification_type,
+		link_id,
+		summary_id,
+	).Scan(&notification_id); err != nil {
+		return err
+	}
+
+	// count distinct actors (e.g., A, B, A is 2)
+	if _, err = tx.Exec(
+		`INSERT OR IGNORE INTO "Notification Actors" VALUES (?,?);`,
+		notification_id,
+		actor_id,
+	); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(
+		`UPDATE Notifications
+		SET actor_count = (
+			SELECT count(*)
+			FROM "Notification Actors"
+			WHERE notification_id = ?
+		)
+		WHERE id = ?;`,
+		notification_id,
+		notification_id,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Preferences
+func NotificationTypeEnabled(user_id string, notification_type string) (bool, error) {
+	var enabled bool
+	err := db.Client.QueryRow(
+		`SELECT enabled
+		FROM "Notification Preferences"
+		WHERE user_id = ? AND type = ?;`,
+		user_id,
+		notification_type,
+	).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return enabled, nil
+}
+
+// defaults (all enabled) for types user has never set
+func GetNotificationPreferences(user_id string) (model.NotificationPreferences, error) {
+	preferences := model.NewDefaultNotificationPreferences()
+
+	rows, err := db.Client.Query(
+		`SELECT type, enabled
+		FROM "Notification Preferences"
+		WHERE user_id = ?;`,
+		user_id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var notification_type string
+		var enabled bool
+		if err := rows.Scan(&notification_type, &enabled); err != nil {
+			return nil, err
+		}
+		preferences[notification_type] = enabled
+	}
+
+	return preferences, nil
+}
+
+func SaveNotificationPreferences(user_id string, request *model.EditNotificationPreferencesRequest) error {
+	tx, err := db.Client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for notification_type, enabled := range *request {
+		if _, err = tx.Exec(
+			`INSERT INTO "Notification Preferences" VALUES (?,?,?)
+			ON CONFLICT(user_id, type) DO UPDATE SET enabled = excluded.enabled;`,
+			user_id,
+			notification_type,
+			enabled,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Get
+func GetNotifications(user_id string, page int) (*model.PaginatedNotifications, error) {
+	limit := mutil.NOTIFICATIONS_PAGE_LIMIT
+	rows, err := db.Client.Query(
+		`SELECT
+			n.id,
+			n.type,
+			n.link_id,
+			n.summary_id,
+			COALESCE(u.login_name, ''),
+			n.actor_count,
+			n.is_read,
+			n.created,
+			n.last_updated
+		FROM Notifications n
+		LEFT JOIN Users u ON u.id = n.last_actor_id
+		WHERE n.user_id = ?
+		ORDER BY n.last_updated DESC, n.id
+		LIMIT ? OFFSET ?;`,
+		user_id,
+		limit+1,
+		(page-1)*limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []model.Notification{}
+	for rows.Next() {
+		var n model.Notification
+		if err := rows.Scan(
+			&n.ID,
+			&n.Type,
+			&n.LinkID,
+			&n.SummaryID,
+			&n.LastActor,
+			&n.ActorCount,
+			&n.IsRead,
+			&n.Created,
+			&n.LastUpdated,
+		); err != nil {
+			return nil, err
+		}
+		n.SetMessage()
+		notifications = append(notifications, n)
+	}
+
+	unread_count, err := GetNumUnreadNotifications(user_id)
+	if err != nil {
+		return nil, err
+	}
+
+	paginated := &model.PaginatedNotifications{
+		Notifications: &notifications,
+		UnreadCount:   unread_count,
+		NextPage:      -1,
+	}
+	if len(notifications) == limit+1 {
+		sliced := notifications[0:limit]
+		paginated.Notifications = &sliced
+		paginated.NextPage = page + 1
+	}
+
+	return paginated, nil
+}
+
+func GetNumUnreadNotifications(user_id string) (int, error) {
+	var unread_count int
+	err := db.Client.QueryRow(
+		`SELECT count(*) FROM Notifications WHERE user_id = ? AND is_read = 0;`,
+		user_id,
+	).Scan(&unread_count)
+	if err != nil {
+		return 0, err
+	}
+
+	return unread_count, nil
+}
+
+// Mark read
+func MarkNotificationRead(user_id string, notification_id string) error {
+	res, err := db.Client.Exec(
+		`UPDATE Notifications SET is_read = 1 WHERE id = ? AND user_id = ?;`,
+		notification_id,
+		user_id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if num_rows, err := res.RowsAffected(); err != nil {
+		return err
+	} else if num_rows == 0 {
+		return e.ErrNoNotificationWithID
+	}
+
+	return nil
+}
+
+func MarkAllNotificationsRead(user_id string) error {
+	_, err := db.Client.Exec(
+		`UPDATE Notifications SET is_read = 1 WHERE user_id = ? AND is_read = 0;`,
+		user_id,
+	)
+
+	return err
+}
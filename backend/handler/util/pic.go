@@ -0,0 +1,276 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"golang.org/x/image/draw"
+
+	"github.com/julianlk522/fitm/db"
+	e "github.com/julianlk522/fitm/error"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+	"github.com/julianlk522/fitm/storage"
+)
+
+// sniffed content type -> image package format name
+var accepted_pic_formats = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/webp": "webp",
+}
+
+// Users.pfp for processed pics: "{sha256 of largest rendition}.jpg"
+var content_hash_pic_name_regex = regexp.MustCompile(`^([0-9a-f]{64})\.jpg$`)
+
+// Upload profile pic
+// Sniff real format from file contents, ignoring client filename and
+// Content-Type
+func SniffProfilePicFormat(head []byte) (string, error) {
+	format, ok := accepted_pic_formats[http.DetectContentType(head)]
+	if !ok {
+		return "", e.ErrInvalidFileType
+	}
+
+	return format, nil
+}
+
+// Check header dimensions before allocating for full decode
+func DecodeProfilePic(file io.ReadSeeker) (image.Image, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, e.ErrInvalidFileType
+	}
+
+	sniffed_format, err := SniffProfilePicFormat(head[:n])
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	config, format, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, err
+	} else if format != sniffed_format {
+		return nil, e.ErrProfilePicFormatMismatch
+	}
+
+	if config.Width > mutil.PFP_MAX_DIMENSION ||
+		config.Height > mutil.PFP_MAX_DIMENSION ||
+		config.Width*config.Height > mutil.PFP_MAX_PIXELS {
+		return nil, e.ProfilePicDimensionsExceedLimit(
+			mutil.PFP_MAX_DIMENSION,
+			mutil.PFP_MAX_PIXELS,
+		)
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
+
+func CropToCenterSquare(img image.Image) image.Image {
+	b := img.Bounds()
+	side := min(b.Dx(), b.Dy())
+	x0 := b.Min.X + (b.Dx()-side)/2
+	y0 := b.Min.Y + (b.Dy()-side)/2
+	crop := image.Rect(x0, y0, x0+side, y0+side)
+
+	if sub_img, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return sub_img.SubImage(crop)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), img, crop.Min, draw.Src)
+	return dst
+}
+
+// Re-encoded from pixels only, so no EXIF / GPS or other metadata carries
+// over. Transparent areas are flattened onto white.
+// Returns content hash and encoded JPEG for each of PFP_SIZES.
+func ProcessProfilePic(img image.Image) (string, map[int][]byte, error) {
+	square := CropToCenterSquare(img)
+	renditions := make(map[int][]byte, len(mutil.PFP_SIZES))
+
+	for _, size := range mutil.PFP_SIZES {
+		dst := image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+		draw.CatmullRom.Scale(dst, dst.Bounds(), square, square.Bounds(), draw.Over, nil)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: mutil.PFP_JPEG_QUALITY}); err != nil {
+			return "", nil, err
+		}
+		renditions[size] = buf.Bytes()
+	}
+
+	sum := sha256.Sum256(renditions[slices.Max(mutil.PFP_SIZES)])
+	return hex.EncodeToString(sum[:]), renditions, nil
+}
+
+// Identical pics share blobs, so existing renditions are kept
+func SaveProfilePicRenditions(store storage.BlobStore, hash string, renditions map[int][]byte) error {
+	for size, data := range renditions {
+		key := ProfilePicRenditionName(hash, size)
+		exists, err := store.Exists(key)
+		if err != nil {
+			return err
+		} else if exists {
+			continue
+		}
+
+		if err = store.Put(key, bytes.NewReader(data), "image/jpeg"); err != nil {
+			log.Printf("could not save profile pic rendition %s: %s", key, err)
+			return e.ErrCouldNotCopyProfilePic
+		}
+	}
+
+	return nil
+}
+
+func ProfilePicName(hash string) string {
+	return hash + ".jpg"
+}
+
+func ProfilePicRenditionName(hash string, size int) string {
+	return fmt.Sprintf("%s_%d.jpg", hash, size)
+}
+
+// Blob keys belonging to a Users.pfp value
+// (all renditions, or the file itself for pre-pipeline uploads)
+func ProfilePicKeys(pfp string) []string {
+	if hash := GetProfilePicHash(pfp); hash != "" {
+		keys := make([]string, 0, len(mutil.PFP_SIZES))
+		for _, size := range mutil.PFP_SIZES {
+			keys = append(keys, ProfilePicRenditionName(hash, size))
+		}
+		return keys
+	} else if storage.ValidateKey(pfp) == nil {
+		return []string{pfp}
+	}
+
+	return nil
+}
+
+// Replace / delete profile pic
+// Identical uploads share blobs, so only delete once no user has pfp
+func DeleteProfilePicIfUnreferenced(store storage.BlobStore, pfp string) error {
+	var is_referenced bool
+	err := db.Client.QueryRow(
+		"SELECT EXISTS(SELECT 1 FROM Users WHERE pfp = ?);",
+		pfp,
+	).Scan(&is_referenced)
+	if err != nil {
+		return err
+	} else if is_referenced {
+		return nil
+	}
+
+	for _, key := range ProfilePicKeys(pfp) {
+		if err = store.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GC
+// Blobs not belonging to any Users.pfp, excluding those newer than
+// min_age (may belong to an upload still in progress)
+func CollectOrphanedProfilePics(store storage.BlobStore, remove bool, min_age time.Duration) (*model.ProfilePicGCReport, error) {
+	rows, err := db.Client.Query("SELECT pfp FROM Users WHERE pfp IS NOT NULL;")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	referenced := map[string]bool{}
+	for rows.Next() {
+		var pfp string
+		if err = rows.Scan(&pfp); err != nil {
+			return nil, err
+		}
+		for _, key := range ProfilePicKeys(pfp) {
+			referenced[key] = true
+		}
+	}
+
+	blobs, err := store.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.ProfilePicGCReport{
+		Scanned: len(blobs),
+		Orphans: []string{},
+	}
+	cutoff := time.Now().Add(-min_age)
+
+	for _, blob := range blobs {
+		if referenced[blob.Key] {
+			report.Referenced++
+			continue
+		} else if blob.LastModified.After(cutoff) {
+			continue
+		}
+
+		report.Orphans = append(report.Orphans, blob.Key)
+		report.OrphanBytes += blob.Size
+
+		if remove {
+			if err = store.Delete(blob.Key); err != nil {
+				return report, err
+			}
+			report.Removed++
+		}
+	}
+
+	return report, nil
+}
+
+// Get profile pic
+// "" if file_name is a pre-pipeline upload (no renditions)
+func GetProfilePicHash(file_name string) string {
+	matches := content_hash_pic_name_regex.FindStringSubmatch(file_name)
+	if matches == nil {
+		return ""
+	}
+
+	return matches[1]
+}
+
+func ParseProfilePicSize(size_params string) (int, error) {
+	if size_params == "" {
+		return mutil.PFP_DEFAULT_SIZE, nil
+	}
+
+	size, err := strconv.Atoi(size_params)
+	if err != nil || !slices.Contains(mutil.PFP_SIZES, size) {
+		return 0, e.InvalidProfilePicSize(mutil.PFP_SIZES)
+	}
+
+	return size, nil
+}
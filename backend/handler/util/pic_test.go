@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"bytes"
+	"database/sql"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+
+	mutil "github.com/julianlk522/fitm/model/util"
+	"github.com/julianlk522/fitm/storage"
+)
+
+func encodeTestPNG(t *testing.T, width int, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		img.Set(x, height/2, color.NRGBA{255, 0, 0, 255})
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestSniffProfilePicFormat(t *testing.T) {
+	var test_heads = []struct {
+		Head   []byte
+		Format string
+		Valid  bool
+	}{
+		{encodeTestPNG(t, 4, 4), "png", true},
+		{[]byte("<html><body>not an image</body></html>"), "", false},
+		{[]byte("GIF89a"), "", false},
+	}
+
+	for _, th := range test_heads {
+		format, err := SniffProfilePicFormat(th.Head)
+		if th.Valid && err != nil {
+			t.Fatal(err)
+		} else if !th.Valid && err == nil {
+			t.Fatalf("expected error for head %q", th.Head[:6])
+		} else if format != th.Format {
+			t.Fatalf("got format %s, want %s", format, th.Format)
+		}
+	}
+}
+
+func TestDecodeProfilePic(t *testing.T) {
+	var test_pics = []struct {
+		Name  string
+		Data  []byte
+		Valid bool
+	}{
+		{"small png", encodeTestPNG(t, 40, 30), true},
+		{"too tall", encodeTestPNG(t, 1, mutil.PFP_MAX_DIMENSION+1), false},
+		{"not an image", []byte("hello"), false},
+		// valid PNG signature but truncated
+		{"truncated", encodeTestPNG(t, 40, 30)[:20], false},
+	}
+
+	for _, tp := range test_pics {
+		_, err := DecodeProfilePic(bytes.NewReader(tp.Data))
+		if tp.Valid && err != nil {
+			t.Fatalf("%s: %s", tp.Name, err)
+		} else if !tp.Valid && err == nil {
+			t.Fatalf("%s: expected error", tp.Name)
+		}
+	}
+}
+
+func TestCropToCenterSquare(t *testing.T) {
+	var test_sizes = []struct {
+		Width  int
+		Height int
+		Side   int
+	}{
+		{40, 30, 30},
+		{30, 40, 30},
+		{25, 25, 25},
+	}
+
+	for _, ts := range test_sizes {
+		img := image.NewRGBA(image.Rect(0, 0, ts.Width, ts.Height))
+		b := CropToCenterSquare(img).Bounds()
+		if b.Dx() != ts.Side || b.Dy() != ts.Side {
+			t.Fatalf("got %dx%d, want %dx%d", b.Dx(), b.Dy(), ts.Side, ts.Side)
+		}
+	}
+}
+
+func TestProcessProfilePic(t *testing.T) {
+	img, err := DecodeProfilePic(bytes.NewReader(encodeTestPNG(t, 60, 40)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash, renditions, err := ProcessProfilePic(img)
+	if err != nil {
+		t.Fatal(err)
+	} else if GetProfilePicHash(ProfilePicName(hash)) != hash {
+		t.Fatalf("invalid hash %s", hash)
+	}
+
+	for _, size := range mutil.PFP_SIZES {
+		data, ok := renditions[size]
+		if !ok {
+			t.Fatalf("missing %dpx rendition", size)
+		}
+
+		// no metadata segments
+		if bytes.Contains(data, []byte("Exif")) {
+			t.Fatalf("%dpx rendition contains EXIF", size)
+		}
+
+		config, err := jpeg.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		} else if config.Width != size || config.Height != size {
+			t.Fatalf("got %dx%d, want %dx%d", config.Width, config.Height, size, size)
+		}
+	}
+
+	// same pic, same hash
+	hash2, _, err := ProcessProfilePic(img)
+	if err != nil {
+		t.Fatal(err)
+	} else if hash2 != hash {
+		t.Fatalf("got hash %s, want %s", hash2, hash)
+	}
+
+	// saved under content hash
+	store := storage.NewFSStore(t.TempDir())
+	if err = SaveProfilePicRenditions(store, hash, renditions); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range ProfilePicKeys(ProfilePicName(hash)) {
+		if exists, err := store.Exists(key); err != nil || !exists {
+			t.Fatalf("expected %s to exist (err %v)", key, err)
+		}
+	}
+}
+
+func TestProfilePicKeys(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+
+	var test_pfps = []struct {
+		PFP      string
+		NumKeys  int
+		FirstKey string
+	}{
+		{ProfilePicName(hash), len(mutil.PFP_SIZES), ProfilePicRenditionName(hash, mutil.PFP_SIZES[0])},
+		// pre-pipeline upload
+		{"5bd3d0c4-7b3e-4a3b-9d6b-2b2f8f0f8e1a.png", 1, "5bd3d0c4-7b3e-4a3b-9d6b-2b2f8f0f8e1a.png"},
+		{"../../fitm.db", 0, ""},
+	}
+
+	for _, tp := range test_pfps {
+		keys := ProfilePicKeys(tp.PFP)
+		if len(keys) != tp.NumKeys {
+			t.Fatalf("%s: got %d keys, want %d", tp.PFP, len(keys), tp.NumKeys)
+		} else if len(keys) > 0 && keys[0] != tp.FirstKey {
+			t.Fatalf("%s: got first key %s, want %s", tp.PFP, keys[0], tp.FirstKey)
+		}
+	}
+}
+
+func TestCollectOrphanedProfilePics(t *testing.T) {
+	store := storage.NewFSStore(t.TempDir())
+
+	var referenced_pfp string
+	err := TestClient.QueryRow(
+		"SELECT pfp FROM Users WHERE pfp IS NOT NULL LIMIT 1;",
+	).Scan(&referenced_pfp)
+	if err == sql.ErrNoRows {
+		referenced_pfp = "gc_test_referenced.png"
+		if _, err = TestClient.Exec(
+			"UPDATE Users SET pfp = ? WHERE id = ?;",
+			referenced_pfp,
+			test_req_user_id,
+		); err != nil {
+			t.Fatal(err)
+		}
+	} else if err != nil {
+		t.Fatal(err)
+	}
+
+	orphaned_pfp := ProfilePicName(strings.Repeat("cd", 32))
+	for _, key := range append(ProfilePicKeys(referenced_pfp), ProfilePicKeys(orphaned_pfp)...) {
+		if err = store.Put(key, strings.NewReader("x"), "image/jpeg"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// all too new
+	report, err := CollectOrphanedProfilePics(store, true, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(report.Orphans) != 0 || report.Removed != 0 {
+		t.Fatalf("got %+v, want no orphans", report)
+	}
+
+	// report only
+	report, err = CollectOrphanedProfilePics(store, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(report.Orphans) != len(mutil.PFP_SIZES) || report.Removed != 0 {
+		t.Fatalf("got %+v, want %d orphans, 0 removed", report, len(mutil.PFP_SIZES))
+	} else if report.Referenced != len(ProfilePicKeys(referenced_pfp)) {
+		t.Fatalf("got %d referenced, want %d", report.Referenced, len(ProfilePicKeys(referenced_pfp)))
+	}
+
+	// remove
+	report, err = CollectOrphanedProfilePics(store, true, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if report.Removed != len(mutil.PFP_SIZES) {
+		t.Fatalf("got %d removed, want %d", report.Removed, len(mutil.PFP_SIZES))
+	}
+	blobs, err := store.List("")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(blobs) != len(ProfilePicKeys(referenced_pfp)) {
+		t.Fatalf("got %d blobs left, want %d", len(blobs), len(ProfilePicKeys(referenced_pfp)))
+	}
+
+	// still referenced: kept
+	if err = DeleteProfilePicIfUnreferenced(store, referenced_pfp); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := store.Exists(ProfilePicKeys(referenced_pfp)[0]); !exists {
+		t.Fatal("expected referenced pic to be kept")
+	}
+}
+
+func TestParseProfilePicSize(t *testing.T) {
+	var test_params = []struct {
+		Params string
+		Size   int
+		Valid  bool
+	}{
+		{"", mutil.PFP_DEFAULT_SIZE, true},
+		{"64", 64, true},
+		{"512", 512, true},
+		{"100", 0, false},
+		{"big", 0, false},
+	}
+
+	for _, tp := range test_params {
+		size, err := ParseProfilePicSize(tp.Params)
+		if tp.Valid && err != nil {
+			t.Fatal(err)
+		} else if !tp.Valid && err == nil {
+			t.Fatalf("expected error for size %s", tp.Params)
+		} else if size != tp.Size {
+			t.Fatalf("got size %d, want %d", size, tp.Size)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/julianlk522/fitm/model"
+)
+
+// Pinboard JSON export (https://pinboard.in/export/format:json/)
+type pinboardBookmark struct {
+	Href        string `json:"href"`
+	Description string `json:"description"`
+	Extended    string `json:"extended"`
+	Tags        string `json:"tags"`
+	ToRead      string `json:"toread"`
+}
+
+// tags are space-separated; unread ("toread") bookmarks are not starred
+func ParsePinboardBookmarks(r io.Reader) ([]model.ImportedBookmark, error) {
+	var pins []pinboardBookmark
+	if err := json.NewDecoder(r).Decode(&pins); err != nil {
+		return nil, err
+	}
+
+	var bookmarks []model.ImportedBookmark
+	for _, pin := range pins {
+		url := strings.TrimSpace(pin.Href)
+		if !IsImportableURL(url) {
+			continue
+		}
+
+		bookmarks = append(bookmarks, model.ImportedBookmark{
+			URL:     url,
+			Title:   strings.TrimSpace(pin.Description),
+			Cats:    BookmarkCats(strings.Fields(pin.Tags)),
+			Summary: pin.Extended,
+			Starred: pin.ToRead != "yes",
+		})
+	}
+
+	return bookmarks, nil
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/julianlk522/fitm/model"
+)
+
+// Pocket export: either the legacy ril_export.html or the newer CSV
+// (title,url,time_added,tags,status). Archived (read) items are starred.
+func ParsePocketBookmarks(r io.Reader) ([]model.ImportedBookmark, error) {
+	br := bufio.NewReader(r)
+
+	// skip BOM / leading whitespace to sniff format
+	for {
+		c, _, err := br.ReadRune()
+		if err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if c == '\uFEFF' || c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		br.UnreadRune()
+
+		if c == '<' {
+			return parsePocketHTML(br)
+		}
+		return parsePocketCSV(br)
+	}
+}
+
+// <h1>Unread</h1><ul><li><a href="..." tags="a,b">title</a></li>...</ul>
+// <h1>Read Archive</h1><ul>...</ul>
+func parsePocketHTML(r io.Reader) ([]model.ImportedBookmark, error) {
+	z := html.NewTokenizer(r)
+
+	var bookmarks []model.ImportedBookmark
+	var in_heading bool
+	var heading bytes.Buffer
+	var is_archive bool
+
+	var current *model.ImportedBookmark
+
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return bookmarks, nil
+			}
+			return nil, z.Err()
+
+		case html.StartTagToken:
+			name, has_attrs := z.TagName()
+			switch string(name) {
+			case "h1":
+				in_heading = true
+				heading.Reset()
+
+			case "a":
+				var href, tags string
+				for has_attrs {
+					var key, val []byte
+					key, val, has_attrs = z.TagAttr()
+					switch string(key) {
+					case "href":
+						href = strings.TrimSpace(string(val))
+					case "tags":
+						tags = string(val)
+					}
+				}
+
+				if !IsImportableURL(href) {
+					continue
+				}
+				current = &model.ImportedBookmark{
+					URL:     href,
+					Cats:    BookmarkCats(strings.Split(tags, ",")),
+					Starred: is_archive,
+				}
+			}
+
+		case html.TextToken:
+			if in_heading {
+				heading.Write(z.Text())
+			} else if current != nil {
+				current.Title += string(z.Text())
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "h1":
+				in_heading = false
+				is_archive = strings.Contains(strings.ToLower(heading.String()), "archive")
+			case "a":
+				if current != nil {
+					current.Title = strings.TrimSpace(current.Title)
+					bookmarks = append(bookmarks, *current)
+					current = nil
+				}
+			}
+		}
+	}
+}
+
+// tags "|"-separated, status "unread" or "archive"
+func parsePocketCSV(r io.Reader) ([]model.ImportedBookmark, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns, err := csvColumns(header, "url")
+	if err != nil {
+		return nil, err
+	}
+
+	var bookmarks []model.ImportedBookmark
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return bookmarks, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		url := csvField(record, columns, "url")
+		if !IsImportableURL(url) {
+			continue
+		}
+
+		bookmarks = append(bookmarks, model.ImportedBookmark{
+			URL:     url,
+			Title:   csvField(record, columns, "title"),
+			Cats:    BookmarkCats(strings.Split(csvField(record, columns, "tags"), "|")),
+			Starred: csvField(record, columns, "status") == "archive",
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"encoding/csv"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/julianlk522/fitm/model"
+)
+
+// Raindrop.io CSV export: id,title,note,excerpt,url,folder,tags,
+// created,cover,highlights,favorite
+// folder is a "/"-separated path, tags comma-separated
+func ParseRaindropBookmarks(r io.Reader) ([]model.ImportedBookmark, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns, err := csvColumns(header, "url")
+	if err != nil {
+		return nil, err
+	}
+
+	var bookmarks []model.ImportedBookmark
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return bookmarks, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		url := csvField(record, columns, "url")
+		if !IsImportableURL(url) {
+			continue
+		}
+
+		// user's own note preferred over page excerpt
+		summary := csvField(record, columns, "note")
+		if summary == "" {
+			summary = csvField(record, columns, "excerpt")
+		}
+
+		var folders []string
+		if folder := csvField(record, columns, "folder"); !strings.EqualFold(folder, "unsorted") {
+			folders = strings.Split(folder, "/")
+		}
+		tags := strings.Split(csvField(record, columns, "tags"), ",")
+
+		bookmarks = append(bookmarks, model.ImportedBookmark{
+			URL:     url,
+			Title:   csvField(record, columns, "title"),
+			Cats:    BookmarkCats(slices.Concat(folders, tags)),
+			Summary: summary,
+			Starred: csvField(record, columns, "favorite") == "true",
+		})
+	}
+}
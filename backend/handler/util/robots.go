@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// product token matched against robots.txt User-agent lines
+const ROBOTS_USER_AGENT = "fitm-bot"
+
+type robotsRule struct {
+	Allow   bool
+	Pattern string
+	regex   *regexp.Regexp
+}
+
+// Rules of the robots.txt group that applies to FITM-Bot
+// (a group naming it, else the * group)
+type RobotsRules struct {
+	rules []robotsRule
+}
+
+// Allows everything (e.g., robots.txt missing)
+var AllowAllRobotsRules = &RobotsRules{}
+
+// Disallows everything (e.g., robots.txt unreachable)
+var DisallowAllRobotsRules = &RobotsRules{
+	rules: []robotsRule{{Allow: false, Pattern: "/", regex: robotsPatternRegex("/")}},
+}
+
+func ParseRobots(r io.Reader) *RobotsRules {
+	var (
+		own_rules, any_rules []robotsRule
+		has_own_group        bool
+		group_agents         []string
+		in_rules             bool
+	)
+
+	scanner := bufio.NewScanner(io.LimitReader(r, 512<<10))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// new group starts after previous group's rules
+			if in_rules {
+				group_agents = nil
+				in_rules = false
+			}
+			agent := strings.ToLower(value)
+			if agent == ROBOTS_USER_AGENT {
+				has_own_group = true
+			}
+			group_agents = append(group_agents, agent)
+		case "allow", "disallow":
+			in_rules = true
+			// empty Disallow allows everything
+			if value == "" {
+				continue
+			}
+
+			rule := robotsRule{
+				Allow:   key == "allow",
+				Pattern: value,
+				regex:   robotsPatternRegex(value),
+			}
+			for _, agent := range group_agents {
+				if agent == ROBOTS_USER_AGENT {
+					own_rules = append(own_rules, rule)
+				} else if agent == "*" {
+					any_rules = append(any_rules, rule)
+				}
+			}
+		}
+	}
+
+	if has_own_group {
+		return &RobotsRules{rules: own_rules}
+	}
+	return &RobotsRules{rules: any_rules}
+}
+
+// * matches any chars, trailing $ anchors end
+func robotsPatternRegex(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i, part := range strings.Split(pattern, "*") {
+		if i > 0 {
+			expr.WriteString(".*")
+		}
+		expr.WriteString(regexp.QuoteMeta(part))
+	}
+	if anchored {
+		expr.WriteString("$")
+	}
+
+	return regexp.MustCompile(expr.String())
+}
+
+// Longest matching rule wins (Allow on ties).
+// path should include query if any
+func (r *RobotsRules) Allowed(path string) bool {
+	if path == "" {
+		path = "/"
+	}
+
+	allowed, match_len := true, -1
+	for _, rule := range r.rules {
+		if !rule.regex.MatchString(path) {
+			continue
+		}
+		if len(rule.Pattern) > match_len || (len(rule.Pattern) == match_len && rule.Allow) {
+			allowed, match_len = rule.Allow, len(rule.Pattern)
+		}
+	}
+
+	return allowed
+}
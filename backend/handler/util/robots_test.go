@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRobots(t *testing.T) {
+	var test_robots = []struct {
+		Robots  string
+		Path    string
+		Allowed bool
+	}{
+		{"", "/anything", true},
+		{"User-agent: *\nDisallow: /", "/page", false},
+		{"User-agent: *\nDisallow:", "/page", true},
+		{"User-agent: *\nDisallow: /private", "/private/page", false},
+		{"User-agent: *\nDisallow: /private", "/public", true},
+		// longest match wins
+		{"User-agent: *\nDisallow: /docs\nAllow: /docs/public", "/docs/public/a", true},
+		{"User-agent: *\nDisallow: /docs\nAllow: /docs/public", "/docs/secret", false},
+		// wildcards and end anchor
+		{"User-agent: *\nDisallow: /*.pdf$", "/files/a.pdf", false},
+		{"User-agent: *\nDisallow: /*.pdf$", "/files/a.pdf?x=1", true},
+		{"User-agent: *\nDisallow: /*?session=", "/page?session=1", false},
+		// own group overrides *
+		{"User-agent: *\nDisallow: /\n\nUser-agent: FITM-Bot\nAllow: /", "/page", true},
+		{"User-agent: *\nAllow: /\n\nUser-agent: fitm-bot\nDisallow: /page", "/page", false},
+		// other bots' groups ignored
+		{"User-agent: Googlebot\nDisallow: /", "/page", true},
+		// grouped user agents share rules
+		{"User-agent: Googlebot\nUser-agent: FITM-Bot\nDisallow: /page # comment", "/page", false},
+	}
+
+	for _, tr := range test_robots {
+		rules := ParseRobots(strings.NewReader(tr.Robots))
+		if got := rules.Allowed(tr.Path); got != tr.Allowed {
+			t.Fatalf("%q with robots.txt:\n%s\ngot allowed %t, want %t", tr.Path, tr.Robots, got, tr.Allowed)
+		}
+	}
+
+	if DisallowAllRobotsRules.Allowed("/page") || !AllowAllRobotsRules.Allowed("/page") {
+		t.Fatal("incorrect allow / disallow all rules")
+	}
+}
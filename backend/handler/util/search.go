@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"html"
+	"strings"
+
+	"github.com/julianlk522/fitm/db"
+	"github.com/julianlk522/fitm/model"
+	"github.com/julianlk522/fitm/query"
+)
+
+var snippet_marks_replacer = strings.NewReplacer(
+	query.SEARCH_SNIPPET_MATCH_START, "<mark>",
+	query.SEARCH_SNIPPET_MATCH_END, "</mark>",
+)
+
+func ScanSearchLinks[T model.SearchLink | model.SearchLinkSignedIn](search_sql *query.SearchLinks) (*[]T, error) {
+	rows, err := db.Client.Query(search_sql.Text, search_sql.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []T{}
+	for rows.Next() {
+		var link T
+		var snippet string
+
+		switch l := any(&link).(type) {
+		case *model.SearchLink:
+			err = rows.Scan(
+				&l.ID,
+				&l.URL,
+				&l.SubmittedBy,
+				&l.SubmitDate,
+				&l.Cats,
+				&l.Summary,
+				&l.SummaryCount,
+				&l.TagCount,
+				&l.LikeCount,
+				&l.ImgURL,
+				&l.HealthStatus,
+				&snippet,
+			)
+			l.Snippet = HighlightSearchSnippet(snippet)
+		case *model.SearchLinkSignedIn:
+			err = rows.Scan(
+				&l.ID,
+				&l.URL,
+				&l.SubmittedBy,
+				&l.SubmitDate,
+				&l.Cats,
+				&l.Summary,
+				&l.SummaryCount,
+				&l.TagCount,
+				&l.LikeCount,
+				&l.ImgURL,
+				&l.HealthStatus,
+				&l.IsLiked,
+				&l.IsCopied,
+				&snippet,
+			)
+			l.Snippet = HighlightSearchSnippet(snippet)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		links = append(links, link)
+	}
+
+	return &links, rows.Err()
+}
+
+// Escapes snippet (user-submitted text) and wraps matches in <mark>
+func HighlightSearchSnippet(snippet string) string {
+	return snippet_marks_replacer.Replace(html.EscapeString(snippet))
+}
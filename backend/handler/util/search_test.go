@@ -0,0 +1,18 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/julianlk522/fitm/query"
+)
+
+func TestHighlightSearchSnippet(t *testing.T) {
+	snippet := "a <b>bold</b> " +
+		query.SEARCH_SNIPPET_MATCH_START + "match" + query.SEARCH_SNIPPET_MATCH_END +
+		" & more"
+
+	want := "a &lt;b&gt;bold&lt;/b&gt; <mark>match</mark> &amp; more"
+	if got := HighlightSearchSnippet(snippet); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/julianlk522/fitm/db"
+	"github.com/julianlk522/fitm/model"
+)
+
+// defaults if user has never saved settings
+func GetUserSettings(user_id string) (*model.UserSettings, error) {
+	settings := model.NewDefaultUserSettings()
+
+	var sort_by, period, tmap_section_order, muted_cats sql.NullString
+	err := db.Client.QueryRow(
+		`SELECT nsfw, sort_by, period, tmap_section_order, muted_cats
+		FROM "User Settings"
+		WHERE user_id = ?;`,
+		user_id,
+	).Scan(
+		&settings.NSFW,
+		&sort_by,
+		&period,
+		&tmap_section_order,
+		&muted_cats,
+	)
+	if err == sql.ErrNoRows {
+		return settings, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	settings.SortBy = sort_by.String
+	settings.Period = period.String
+	if tmap_section_order.String != "" {
+		settings.TmapSectionOrder = tmap_section_order.String
+	}
+	settings.MutedCats = muted_cats.String
+
+	return settings, nil
+}
+
+func SaveUserSettings(user_id string, settings *model.UserSettings, last_updated string) error {
+	_, err := db.Client.Exec(
+		`INSERT INTO "User Settings" 
+		(user_id, nsfw, sort_by, period, tmap_section_order, muted_cats, last_updated)
+		VALUES (?,?,?,?,?,?,?)
+		ON CONFLICT(user_id) DO UPDATE SET 
+			nsfw = excluded.nsfw,
+			sort_by = excluded.sort_by,
+			period = excluded.period,
+			tmap_section_order = excluded.tmap_section_order,
+			muted_cats = excluded.muted_cats,
+			last_updated = excluded.last_updated;`,
+		user_id,
+		settings.NSFW,
+		settings.SortBy,
+		settings.Period,
+		settings.TmapSectionOrder,
+		settings.MutedCats,
+		last_updated,
+	)
+
+	return err
+}
+
+// muted cats explicitly requested in cats params are shown anyway
+func GetUnrequestedMutedCats(settings *model.UserSettings, requested_cats []string) []string {
+	unrequested := []string{}
+	for _, muted_cat := range settings.MutedCatsSlice() {
+		requested := false
+		for _, cat := range requested_cats {
+			if strings.EqualFold(cat, muted_cat) {
+				requested = true
+				break
+			}
+		}
+
+		if !requested {
+			unrequested = append(unrequested, muted_cat)
+		}
+	}
+
+	return unrequested
+}
+
+func FilterTmapLinksWithMutedCats[T model.TmapLink | model.TmapLinkSignedIn](links *[]T, muted_cats []string) *[]T {
+	if len(muted_cats) == 0 {
+		return links
+	}
+
+	filtered := []T{}
+	for _, link := range *links {
+		var cats string
+		switch l := any(link).(type) {
+		case model.TmapLinkSignedIn:
+			cats = l.Cats
+		case model.TmapLink:
+			cats = l.Cats
+		}
+
+		if !HasMutedCat(cats, muted_cats) {
+			filtered = append(filtered, link)
+		}
+	}
+
+	return &filtered
+}
+
+func HasMutedCat(cats string, muted_cats []string) bool {
+	for _, cat := range strings.Split(cats, ",") {
+		for _, muted_cat := range muted_cats {
+			if strings.EqualFold(cat, muted_cat) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
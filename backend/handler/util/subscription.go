@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"encoding/base64"
+	"slices"
+	"strings"
+
+	"github.com/julianlk522/fitm/db"
+	e "github.com/julianlk522/fitm/error"
+	"github.com/julianlk522/fitm/model"
+	"github.com/julianlk522/fitm/query"
+)
+
+// Get subscriptions
+func GetCatSubscriptions(user_id string) (*[]model.CatSubscription, error) {
+	rows, err := db.Client.Query(
+		`SELECT id, cats, created
+		FROM "Cat Subscriptions"
+		WHERE user_id = ?
+		ORDER BY created DESC, id;`,
+		user_id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []model.CatSubscription{}
+	for rows.Next() {
+		var s model.CatSubscription
+		if err := rows.Scan(&s.ID, &s.Cats, &s.Created); err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, s)
+	}
+
+	return &subscriptions, nil
+}
+
+// Subscribe
+func GetNumCatSubscriptions(user_id string) (int, error) {
+	var count int
+	err := db.Client.QueryRow(
+		`SELECT count(*) FROM "Cat Subscriptions" WHERE user_id = ?;`,
+		user_id,
+	).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// cats compared case-insensitively
+func UserIsSubscribedToCats(user_id string, cats string) (bool, error) {
+	var is_subscribed bool
+	err := db.Client.QueryRow(
+		`SELECT EXISTS(
+			SELECT 1 FROM "Cat Subscriptions"
+			WHERE user_id = ?
+			AND lower(cats) = lower(?)
+		);`,
+		user_id,
+		cats,
+	).Scan(&is_subscribed)
+	if err != nil {
+		return false, err
+	}
+
+	return is_subscribed, nil
+}
+
+func SaveCatSubscription(user_id string, request *model.NewCatSubscriptionRequest) error {
+	_, err := db.Client.Exec(
+		`INSERT INTO "Cat Subscriptions" VALUES (?,?,?,?);`,
+		request.ID,
+		user_id,
+		request.Cats,
+		request.Created,
+	)
+
+	return err
+}
+
+// Unsubscribe
+// ErrNoSubscriptionWithID if not user's
+func DeleteCatSubscription(user_id string, subscription_id string) error {
+	res, err := db.Client.Exec(
+		`DELETE FROM "Cat Subscriptions" WHERE id = ? AND user_id = ?;`,
+		subscription_id,
+		user_id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return e.ErrNoSubscriptionWithID
+	}
+
+	return nil
+}
+
+// Subscription feed
+func NewSubscriptionFeedQuery(user_id string, subscriptions []model.CatSubscription) *query.SubscriptionFeed {
+	var ids []string
+	var cats [][]string
+	for _, s := range subscriptions {
+		ids = append(ids, s.ID)
+		cats = append(cats, strings.Split(s.Cats, ","))
+	}
+
+	return query.NewSubscriptionFeed(user_id, ids, cats)
+}
+
+// Links newest first; with since_cursor the page after it is reversed
+// to match
+func ScanSubscriptionFeed(feed_sql *query.SubscriptionFeed, subscriptions []model.CatSubscription, since_cursor bool) (*model.SubscriptionFeed, error) {
+	subscription_cats := map[string]string{}
+	for _, s := range subscriptions {
+		subscription_cats[s.ID] = s.Cats
+	}
+
+	rows, err := db.Client.Query(feed_sql.Text, feed_sql.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []model.SubscriptionFeedLink{}
+	for rows.Next() {
+		var l model.SubscriptionFeedLink
+		var subscription_ids string
+		if err := rows.Scan(
+			&l.ID,
+			&l.URL,
+			&l.SubmittedBy,
+			&l.SubmitDate,
+			&l.Cats,
+			&l.Summary,
+			&l.SummaryCount,
+			&l.TagCount,
+			&l.LikeCount,
+			&l.ImgURL,
+			&l.HealthStatus,
+			&l.IsLiked,
+			&l.IsCopied,
+			&subscription_ids,
+		); err != nil {
+			return nil, err
+		}
+
+		for _, id := range strings.Split(subscription_ids, ",") {
+			l.MatchedSubscriptions = append(l.MatchedSubscriptions, subscription_cats[id])
+		}
+		slices.Sort(l.MatchedSubscriptions)
+
+		links = append(links, l)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	feed := &model.SubscriptionFeed{}
+	if since_cursor {
+		if len(links) > query.LINKS_PAGE_LIMIT {
+			links = links[:query.LINKS_PAGE_LIMIT]
+			feed.HasMore = true
+		}
+		slices.Reverse(links)
+	}
+	feed.Links = &links
+	if len(links) > 0 {
+		feed.Cursor = EncodeFeedCursor(links[0].SubmitDate, links[0].ID)
+	}
+
+	return feed, nil
+}
+
+// opaque to clients
+func EncodeFeedCursor(submit_date string, link_id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(submit_date + "|" + link_id))
+}
+
+func DecodeFeedCursor(cursor string) (submit_date string, link_id string, err error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", "", e.ErrInvalidFeedCursor
+	}
+
+	submit_date, link_id, found := strings.Cut(string(decoded), "|")
+	if !found || submit_date == "" || link_id == "" {
+		return "", "", e.ErrInvalidFeedCursor
+	}
+
+	return submit_date, link_id, nil
+}
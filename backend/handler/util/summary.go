@@ -216,7 +216,7 @@ func CalculateAndSetGlobalSummary(link_id string) error {
 	// Summary with the most upvotes is the global summary
 	// UNLESS 1st is auto summary and tied with 2nd place,
 	// then use 2nd place
-	var top_summary_text string
+	var top_summary_text, top_summary_submitted_by string
 	err := db.Client.QueryRow(`WITH RankedSummaries AS (
 		SELECT 
 			s.text,
@@ -236,14 +236,18 @@ func CalculateAndSetGlobalSummary(link_id string) error {
 		) sl ON s.id = sl.summary_id
 		WHERE s.link_id = ?
 	)
-	SELECT text
+	SELECT text, submitted_by
 	FROM RankedSummaries
 	WHERE rank = 1`,
 		db.AUTO_SUMMARY_USER_ID,
 		link_id,
-	).Scan(&top_summary_text)
+	).Scan(&top_summary_text, &top_summary_submitted_by)
 
-	if err != nil {
+	// no summaries left (e.g., removed by moderator)
+	if err == sql.ErrNoRows {
+		SetLinkGlobalSummary(link_id, "")
+		return nil
+	} else if err != nil {
 		return err
 	}
 
@@ -257,11 +261,39 @@ func CalculateAndSetGlobalSummary(link_id string) error {
 		return err
 	} else if gs == "" || gs != top_summary_text {
 		SetLinkGlobalSummary(link_id, top_summary_text)
+
+		if gs != "" {
+			notifyGlobalSummaryReplaced(link_id, gs, top_summary_submitted_by)
+		}
 	}
 
 	return nil
 }
 
+// notifies submitter of the previous global summary, if it still exists
+// (failure logged: global summary already updated)
+func notifyGlobalSummaryReplaced(link_id string, replaced_text string, actor_id string) {
+	var replaced_summary_id string
+	err := db.Client.QueryRow(
+		`SELECT id FROM Summaries WHERE link_id = ? AND text = ? LIMIT 1;`,
+		link_id,
+		replaced_text,
+	).Scan(&replaced_summary_id)
+	if err == sql.ErrNoRows {
+		return
+	} else if err == nil {
+		err = NotifySummarySubmitter(
+			replaced_summary_id,
+			model.NOTIFICATION_GLOBAL_SUMMARY_REPLACED,
+			actor_id,
+		)
+	}
+
+	if err != nil {
+		log.Printf("could not notify global summary replaced for link %s: %s", link_id, err)
+	}
+}
+
 func SetLinkGlobalSummary(link_id string, text string) {
 	_, err := db.Client.Exec(`UPDATE Links SET global_summary = ? WHERE id = ?`, text, link_id)
 	if err != nil {
@@ -325,6 +325,19 @@ func AlphabetizeOverlapScoreCats(scores map[string]float32) []string {
 	return cats
 }
 
+func GetLinkGlobalCats(link_id string) (string, error) {
+	var global_cats string
+	err := db.Client.QueryRow(
+		"SELECT global_cats FROM Links WHERE id = ?;",
+		link_id,
+	).Scan(&global_cats)
+	if err != nil {
+		return "", err
+	}
+
+	return global_cats, nil
+}
+
 func SetGlobalCats(link_id string, text string) error {
 
 	// determine diff to adjust spellfix ranks
@@ -73,8 +73,18 @@ func GetTmapForUser[T model.TmapLink | model.TmapLinkSignedIn](login_name string
 		nsfw_links_count_sql = nsfw_links_count_sql.FromCats(cats)
 	}
 
-	// auth (add IsLiked, IsCopied)
+	// viewer's saved settings apply when params omitted
 	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	settings := model.NewDefaultUserSettings()
+	if req_user_id != "" {
+		var err error
+		settings, err = GetUserSettings(req_user_id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// auth (add IsLiked, IsCopied)
 	if req_user_id != "" {
 		submitted_sql = submitted_sql.AsSignedInUser(req_user_id)
 		copied_sql = copied_sql.AsSignedInUser(req_user_id)
@@ -87,6 +97,8 @@ func GetTmapForUser[T model.TmapLink | model.TmapLinkSignedIn](login_name string
 		nsfw_params = r.URL.Query().Get("nsfw")
 	} else if r.URL.Query().Get("NSFW") != "" {
 		nsfw_params = r.URL.Query().Get("NSFW")
+	} else if settings.NSFW {
+		nsfw_params = "true"
 	}
 
 	if nsfw_params == "true" {
@@ -97,6 +109,40 @@ func GetTmapForUser[T model.TmapLink | model.TmapLinkSignedIn](login_name string
 		return nil, e.ErrInvalidNSFWParams
 	}
 
+	// dead links
+	hide_dead_params := r.URL.Query().Get("hide_dead")
+	if hide_dead_params != "true" && hide_dead_params != "false" && hide_dead_params != "" {
+		return nil, e.ErrInvalidHideDeadParams
+	}
+
+	// date range
+	from_params, to_params, err := GetDateRangeParams(r)
+	if err != nil {
+		return nil, err
+	}
+	if from_params != "" || to_params != "" {
+		submitted_sql = submitted_sql.DuringDateRange(from_params, to_params)
+		copied_sql = copied_sql.DuringDateRange(from_params, to_params)
+		tagged_sql = tagged_sql.DuringDateRange(from_params, to_params)
+		nsfw_links_count_sql = nsfw_links_count_sql.DuringDateRange(from_params, to_params)
+		if submitted_sql.Error != nil {
+			return nil, submitted_sql.Error
+		}
+	}
+
+	// domain
+	if domain_params := r.URL.Query().Get("domain"); domain_params != "" {
+		domain, err := GetDomainFromParams(domain_params)
+		if err != nil {
+			return nil, err
+		}
+
+		submitted_sql = submitted_sql.FromDomain(domain)
+		copied_sql = copied_sql.FromDomain(domain)
+		tagged_sql = tagged_sql.FromDomain(domain)
+		nsfw_links_count_sql = nsfw_links_count_sql.FromDomain(domain)
+	}
+
 	// Scan
 	// links
 	submitted, err := ScanTmapLinks[T](submitted_sql.Query)
@@ -111,6 +157,17 @@ func GetTmapForUser[T model.TmapLink | model.TmapLinkSignedIn](login_name string
 	if err != nil {
 		return nil, err
 	}
+	// muted cats
+	muted_cats := GetUnrequestedMutedCats(settings, cats_with_unescaped_reserved_chars)
+	submitted = FilterTmapLinksWithMutedCats(submitted, muted_cats)
+	copied = FilterTmapLinksWithMutedCats(copied, muted_cats)
+	tagged = FilterTmapLinksWithMutedCats(tagged, muted_cats)
+	if hide_dead_params == "true" {
+		submitted = FilterDeadTmapLinks(submitted)
+		copied = FilterDeadTmapLinks(copied)
+		tagged = FilterDeadTmapLinks(tagged)
+	}
+
 	// NSFW links count
 	var nsfw_links_count int
 	if err := db.Client.QueryRow(nsfw_links_count_sql.Text, nsfw_links_count_sql.Args...).Scan(&nsfw_links_count); err != nil {
@@ -133,6 +190,7 @@ func GetTmapForUser[T model.TmapLink | model.TmapLinkSignedIn](login_name string
 
 	// Assemble and return tmap
 	sections := &model.TmapSections[T]{
+		SectionOrder: strings.Split(settings.TmapSectionOrder, ","),
 		Cats:      cat_counts,
 		Submitted: submitted,
 		Copied:    copied,
@@ -163,6 +221,8 @@ func ScanTmapProfile(sql *query.TmapProfile) (*model.Profile, error) {
 			&u.About,
 			&u.PFP,
 			&u.Created,
+			&u.FollowerCount,
+			&u.FollowingCount,
 		)
 	if err != nil {
 		return nil, e.ErrNoUserWithLoginName
@@ -198,6 +258,7 @@ func ScanTmapLinks[T model.TmapLink | model.TmapLinkSignedIn](sql *query.Query)
 				&l.LikeCount,
 				&l.TagCount,
 				&l.ImgURL,
+				&l.HealthStatus,
 
 				// Add IsLiked / IsCopied
 				&l.IsLiked,
@@ -226,7 +287,8 @@ func ScanTmapLinks[T model.TmapLink | model.TmapLinkSignedIn](sql *query.Query)
 				&l.SummaryCount,
 				&l.LikeCount,
 				&l.TagCount,
-				&l.ImgURL)
+				&l.ImgURL,
+				&l.HealthStatus)
 			if err != nil {
 				return nil, err
 			}
@@ -284,7 +346,11 @@ func GetCatCountsFromTmapLinks[T model.TmapLink | model.TmapLinkSignedIn](links
 		}
 	}
 
-	SortAndLimitCatCounts(&counts, TMAP_CATS_PAGE_LIMIT)
+	limit := TMAP_CATS_PAGE_LIMIT
+	if opts != nil && opts.Limit > 0 {
+		limit = opts.Limit
+	}
+	SortAndLimitCatCounts(&counts, limit)
 
 	return &counts
 }
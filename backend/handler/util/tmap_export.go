@@ -0,0 +1,292 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	e "github.com/julianlk522/fitm/error"
+	"github.com/julianlk522/fitm/model"
+)
+
+const (
+	TMAP_EXPORT_BOOKMARKS = "bookmarks"
+	TMAP_EXPORT_OPML      = "opml"
+	TMAP_EXPORT_MARKDOWN  = "markdown"
+)
+
+// cat folders: links with none of the top N cats are left unfiled
+const (
+	TMAP_EXPORT_DEFAULT_FOLDERS = TMAP_CATS_PAGE_LIMIT
+	TMAP_EXPORT_MAX_FOLDERS     = 100
+)
+
+type tmapExportFormat struct {
+	ContentType string
+	Extension   string
+	Write       func(io.Writer, *model.TmapExport) error
+}
+
+var tmap_export_formats = map[string]tmapExportFormat{
+	TMAP_EXPORT_BOOKMARKS: {"text/html; charset=utf-8", "html", writeTmapBookmarks},
+	TMAP_EXPORT_OPML:      {"text/x-opml; charset=utf-8", "opml", writeTmapOPML},
+	TMAP_EXPORT_MARKDOWN:  {"text/markdown; charset=utf-8", "md", writeTmapMarkdown},
+}
+
+func TmapExportFormats() []string {
+	formats := []string{}
+	for format := range tmap_export_formats {
+		formats = append(formats, format)
+	}
+	slices.Sort(formats)
+
+	return formats
+}
+
+// Content type and file extension for export format
+func TmapExportFileType(format string) (content_type string, extension string, err error) {
+	f, ok := tmap_export_formats[format]
+	if !ok {
+		return "", "", e.UnsupportedTmapExportFormat(format, TmapExportFormats())
+	}
+
+	return f.ContentType, f.Extension, nil
+}
+
+// ?folders= (default TMAP_EXPORT_DEFAULT_FOLDERS)
+func GetTmapExportFolderLimit(folders_params string) (int, error) {
+	if folders_params == "" {
+		return TMAP_EXPORT_DEFAULT_FOLDERS, nil
+	}
+
+	folders, err := strconv.Atoi(folders_params)
+	if err != nil || folders < 1 || folders > TMAP_EXPORT_MAX_FOLDERS {
+		return 0, e.InvalidTmapExportFolders(TMAP_EXPORT_MAX_FOLDERS)
+	}
+
+	return folders, nil
+}
+
+// tmap is model.Tmap or model.FilteredTmap, as returned by GetTmapForUser
+// opts.OmittedCats: cats filter (every link has them, so no folders)
+// opts.Limit: max folders
+func NewTmapExport(login_name string, tmap interface{}, opts *model.TmapCatCountsOpts) *model.TmapExport {
+	switch t := tmap.(type) {
+	case model.Tmap[model.TmapLink]:
+		return newTmapExport(login_name, t.TmapSections, opts)
+	case model.FilteredTmap[model.TmapLink]:
+		return newTmapExport(login_name, t.TmapSections, opts)
+	case model.Tmap[model.TmapLinkSignedIn]:
+		return newTmapExport(login_name, t.TmapSections, opts)
+	case model.FilteredTmap[model.TmapLinkSignedIn]:
+		return newTmapExport(login_name, t.TmapSections, opts)
+	}
+
+	return &model.TmapExport{LoginName: login_name}
+}
+
+// Each link goes in the folder of its most common cat (counted across
+// exported links, so not limited to the tmap's top cats); links in more
+// than one section are listed once
+func newTmapExport[T model.TmapLink | model.TmapLinkSignedIn](login_name string, sections *model.TmapSections[T], opts *model.TmapCatCountsOpts) *model.TmapExport {
+	export := &model.TmapExport{LoginName: login_name}
+
+	links := []T{}
+	var export_links []model.TmapExportLink
+	seen := map[string]bool{}
+	for _, section := range []*[]T{sections.Submitted, sections.Copied, sections.Tagged} {
+		if section == nil {
+			continue
+		}
+
+		for _, link := range *section {
+			var id string
+			var export_link model.TmapExportLink
+			switch l := any(link).(type) {
+			case model.TmapLinkSignedIn:
+				id = l.ID
+				export_link = model.TmapExportLink{URL: l.URL, Cats: l.Cats, Summary: l.Summary}
+			case model.TmapLink:
+				id = l.ID
+				export_link = model.TmapExportLink{URL: l.URL, Cats: l.Cats, Summary: l.Summary}
+			}
+
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			links = append(links, link)
+			export_links = append(export_links, export_link)
+		}
+	}
+
+	var folders []model.TmapExportFolder
+	for _, cat_count := range *GetCatCountsFromTmapLinks(&links, opts) {
+		folders = append(folders, model.TmapExportFolder{Cat: cat_count.Category})
+	}
+
+	for _, export_link := range export_links {
+		link_cats := strings.Split(export_link.Cats, ",")
+		filed := false
+		for i := range folders {
+			if slices.Contains(link_cats, folders[i].Cat) {
+				folders[i].Links = append(folders[i].Links, export_link)
+				filed = true
+				break
+			}
+		}
+		if !filed {
+			export.Unfiled = append(export.Unfiled, export_link)
+		}
+	}
+
+	for _, folder := range folders {
+		if len(folder.Links) > 0 {
+			export.Folders = append(export.Folders, folder)
+		}
+	}
+
+	return export
+}
+
+func WriteTmapExport(w io.Writer, format string, export *model.TmapExport) error {
+	f, ok := tmap_export_formats[format]
+	if !ok {
+		return e.UnsupportedTmapExportFormat(format, TmapExportFormats())
+	}
+
+	return f.Write(w, export)
+}
+
+func tmapExportTitle(login_name string) string {
+	return login_name + "'s treasure map"
+}
+
+// Netscape bookmark file; TAGS keeps all of a link's cats so it can be
+// re-imported
+func writeTmapBookmarks(w io.Writer, export *model.TmapExport) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>%[1]s</TITLE>
+<H1>%[1]s</H1>
+<DL><p>
+`, html.EscapeString(tmapExportTitle(export.LoginName)))
+
+	write_links := func(links []model.TmapExportLink, indent string) {
+		for _, link := range links {
+			fmt.Fprintf(
+				bw,
+				"%s<DT><A HREF=\"%s\" TAGS=\"%s\">%s</A>\n",
+				indent,
+				html.EscapeString(link.URL),
+				html.EscapeString(link.Cats),
+				html.EscapeString(link.URL),
+			)
+			if link.Summary != "" {
+				fmt.Fprintf(bw, "%s<DD>%s\n", indent, html.EscapeString(link.Summary))
+			}
+		}
+	}
+
+	write_links(export.Unfiled, "    ")
+	for _, folder := range export.Folders {
+		fmt.Fprintf(bw, "    <DT><H3>%s</H3>\n    <DL><p>\n", html.EscapeString(folder.Cat))
+		write_links(folder.Links, "        ")
+		bw.WriteString("    </DL><p>\n")
+	}
+	bw.WriteString("</DL><p>\n")
+
+	return bw.Flush()
+}
+
+type opmlDocument struct {
+	XMLName xml.Name      `xml:"opml"`
+	Version string        `xml:"version,attr"`
+	Title   string        `xml:"head>title"`
+	Body    []opmlOutline `xml:"body>outline"`
+}
+
+type opmlOutline struct {
+	Text        string        `xml:"text,attr"`
+	Type        string        `xml:"type,attr,omitempty"`
+	URL         string        `xml:"url,attr,omitempty"`
+	Category    string        `xml:"category,attr,omitempty"`
+	Description string        `xml:"description,attr,omitempty"`
+	Outlines    []opmlOutline `xml:"outline"`
+}
+
+func writeTmapOPML(w io.Writer, export *model.TmapExport) error {
+	link_outlines := func(links []model.TmapExportLink) []opmlOutline {
+		outlines := []opmlOutline{}
+		for _, link := range links {
+			outlines = append(outlines, opmlOutline{
+				Text:        link.URL,
+				Type:        "link",
+				URL:         link.URL,
+				Category:    link.Cats,
+				Description: link.Summary,
+			})
+		}
+		return outlines
+	}
+
+	doc := opmlDocument{
+		Version: "2.0",
+		Title:   tmapExportTitle(export.LoginName),
+		Body:    link_outlines(export.Unfiled),
+	}
+	for _, folder := range export.Folders {
+		doc.Body = append(doc.Body, opmlOutline{
+			Text:     folder.Cat,
+			Outlines: link_outlines(folder.Links),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// unfiled links first, then a section per folder
+func writeTmapMarkdown(w io.Writer, export *model.TmapExport) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "# %s\n", tmapExportTitle(export.LoginName))
+
+	write_links := func(links []model.TmapExportLink) {
+		bw.WriteString("\n")
+		for _, link := range links {
+			fmt.Fprintf(bw, "- <%s>", link.URL)
+			if link.Summary != "" {
+				fmt.Fprintf(bw, ": %s", link.Summary)
+			}
+			bw.WriteString("\n")
+		}
+	}
+
+	if len(export.Unfiled) > 0 {
+		write_links(export.Unfiled)
+	}
+	for _, folder := range export.Folders {
+		fmt.Fprintf(bw, "\n## %s\n", folder.Cat)
+		write_links(folder.Links)
+	}
+
+	return bw.Flush()
+}
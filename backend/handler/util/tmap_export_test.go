@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/julianlk522/fitm/model"
+)
+
+func TestNewTmapExport(t *testing.T) {
+	submitted := []model.TmapLink{
+		{Link: model.Link{ID: "a", URL: "https://a.example.com", Cats: "go,web", Summary: "A"}},
+		{Link: model.Link{ID: "b", URL: "https://b.example.com", Cats: "web"}},
+	}
+	copied := []model.TmapLink{
+		// also submitted
+		{Link: model.Link{ID: "a", URL: "https://a.example.com", Cats: "go,web", Summary: "A"}},
+		{Link: model.Link{ID: "c", URL: "https://c.example.com", Cats: "rare"}},
+	}
+	tagged := []model.TmapLink{}
+	tmap := model.Tmap[model.TmapLink]{
+		TmapSections: &model.TmapSections[model.TmapLink]{
+			Submitted: &submitted,
+			Copied:    &copied,
+			Tagged:    &tagged,
+		},
+	}
+
+	// cats counted once per link: web 2, go 1, rare 1
+	// "go" folder empty since "a" filed under more common "web"
+	export := NewTmapExport("jlk", tmap, &model.TmapCatCountsOpts{Limit: 2})
+	if len(export.Folders) != 1 || export.Folders[0].Cat != "web" || len(export.Folders[0].Links) != 2 {
+		t.Fatalf("got folders %+v, want web folder with 2 links", export.Folders)
+	} else if len(export.Unfiled) != 1 || export.Unfiled[0].URL != "https://c.example.com" {
+		t.Fatalf("got unfiled %+v, want c", export.Unfiled)
+	}
+
+	// default limit fits all cats
+	export = NewTmapExport("jlk", tmap, nil)
+	if len(export.Folders) != 2 || export.Folders[1].Cat != "rare" || len(export.Unfiled) != 0 {
+		t.Fatalf("got folders %+v, unfiled %+v, want web and rare folders", export.Folders, export.Unfiled)
+	}
+
+	// cats filter gets no folder
+	export = NewTmapExport("jlk", tmap, &model.TmapCatCountsOpts{OmittedCats: []string{"web"}})
+	if len(export.Folders) != 2 || export.Folders[0].Cat != "go" || export.Folders[1].Cat != "rare" {
+		t.Fatalf("got folders %+v, want go and rare folders", export.Folders)
+	} else if len(export.Unfiled) != 1 || export.Unfiled[0].URL != "https://b.example.com" {
+		t.Fatalf("got unfiled %+v, want b", export.Unfiled)
+	}
+}
+
+func TestGetTmapExportFolderLimit(t *testing.T) {
+	var test_params = []struct {
+		Params string
+		Want   int
+		Valid  bool
+	}{
+		{"", TMAP_EXPORT_DEFAULT_FOLDERS, true},
+		{"1", 1, true},
+		{"100", 100, true},
+		{"0", 0, false},
+		{"101", 0, false},
+		{"poop", 0, false},
+	}
+
+	for _, tp := range test_params {
+		got, err := GetTmapExportFolderLimit(tp.Params)
+		if tp.Valid != (err == nil) {
+			t.Fatalf("%q: got error %v", tp.Params, err)
+		} else if got != tp.Want {
+			t.Fatalf("%q: got %d, want %d", tp.Params, got, tp.Want)
+		}
+	}
+}
+
+func TestWriteTmapExport(t *testing.T) {
+	export := &model.TmapExport{
+		LoginName: "jlk",
+		Folders: []model.TmapExportFolder{
+			{Cat: "web", Links: []model.TmapExportLink{
+				{URL: "https://a.example.com/?q=1&r=2", Cats: "go,web", Summary: `A "quoted" <summary>`},
+			}},
+		},
+		Unfiled: []model.TmapExportLink{{URL: "https://c.example.com", Cats: "rare"}},
+	}
+
+	// bookmarks can be re-imported
+	var buf bytes.Buffer
+	if err := WriteTmapExport(&buf, TMAP_EXPORT_BOOKMARKS, export); err != nil {
+		t.Fatal(err)
+	}
+	bookmarks, err := ParseNetscapeBookmarks(&buf)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(bookmarks) != 2 {
+		t.Fatalf("got %d bookmarks, want 2", len(bookmarks))
+	} else if b := bookmarks[1]; b.URL != "https://a.example.com/?q=1&r=2" ||
+		b.Cats != "go,web" ||
+		strings.TrimSpace(b.Summary) != `A "quoted" <summary>` {
+		t.Fatalf("got %+v", b)
+	}
+
+	buf.Reset()
+	if err = WriteTmapExport(&buf, TMAP_EXPORT_OPML, export); err != nil {
+		t.Fatal(err)
+	}
+	var doc opmlDocument
+	if err = xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	} else if len(doc.Body) != 2 || doc.Body[1].Text != "web" || doc.Body[1].Outlines[0].Description != `A "quoted" <summary>` {
+		t.Fatalf("got %+v", doc)
+	}
+
+	buf.Reset()
+	if err = WriteTmapExport(&buf, TMAP_EXPORT_MARKDOWN, export); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(buf.String(), "## web\n\n- <https://a.example.com/?q=1&r=2>: A \"quoted\" <summary>\n") {
+		t.Fatalf("got markdown:\n%s", buf.String())
+	}
+
+	if err = WriteTmapExport(&buf, "csv", export); err == nil {
+		t.Fatal("expected error for unsupported format")
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	m "github.com/julianlk522/fitm/middleware"
 	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
 	"github.com/julianlk522/fitm/query"
 )
 
@@ -103,6 +104,160 @@ func TestGetTmapForUser(t *testing.T) {
 	}
 }
 
+func TestGetTmapForUserHideDead(t *testing.T) {
+	_, err := TestClient.Exec(
+		`INSERT INTO "Link Health" VALUES ('1', 'dead', 404, 4, '2024-01-01 00:00:00', '2024-01-08 00:00:00');`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer TestClient.Exec(`DELETE FROM "Link Health" WHERE link_id = '1';`)
+
+	var test_params = []struct {
+		HideDead     string
+		Valid        bool
+		ContainsDead bool
+	}{
+		{"", true, true},
+		{"false", true, true},
+		{"true", true, false},
+		{"maybe", false, false},
+	}
+
+	for _, tp := range test_params {
+		req := &http.Request{
+			URL: &url.URL{
+				RawQuery: url.Values{
+					"hide_dead": {tp.HideDead},
+				}.Encode(),
+			},
+		}
+		ctx := context.WithValue(context.Background(), m.JWTClaimsKey, map[string]interface{}{
+			"user_id": "",
+		})
+		req = req.WithContext(ctx)
+
+		tmap, err := GetTmapForUser[model.TmapLink](test_login_name, req)
+		if !tp.Valid {
+			if err == nil {
+				t.Fatalf("expected error for hide_dead=%s", tp.HideDead)
+			}
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		submitted := *tmap.(model.Tmap[model.TmapLink]).Submitted
+		contains_dead := slices.ContainsFunc(submitted, func(l model.TmapLink) bool {
+			return l.ID == "1"
+		})
+		if contains_dead != tp.ContainsDead {
+			t.Fatalf("hide_dead=%s: dead link returned: %t", tp.HideDead, contains_dead)
+		}
+	}
+}
+
+func TestGetTmapForUserDomain(t *testing.T) {
+	if _, err := BackfillLinkDomains(); err != nil {
+		t.Fatal(err)
+	}
+	defer TestClient.Exec(`UPDATE Links SET domain = NULL;`)
+
+	var test_params = []struct {
+		Domain     string
+		Valid      bool
+		WantDomain string
+		WantIDs    []string
+	}{
+		{"github.com", true, "github.com", []string{"2"}},
+		{"www.example.com", true, "example.com", []string{"1"}},
+		{"github.com/golang", false, "", nil},
+	}
+
+	for _, tp := range test_params {
+		req := &http.Request{
+			URL: &url.URL{
+				RawQuery: url.Values{
+					"domain": {tp.Domain},
+				}.Encode(),
+			},
+		}
+		ctx := context.WithValue(context.Background(), m.JWTClaimsKey, map[string]interface{}{
+			"user_id": "",
+		})
+		req = req.WithContext(ctx)
+
+		tmap, err := GetTmapForUser[model.TmapLink](test_login_name, req)
+		if !tp.Valid {
+			if err == nil {
+				t.Fatalf("expected error for domain=%s", tp.Domain)
+			}
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		sections := tmap.(model.Tmap[model.TmapLink]).TmapSections
+		all_links := slices.Concat(*sections.Submitted, *sections.Copied, *sections.Tagged)
+		for _, l := range all_links {
+			if mutil.LinkDomain(l.URL) != tp.WantDomain {
+				t.Fatalf("domain=%s: got link %s (%s)", tp.Domain, l.ID, l.URL)
+			}
+		}
+		for _, id := range tp.WantIDs {
+			if !slices.ContainsFunc(all_links, func(l model.TmapLink) bool { return l.ID == id }) {
+				t.Fatalf("domain=%s: link %s missing", tp.Domain, id)
+			}
+		}
+	}
+}
+
+func TestGetTmapForUserDateRange(t *testing.T) {
+	var test_params = []struct {
+		Params url.Values
+		Valid  bool
+	}{
+		{url.Values{"from": {"2024-01-15"}}, true},
+		{url.Values{"to": {"2024-01"}}, true},
+		{url.Values{"from": {"2024-01"}, "period": {"week"}}, false},
+		{url.Values{"from": {"2024-02"}, "to": {"2024-01"}}, false},
+		{url.Values{"to": {"last week"}}, false},
+	}
+
+	for _, tp := range test_params {
+		req := &http.Request{
+			URL: &url.URL{RawQuery: tp.Params.Encode()},
+		}
+		ctx := context.WithValue(context.Background(), m.JWTClaimsKey, map[string]interface{}{
+			"user_id": "",
+		})
+		req = req.WithContext(ctx)
+
+		tmap, err := GetTmapForUser[model.TmapLink](test_login_name, req)
+		if !tp.Valid {
+			if err == nil {
+				t.Fatalf("expected error for %s", tp.Params.Encode())
+			}
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		from, to := tp.Params.Get("from"), tp.Params.Get("to")
+		sections := tmap.(model.Tmap[model.TmapLink]).TmapSections
+		all_links := slices.Concat(*sections.Submitted, *sections.Copied, *sections.Tagged)
+		if len(all_links) == 0 {
+			t.Fatalf("%s: no links returned", tp.Params.Encode())
+		}
+		for _, l := range all_links {
+			submit_date := l.SubmitDate[:len("2006-01")]
+			if (from != "" && l.SubmitDate < from) || (to != "" && submit_date > to) {
+				t.Fatalf("%s: got link %s submitted %s", tp.Params.Encode(), l.ID, l.SubmitDate)
+			}
+		}
+	}
+}
+
 func TestScanTmapProfile(t *testing.T) {
 	profile_sql := query.NewTmapProfile(test_login_name)
 	// NewTmapProfile() tested in query/tmap_test.go
@@ -3,10 +3,12 @@ package handler
 import (
 	"database/sql"
 	"net/http"
-	"os"
 
 	"github.com/julianlk522/fitm/db"
 	e "github.com/julianlk522/fitm/error"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
 
 	"image"
 	_ "image/jpeg"
@@ -47,8 +49,8 @@ func AuthenticateUser(login_name string, password string) (bool, error) {
 }
 
 func GetJWTFromLoginName(login_name string) (string, error) {
-	var id sql.NullString
-	err := db.Client.QueryRow("SELECT id FROM Users WHERE login_name = ?", login_name).Scan(&id)
+	var id, role sql.NullString
+	err := db.Client.QueryRow("SELECT id, role FROM Users WHERE login_name = ?", login_name).Scan(&id, &role)
 	if err != nil {
 		return "", err
 	}
@@ -56,21 +58,14 @@ func GetJWTFromLoginName(login_name string) (string, error) {
 	claims := map[string]interface{}{
 		"user_id":    id.String,
 		"login_name": login_name,
+		"role":       role.String,
 	}
 	// TEST
 	jwtauth.SetIssuedNow(claims)
 	jwtauth.SetExpiry(claims, time.Now().Add(4*time.Hour))
 
-	secret := os.Getenv("FITM_JWT_SECRET")
-	if secret == "" {
-		return "", e.ErrNoJWTSecretEnv
-	}
-	auth := jwtauth.New(
-		"HS256",
-		[]byte(secret),
-		nil,
-	)
-	_, token, err := auth.Encode(claims)
+	// shared keyring also used by JWT middleware
+	_, token, err := m.TokenAuth.Encode(claims)
 	if err != nil {
 		return "", err
 	}
@@ -103,3 +98,134 @@ func UserWithIDHasProfilePic(user_id string) bool {
 	}
 	return p.Valid
 }
+
+// Edit login name
+func GetLoginNameFromUserID(user_id string) (string, error) {
+	var login_name string
+	err := db.Client.QueryRow("SELECT login_name FROM Users WHERE id = ?;", user_id).Scan(&login_name)
+	if err != nil {
+		return "", err
+	}
+
+	return login_name, nil
+}
+
+// Released names stay reserved for their previous owner during cooldown
+// (user_id empty if requester has no account yet)
+func LoginNameReserved(login_name string, user_id string) (bool, error) {
+	var is_reserved bool
+	err := db.Client.QueryRow(
+		`SELECT EXISTS(
+			SELECT 1 FROM "Login Name History"
+			WHERE old_login_name = ?
+			AND user_id != ?
+			AND reserved_until > ?
+		);`,
+		login_name,
+		user_id,
+		mutil.NEW_LONG_TIMESTAMP(),
+	).Scan(&is_reserved)
+	if err != nil {
+		return false, err
+	}
+
+	return is_reserved, nil
+}
+
+// Returns when user can next change login name ("" if now)
+func GetNextLoginNameChangeTime(user_id string) (string, error) {
+	var last_changed_at sql.NullString
+	err := db.Client.QueryRow(
+		`SELECT MAX(changed_at)
+		FROM "Login Name History"
+		WHERE user_id = ?
+		AND changed_at > ?;`,
+		user_id,
+		time.Now().
+			Add(-mutil.LOGIN_NAME_CHANGE_COOLDOWN).
+			Format("2006-01-02 15:04:05"),
+	).Scan(&last_changed_at)
+	if err != nil {
+		return "", err
+	} else if !last_changed_at.Valid {
+		return "", nil
+	}
+
+	changed_at, err := time.ParseInLocation("2006-01-02 15:04:05", last_changed_at.String, time.Local)
+	if err != nil {
+		return "", err
+	}
+
+	return changed_at.
+		Add(mutil.LOGIN_NAME_CHANGE_COOLDOWN).
+		Format("2006-01-02 15:04:05"), nil
+}
+
+// Links.submitted_by, Tags.submitted_by and user_cats_fts store login
+// names, so all are updated along with Users
+func RenameUser(edit_data *model.EditLoginNameRequest, user_id string, old_login_name string) error {
+	tx, err := db.Client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE Users SET login_name = ? WHERE id = ?;",
+		edit_data.LoginName,
+		user_id,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range []string{"Links", "Tags", "user_cats_fts"} {
+		_, err = tx.Exec(
+			"UPDATE "+table+" SET submitted_by = ? WHERE submitted_by = ?;",
+			edit_data.LoginName,
+			old_login_name,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO "Login Name History" VALUES (?,?,?,?,?,?);`,
+		edit_data.ID,
+		user_id,
+		old_login_name,
+		edit_data.LoginName,
+		edit_data.ChangedAt,
+		edit_data.ReservedUntil,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Get treasure map
+// Current login name of whoever last gave up old_login_name
+// ("" if never renamed)
+func GetRenamedLoginName(old_login_name string) (string, error) {
+	var login_name string
+	err := db.Client.QueryRow(
+		`SELECT u.login_name
+		FROM "Login Name History" h
+		INNER JOIN Users u ON u.id = h.user_id
+		WHERE h.old_login_name = ?
+		ORDER BY h.changed_at DESC
+		LIMIT 1;`,
+		old_login_name,
+	).Scan(&login_name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return login_name, nil
+}
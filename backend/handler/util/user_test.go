@@ -52,9 +52,44 @@ func TestAuthenticateUser(t *testing.T) {
 	}
 }
 
+// Edit login name
+func TestLoginNameReserved(t *testing.T) {
+	_, err := TestClient.Exec(
+		`INSERT INTO "Login Name History" VALUES (?,?,?,?,?,?), (?,?,?,?,?,?);`,
+		"reserved_test_1", test_user_id, "reserved_old", test_login_name, "2024-01-01 00:00:00", "9999-01-01 00:00:00",
+		"reserved_test_2", test_user_id, "expired_old", test_login_name, "2024-01-01 00:00:00", "2024-01-31 00:00:00",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var test_login_names = []struct {
+		LoginName string
+		UserID    string
+		Reserved  bool
+	}{
+		{"reserved_old", "", true},
+		{"reserved_old", "some_other_user", true},
+		// previous owner can reclaim
+		{"reserved_old", test_user_id, false},
+		// cooldown over
+		{"expired_old", "", false},
+		{"never_used", "", false},
+	}
+
+	for _, l := range test_login_names {
+		is_reserved, err := LoginNameReserved(l.LoginName, l.UserID)
+		if err != nil {
+			t.Fatal(err)
+		} else if is_reserved != l.Reserved {
+			t.Fatalf("login name %s (user %s): got reserved %t, want %t", l.LoginName, l.UserID, is_reserved, l.Reserved)
+		}
+	}
+}
+
 // GetJWTFromLoginName() is just running an 8-word SQL query to get a user ID
-// and using go-chi jwtauth.New()
-// not worth testing
+// and signing with the shared keyring
+// (keyring covered in middleware/keyring_test.go)
 
 // Upload profile pic
 func TestHasAcceptableAspectRatio(t *testing.T) {
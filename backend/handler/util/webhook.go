@@ -0,0 +1,695 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/julianlk522/fitm/db"
+	e "github.com/julianlk522/fitm/error"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+// (replaced in tests)
+var WebhookClient = newPublicOnlyClient(mutil.WEBHOOK_TIMEOUT, false)
+
+// special-use ranges not covered by netip.Addr methods
+var non_public_prefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// CGNAT
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.88.99.0/24"),
+	// benchmarking
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	// reserved, broadcast
+	netip.MustParsePrefix("240.0.0.0/4"),
+	// NAT64 (can embed private IPv4 addresses)
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// Doesn't connect to loopback / private addresses (incl. via redirects,
+// which are followed only if follow_redirects)
+func newPublicOnlyClient(timeout time.Duration, follow_redirects bool) *http.Client {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: timeout,
+				Control: rejectNonPublicAddress,
+			}).DialContext,
+		},
+	}
+	if !follow_redirects {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	return client
+}
+
+func rejectNonPublicAddress(network string, address string, c syscall.RawConn) error {
+	addr_port, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return e.ErrWebhookHostNotAllowed
+	}
+
+	if !isPublicAddr(addr_port.Addr()) {
+		return e.ErrWebhookHostNotAllowed
+	}
+
+	return nil
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	// e.g., ::ffff:127.0.0.1
+	addr = addr.Unmap()
+
+	if !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsMulticast() {
+		return false
+	}
+
+	for _, prefix := range non_public_prefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Get webhooks
+func GetWebhooks(user_id string) (*[]model.Webhook, error) {
+	rows, err := db.Client.Query(
+		`SELECT id, url, cats, followed_users, is_enabled, consecutive_failures, created
+		FROM Webhooks
+		WHERE user_id = ?
+		ORDER BY created DESC, id;`,
+		user_id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []model.Webhook{}
+	for rows.Next() {
+		var wh model.Webhook
+		if err := rows.Scan(
+			&wh.ID,
+			&wh.URL,
+			&wh.Cats,
+			&wh.FollowedUsers,
+			&wh.IsEnabled,
+			&wh.ConsecutiveFailures,
+			&wh.Created,
+		); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, wh)
+	}
+
+	return &webhooks, nil
+}
+
+// ErrNoWebhookWithID if not user's
+func GetWebhook(user_id string, webhook_id string) (*model.Webhook, error) {
+	var wh model.Webhook
+	err := db.Client.QueryRow(
+		`SELECT id, url, cats, followed_users, is_enabled, consecutive_failures, created
+		FROM Webhooks
+		WHERE id = ? AND user_id = ?;`,
+		webhook_id,
+		user_id,
+	).Scan(
+		&wh.ID,
+		&wh.URL,
+		&wh.Cats,
+		&wh.FollowedUsers,
+		&wh.IsEnabled,
+		&wh.ConsecutiveFailures,
+		&wh.Created,
+	)
+	if err == sql.ErrNoRows {
+		return nil, e.ErrNoWebhookWithID
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &wh, nil
+}
+
+// Add webhook
+func GetNumWebhooks(user_id string) (int, error) {
+	var count int
+	err := db.Client.QueryRow(
+		`SELECT count(*) FROM Webhooks WHERE user_id = ?;`,
+		user_id,
+	).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func SaveNewWebhook(user_id string, request *model.NewWebhookRequest) (*model.NewWebhook, error) {
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Client.Exec(
+		`INSERT INTO Webhooks (id, user_id, url, secret, cats, followed_users, created)
+		VALUES (?,?,?,?,?,?,?);`,
+		request.ID,
+		user_id,
+		request.URL,
+		secret,
+		request.Cats,
+		request.FollowedUsers,
+		request.Created,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.NewWebhook{
+		Webhook: model.Webhook{
+			ID:            request.ID,
+			URL:           request.URL,
+			Cats:          request.Cats,
+			FollowedUsers: request.FollowedUsers,
+			IsEnabled:     true,
+			Created:       request.Created,
+		},
+		Secret: secret,
+	}, nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Delete webhook
+// (along with its delivery log)
+func DeleteWebhook(user_id string, webhook_id string) error {
+	tx, err := db.Client.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		`DELETE FROM Webhooks WHERE id = ? AND user_id = ?;`,
+		webhook_id,
+		user_id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return e.ErrNoWebhookWithID
+	}
+
+	if _, err = tx.Exec(
+		`DELETE FROM "Webhook Deliveries" WHERE webhook_id = ?;`,
+		webhook_id,
+	); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Re-enable webhook
+// (e.g., after it was disabled for failing)
+func EnableWebhook(webhook_id string) error {
+	_, err := db.Client.Exec(
+		`UPDATE Webhooks SET is_enabled = 1, consecutive_failures = 0 WHERE id = ?;`,
+		webhook_id,
+	)
+
+	return err
+}
+
+// Delivery log
+// most recent first
+func GetWebhookDeliveries(webhook_id string) (*[]model.WebhookDelivery, error) {
+	rows, err := db.Client.Query(
+		`SELECT `+WEBHOOK_DELIVERY_FIELDS+`
+		FROM "Webhook Deliveries"
+		WHERE webhook_id = ?
+		ORDER BY created DESC, id
+		LIMIT ?;`,
+		webhook_id,
+		mutil.WEBHOOK_DELIVERIES_LIMIT,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []model.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+
+	return &deliveries, nil
+}
+
+func GetWebhookDelivery(delivery_id string) (*model.WebhookDelivery, error) {
+	return scanWebhookDelivery(db.Client.QueryRow(
+		`SELECT `+WEBHOOK_DELIVERY_FIELDS+`
+		FROM "Webhook Deliveries"
+		WHERE id = ?;`,
+		delivery_id,
+	))
+}
+
+const WEBHOOK_DELIVERY_FIELDS = `id, event, status, attempts, response_code, error, created, last_attempted, next_attempt`
+
+// row is *sql.Row or *sql.Rows
+func scanWebhookDelivery(row interface{ Scan(...any) error }) (*model.WebhookDelivery, error) {
+	var d model.WebhookDelivery
+	var response_code sql.NullInt64
+	var delivery_err, last_attempted sql.NullString
+
+	if err := row.Scan(
+		&d.ID,
+		&d.Event,
+		&d.Status,
+		&d.Attempts,
+		&response_code,
+		&delivery_err,
+		&d.Created,
+		&last_attempted,
+		&d.NextAttempt,
+	); err != nil {
+		return nil, err
+	}
+
+	d.ResponseCode = int(response_code.Int64)
+	d.Error = delivery_err.String
+	d.LastAttempted = last_attempted.String
+	if d.Status != model.WEBHOOK_DELIVERY_PENDING {
+		d.NextAttempt = ""
+	}
+
+	return &d, nil
+}
+
+// Queue deliveries
+// Returns IDs of deliveries queued for enabled webhooks matching link:
+// link has all of webhook's cats, or (with followed_users) submitter is
+// followed by webhook's owner.
+// NSFW links only match webhooks with NSFW in cats
+func QueueNewLinkWebhookDeliveries(link *model.Link) ([]string, error) {
+	rows, err := db.Client.Query(
+		`SELECT
+			w.id,
+			w.cats,
+			w.followed_users = 1 AND EXISTS(
+				SELECT 1
+				FROM "User Follows" f
+				INNER JOIN Users u ON u.id = f.followed_id
+				WHERE f.user_id = w.user_id
+				AND u.login_name = ?
+			)
+		FROM Webhooks w
+		WHERE w.is_enabled = 1;`,
+		link.SubmittedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	link_cats := strings.Split(strings.ToLower(link.Cats), ",")
+	is_nsfw := slices.Contains(link_cats, "nsfw")
+
+	var webhook_ids []string
+	for rows.Next() {
+		var id, cats string
+		var submitter_followed bool
+		if err := rows.Scan(&id, &cats, &submitter_followed); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		webhook_cats := strings.Split(strings.ToLower(cats), ",")
+		if is_nsfw && !slices.Contains(webhook_cats, "nsfw") {
+			continue
+		}
+
+		has_all_cats := cats != ""
+		for _, cat := range webhook_cats {
+			has_all_cats = has_all_cats && slices.Contains(link_cats, cat)
+		}
+
+		if submitter_followed || has_all_cats {
+			webhook_ids = append(webhook_ids, id)
+		}
+	}
+	rows.Close()
+
+	var delivery_ids []string
+	for _, webhook_id := range webhook_ids {
+		delivery_id, err := QueueWebhookDelivery(webhook_id, model.WEBHOOK_EVENT_NEW_LINK, link)
+		if err != nil {
+			return nil, err
+		}
+		delivery_ids = append(delivery_ids, delivery_id)
+	}
+
+	return delivery_ids, nil
+}
+
+// link nil for test events
+func QueueWebhookDelivery(webhook_id string, event string, link *model.Link) (string, error) {
+	created := mutil.NEW_LONG_TIMESTAMP()
+	payload, err := json.Marshal(model.WebhookPayload{
+		Event:     event,
+		WebhookID: webhook_id,
+		Created:   created,
+		Link:      link,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	delivery_id := uuid.New().String()
+	_, err = db.Client.Exec(
+		`INSERT INTO "Webhook Deliveries" (id, webhook_id, event, payload, status, created, next_attempt)
+		VALUES (?,?,?,?,?,?,?);`,
+		delivery_id,
+		webhook_id,
+		event,
+		string(payload),
+		model.WEBHOOK_DELIVERY_PENDING,
+		created,
+		created,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return delivery_id, nil
+}
+
+// Run deliveries
+func GetDueWebhookDeliveryIDs() ([]string, error) {
+	rows, err := db.Client.Query(
+		`SELECT id
+		FROM "Webhook Deliveries"
+		WHERE status = ? AND next_attempt <= ?
+		ORDER BY next_attempt, id;`,
+		model.WEBHOOK_DELIVERY_PENDING,
+		mutil.NEW_LONG_TIMESTAMP(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// host of delivery's webhook URL ("" if webhook deleted)
+func GetWebhookDeliveryHost(delivery_id string) (string, error) {
+	var webhook_url string
+	err := db.Client.QueryRow(
+		`SELECT COALESCE(w.url, '')
+		FROM "Webhook Deliveries" d
+		LEFT JOIN Webhooks w ON w.id = d.webhook_id
+		WHERE d.id = ?;`,
+		delivery_id,
+	).Scan(&webhook_url)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(webhook_url)
+	if err != nil {
+		return "", nil
+	}
+
+	return strings.ToLower(u.Host), nil
+}
+
+// Runs deliveries in the background: one at a time per endpoint host, up
+// to max_concurrent at once
+type WebhookDispatcher struct {
+	mu sync.Mutex
+	// host -> queued delivery IDs (host present while its worker runs)
+	queues map[string][]string
+	// (sweeps may dispatch deliveries still queued)
+	queued map[string]bool
+	sem    chan struct{}
+}
+
+func NewWebhookDispatcher(max_concurrent int) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		queues: map[string][]string{},
+		queued: map[string]bool{},
+		sem:    make(chan struct{}, max_concurrent),
+	}
+}
+
+func (d *WebhookDispatcher) Dispatch(delivery_id string) error {
+	host, err := GetWebhookDeliveryHost(delivery_id)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.queued[delivery_id] {
+		return nil
+	}
+	d.queued[delivery_id] = true
+
+	_, is_running := d.queues[host]
+	d.queues[host] = append(d.queues[host], delivery_id)
+	if !is_running {
+		go d.runHost(host)
+	}
+
+	return nil
+}
+
+func (d *WebhookDispatcher) runHost(host string) {
+	for {
+		d.mu.Lock()
+		queue := d.queues[host]
+		if len(queue) == 0 {
+			delete(d.queues, host)
+			d.mu.Unlock()
+			return
+		}
+		delivery_id := queue[0]
+		d.queues[host] = queue[1:]
+		d.mu.Unlock()
+
+		d.sem <- struct{}{}
+		if err := RunWebhookDelivery(delivery_id); err != nil {
+			log.Printf("webhook delivery %s failed: %s", delivery_id, err)
+		}
+		<-d.sem
+
+		d.mu.Lock()
+		delete(d.queued, delivery_id)
+		d.mu.Unlock()
+	}
+}
+
+// Attempts delivery if due. On failure, retried with exponential backoff
+// until WEBHOOK_MAX_ATTEMPTS (test events not retried).
+// Webhook disabled after WEBHOOK_DISABLE_AFTER_FAILURES deliveries in a row
+// fail all attempts
+func RunWebhookDelivery(delivery_id string) error {
+	now := mutil.NEW_LONG_TIMESTAMP()
+
+	// claim until attempt times out so delivery not sent twice
+	lease := time.Now().Add(2 * mutil.WEBHOOK_TIMEOUT).Format("2006-01-02 15:04:05")
+	var webhook_id, event, payload string
+	var attempts int
+	err := db.Client.QueryRow(
+		`UPDATE "Webhook Deliveries"
+		SET next_attempt = ?
+		WHERE id = ? AND status = ? AND next_attempt <= ?
+		RETURNING webhook_id, event, payload, attempts;`,
+		lease,
+		delivery_id,
+		model.WEBHOOK_DELIVERY_PENDING,
+		now,
+	).Scan(&webhook_id, &event, &payload, &attempts)
+
+	// already claimed or not due
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var url, secret string
+	var is_enabled bool
+	err = db.Client.QueryRow(
+		`SELECT url, secret, is_enabled FROM Webhooks WHERE id = ?;`,
+		webhook_id,
+	).Scan(&url, &secret, &is_enabled)
+	// (test events sent to disabled webhooks to help fix them)
+	if err == sql.ErrNoRows || (err == nil && !is_enabled && event != model.WEBHOOK_EVENT_TEST) {
+		return finishWebhookDelivery(delivery_id, model.WEBHOOK_DELIVERY_FAILED, attempts, 0, "webhook disabled", now, now)
+	} else if err != nil {
+		return err
+	}
+
+	attempts++
+	response_code, err := SendWebhook(url, secret, delivery_id, event, []byte(payload))
+	if err == nil {
+		if _, err = db.Client.Exec(
+			`UPDATE Webhooks SET consecutive_failures = 0 WHERE id = ?;`,
+			webhook_id,
+		); err != nil {
+			return err
+		}
+		return finishWebhookDelivery(delivery_id, model.WEBHOOK_DELIVERY_SUCCEEDED, attempts, response_code, "", now, now)
+	}
+
+	if event != model.WEBHOOK_EVENT_TEST && attempts < mutil.WEBHOOK_MAX_ATTEMPTS {
+		next_attempt := time.Now().
+			Add(mutil.WEBHOOK_RETRY_BASE_DELAY << (attempts - 1)).
+			Format("2006-01-02 15:04:05")
+		return finishWebhookDelivery(delivery_id, model.WEBHOOK_DELIVERY_PENDING, attempts, response_code, err.Error(), now, next_attempt)
+	}
+
+	if err := finishWebhookDelivery(delivery_id, model.WEBHOOK_DELIVERY_FAILED, attempts, response_code, err.Error(), now, now); err != nil {
+		return err
+	}
+	if event == model.WEBHOOK_EVENT_TEST {
+		return nil
+	}
+
+	_, err = db.Client.Exec(
+		`UPDATE Webhooks
+		SET
+			consecutive_failures = consecutive_failures + 1,
+			is_enabled = consecutive_failures + 1 < ?
+		WHERE id = ?;`,
+		mutil.WEBHOOK_DISABLE_AFTER_FAILURES,
+		webhook_id,
+	)
+
+	return err
+}
+
+// response_code 0: no response
+func finishWebhookDelivery(delivery_id string, status string, attempts int, response_code int, delivery_err string, attempted string, next_attempt string) error {
+	_, err := db.Client.Exec(
+		`UPDATE "Webhook Deliveries"
+		SET
+			status = ?,
+			attempts = ?,
+			response_code = NULLIF(?, 0),
+			error = NULLIF(?, ''),
+			last_attempted = ?,
+			next_attempt = ?
+		WHERE id = ?;`,
+		status,
+		attempts,
+		response_code,
+		delivery_err,
+		attempted,
+		next_attempt,
+		delivery_id,
+	)
+
+	return err
+}
+
+// POSTs payload signed like GitHub webhooks: X-FITM-Signature-256 is
+// "sha256=" + hex HMAC-SHA256 of body using webhook secret.
+// Error if no 2xx response (response_code 0 if no response)
+func SendWebhook(url string, secret string, delivery_id string, event string, payload []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "FITM-Webhooks")
+	req.Header.Set("X-FITM-Event", event)
+	req.Header.Set("X-FITM-Delivery", delivery_id)
+	req.Header.Set("X-FITM-Signature-256", WebhookSignature(secret, payload))
+
+	resp, err := WebhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, e.UnexpectedWebhookResponse(resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+func WebhookSignature(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
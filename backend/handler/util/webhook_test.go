@@ -0,0 +1,278 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+func insertTestWebhook(t *testing.T, id string, url string, cats string, followed_users bool) {
+	if _, err := TestClient.Exec(
+		`INSERT INTO Webhooks (id, user_id, url, secret, cats, followed_users, created)
+		VALUES (?,?,?,?,?,?,?);`,
+		id,
+		test_user_id,
+		url,
+		"test_secret",
+		cats,
+		followed_users,
+		"2024-01-01 00:00:00",
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueueNewLinkWebhookDeliveries(t *testing.T) {
+	insertTestWebhook(t, "webhook_go", "https://hooks.example.com", "go", false)
+	insertTestWebhook(t, "webhook_go_rust", "https://hooks.example.com", "go,rust", false)
+	insertTestWebhook(t, "webhook_nsfw", "https://hooks.example.com", "NSFW", false)
+	insertTestWebhook(t, "webhook_followed", "https://hooks.example.com", "", true)
+	defer TestClient.Exec(`DELETE FROM Webhooks WHERE id LIKE 'webhook_%';`)
+	defer TestClient.Exec(`DELETE FROM "Webhook Deliveries" WHERE webhook_id LIKE 'webhook_%';`)
+
+	// jlk (test_user_id) follows test_req_login_name
+	if err := FollowUser(test_user_id, test_req_user_id); err != nil {
+		t.Fatal(err)
+	}
+	defer UnfollowUser(test_user_id, test_req_user_id)
+
+	var test_links = []struct {
+		Link           model.Link
+		WantWebhookIDs []string
+	}{
+		{model.Link{ID: "a", SubmittedBy: "jlk", Cats: "Go,programming"}, []string{"webhook_go"}},
+		{model.Link{ID: "b", SubmittedBy: "jlk", Cats: "go,rust"}, []string{"webhook_go", "webhook_go_rust"}},
+		{model.Link{ID: "c", SubmittedBy: "test_req_login_name", Cats: "cooking"}, []string{"webhook_followed"}},
+		// NSFW only to webhooks filtering for it
+		{model.Link{ID: "d", SubmittedBy: "test_req_login_name", Cats: "go,NSFW"}, []string{"webhook_nsfw"}},
+	}
+
+	for _, tl := range test_links {
+		delivery_ids, err := QueueNewLinkWebhookDeliveries(&tl.Link)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var webhook_ids []string
+		for _, id := range delivery_ids {
+			var webhook_id string
+			if err := TestClient.QueryRow(
+				`SELECT webhook_id FROM "Webhook Deliveries" WHERE id = ?;`,
+				id,
+			).Scan(&webhook_id); err != nil {
+				t.Fatal(err)
+			}
+			webhook_ids = append(webhook_ids, webhook_id)
+		}
+		slices.Sort(webhook_ids)
+
+		if !slices.Equal(webhook_ids, tl.WantWebhookIDs) {
+			t.Fatalf("link with cats %s: got webhooks %v, want %v", tl.Link.Cats, webhook_ids, tl.WantWebhookIDs)
+		}
+	}
+}
+
+func TestRunWebhookDelivery(t *testing.T) {
+	status_code := http.StatusNoContent
+	var num_requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		num_requests++
+		payload, _ := io.ReadAll(r.Body)
+		if !hmac.Equal(
+			[]byte(r.Header.Get("X-FITM-Signature-256")),
+			[]byte(WebhookSignature("test_secret", payload)),
+		) {
+			t.Errorf("invalid signature %q", r.Header.Get("X-FITM-Signature-256"))
+		}
+		w.WriteHeader(status_code)
+	}))
+	defer server.Close()
+
+	default_client := WebhookClient
+	WebhookClient = server.Client()
+	defer func() { WebhookClient = default_client }()
+
+	insertTestWebhook(t, "webhook_retry", server.URL, "go", false)
+	defer TestClient.Exec(`DELETE FROM Webhooks WHERE id = 'webhook_retry';`)
+	defer TestClient.Exec(`DELETE FROM "Webhook Deliveries" WHERE webhook_id = 'webhook_retry';`)
+
+	// loopback addresses rejected by default client
+	if _, err := SendWebhook(server.URL, "test_secret", "x", model.WEBHOOK_EVENT_TEST, []byte("{}")); err != nil {
+		t.Fatal(err)
+	} else if _, err = default_client.Post(server.URL, "application/json", nil); err == nil {
+		t.Fatal("expected default client to reject loopback address")
+	}
+	num_requests = 0
+	status_code = http.StatusInternalServerError
+
+	delivery_id, err := QueueWebhookDelivery("webhook_retry", model.WEBHOOK_EVENT_NEW_LINK, &model.Link{ID: "1"})
+	if err != nil {
+		t.Fatal(err)
+	} else if err = RunWebhookDelivery(delivery_id); err != nil {
+		t.Fatal(err)
+	}
+
+	delivery, err := GetWebhookDelivery(delivery_id)
+	if err != nil {
+		t.Fatal(err)
+	} else if delivery.Status != model.WEBHOOK_DELIVERY_PENDING ||
+		delivery.Attempts != 1 ||
+		delivery.ResponseCode != http.StatusInternalServerError ||
+		delivery.NextAttempt <= delivery.LastAttempted {
+		t.Fatalf("got %+v, want pending retry after 500", delivery)
+	}
+
+	// not due yet
+	if err = RunWebhookDelivery(delivery_id); err != nil {
+		t.Fatal(err)
+	} else if num_requests != 1 {
+		t.Fatalf("got %d requests, want 1", num_requests)
+	}
+
+	// due
+	if _, err = TestClient.Exec(
+		`UPDATE "Webhook Deliveries" SET next_attempt = ? WHERE id = ?;`,
+		"2024-01-01 00:00:00",
+		delivery_id,
+	); err != nil {
+		t.Fatal(err)
+	}
+	status_code = http.StatusNoContent
+	if err = RunWebhookDelivery(delivery_id); err != nil {
+		t.Fatal(err)
+	} else if delivery, err = GetWebhookDelivery(delivery_id); err != nil {
+		t.Fatal(err)
+	} else if delivery.Status != model.WEBHOOK_DELIVERY_SUCCEEDED || delivery.Attempts != 2 {
+		t.Fatalf("got %+v, want succeeded after 2 attempts", delivery)
+	}
+
+	// disabled after deliveries fail all attempts in a row
+	status_code = http.StatusBadGateway
+	for i := 0; i < mutil.WEBHOOK_DISABLE_AFTER_FAILURES; i++ {
+		delivery_id, err := QueueWebhookDelivery("webhook_retry", model.WEBHOOK_EVENT_NEW_LINK, &model.Link{ID: "1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = TestClient.Exec(
+			`UPDATE "Webhook Deliveries" SET attempts = ? WHERE id = ?;`,
+			mutil.WEBHOOK_MAX_ATTEMPTS-1,
+			delivery_id,
+		); err != nil {
+			t.Fatal(err)
+		} else if err = RunWebhookDelivery(delivery_id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	webhook, err := GetWebhook(test_user_id, "webhook_retry")
+	if err != nil {
+		t.Fatal(err)
+	} else if webhook.IsEnabled || webhook.ConsecutiveFailures != mutil.WEBHOOK_DISABLE_AFTER_FAILURES {
+		t.Fatalf("got %+v, want disabled", webhook)
+	}
+}
+
+// slow endpoint doesn't hold up others'
+func TestWebhookDispatcher(t *testing.T) {
+	release := make(chan struct{})
+	slow_server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer slow_server.Close()
+
+	fast_server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer fast_server.Close()
+
+	default_client := WebhookClient
+	WebhookClient = fast_server.Client()
+	defer func() { WebhookClient = default_client }()
+
+	insertTestWebhook(t, "webhook_slow", slow_server.URL, "go", false)
+	insertTestWebhook(t, "webhook_fast", fast_server.URL, "go", false)
+	defer TestClient.Exec(`DELETE FROM Webhooks WHERE id IN ('webhook_slow', 'webhook_fast');`)
+	defer TestClient.Exec(`DELETE FROM "Webhook Deliveries" WHERE webhook_id IN ('webhook_slow', 'webhook_fast');`)
+
+	dispatcher := NewWebhookDispatcher(2)
+	for _, webhook_id := range []string{"webhook_slow", "webhook_slow", "webhook_fast"} {
+		delivery_id, err := QueueWebhookDelivery(webhook_id, model.WEBHOOK_EVENT_NEW_LINK, &model.Link{ID: "1"})
+		if err != nil {
+			t.Fatal(err)
+		} else if err = dispatcher.Dispatch(delivery_id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wait_for_deliveries := func(webhook_id string, num_deliveries int) {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			deliveries, err := GetWebhookDeliveries(webhook_id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			num_succeeded := 0
+			for _, d := range *deliveries {
+				if d.Status == model.WEBHOOK_DELIVERY_SUCCEEDED {
+					num_succeeded++
+				}
+			}
+			if num_succeeded == num_deliveries {
+				return
+			} else if time.Now().After(deadline) {
+				t.Fatalf("%s: got %+v, want %d succeeded", webhook_id, *deliveries, num_deliveries)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// while slow endpoint blocked
+	wait_for_deliveries("webhook_fast", 1)
+
+	close(release)
+	wait_for_deliveries("webhook_slow", 2)
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	var test_addrs = []struct {
+		Addr     string
+		IsPublic bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"192.0.0.8", false},
+		{"198.18.0.1", false},
+		{"198.19.255.255", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"64:ff9b::a00:1", false},
+	}
+
+	for _, ta := range test_addrs {
+		if got := isPublicAddr(netip.MustParseAddr(ta.Addr)); got != ta.IsPublic {
+			t.Fatalf("%s: got %t, want %t", ta.Addr, got, ta.IsPublic)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+var webhook_jobs = make(chan string, 100)
+
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+
+	webhooks, err := util.GetWebhooks(req_user_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.JSON(w, r, webhooks)
+}
+
+// {"url": "https://example.com/hook", "cats": "go", "followed_users": true}
+// Response includes secret for verifying deliveries (not shown again)
+func AddWebhook(w http.ResponseWriter, r *http.Request) {
+	request := &model.NewWebhookRequest{}
+	if err := render.Bind(r, request); err != nil {
+		render.Render(w, r, e.ErrInvalidRequest(err))
+		return
+	}
+
+	if request.Cats != "" {
+		request.Cats = util.AlphabetizeCats(request.Cats)
+	}
+
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+	if num_webhooks, err := util.GetNumWebhooks(req_user_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	} else if num_webhooks >= mutil.WEBHOOKS_LIMIT {
+		render.Render(w, r, e.ErrInvalidRequest(e.NumWebhooksExceedsLimit(mutil.WEBHOOKS_LIMIT)))
+		return
+	}
+
+	webhook, err := util.SaveNewWebhook(req_user_id, request)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, webhook)
+}
+
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook_id := chi.URLParam(r, "webhook_id")
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+
+	err := util.DeleteWebhook(req_user_id, webhook_id)
+	if err == e.ErrNoWebhookWithID {
+		render.Render(w, r, e.Err404(err))
+		return
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func EnableWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := getRequestedWebhook(w, r)
+	if !ok {
+		return
+	} else if webhook.IsEnabled {
+		render.Render(w, r, e.ErrInvalidRequest(e.ErrWebhookAlreadyEnabled))
+		return
+	}
+
+	if err := util.EnableWebhook(webhook.ID); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := getRequestedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := util.GetWebhookDeliveries(webhook.ID)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.JSON(w, r, deliveries)
+}
+
+// Delivers test event right away (even if webhook disabled) and returns
+// the result; not retried
+func SendTestWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, ok := getRequestedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	delivery_id, err := util.QueueWebhookDelivery(webhook.ID, model.WEBHOOK_EVENT_TEST, nil)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	if err = util.RunWebhookDelivery(delivery_id); err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	delivery, err := util.GetWebhookDelivery(delivery_id)
+	if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return
+	}
+
+	render.JSON(w, r, delivery)
+}
+
+// renders error and returns false if webhook not found or not user's
+func getRequestedWebhook(w http.ResponseWriter, r *http.Request) (*model.Webhook, bool) {
+	webhook_id := chi.URLParam(r, "webhook_id")
+	req_user_id := r.Context().Value(m.JWTClaimsKey).(map[string]interface{})["user_id"].(string)
+
+	webhook, err := util.GetWebhook(req_user_id, webhook_id)
+	if err == e.ErrNoWebhookWithID {
+		render.Render(w, r, e.Err404(err))
+		return nil, false
+	} else if err != nil {
+		render.Render(w, r, e.Err500(err))
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+// Queues deliveries of new link to matching webhooks
+// (failure logged: link already added)
+func queueNewLinkWebhooks(link *model.Link) {
+	delivery_ids, err := util.QueueNewLinkWebhookDeliveries(link)
+	if err != nil {
+		log.Printf("could not queue webhook deliveries for link %s: %s", link.ID, err)
+		return
+	}
+
+	for _, delivery_id := range delivery_ids {
+		select {
+		case webhook_jobs <- delivery_id:
+		default:
+			// queue full: sweep will pick it up
+		}
+	}
+}
+
+// Sends queued deliveries in the background and retries failed ones
+func StartWebhookDeliveries() error {
+	dispatcher := util.NewWebhookDispatcher(mutil.WEBHOOK_MAX_CONCURRENT_DELIVERIES)
+
+	go func() {
+		ticker := time.NewTicker(mutil.WEBHOOK_SWEEP_INTERVAL)
+		defer ticker.Stop()
+
+		// catch up on deliveries due before start
+		sweepWebhookDeliveries(dispatcher)
+
+		for {
+			select {
+			case delivery_id := <-webhook_jobs:
+				if err := dispatcher.Dispatch(delivery_id); err != nil {
+					log.Printf("could not dispatch webhook delivery %s: %s", delivery_id, err)
+				}
+			case <-ticker.C:
+				sweepWebhookDeliveries(dispatcher)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func sweepWebhookDeliveries(dispatcher *util.WebhookDispatcher) {
+	delivery_ids, err := util.GetDueWebhookDeliveryIDs()
+	if err != nil {
+		log.Printf("could not get due webhook deliveries: %s", err)
+	}
+	for _, delivery_id := range delivery_ids {
+		if err = dispatcher.Dispatch(delivery_id); err != nil {
+			log.Printf("could not dispatch webhook delivery %s: %s", delivery_id, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/julianlk522/fitm/db"
+	util "github.com/julianlk522/fitm/handler/util"
+	m "github.com/julianlk522/fitm/middleware"
+	"github.com/julianlk522/fitm/model"
+)
+
+func TestWebhooks(t *testing.T) {
+	var received_event string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received_event = r.Header.Get("X-FITM-Event")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	default_client := util.WebhookClient
+	util.WebhookClient = server.Client()
+	defer func() { util.WebhookClient = default_client }()
+
+	defer db.Client.Exec(`DELETE FROM Webhooks WHERE user_id = ?;`, test_user_id)
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), m.JWTClaimsKey, map[string]interface{}{
+				"user_id":    test_user_id,
+				"login_name": test_login_name,
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	r.Get("/webhooks", GetWebhooks)
+	r.Post("/webhooks", AddWebhook)
+	r.Delete("/webhooks/{webhook_id}", DeleteWebhook)
+	r.Put("/webhooks/{webhook_id}/enable", EnableWebhook)
+	r.Get("/webhooks/{webhook_id}/deliveries", GetWebhookDeliveries)
+	r.Post("/webhooks/{webhook_id}/test", SendTestWebhook)
+
+	test_requests := []struct {
+		Payload            string
+		ExpectedStatusCode int
+	}{
+		{`{"cats": "go"}`, 400},
+		{`{"url": "ftp://example.com", "cats": "go"}`, 400},
+		// no filters
+		{`{"url": "https://example.com"}`, 400},
+		{`{"url": "https://example.com", "cats": "go,go"}`, 400},
+		{`{"url": "` + server.URL + `", "cats": "rust,go"}`, 201},
+	}
+
+	var webhook model.NewWebhook
+	for _, tr := range test_requests {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(tr.Payload))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf(
+				"expected status code %d, got %d (payload %s)\n%s",
+				tr.ExpectedStatusCode,
+				w.Code,
+				tr.Payload,
+				w.Body.String(),
+			)
+		} else if w.Code == http.StatusCreated {
+			if err := json.NewDecoder(w.Body).Decode(&webhook); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if webhook.Secret == "" || webhook.Cats != "go,rust" {
+		t.Fatalf("got %+v, want secret and alphabetized cats", webhook)
+	}
+	defer db.Client.Exec(`DELETE FROM "Webhook Deliveries" WHERE webhook_id = ?;`, webhook.ID)
+
+	// secret not listed
+	req := httptest.NewRequest(http.MethodGet, "/webhooks", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if strings.Contains(w.Body.String(), webhook.Secret) {
+		t.Fatal("expected secret not to be listed")
+	}
+
+	test_requests_for_webhook := []struct {
+		Method             string
+		Path               string
+		ExpectedStatusCode int
+	}{
+		{http.MethodPost, "/webhooks/nonexistent/test", 404},
+		{http.MethodPost, "/webhooks/" + webhook.ID + "/test", 200},
+		{http.MethodPut, "/webhooks/" + webhook.ID + "/enable", 400},
+		{http.MethodGet, "/webhooks/" + webhook.ID + "/deliveries", 200},
+	}
+
+	for _, tr := range test_requests_for_webhook {
+		req := httptest.NewRequest(tr.Method, tr.Path, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf(
+				"expected status code %d, got %d (%s %s)\n%s",
+				tr.ExpectedStatusCode,
+				w.Code,
+				tr.Method,
+				tr.Path,
+				w.Body.String(),
+			)
+		} else if strings.HasSuffix(tr.Path, "/deliveries") {
+			var deliveries []model.WebhookDelivery
+			if err := json.NewDecoder(w.Body).Decode(&deliveries); err != nil {
+				t.Fatal(err)
+			} else if len(deliveries) != 1 ||
+				deliveries[0].Status != model.WEBHOOK_DELIVERY_SUCCEEDED ||
+				deliveries[0].ResponseCode != http.StatusOK {
+				t.Fatalf("got deliveries %+v, want 1 succeeded test event", deliveries)
+			}
+		}
+	}
+
+	if received_event != model.WEBHOOK_EVENT_TEST {
+		t.Fatalf("got event %q, want %q", received_event, model.WEBHOOK_EVENT_TEST)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/webhooks/"+webhook.ID, nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("got status %d, want 204", w.Code)
+	}
+}
@@ -3,30 +3,53 @@ package main
 import (
 	"log"
 	"net/http"
-	"os"
 
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
 	"github.com/go-chi/httprate"
-	"github.com/go-chi/jwtauth/v5"
 
+	"github.com/julianlk522/fitm/db"
 	h "github.com/julianlk522/fitm/handler"
 	m "github.com/julianlk522/fitm/middleware"
+	util "github.com/julianlk522/fitm/model/util"
 )
 
 var (
-	token_auth *jwtauth.JWTAuth
 	// test_api_url = "localhost:1999"
 	api_url = "api.fitm.online:1999"
 )
 
-func init() {
-	token_auth = jwtauth.New("HS256", []byte(os.Getenv("FITM_JWT_SECRET")), nil)
-}
-
 func main() {
+	if err := db.Migrate(db.Client); err != nil {
+		log.Fatal(err)
+	}
+	if err := h.BackfillCanonicalURLs(); err != nil {
+		log.Fatal(err)
+	}
+	if err := h.BackfillLinkDomains(); err != nil {
+		log.Fatal(err)
+	}
+	if err := h.StartProfilePicGC(); err != nil {
+		log.Fatal(err)
+	}
+	if err := h.StartDataExports(); err != nil {
+		log.Fatal(err)
+	}
+	if err := h.StartLinkImports(); err != nil {
+		log.Fatal(err)
+	}
+	if err := h.StartWebhookDeliveries(); err != nil {
+		log.Fatal(err)
+	}
+	if err := h.StartLinkHealthChecks(); err != nil {
+		log.Fatal(err)
+	}
+	if err := h.StartLinkArchiving(); err != nil {
+		log.Fatal(err)
+	}
+
 	r := chi.NewRouter()
 	defer func() {
 		if err := http.ListenAndServeTLS(
@@ -89,11 +112,21 @@ func main() {
 	// PUBLIC
 	r.Post("/signup", h.SignUp)
 	r.Post("/login", h.LogIn)
+	r.Get("/.well-known/jwks.json", h.GetJWKS)
 	r.Get("/pic/{file_name}", h.GetProfilePic)
+	r.Get("/exports/{export_id}/download", h.DownloadDataExport)
 	
 	r.Get("/cats", h.GetTopGlobalCats) // includes subcats
 	r.Get("/cats/*", h.GetSpellfixMatchesForSnippet)
 	r.Get("/contributors", h.GetTopContributors)
+	r.Get("/domains", h.GetTopDomains)
+
+	// Feeds
+	r.Get("/feeds/links", h.GetLinksFeed)
+	r.Get("/feeds/map/{login_name}", h.GetTmapFeed)
+
+	// Live activity (server-sent events)
+	r.Get("/events", h.StreamSiteEvents)
 
 	// CD webhook: application update and refresh
 	r.Post("/ghwh", h.HandleGitHubWebhook)
@@ -101,16 +134,26 @@ func main() {
 	// OPTIONAL AUTHENTICATION
 	// (bearer token used optionally to get IsLiked / IsCopied for links)
 	r.Group(func(r chi.Router) {
-		r.Use(m.VerifierOptional(token_auth))
-		r.Use(m.AuthenticatorOptional(token_auth))
+		r.Use(m.VerifierOptional(m.TokenAuth))
+		r.Use(m.AuthenticatorOptional(m.TokenAuth))
 		r.Use(m.JWTContext)
 
 		r.Get("/map/{login_name}", h.GetTreasureMap)
+		r.Get("/map/{login_name}/export", h.ExportTreasureMap)
 
 		r.
 			With(m.Pagination).
 			Get("/links", h.GetLinks)
+		r.
+			With(m.Pagination).
+			Get("/search", h.SearchLinks)
 
+		r.
+			With(m.Pagination).
+			Get("/domains/{domain}", h.GetDomain)
+
+		r.Get("/links/{link_id}/health", h.GetLinkHealth)
+		r.Get("/links/{link_id}/archive", h.GetLinkArchive)
 		r.Get("/summaries/{link_id}", h.GetSummaryPage)
 		r.Get("/tags/{link_id}", h.GetTagPage)
 	})
@@ -118,14 +161,49 @@ func main() {
 	// PROTECTED
 	// (bearer token required)
 	r.Group(func(r chi.Router) {
-		r.Use(jwtauth.Verifier(token_auth))
-		r.Use(jwtauth.Authenticator(token_auth))
+		r.Use(m.Verifier(m.TokenAuth))
+		r.Use(m.Authenticator(m.TokenAuth))
 		r.Use(m.JWTContext)
+		r.Use(m.RejectBanned)
 
 		// Users
+		r.Put("/login_name", h.EditLoginName)
 		r.Put("/about", h.EditAbout)
 		r.Post("/pic", h.UploadProfilePic)
 		r.Delete("/pic", h.DeleteProfilePic)
+		r.Get("/mutes", h.GetMutedUsers)
+		r.Post("/mutes/{login_name}", h.MuteUser)
+		r.Delete("/mutes/{login_name}", h.UnmuteUser)
+		r.Post("/blocks/{login_name}", h.BlockUser)
+		r.Delete("/blocks/{login_name}", h.UnblockUser)
+		r.Post("/follows/{login_name}", h.FollowUser)
+		r.Delete("/follows/{login_name}", h.UnfollowUser)
+		r.
+			With(m.Pagination).
+			Get("/feed", h.GetFollowingFeed)
+		r.Get("/subscriptions", h.GetCatSubscriptions)
+		r.Post("/subscriptions", h.SubscribeToCats)
+		r.Delete("/subscriptions/{subscription_id}", h.UnsubscribeFromCats)
+		r.Get("/subscriptions/feed", h.GetSubscriptionFeed)
+		r.
+			With(m.Pagination).
+			Get("/notifications", h.GetNotifications)
+		r.Put("/notifications/read", h.MarkAllNotificationsRead)
+		r.Put("/notifications/{notification_id}/read", h.MarkNotificationRead)
+		r.Get("/notifications/preferences", h.GetNotificationPreferences)
+		r.Put("/notifications/preferences", h.EditNotificationPreferences)
+		r.Get("/webhooks", h.GetWebhooks)
+		r.Post("/webhooks", h.AddWebhook)
+		r.Delete("/webhooks/{webhook_id}", h.DeleteWebhook)
+		r.Put("/webhooks/{webhook_id}/enable", h.EnableWebhook)
+		r.Get("/webhooks/{webhook_id}/deliveries", h.GetWebhookDeliveries)
+		r.Post("/webhooks/{webhook_id}/test", h.SendTestWebhook)
+		r.Get("/settings", h.GetSettings)
+		r.Put("/settings", h.EditSettings)
+		r.Post("/exports", h.RequestDataExport)
+		r.Get("/exports/{export_id}", h.GetDataExport)
+		r.Post("/imports", h.ImportLinks)
+		r.Get("/imports/{import_id}", h.GetLinkImport)
 
 		// Links
 		r.Post("/links", h.AddLink)
@@ -134,6 +212,7 @@ func main() {
 		r.Delete("/links/{link_id}/like", h.UnlikeLink)
 		r.Post("/links/{link_id}/copy", h.CopyLink)
 		r.Delete("/links/{link_id}/copy", h.UncopyLink)
+		r.Post("/links/{link_id}/merge", h.MergeLink)
 
 		// Tags
 		r.Post("/tags", h.AddTag)
@@ -146,4 +225,42 @@ func main() {
 		r.Post("/summaries/{summary_id}/like", h.LikeSummary)
 		r.Delete("/summaries/{summary_id}/like", h.UnlikeSummary)
 	})
-}
\ No newline at end of file
+
+	// MODERATOR / ADMIN
+	// (bearer token with moderator or admin role required)
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(m.Verifier(m.TokenAuth))
+		r.Use(m.Authenticator(m.TokenAuth))
+		r.Use(m.JWTContext)
+		r.Use(m.RejectBanned)
+		r.Use(m.RequireRole(util.ROLE_MODERATOR, util.ROLE_ADMIN))
+
+		// Links
+		r.Delete("/links/{link_id}", h.ModeratorDeleteLink)
+		r.Put("/links/{link_id}/cats", h.ModeratorEditLinkCats)
+
+		// Tags
+		r.Delete("/tags/{tag_id}", h.ModeratorDeleteTag)
+
+		// Summaries
+		r.Put("/summaries/{summary_id}", h.ModeratorEditSummary)
+		r.Delete("/summaries/{summary_id}", h.ModeratorDeleteSummary)
+
+		// Users
+		r.Post("/users/{login_name}/ban", h.BanUser)
+		r.Delete("/users/{login_name}/ban", h.UnbanUser)
+
+		// ADMIN ONLY
+		r.Group(func(r chi.Router) {
+			r.Use(m.RequireRole(util.ROLE_ADMIN))
+
+			r.Put("/users/{login_name}/role", h.EditUserRole)
+			r.
+				With(m.Pagination).
+				Get("/actions", h.GetModeratorActions)
+			r.Get("/errors", h.GetRecentErrors)
+			r.Get("/pics/orphans", h.GetOrphanedProfilePics)
+			r.Delete("/pics/orphans", h.RemoveOrphanedProfilePics)
+		})
+	})
+}
@@ -5,29 +5,31 @@ import (
 	"net/http"
 
 	"github.com/go-chi/jwtauth/v5"
+	util "github.com/julianlk522/fitm/model/util"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 )
 
 var claims_defaults = map[string]interface{}{
 	"user_id": "",
 	"login_name": "",
+	"role": "",
 	"iat": nil,
 	"exp": nil,
 }
 
-// MODIFIED JWT VERIFIER / AUTHENTICATOR
-// (requests with no token are allowed,
-// but getting link isLiked / isCopied requires a token)
-func VerifierOptional(ja *jwtauth.JWTAuth) func(http.Handler) http.Handler {
-	return VerifyOptional(ja, jwtauth.TokenFromHeader, jwtauth.TokenFromCookie)
+// JWT VERIFIERS / AUTHENTICATORS
+// (same as jwtauth's but verify against KeyRing so that
+// tokens signed with any active key are accepted)
+func Verifier(kr *KeyRing) func(http.Handler) http.Handler {
+	return Verify(kr, jwtauth.TokenFromHeader, jwtauth.TokenFromCookie)
 }
 
-func VerifyOptional(ja *jwtauth.JWTAuth, findTokenFns ...func(r *http.Request) string) func(http.Handler) http.Handler {
+func Verify(kr *KeyRing, findTokenFns ...func(r *http.Request) string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		hfn := func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
 
-			token, err := VerifyRequestOptional(ja, r, findTokenFns...)
+			token, err := VerifyRequest(kr, r, findTokenFns...)
 			ctx = jwtauth.NewContext(ctx, token, err)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
@@ -36,24 +38,79 @@ func VerifyOptional(ja *jwtauth.JWTAuth, findTokenFns ...func(r *http.Request) s
 	}
 }
 
-func VerifyRequestOptional(ja *jwtauth.JWTAuth, r *http.Request, findTokenFns ...func(r *http.Request) string) (jwt.Token, error) {
-	var tokenString string
+func VerifyRequest(kr *KeyRing, r *http.Request, findTokenFns ...func(r *http.Request) string) (jwt.Token, error) {
+	tokenString := findToken(r, findTokenFns...)
+	if tokenString == "" {
+		return nil, jwtauth.ErrNoTokenFound
+	}
 
-	for _, fn := range findTokenFns {
-		tokenString = fn(r)
-		if tokenString != "" {
-			break
+	return VerifyToken(kr, tokenString)
+}
+
+func VerifyToken(kr *KeyRing, tokenString string) (jwt.Token, error) {
+	token, err := kr.Decode(tokenString)
+	if err != nil {
+		return token, jwtauth.ErrorReason(err)
+	}
+
+	if token == nil {
+		return nil, jwtauth.ErrUnauthorized
+	}
+
+	if err := jwt.Validate(token); err != nil {
+		return token, jwtauth.ErrorReason(err)
+	}
+
+	return token, nil
+}
+
+func Authenticator(kr *KeyRing) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			token, _, err := jwtauth.FromContext(r.Context())
+
+			if err != nil || token == nil || jwt.Validate(token) != nil {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r)
 		}
+		return http.HandlerFunc(hfn)
 	}
+}
 
+// MODIFIED JWT VERIFIER / AUTHENTICATOR
+// (requests with no token are allowed,
+// but getting link isLiked / isCopied requires a token)
+func VerifierOptional(kr *KeyRing) func(http.Handler) http.Handler {
+	return VerifyOptional(kr, jwtauth.TokenFromHeader, jwtauth.TokenFromCookie)
+}
+
+func VerifyOptional(kr *KeyRing, findTokenFns ...func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		hfn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			token, err := VerifyRequestOptional(kr, r, findTokenFns...)
+			ctx = jwtauth.NewContext(ctx, token, err)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(hfn)
+	}
+}
+
+func VerifyRequestOptional(kr *KeyRing, r *http.Request, findTokenFns ...func(r *http.Request) string) (jwt.Token, error) {
+	tokenString := findToken(r, findTokenFns...)
 	if tokenString == "" {
 		return nil, nil
 	}
 
-	return jwtauth.VerifyToken(ja, tokenString)
+	return VerifyToken(kr, tokenString)
 }
 
-func AuthenticatorOptional(ja *jwtauth.JWTAuth) func(http.Handler) http.Handler {
+func AuthenticatorOptional(kr *KeyRing) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		hfn := func(w http.ResponseWriter, r *http.Request) {
 			token, _, err := jwtauth.FromContext(r.Context())
@@ -64,7 +121,7 @@ func AuthenticatorOptional(ja *jwtauth.JWTAuth) func(http.Handler) http.Handler
 				return
 
 				// Invalid token
-			} else if token != nil && jwt.Validate(token, ja.ValidateOptions()...) != nil {
+			} else if token != nil && jwt.Validate(token) != nil {
 				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 				return
 			}
@@ -76,8 +133,21 @@ func AuthenticatorOptional(ja *jwtauth.JWTAuth) func(http.Handler) http.Handler
 	}
 }
 
+func findToken(r *http.Request, findTokenFns ...func(r *http.Request) string) string {
+	var tokenString string
+
+	for _, fn := range findTokenFns {
+		tokenString = fn(r)
+		if tokenString != "" {
+			break
+		}
+	}
+
+	return tokenString
+}
+
 // Retrieve JWT claims if passed in request context or assign empty values
-// claims = {"user_id":"1234","login_name":"johndoe", "exp": 1234567890, "iat": 1234567890}
+// claims = {"user_id":"1234","login_name":"johndoe","role":"user", "exp": 1234567890, "iat": 1234567890}
 func JWTContext(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, claims, err := jwtauth.FromContext(r.Context())
@@ -85,13 +155,18 @@ func JWTContext(next http.Handler) http.Handler {
 			claims = claims_defaults
 		} else {
 			for k, v := range claims {
-				if k == "user_id" || k == "login_name" {
+				if k == "user_id" || k == "login_name" || k == "role" {
 					_, ok := v.(string)
 					if !ok {
 						claims[k] = claims_defaults[k]
 					}
 				}
 			}
+
+			// tokens issued before roles existed
+			if role, _ := claims["role"].(string); role == "" {
+				claims["role"] = util.ROLE_USER
+			}
 		}
 
 		ctx := context.WithValue(r.Context(), JWTClaimsKey, claims)
@@ -0,0 +1,353 @@
+package middleware
+
+import (
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	e "github.com/julianlk522/fitm/error"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// kid assigned to FITM_JWT_SECRET
+// (tokens issued before the keyring carry no kid, so they verify against it)
+const LEGACY_KID = "legacy"
+
+// how long a replaced signing key keeps verifying tokens issued with it
+// (matches token lifespan set in GetJWTFromLoginName)
+const DEFAULT_KEY_GRACE_PERIOD = 4 * time.Hour
+
+// custom JWK field used in FITM_JWT_KEYS_FILE to mark a key as retiring
+const RETIRES_AT_FIELD = "retires_at"
+
+var SUPPORTED_JWT_ALGS = []jwa.SignatureAlgorithm{
+	jwa.HS256,
+	jwa.ES256,
+	jwa.EdDSA,
+}
+
+var (
+	TokenAuth *KeyRing
+)
+
+func init() {
+	var err error
+	TokenAuth, err = NewKeyRingFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type JWTKey struct {
+	ID        string
+	Alg       jwa.SignatureAlgorithm
+	SignKey   jwk.Key
+	VerifyKey jwk.Key
+	// zero value means the key never retires
+	RetiresAt time.Time
+}
+
+func (k *JWTKey) IsRetired(now time.Time) bool {
+	return !k.RetiresAt.IsZero() && !now.Before(k.RetiresAt)
+}
+
+// holds every key that may verify tokens, plus the one used to sign
+// new ones
+type KeyRing struct {
+	mu          sync.RWMutex
+	keys        []*JWTKey
+	signing_kid string
+}
+
+func NewKeyRing() *KeyRing {
+	return &KeyRing{}
+}
+
+// Build keyring from env vars:
+// FITM_JWT_KEYS_FILE: JWK set (private keys w/ "kid" and "alg",
+// optionally "retires_at" in RFC 3339)
+// FITM_JWT_SIGNING_KID: kid of key used to sign new tokens
+// (defaults to first non-retiring key in file)
+// FITM_JWT_SECRET: legacy HS256 secret (signs only if no other key does)
+func NewKeyRingFromEnv() (*KeyRing, error) {
+	kr := NewKeyRing()
+
+	if keys_file := os.Getenv("FITM_JWT_KEYS_FILE"); keys_file != "" {
+		keys_json, err := os.ReadFile(keys_file)
+		if err != nil {
+			return nil, err
+		}
+		if err = kr.AddKeysFromJWKS(keys_json); err != nil {
+			return nil, err
+		}
+		log.Printf("loaded %d JWT key(s) from %s", len(kr.keys), keys_file)
+	}
+
+	if secret := os.Getenv("FITM_JWT_SECRET"); secret != "" {
+		legacy_key, err := NewJWTKey(LEGACY_KID, jwa.HS256, []byte(secret))
+		if err != nil {
+			return nil, err
+		}
+		if err = kr.AddKey(legacy_key); err != nil {
+			return nil, err
+		}
+	}
+
+	if signing_kid := os.Getenv("FITM_JWT_SIGNING_KID"); signing_kid != "" {
+		if err := kr.SetSigningKey(signing_kid, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if kr.signing_kid == "" {
+		log.Print("no JWT signing key found: tokens cannot be issued")
+	}
+
+	return kr, nil
+}
+
+// raw_key: []byte (HS256), *ecdsa.PrivateKey (ES256)
+// or ed25519.PrivateKey (EdDSA)
+func NewJWTKey(kid string, alg jwa.SignatureAlgorithm, raw_key interface{}) (*JWTKey, error) {
+	sign_key, err := jwk.FromRaw(raw_key)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewJWTKeyFromJWK(kid, alg, sign_key)
+}
+
+func NewJWTKeyFromJWK(kid string, alg jwa.SignatureAlgorithm, sign_key jwk.Key) (*JWTKey, error) {
+	if kid == "" {
+		return nil, e.ErrNoJWTKeyID
+	} else if !slices.Contains(SUPPORTED_JWT_ALGS, alg) {
+		return nil, e.ErrUnsupportedJWTAlg(alg.String())
+	}
+
+	if err := sign_key.Set(jwk.KeyIDKey, kid); err != nil {
+		return nil, err
+	}
+	if err := sign_key.Set(jwk.AlgorithmKey, alg); err != nil {
+		return nil, err
+	}
+
+	// symmetric keys verify with themselves
+	verify_key, err := jwk.PublicKeyOf(sign_key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JWTKey{
+		ID:        kid,
+		Alg:       alg,
+		SignKey:   sign_key,
+		VerifyKey: verify_key,
+	}, nil
+}
+
+func (kr *KeyRing) AddKeysFromJWKS(jwks []byte) error {
+	set, err := jwk.Parse(jwks)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < set.Len(); i++ {
+		key, _ := set.Key(i)
+
+		alg := jwa.SignatureAlgorithm(key.Algorithm().String())
+		jwt_key, err := NewJWTKeyFromJWK(key.KeyID(), alg, key)
+		if err != nil {
+			return err
+		}
+
+		if retires_at, ok := key.Get(RETIRES_AT_FIELD); ok {
+			retires_at_str, _ := retires_at.(string)
+			jwt_key.RetiresAt, err = time.Parse(time.RFC3339, retires_at_str)
+			if err != nil {
+				return e.ErrInvalidJWTKeyRetiresAt(jwt_key.ID)
+			}
+
+			// don't expose custom field in JWKS
+			jwt_key.VerifyKey.Remove(RETIRES_AT_FIELD)
+		}
+
+		if err = kr.AddKey(jwt_key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// first non-retiring key added becomes signing key
+func (kr *KeyRing) AddKey(key *JWTKey) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	if kr.find(key.ID) != nil {
+		return e.ErrDuplicateJWTKeyID(key.ID)
+	}
+
+	kr.keys = append(kr.keys, key)
+	if kr.signing_kid == "" && key.RetiresAt.IsZero() {
+		kr.signing_kid = key.ID
+	}
+
+	return nil
+}
+
+// Switch signing key to kid; previous signing key keeps verifying
+// for grace_period (0 = until removed)
+func (kr *KeyRing) SetSigningKey(kid string, grace_period time.Duration) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	key := kr.find(kid)
+	if key == nil {
+		return e.ErrNoJWTKeyWithID(kid)
+	} else if key.IsRetired(time.Now()) {
+		return e.ErrJWTKeyRetired(kid)
+	}
+
+	if prev := kr.find(kr.signing_kid); prev != nil && prev.ID != kid && grace_period > 0 {
+		prev.RetiresAt = time.Now().Add(grace_period)
+	}
+
+	key.RetiresAt = time.Time{}
+	kr.signing_kid = kid
+
+	return nil
+}
+
+// Add key and sign with it from now on
+func (kr *KeyRing) Rotate(key *JWTKey, grace_period time.Duration) error {
+	if err := kr.AddKey(key); err != nil {
+		return err
+	}
+
+	return kr.SetSigningKey(key.ID, grace_period)
+}
+
+func (kr *KeyRing) RemoveKey(kid string) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	kr.keys = slices.DeleteFunc(kr.keys, func(k *JWTKey) bool {
+		return k.ID == kid
+	})
+	if kr.signing_kid == kid {
+		kr.signing_kid = ""
+	}
+}
+
+func (kr *KeyRing) SigningKeyID() string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	return kr.signing_kid
+}
+
+// must hold lock
+func (kr *KeyRing) find(kid string) *JWTKey {
+	for _, key := range kr.keys {
+		if key.ID == kid {
+			return key
+		}
+	}
+
+	return nil
+}
+
+// Sign claims with signing key and set "kid" header
+func (kr *KeyRing) Encode(claims map[string]interface{}) (jwt.Token, string, error) {
+	kr.mu.RLock()
+	key := kr.find(kr.signing_kid)
+	kr.mu.RUnlock()
+
+	if key == nil {
+		return nil, "", e.ErrNoJWTSigningKey
+	}
+
+	t := jwt.New()
+	for k, v := range claims {
+		if err := t.Set(k, v); err != nil {
+			return nil, "", err
+		}
+	}
+
+	headers := jws.NewHeaders()
+	if err := headers.Set(jws.KeyIDKey, key.ID); err != nil {
+		return nil, "", err
+	}
+
+	signed, err := jwt.Sign(
+		t,
+		jwt.WithKey(key.Alg, key.SignKey, jws.WithProtectedHeaders(headers)),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return t, string(signed), nil
+}
+
+// Verify signature against key named by "kid" header
+// (no kid: legacy key)
+// claims are validated separately (see Authenticator)
+func (kr *KeyRing) Decode(token_string string) (jwt.Token, error) {
+	msg, err := jws.Parse([]byte(token_string))
+	if err != nil {
+		return nil, err
+	}
+
+	sigs := msg.Signatures()
+	if len(sigs) != 1 {
+		return nil, e.ErrInvalidJWT
+	}
+
+	kid := sigs[0].ProtectedHeaders().KeyID()
+	if kid == "" {
+		kid = LEGACY_KID
+	}
+
+	kr.mu.RLock()
+	key := kr.find(kid)
+	kr.mu.RUnlock()
+
+	if key == nil || key.IsRetired(time.Now()) {
+		return nil, e.ErrNoJWTKeyWithID(kid)
+	}
+
+	return jwt.Parse(
+		[]byte(token_string),
+		jwt.WithKey(key.Alg, key.VerifyKey),
+		jwt.WithValidate(false),
+	)
+}
+
+// Public halves of asymmetric keys that still verify
+// (HS256 secrets are never published)
+func (kr *KeyRing) PublicJWKS() (jwk.Set, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	set := jwk.NewSet()
+	now := time.Now()
+
+	for _, key := range kr.keys {
+		if key.Alg == jwa.HS256 || key.IsRetired(now) {
+			continue
+		}
+		if err := set.AddKey(key.VerifyKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return set, nil
+}
@@ -0,0 +1,179 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+var test_claims = map[string]interface{}{
+	"user_id":    "3",
+	"login_name": "jlk",
+}
+
+func newTestKeys(t *testing.T) []*JWTKey {
+	_, ed_key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec_key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []*JWTKey
+	for _, k := range []struct {
+		ID  string
+		Alg jwa.SignatureAlgorithm
+		Raw interface{}
+	}{
+		{"hs", jwa.HS256, []byte("test_secret")},
+		{"ed", jwa.EdDSA, ed_key},
+		{"es", jwa.ES256, ec_key},
+	} {
+		key, err := NewJWTKey(k.ID, k.Alg, k.Raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func TestEncodeDecode(t *testing.T) {
+	for _, key := range newTestKeys(t) {
+		kr := NewKeyRing()
+		if err := kr.AddKey(key); err != nil {
+			t.Fatal(err)
+		}
+
+		_, token_string, err := kr.Encode(test_claims)
+		if err != nil {
+			t.Fatalf("%s: %s", key.Alg, err)
+		}
+
+		token, err := VerifyToken(kr, token_string)
+		if err != nil {
+			t.Fatalf("%s: %s", key.Alg, err)
+		}
+		if login_name, _ := token.Get("login_name"); login_name != "jlk" {
+			t.Fatalf("%s: got login_name %v, want jlk", key.Alg, login_name)
+		}
+
+		// unknown kid
+		other := NewKeyRing()
+		other_key, _ := NewJWTKey("other", jwa.HS256, []byte("other_secret"))
+		other.AddKey(other_key)
+		if _, err = VerifyToken(other, token_string); err == nil {
+			t.Fatalf("%s: expected token from other keyring to fail", key.Alg)
+		}
+	}
+}
+
+func TestLegacyTokenWithoutKid(t *testing.T) {
+	secret := []byte("legacy_secret")
+	_, legacy_token, err := jwtauth.New("HS256", secret, nil).Encode(test_claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kr := NewKeyRing()
+	legacy_key, _ := NewJWTKey(LEGACY_KID, jwa.HS256, secret)
+	kr.AddKey(legacy_key)
+
+	if _, err = VerifyToken(kr, legacy_token); err != nil {
+		t.Fatalf("expected legacy token to verify: %s", err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	keys := newTestKeys(t)
+	kr := NewKeyRing()
+	kr.AddKey(keys[0])
+
+	_, old_token, _ := kr.Encode(test_claims)
+
+	// within grace period: old token still verifies
+	if err := kr.Rotate(keys[1], time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if kr.SigningKeyID() != keys[1].ID {
+		t.Fatalf("got signing kid %s, want %s", kr.SigningKeyID(), keys[1].ID)
+	}
+	if _, err := VerifyToken(kr, old_token); err != nil {
+		t.Fatalf("expected old token to verify during grace period: %s", err)
+	}
+
+	_, new_token, _ := kr.Encode(test_claims)
+	if _, err := VerifyToken(kr, new_token); err != nil {
+		t.Fatal(err)
+	}
+
+	// after grace period: old token rejected
+	keys[0].RetiresAt = time.Now().Add(-time.Second)
+	if _, err := VerifyToken(kr, old_token); err == nil {
+		t.Fatal("expected old token to fail after grace period")
+	}
+
+	// retired key cannot sign again
+	if err := kr.SetSigningKey(keys[0].ID, 0); err == nil {
+		t.Fatal("expected retired key to be rejected as signing key")
+	}
+}
+
+func TestAddKeysFromJWKS(t *testing.T) {
+	keys := newTestKeys(t)
+
+	set := jwk.NewSet()
+	for i, key := range keys {
+		if i == 0 {
+			key.SignKey.Set(RETIRES_AT_FIELD, time.Now().Add(time.Hour).Format(time.RFC3339))
+		}
+		set.AddKey(key.SignKey)
+	}
+	jwks, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kr := NewKeyRing()
+	if err = kr.AddKeysFromJWKS(jwks); err != nil {
+		t.Fatal(err)
+	}
+
+	// retiring key skipped as default signer
+	if kr.SigningKeyID() != keys[1].ID {
+		t.Fatalf("got signing kid %s, want %s", kr.SigningKeyID(), keys[1].ID)
+	}
+
+	// HS256 secret not published
+	public, err := kr.PublicJWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if public.Len() != 2 {
+		t.Fatalf("got %d public keys, want 2", public.Len())
+	}
+	for i := 0; i < public.Len(); i++ {
+		k, _ := public.Key(i)
+		if is_private, _ := jwk.IsPrivateKey(k); is_private {
+			t.Fatalf("key %s in JWKS is private", k.KeyID())
+		}
+	}
+
+	// published keys verify issued tokens
+	_, token_string, _ := kr.Encode(test_claims)
+	if _, err = jwt.Parse([]byte(token_string), jwt.WithKeySet(public)); err != nil {
+		t.Fatalf("could not verify with public JWKS: %s", err)
+	}
+}
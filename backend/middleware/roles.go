@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"database/sql"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/render"
+
+	"github.com/julianlk522/fitm/db"
+	e "github.com/julianlk522/fitm/error"
+)
+
+// Allow only requests from users whose current role is one of roles
+// (read from Users, not the JWT "role" claim, so demotions apply before
+// the token expires)
+// (must come after JWTContext)
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ := r.Context().Value(JWTClaimsKey).(map[string]interface{})
+			user_id, _ := claims["user_id"].(string)
+
+			var role sql.NullString
+			if user_id != "" {
+				err := db.Client.QueryRow(
+					"SELECT role FROM Users WHERE id = ?;",
+					user_id,
+				).Scan(&role)
+				if err != nil && err != sql.ErrNoRows {
+					render.Render(w, r, e.Err500(err))
+					return
+				}
+			}
+
+			if !slices.Contains(roles, role.String) {
+				render.Render(w, r, e.ErrUnauthorized(e.ErrInsufficientRole))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Reject requests from banned users even if their token is still valid
+// Also corrects "login_name" claim for tokens issued before a rename
+// (must come after JWTContext)
+func RejectBanned(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ := r.Context().Value(JWTClaimsKey).(map[string]interface{})
+		user_id, _ := claims["user_id"].(string)
+
+		if user_id != "" {
+			var login_name, banned_at sql.NullString
+			err := db.Client.QueryRow(
+				"SELECT login_name, banned_at FROM Users WHERE id = ?;",
+				user_id,
+			).Scan(&login_name, &banned_at)
+			if err != nil && err != sql.ErrNoRows {
+				render.Render(w, r, e.Err500(err))
+				return
+			} else if banned_at.Valid {
+				render.Render(w, r, e.ErrUnauthorized(e.ErrUserBanned))
+				return
+			}
+
+			if login_name.Valid && login_name.String != claims["login_name"] {
+				claims["login_name"] = login_name.String
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
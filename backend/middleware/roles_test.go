@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julianlk522/fitm/db"
+	"github.com/julianlk522/fitm/dbtest"
+)
+
+func TestMain(m *testing.M) {
+	err := dbtest.SetupTestDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	m.Run()
+}
+
+func TestRequireRole(t *testing.T) {
+	for _, u := range []struct {
+		ID   string
+		Role string
+	}{
+		{"role_test_user", "user"},
+		{"role_test_moderator", "moderator"},
+		{"role_test_admin", "admin"},
+	} {
+		if _, err := db.Client.Exec(
+			`INSERT OR IGNORE INTO Users (id, login_name, password, created, role) VALUES (?,?,?,?,?);`,
+			u.ID,
+			u.ID,
+			"x",
+			"2024-01-01",
+			u.Role,
+		); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var test_roles = []struct {
+		UserID string
+		// JWT claim (ignored)
+		ClaimedRole        string
+		ExpectedStatusCode int
+	}{
+		{"", "", 403},
+		{"", "admin", 403},
+		{"role_test_nobody", "admin", 403},
+		{"role_test_user", "user", 403},
+		// demoted since token issued
+		{"role_test_user", "admin", 403},
+		{"role_test_moderator", "moderator", 200},
+		{"role_test_admin", "admin", 200},
+		// promoted since token issued
+		{"role_test_admin", "user", 200},
+	}
+
+	handler := RequireRole("moderator", "admin")(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		},
+	))
+
+	for _, tr := range test_roles {
+		r := httptest.NewRequest(http.MethodGet, "/admin/actions", nil)
+		ctx := context.WithValue(r.Context(), JWTClaimsKey, map[string]interface{}{
+			"user_id": tr.UserID,
+			"role":    tr.ClaimedRole,
+		})
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r.WithContext(ctx))
+
+		if w.Code != tr.ExpectedStatusCode {
+			t.Fatalf("%+v: got status code %d, want %d", tr, w.Code, tr.ExpectedStatusCode)
+		}
+	}
+}
@@ -80,6 +80,12 @@ func (crw *CustomResponseWriter) Write(b []byte) (int, error) {
 	return crw.ResponseWriter.Write(b)
 }
 
+// lets http.ResponseController reach the underlying writer
+// (e.g., to flush event streams)
+func (crw *CustomResponseWriter) Unwrap() http.ResponseWriter {
+	return crw.ResponseWriter
+}
+
 // wraps DefaultLogFormatter to allow "teeing" err logs to file
 type SplitLogFormatter struct {
 	middleware.DefaultLogFormatter
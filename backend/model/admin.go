@@ -0,0 +1,115 @@
+package model
+
+import (
+	"net/http"
+	"strings"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/model/util"
+)
+
+// AUDIT
+type ModeratorAction struct {
+	ID          string
+	Moderator   string
+	Action      string
+	TargetType  string
+	TargetID    string
+	Details     string
+	PerformedAt string
+}
+
+type PaginatedModeratorActions struct {
+	Actions  *[]ModeratorAction
+	NextPage int
+}
+
+type ProfilePicGCReport struct {
+	Scanned     int
+	Referenced  int
+	Orphans     []string
+	OrphanBytes int64
+	Removed     int
+}
+
+type ErrLogEntry struct {
+	Timestamp  string
+	Entry      string
+	StatusText string
+}
+
+// LINKS
+type EditLinkCatsRequest struct {
+	Cats        string `json:"cats"`
+	LastUpdated string
+}
+
+func (elc *EditLinkCatsRequest) Bind(r *http.Request) error {
+	switch {
+	case elc.Cats == "":
+		return e.ErrNoCats
+	case util.HasTooLongCats(elc.Cats):
+		return e.CatCharsExceedLimit(util.CAT_CHAR_LIMIT)
+	case util.HasTooManyCats(elc.Cats):
+		return e.NumCatsExceedsLimit(util.NUM_CATS_LIMIT)
+	case util.HasDuplicateCats(elc.Cats):
+		return e.ErrDuplicateCats
+	}
+
+	elc.Cats = util.CapitalizeNSFWCatIfNotAlready(elc.Cats)
+	elc.Cats = util.TrimExcessAndTrailingSpaces(elc.Cats)
+	elc.LastUpdated = util.NEW_LONG_TIMESTAMP()
+
+	return nil
+}
+
+// SUMMARIES
+type ModeratorEditSummaryRequest struct {
+	Text        string `json:"text"`
+	LastUpdated string
+}
+
+func (mes *ModeratorEditSummaryRequest) Bind(r *http.Request) error {
+	if mes.Text == "" {
+		return e.ErrNoSummaryReplacementText
+	} else if len(mes.Text) > util.SUMMARY_CHAR_LIMIT {
+		return e.SummaryLengthExceedsLimit(util.SUMMARY_CHAR_LIMIT)
+	}
+
+	if strings.Contains(mes.Text, "\"") {
+		mes.Text = strings.ReplaceAll(mes.Text, "\"", "'")
+	}
+	mes.LastUpdated = util.NEW_LONG_TIMESTAMP()
+
+	return nil
+}
+
+// USERS
+type BanUserRequest struct {
+	Reason   string `json:"reason"`
+	BannedAt string
+}
+
+func (bu *BanUserRequest) Bind(r *http.Request) error {
+	if len(bu.Reason) > util.BAN_REASON_CHAR_LIMIT {
+		return e.BanReasonLengthExceedsLimit(util.BAN_REASON_CHAR_LIMIT)
+	}
+
+	bu.BannedAt = util.NEW_LONG_TIMESTAMP()
+
+	return nil
+}
+
+type EditRoleRequest struct {
+	Role string `json:"role"`
+}
+
+func (er *EditRoleRequest) Bind(r *http.Request) error {
+	if er.Role == "" {
+		return e.ErrNoRole
+	} else if !util.IsValidRole(er.Role) {
+		return e.ErrInvalidRole
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package model
+
+const (
+	LINK_SNAPSHOT_READY   = "ready"
+	LINK_SNAPSHOT_BLOCKED = "blocked" // by robots.txt
+	LINK_SNAPSHOT_FAILED  = "failed"
+)
+
+// capture time format; identifies snapshot in
+// /links/{link_id}/archive?timestamp=
+const LINK_SNAPSHOT_TIMESTAMP_LAYOUT = "20060102150405"
+
+// Timestamp: capture time (UTC, LINK_SNAPSHOT_TIMESTAMP_LAYOUT)
+// NumRecords: archived URLs (page and first-party assets)
+type LinkSnapshot struct {
+	ID         string `json:"-"`
+	LinkID     string
+	URL        string
+	Status     string
+	Error      string `json:",omitempty"`
+	Size       int64
+	NumRecords int
+	Timestamp  string
+	WARCKey    string `json:"-"`
+}
+
+// Offset, Length: of record's gzip member in snapshot's WARC file
+type LinkSnapshotRecord struct {
+	URL         string
+	ContentType string
+	StatusCode  int
+	Offset      int64
+	Length      int64
+}
@@ -0,0 +1,13 @@
+package model
+
+type DomainCount struct {
+	Domain    string
+	LinkCount int
+	LikeCount int64
+}
+
+type DomainPage struct {
+	Domain  string
+	TopCats *[]CatCount
+	Links   interface{} // PaginatedLinks
+}
@@ -0,0 +1,29 @@
+package model
+
+const (
+	SITE_EVENT_NEW_LINK           = "new_link"
+	SITE_EVENT_CATS_CHANGED       = "cats_changed"
+	SITE_EVENT_LIKE_COUNT_CHANGED = "like_count_changed"
+)
+
+// Cats: link's global cats (for stream filters).
+// Data: Link, LinkCatsChange or LinkLikeCountChange, by Type
+type SiteEvent struct {
+	ID      uint64
+	Type    string
+	LinkID  string
+	Cats    string
+	Data    interface{}
+	Created string
+}
+
+type LinkCatsChange struct {
+	LinkID       string
+	PreviousCats string
+	Cats         string
+}
+
+type LinkLikeCountChange struct {
+	LinkID    string
+	LikeCount int64
+}
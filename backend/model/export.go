@@ -0,0 +1,76 @@
+package model
+
+import (
+	"github.com/google/uuid"
+
+	util "github.com/julianlk522/fitm/model/util"
+)
+
+const (
+	DATA_EXPORT_PENDING = "pending"
+	DATA_EXPORT_RUNNING = "running"
+	DATA_EXPORT_READY   = "ready"
+	DATA_EXPORT_FAILED  = "failed"
+	DATA_EXPORT_EXPIRED = "expired"
+)
+
+// DownloadURL set only when ready
+type DataExport struct {
+	ID          string
+	Status      string
+	Error       string `json:",omitempty"`
+	Size        int64
+	Created     string
+	Completed   string `json:",omitempty"`
+	Expires     string `json:",omitempty"`
+	DownloadURL string `json:",omitempty"`
+}
+
+func NewDataExport() *DataExport {
+	return &DataExport{
+		ID:      uuid.New().String(),
+		Status:  DATA_EXPORT_PENDING,
+		Created: util.NEW_LONG_TIMESTAMP(),
+	}
+}
+
+// Contents (data.json, plus one CSV per slice)
+type UserDataExport struct {
+	ExportedAt   string
+	Profile      *Profile
+	Submitted    *[]TmapLinkSignedIn
+	Copied       *[]TmapLinkSignedIn
+	Tagged       *[]TmapLinkSignedIn
+	Tags         []ExportedTag
+	Summaries    []ExportedSummary
+	LinkLikes    []ExportedLinkLike
+	SummaryLikes []ExportedSummaryLike
+}
+
+type ExportedTag struct {
+	ID          string
+	LinkID      string
+	URL         string
+	Cats        string
+	LastUpdated string
+}
+
+type ExportedSummary struct {
+	ID          string
+	LinkID      string
+	URL         string
+	Text        string
+	LastUpdated string
+}
+
+type ExportedLinkLike struct {
+	LinkID string
+	URL    string
+}
+
+type ExportedSummaryLike struct {
+	SummaryID string
+	LinkID    string
+	URL       string
+	Text      string
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+)
+
+type Feed struct {
+	Title string
+	// frontend page the feed follows
+	HomeURL string
+	SelfURL string
+	// latest entry (zero if none)
+	Updated time.Time
+	Entries []FeedEntry
+}
+
+type FeedEntry struct {
+	LinkID      string
+	URL         string
+	Summary     string
+	Cats        []string
+	SubmittedBy string
+	ImgURL      string
+	// submit date, or latest tmap activity for tmap feeds
+	Updated time.Time
+}
@@ -0,0 +1,20 @@
+package model
+
+const (
+	FOLLOWING_ACTIVITY_SUBMITTED = "submitted"
+	FOLLOWING_ACTIVITY_TAGGED    = "tagged"
+	FOLLOWING_ACTIVITY_COPIED    = "copied"
+)
+
+// link with latest activity on it by a followed user
+type FollowingFeedLink struct {
+	LinkSignedIn
+	ActivityBy   string
+	Activity     string
+	ActivityDate string
+}
+
+type PaginatedFollowingFeed struct {
+	Links    *[]FollowingFeedLink
+	NextPage int
+}
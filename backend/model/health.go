@@ -0,0 +1,33 @@
+package model
+
+const (
+	LINK_HEALTH_OK         = "ok"
+	LINK_HEALTH_REDIRECTED = "redirected"
+	LINK_HEALTH_NOT_FOUND  = "not_found"
+	LINK_HEALTH_GONE       = "gone"
+	LINK_HEALTH_TIMEOUT    = "timeout"
+	LINK_HEALTH_ERROR      = "error"
+	// after repeated failed checks
+	LINK_HEALTH_DEAD = "dead"
+)
+
+func LinkHealthCheckFailed(status string) bool {
+	return status != LINK_HEALTH_OK && status != LINK_HEALTH_REDIRECTED
+}
+
+// Status: latest check result or dead ("" if never checked)
+type LinkHealth struct {
+	LinkID              string
+	Status              string
+	ConsecutiveFailures int
+	LastChecked         string
+	NextCheck           string
+	Checks              []LinkHealthCheck
+}
+
+// ResponseCode: 0 if no response
+type LinkHealthCheck struct {
+	Status       string
+	ResponseCode int
+	CheckedAt    string
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"github.com/google/uuid"
+
+	util "github.com/julianlk522/fitm/model/util"
+)
+
+const (
+	IMPORT_PENDING = "pending"
+	IMPORT_RUNNING = "running"
+	// daily link limit reached: resumes automatically
+	IMPORT_PAUSED = "paused"
+	IMPORT_DONE   = "done"
+)
+
+const (
+	IMPORT_ITEM_PENDING = "pending"
+	IMPORT_ITEM_ADDED   = "added"
+	// existing link copied (and tagged) instead
+	IMPORT_ITEM_COPIED = "copied"
+	// existing link tagged only (not starred in source)
+	IMPORT_ITEM_TAGGED  = "tagged"
+	IMPORT_ITEM_SKIPPED = "skipped"
+	IMPORT_ITEM_FAILED  = "failed"
+)
+
+// Parsed from an uploaded export file
+type ImportedBookmark struct {
+	URL   string
+	Title string
+	// comma-separated, within NUM_CATS_LIMIT / CAT_CHAR_LIMIT
+	Cats string
+	// service description, saved as user summary
+	Summary string
+	// favorited in source (formats without favorites: always)
+	Starred bool
+}
+
+type LinkImport struct {
+	ID          string
+	Format      string
+	Status      string
+	Created     string
+	LastUpdated string
+	// per status
+	Counts map[string]int
+	Items  []LinkImportItem `json:",omitempty"`
+}
+
+func NewLinkImport(format string) *LinkImport {
+	timestamp := util.NEW_LONG_TIMESTAMP()
+	return &LinkImport{
+		ID:          uuid.New().String(),
+		Format:      format,
+		Status:      IMPORT_PENDING,
+		Created:     timestamp,
+		LastUpdated: timestamp,
+		Counts:      map[string]int{},
+	}
+}
+
+type LinkImportItem struct {
+	URL       string
+	Cats      string
+	Title     string `json:",omitempty"`
+	Summary   string `json:",omitempty"`
+	Starred   bool
+	Status    string
+	LinkID    string `json:",omitempty"`
+	Error     string `json:",omitempty"`
+	Processed string `json:",omitempty"`
+}
+
+// Dry run: Items' Status is the predicted outcome
+type LinkImportPreview struct {
+	Format string
+	Counts map[string]int
+	Items  []LinkImportItem
+}
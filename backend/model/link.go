@@ -22,6 +22,7 @@ type Link struct {
 	TagCount     int
 	LikeCount    int64
 	ImgURL       string
+	HealthStatus string // see LINK_HEALTH_*; "" if never checked
 }
 
 // YouTube links
@@ -48,11 +49,23 @@ type LinkSignedIn struct {
 	IsCopied bool
 }
 
-type PaginatedLinks[T Link | LinkSignedIn] struct {
+type PaginatedLinks[T Link | LinkSignedIn | SearchLink | SearchLinkSignedIn] struct {
 	Links    *[]T
 	NextPage int
 }
 
+// Snippet: HTML-escaped excerpt of best matching field with matched
+// words in <mark>
+type SearchLink struct {
+	Link
+	Snippet string
+}
+
+type SearchLinkSignedIn struct {
+	LinkSignedIn
+	Snippet string
+}
+
 type TmapLink struct {
 	Link
 	CatsFromUser bool
@@ -82,6 +95,7 @@ type NewLinkRequest struct {
 
 	// to be assigned by handler
 	URL          string // potentially modified after test request(s)
+	CanonicalURL string // for detecting duplicates
 	SubmittedBy  string
 	Cats         string // potentially modified after sort
 	AutoSummary  string
@@ -137,3 +151,17 @@ func (dl *DeleteLinkRequest) Bind(r *http.Request) error {
 
 	return nil
 }
+
+type MergeLinkRequest struct {
+	IntoLinkID string `json:"into_link_id"`
+	MergedAt   string
+}
+
+func (ml *MergeLinkRequest) Bind(r *http.Request) error {
+	if ml.IntoLinkID == "" {
+		return e.ErrNoMergeIntoLinkID
+	}
+
+	ml.MergedAt = util.NEW_LONG_TIMESTAMP()
+	return nil
+}
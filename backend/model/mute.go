@@ -0,0 +1,7 @@
+package model
+
+type MutedUser struct {
+	LoginName string
+	IsBlock   bool
+	Created   string
+}
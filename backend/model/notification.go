@@ -0,0 +1,93 @@
+package model
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	e "github.com/julianlk522/fitm/error"
+)
+
+const (
+	NOTIFICATION_LINK_LIKED              = "link_liked"
+	NOTIFICATION_LINK_COPIED             = "link_copied"
+	NOTIFICATION_LINK_TAGGED             = "link_tagged"
+	NOTIFICATION_SUMMARY_LIKED           = "summary_liked"
+	NOTIFICATION_GLOBAL_SUMMARY_REPLACED = "global_summary_replaced"
+)
+
+var NOTIFICATION_TYPES = []string{
+	NOTIFICATION_LINK_LIKED,
+	NOTIFICATION_LINK_COPIED,
+	NOTIFICATION_LINK_TAGGED,
+	NOTIFICATION_SUMMARY_LIKED,
+	NOTIFICATION_GLOBAL_SUMMARY_REPLACED,
+}
+
+// what happened, by notification type
+var notification_actions = map[string]string{
+	NOTIFICATION_LINK_LIKED:              "liked your link",
+	NOTIFICATION_LINK_COPIED:             "copied your link",
+	NOTIFICATION_LINK_TAGGED:             "tagged your link",
+	NOTIFICATION_SUMMARY_LIKED:           "liked your summary",
+	NOTIFICATION_GLOBAL_SUMMARY_REPLACED: "replaced your summary as the global summary",
+}
+
+// SummaryID empty for link notifications.
+// ActorCount: users batched into this notification, LastActor the latest
+type Notification struct {
+	ID          string
+	Type        string
+	Message     string
+	LinkID      string
+	SummaryID   string
+	LastActor   string
+	ActorCount  int
+	IsRead      bool
+	Created     string
+	LastUpdated string
+}
+
+// e.g., "jlk liked your link" or "5 people liked your link"
+func (n *Notification) SetMessage() {
+	if n.ActorCount > 1 {
+		n.Message = fmt.Sprintf("%d people %s", n.ActorCount, notification_actions[n.Type])
+	} else {
+		n.Message = fmt.Sprintf("%s %s", n.LastActor, notification_actions[n.Type])
+	}
+}
+
+type PaginatedNotifications struct {
+	Notifications *[]Notification
+	UnreadCount   int
+	NextPage      int
+}
+
+// notification type: enabled
+type NotificationPreferences map[string]bool
+
+func NewDefaultNotificationPreferences() NotificationPreferences {
+	preferences := NotificationPreferences{}
+	for _, notification_type := range NOTIFICATION_TYPES {
+		preferences[notification_type] = true
+	}
+
+	return preferences
+}
+
+// omitted types are left unchanged
+type EditNotificationPreferencesRequest map[string]bool
+
+func (ep *EditNotificationPreferencesRequest) Bind(r *http.Request) error {
+	if len(*ep) == 0 {
+		return e.ErrNoNotificationPreferencesProvided
+	}
+
+	for notification_type := range *ep {
+		if !slices.Contains(NOTIFICATION_TYPES, notification_type) {
+			return e.InvalidNotificationType(notification_type, NOTIFICATION_TYPES)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package model
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/model/util"
+)
+
+// empty SortBy / Period mean no preference
+type UserSettings struct {
+	NSFW             bool
+	SortBy           string
+	Period           string
+	TmapSectionOrder string
+	MutedCats        string
+}
+
+func NewDefaultUserSettings() *UserSettings {
+	return &UserSettings{
+		TmapSectionOrder: strings.Join(util.TMAP_SECTIONS, ","),
+	}
+}
+
+func (s *UserSettings) MutedCatsSlice() []string {
+	if s.MutedCats == "" {
+		return []string{}
+	}
+	return strings.Split(s.MutedCats, ",")
+}
+
+// omitted fields are left unchanged
+type EditSettingsRequest struct {
+	NSFW             *bool   `json:"nsfw"`
+	SortBy           *string `json:"sort_by"`
+	Period           *string `json:"period"`
+	TmapSectionOrder *string `json:"tmap_section_order"`
+	MutedCats        *string `json:"muted_cats"`
+	LastUpdated      string
+}
+
+func (es *EditSettingsRequest) Bind(r *http.Request) error {
+	if es.NSFW == nil &&
+		es.SortBy == nil &&
+		es.Period == nil &&
+		es.TmapSectionOrder == nil &&
+		es.MutedCats == nil {
+		return e.ErrNoSettingsProvided
+	}
+
+	if es.SortBy != nil &&
+		*es.SortBy != "" &&
+		!slices.Contains(util.VALID_SORT_BY, *es.SortBy) {
+		return e.ErrInvalidSortBy
+	}
+
+	if es.Period != nil &&
+		*es.Period != "" &&
+		!slices.Contains(util.VALID_PERIODS, *es.Period) {
+		return e.ErrInvalidPeriod
+	}
+
+	if es.TmapSectionOrder != nil {
+		sections := strings.Split(*es.TmapSectionOrder, ",")
+		if len(sections) != len(util.TMAP_SECTIONS) {
+			return e.ErrInvalidTmapSectionOrder
+		}
+		for _, section := range util.TMAP_SECTIONS {
+			if !slices.Contains(sections, section) {
+				return e.ErrInvalidTmapSectionOrder
+			}
+		}
+	}
+
+	if es.MutedCats != nil && *es.MutedCats != "" {
+		muted_cats := util.TrimExcessAndTrailingSpaces(*es.MutedCats)
+		muted_cats = util.CapitalizeNSFWCatIfNotAlready(muted_cats)
+
+		switch {
+		case util.HasTooLongCats(muted_cats):
+			return e.CatCharsExceedLimit(util.CAT_CHAR_LIMIT)
+		case strings.Count(muted_cats, ",")+1 > util.MUTED_CATS_LIMIT:
+			return e.NumMutedCatsExceedsLimit(util.MUTED_CATS_LIMIT)
+		case util.HasDuplicateCats(muted_cats):
+			return e.ErrDuplicateCats
+		case slices.Contains(strings.Split(muted_cats, ","), ""):
+			return e.ErrNoCats
+		}
+
+		es.MutedCats = &muted_cats
+	}
+
+	es.LastUpdated = util.NEW_LONG_TIMESTAMP()
+
+	return nil
+}
+
+func (es *EditSettingsRequest) ApplyTo(s *UserSettings) {
+	if es.NSFW != nil {
+		s.NSFW = *es.NSFW
+	}
+	if es.SortBy != nil {
+		s.SortBy = *es.SortBy
+	}
+	if es.Period != nil {
+		s.Period = *es.Period
+	}
+	if es.TmapSectionOrder != nil {
+		s.TmapSectionOrder = *es.TmapSectionOrder
+	}
+	if es.MutedCats != nil {
+		s.MutedCats = *es.MutedCats
+	}
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/model/util"
+)
+
+type CatSubscription struct {
+	ID      string
+	Cats    string
+	Created string
+}
+
+type NewCatSubscriptionRequest struct {
+	Cats    string `json:"cats"`
+	ID      string
+	Created string
+}
+
+// (cats alphabetized by handler)
+func (s *NewCatSubscriptionRequest) Bind(r *http.Request) error {
+	switch {
+	case s.Cats == "":
+		return e.ErrNoCats
+	case util.HasTooLongCats(s.Cats):
+		return e.CatCharsExceedLimit(util.CAT_CHAR_LIMIT)
+	case util.HasTooManyCats(s.Cats):
+		return e.NumCatsExceedsLimit(util.NUM_CATS_LIMIT)
+	case util.HasDuplicateCats(s.Cats):
+		return e.ErrDuplicateCats
+	}
+
+	s.Cats = util.CapitalizeNSFWCatIfNotAlready(s.Cats)
+	s.Cats = util.TrimExcessAndTrailingSpaces(s.Cats)
+	s.ID = uuid.New().String()
+	s.Created = util.NEW_LONG_TIMESTAMP()
+
+	return nil
+}
+
+// new link matching any of user's subscriptions
+type SubscriptionFeedLink struct {
+	LinkSignedIn
+	// cats of each matched subscription
+	MatchedSubscriptions []string
+}
+
+// Cursor marks newest link; pass as ?since= to get only links after it.
+// HasMore: more links after Cursor (only with ?since=)
+type SubscriptionFeed struct {
+	Links   *[]SubscriptionFeedLink
+	Cursor  string
+	HasMore bool
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"net/http"
 	"regexp"
+	"time"
 
 	e "github.com/julianlk522/fitm/error"
 	util "github.com/julianlk522/fitm/model/util"
@@ -22,16 +23,11 @@ type SignUpRequest struct {
 }
 
 func (s *SignUpRequest) Bind(r *http.Request) error {
-	switch {
-	case s.Auth.LoginName == "":
-		return e.ErrNoLoginName
-	case len(s.Auth.LoginName) < util.LOGIN_NAME_LOWER_LIMIT:
-		return e.LoginNameExceedsLowerLimit(util.LOGIN_NAME_LOWER_LIMIT)
-	case len(s.Auth.LoginName) > util.LOGIN_NAME_UPPER_LIMIT:
-		return e.LoginNameExceedsUpperLimit(util.LOGIN_NAME_UPPER_LIMIT)
-	case util.ContainsInvalidChars(s.Auth.LoginName):
-		return e.ErrLoginNameContainsInvalidChars
+	if err := validateLoginName(s.Auth.LoginName); err != nil {
+		return err
+	}
 
+	switch {
 	case s.Auth.Password == "":
 		return e.ErrNoPassword
 	case len(s.Auth.Password) < util.PASSWORD_LOWER_LIMIT:
@@ -45,6 +41,21 @@ func (s *SignUpRequest) Bind(r *http.Request) error {
 	return nil
 }
 
+func validateLoginName(login_name string) error {
+	switch {
+	case login_name == "":
+		return e.ErrNoLoginName
+	case len(login_name) < util.LOGIN_NAME_LOWER_LIMIT:
+		return e.LoginNameExceedsLowerLimit(util.LOGIN_NAME_LOWER_LIMIT)
+	case len(login_name) > util.LOGIN_NAME_UPPER_LIMIT:
+		return e.LoginNameExceedsUpperLimit(util.LOGIN_NAME_UPPER_LIMIT)
+	case util.ContainsInvalidChars(login_name):
+		return e.ErrLoginNameContainsInvalidChars
+	}
+
+	return nil
+}
+
 type LogInRequest struct {
 	*Auth
 }
@@ -59,12 +70,35 @@ func (l *LogInRequest) Bind(r *http.Request) error {
 	return nil
 }
 
+type EditLoginNameRequest struct {
+	LoginName     string `json:"login_name"`
+	ID            string
+	ChangedAt     string
+	ReservedUntil string
+}
+
+func (el *EditLoginNameRequest) Bind(r *http.Request) error {
+	if err := validateLoginName(el.LoginName); err != nil {
+		return err
+	}
+
+	el.ID = uuid.New().String()
+	el.ChangedAt = util.NEW_LONG_TIMESTAMP()
+	el.ReservedUntil = time.Now().
+		Add(util.RELEASED_LOGIN_NAME_COOLDOWN).
+		Format("2006-01-02 15:04:05")
+
+	return nil
+}
+
 // PROFILE
 type Profile struct {
-	LoginName string
-	About     string
-	PFP       string
-	Created   string
+	LoginName      string
+	About          string
+	PFP            string
+	Created        string
+	FollowerCount  int
+	FollowingCount int
 }
 
 type EditAboutRequest struct {
@@ -88,6 +122,8 @@ type EditProfilePicRequest struct {
 
 // TREASURE MAP
 type TmapSections[T TmapLink | TmapLinkSignedIn] struct {
+	// display order of Submitted / Tagged / Copied (from viewer's settings)
+	SectionOrder []string
 	Cats      *[]CatCount
 	Submitted *[]T
 	Tagged    *[]T
@@ -107,4 +143,25 @@ type FilteredTmap[T TmapLink | TmapLinkSignedIn] struct {
 
 type TmapCatCountsOpts struct {
 	OmittedCats []string
+	// max cats counted (default TMAP_CATS_PAGE_LIMIT)
+	Limit int
+}
+
+// links grouped into folders by tmap cat counts
+type TmapExport struct {
+	LoginName string
+	Folders   []TmapExportFolder
+	// links with none of the folder cats (only top N cats get folders)
+	Unfiled []TmapExportLink
+}
+
+type TmapExportFolder struct {
+	Cat   string
+	Links []TmapExportLink
+}
+
+type TmapExportLink struct {
+	URL     string
+	Cats    string
+	Summary string
 }
@@ -1,5 +1,9 @@
 package model
 
+import (
+	"time"
+)
+
 // User
 const LOGIN_NAME_LOWER_LIMIT = 2
 const LOGIN_NAME_UPPER_LIMIT = 15
@@ -9,6 +13,169 @@ const PASSWORD_UPPER_LIMIT = 72
 
 const PROFILE_ABOUT_CHAR_LIMIT = 500
 
+// released login names can only be reclaimed by their previous owner
+// until this period passes
+const RELEASED_LOGIN_NAME_COOLDOWN = 30 * 24 * time.Hour
+
+// time between login name changes (each change reserves the old name, so
+// otherwise names could be squatted by renaming repeatedly)
+const LOGIN_NAME_CHANGE_COOLDOWN = 7 * 24 * time.Hour
+
+// Profile pic
+const PFP_MAX_UPLOAD_BYTES = 10 << 20
+
+// checked before decoding (decompression bombs)
+const PFP_MAX_DIMENSION = 8000
+const PFP_MAX_PIXELS = 40_000_000
+
+const PFP_JPEG_QUALITY = 85
+
+// square renditions (px)
+var PFP_SIZES = []int{64, 256, 512}
+
+const PFP_DEFAULT_SIZE = 256
+
+// orphaned pic GC (FITM_PFP_GC_INTERVAL overrides interval; 0 disables)
+const PFP_GC_DEFAULT_INTERVAL = 24 * time.Hour
+const PFP_GC_MIN_AGE = time.Hour
+
+// Settings
+// (empty SortBy / Period mean no preference)
+var VALID_SORT_BY = []string{"rating", "newest"}
+var VALID_PERIODS = []string{"day", "week", "month", "year"}
+
+var TMAP_SECTIONS = []string{"submitted", "tagged", "copied"}
+
+const MUTED_CATS_LIMIT = 50
+
+// Data export
+// download link lifetime (zip removed afterward)
+const DATA_EXPORT_TTL = 24 * time.Hour
+
+// worker also picks up pending exports missed at request time
+const DATA_EXPORT_SWEEP_INTERVAL = time.Minute
+
+// Link import
+const IMPORT_MAX_UPLOAD_BYTES = 10 << 20
+const IMPORT_MAX_LINKS = 5000
+
+// for bookmarks with no folders or tags
+const IMPORT_DEFAULT_CAT = "imported"
+
+// worker also resumes imports paused by the daily link limit
+const IMPORT_SWEEP_INTERVAL = time.Minute
+
+// pending items read per query while running an import
+const IMPORT_ITEM_BATCH_SIZE = 100
+
+// Feeds
+// frontend (for feed links back to FITM)
+const FITM_SITE_URL = "https://fitm.online"
+
+const FEED_CACHE_MAX_AGE = 5 * time.Minute
+
+// Cat subscriptions
+const CAT_SUBSCRIPTIONS_LIMIT = 50
+
+// Notifications
+const NOTIFICATIONS_PAGE_LIMIT = 20
+
+// Outgoing requests (metadata, link health, archives)
+const FITM_BOT_USER_AGENT = "FITM-Bot (https://fitm.online/about#retrieving-metadata)"
+
+// Webhooks
+const WEBHOOKS_LIMIT = 10
+const WEBHOOK_TIMEOUT = 10 * time.Second
+
+// retried with exponential backoff (1m, 2m, 4m, ...) up to max attempts
+const WEBHOOK_MAX_ATTEMPTS = 6
+const WEBHOOK_RETRY_BASE_DELAY = time.Minute
+
+// webhook disabled after this many deliveries fail all attempts in a row
+const WEBHOOK_DISABLE_AFTER_FAILURES = 5
+
+// worker also picks up retries due
+const WEBHOOK_SWEEP_INTERVAL = 30 * time.Second
+
+// deliveries sent at once (one at a time per endpoint host, so a slow
+// endpoint only holds up its own deliveries)
+const WEBHOOK_MAX_CONCURRENT_DELIVERIES = 8
+
+// latest deliveries returned per webhook
+const WEBHOOK_DELIVERIES_LIMIT = 50
+
+// Site events
+// recent events kept for Last-Event-ID resume
+const SITE_EVENT_LOG_SIZE = 1000
+
+// events a slow stream can fall behind before it is dropped
+// (client reconnects and resumes from log)
+const SITE_EVENT_STREAM_BUFFER = 64
+
+const SITE_EVENT_HEARTBEAT_INTERVAL = 15 * time.Second
+
+// Link health
+// each link rechecked this long after its last check, or sooner after a
+// failure (1h, 2h, 4h, ...)
+const LINK_HEALTH_CHECK_INTERVAL = 7 * 24 * time.Hour
+const LINK_HEALTH_RETRY_BASE_DELAY = time.Hour
+const LINK_HEALTH_DEAD_AFTER_FAILURES = 4
+
+const LINK_HEALTH_TIMEOUT = 15 * time.Second
+const LINK_HEALTH_SWEEP_INTERVAL = 10 * time.Minute
+
+// links checked per sweep
+const LINK_HEALTH_BATCH_SIZE = 200
+
+// hosts checked at once (each host's links checked one at a time with
+// delay between)
+const LINK_HEALTH_MAX_CONCURRENT_HOSTS = 8
+const LINK_HEALTH_HOST_DELAY = 2 * time.Second
+
+// host's remaining links in sweep postponed this long after 429 / 503
+const LINK_HEALTH_HOST_BACKOFF = time.Hour
+
+// latest checks kept per link
+const LINK_HEALTH_HISTORY_LIMIT = 20
+
+// Link archive
+// page and first-party assets (page over limit not archived, assets
+// skipped once reached)
+const ARCHIVE_MAX_SNAPSHOT_SIZE int64 = 10 << 20
+const ARCHIVE_MAX_ASSETS = 50
+const ARCHIVE_TIMEOUT = 30 * time.Second
+
+// links re-archived this long after last snapshot (dead links aren't)
+const ARCHIVE_INTERVAL = 30 * 24 * time.Hour
+
+// latest ready snapshots kept per link
+const ARCHIVE_SNAPSHOTS_PER_LINK = 5
+
+// worker also archives links due and ones missed at submission time
+const ARCHIVE_SWEEP_INTERVAL = 15 * time.Minute
+
+// links archived per sweep
+const ARCHIVE_BATCH_SIZE = 20
+
+// Roles
+const ROLE_USER = "user"
+const ROLE_MODERATOR = "moderator"
+const ROLE_ADMIN = "admin"
+
+// Moderation
+const BAN_REASON_CHAR_LIMIT = 500
+
+// Moderator actions (recorded in "Moderator Actions")
+const MOD_ACTION_DELETE_LINK = "delete_link"
+const MOD_ACTION_EDIT_LINK_CATS = "edit_link_cats"
+const MOD_ACTION_MERGE_LINK = "merge_link"
+const MOD_ACTION_DELETE_TAG = "delete_tag"
+const MOD_ACTION_DELETE_SUMMARY = "delete_summary"
+const MOD_ACTION_EDIT_SUMMARY = "edit_summary"
+const MOD_ACTION_BAN_USER = "ban_user"
+const MOD_ACTION_UNBAN_USER = "unban_user"
+const MOD_ACTION_EDIT_ROLE = "edit_role"
+
 // Link
 const URL_CHAR_LIMIT = 200
 
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Registrable domain (eTLD+1) of link URL, e.g., docs.github.com ->
+// github.com ("" if unparseable)
+func LinkDomain(link_url string) string {
+	if !strings.Contains(link_url, "://") {
+		link_url = "https://" + link_url
+	}
+
+	u, err := url.Parse(link_url)
+	if err != nil {
+		return ""
+	}
+
+	return RegistrableDomain(u.Hostname())
+}
+
+// hosts without one (IPs, localhost, public suffixes) returned as-is
+func RegistrableDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" || net.ParseIP(host) != nil {
+		return host
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+
+	return domain
+}
+
+// any host reduced to its registrable domain, e.g., www.example.com ->
+// example.com (false if not a valid hostname)
+func NormalizeDomain(domain string) (string, bool) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if !IsValidDomain(domain) {
+		return "", false
+	}
+
+	return RegistrableDomain(domain), true
+}
+
+// hostname chars only (lowercase)
+func IsValidDomain(domain string) bool {
+	return domain_regex.MatchString(domain)
+}
+
+var domain_regex = regexp.MustCompile(`^[a-z0-9-]+(\.[a-z0-9-]+)*$`)
@@ -0,0 +1,52 @@
+package model
+
+import "testing"
+
+func TestLinkDomain(t *testing.T) {
+	var test_urls = []struct {
+		URL  string
+		Want string
+	}{
+		{"https://github.com/golang/go", "github.com"},
+		{"https://www.example.com", "example.com"},
+		{"http://docs.GitHub.com:8080/x?y=z#w", "github.com"},
+		{"https://news.bbc.co.uk/sport", "bbc.co.uk"},
+		{"https://julianlk522.github.io/fitm", "julianlk522.github.io"},
+		{"example.com/path", "example.com"},
+		{"http://127.0.0.1:8000/", "127.0.0.1"},
+		{"http://localhost:3000", "localhost"},
+		{"https://", ""},
+		{"://bad url", ""},
+	}
+
+	for _, tu := range test_urls {
+		if got := LinkDomain(tu.URL); got != tu.Want {
+			t.Fatalf("%s: got %q, want %q", tu.URL, got, tu.Want)
+		}
+	}
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	var test_domains = []struct {
+		Domain string
+		Want   string
+		Valid  bool
+	}{
+		{"github.com", "github.com", true},
+		{" Docs.GitHub.com ", "github.com", true},
+		{"www.example.com", "example.com", true},
+		{"news.bbc.co.uk", "bbc.co.uk", true},
+		{"github.com/golang", "", false},
+		{"%.com", "", false},
+		{"", "", false},
+	}
+
+	for _, td := range test_domains {
+		got, ok := NormalizeDomain(td.Domain)
+		if ok != td.Valid {
+			t.Fatalf("%q: got valid %t, want %t", td.Domain, ok, td.Valid)
+		} else if got != td.Want {
+			t.Fatalf("%q: got %q, want %q", td.Domain, got, td.Want)
+		}
+	}
+}
@@ -4,4 +4,8 @@ import "regexp"
 
 func ContainsInvalidChars(login_name string) bool {
 	return regexp.MustCompile(`\W`).MatchString(login_name)
-}
\ No newline at end of file
+}
+
+func IsValidRole(role string) bool {
+	return role == ROLE_USER || role == ROLE_MODERATOR || role == ROLE_ADMIN
+}
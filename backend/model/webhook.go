@@ -0,0 +1,106 @@
+package model
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+
+	e "github.com/julianlk522/fitm/error"
+	util "github.com/julianlk522/fitm/model/util"
+)
+
+const (
+	WEBHOOK_EVENT_NEW_LINK = "new_link"
+	WEBHOOK_EVENT_TEST     = "test"
+)
+
+const (
+	WEBHOOK_DELIVERY_PENDING   = "pending"
+	WEBHOOK_DELIVERY_SUCCEEDED = "succeeded"
+	WEBHOOK_DELIVERY_FAILED    = "failed"
+)
+
+// IsEnabled false after too many failed deliveries in a row
+type Webhook struct {
+	ID                  string
+	URL                 string
+	Cats                string
+	FollowedUsers       bool
+	IsEnabled           bool
+	ConsecutiveFailures int
+	Created             string
+}
+
+// Secret only returned on creation; used to verify X-FITM-Signature-256
+type NewWebhook struct {
+	Webhook
+	Secret string
+}
+
+type NewWebhookRequest struct {
+	URL           string `json:"url"`
+	Cats          string `json:"cats"`
+	FollowedUsers bool   `json:"followed_users"`
+	ID            string
+	Created       string
+}
+
+// (cats alphabetized by handler)
+func (wr *NewWebhookRequest) Bind(r *http.Request) error {
+	if wr.URL == "" {
+		return e.ErrNoURL
+	} else if len(wr.URL) > util.URL_CHAR_LIMIT {
+		return e.ErrLinkURLCharsExceedLimit(util.URL_CHAR_LIMIT)
+	}
+
+	parsed, err := url.Parse(wr.URL)
+	if err != nil ||
+		(parsed.Scheme != "http" && parsed.Scheme != "https") ||
+		parsed.Hostname() == "" {
+		return e.ErrInvalidURL
+	}
+
+	if wr.Cats != "" {
+		switch {
+		case util.HasTooLongCats(wr.Cats):
+			return e.CatCharsExceedLimit(util.CAT_CHAR_LIMIT)
+		case util.HasTooManyCats(wr.Cats):
+			return e.NumCatsExceedsLimit(util.NUM_CATS_LIMIT)
+		case util.HasDuplicateCats(wr.Cats):
+			return e.ErrDuplicateCats
+		}
+
+		wr.Cats = util.CapitalizeNSFWCatIfNotAlready(wr.Cats)
+		wr.Cats = util.TrimExcessAndTrailingSpaces(wr.Cats)
+	} else if !wr.FollowedUsers {
+		return e.ErrNoWebhookFilters
+	}
+
+	wr.ID = uuid.New().String()
+	wr.Created = util.NEW_LONG_TIMESTAMP()
+
+	return nil
+}
+
+// ResponseCode 0 if no response (e.g., timeout)
+// NextAttempt empty unless pending
+type WebhookDelivery struct {
+	ID            string
+	Event         string
+	Status        string
+	Attempts      int
+	ResponseCode  int
+	Error         string
+	Created       string
+	LastAttempted string
+	NextAttempt   string
+}
+
+// request body; Link nil for test events
+type WebhookPayload struct {
+	Event     string
+	WebhookID string
+	Created   string
+	Link      *Link
+}
@@ -0,0 +1,65 @@
+package query
+
+import (
+	"strings"
+)
+
+const MODERATOR_ACTIONS_PAGE_LIMIT = 20
+
+type ModeratorActions struct {
+	*Query
+}
+
+func NewModeratorActions() *ModeratorActions {
+	return &ModeratorActions{
+		Query: &Query{
+			Text: MODERATOR_ACTIONS_BASE,
+			Args: []interface{}{MODERATOR_ACTIONS_PAGE_LIMIT},
+		},
+	}
+}
+
+const MODERATOR_ACTIONS_BASE = `SELECT
+	ma.id,
+	COALESCE(u.login_name, ma.moderator_id) AS moderator,
+	ma.action,
+	ma.target_type,
+	ma.target_id,
+	COALESCE(ma.details, '') AS details,
+	ma.performed_at
+FROM "Moderator Actions" ma
+LEFT JOIN Users u ON u.id = ma.moderator_id
+ORDER BY ma.performed_at DESC, ma.id DESC
+LIMIT ?;`
+
+func (ma *ModeratorActions) FromModerator(login_name string) *ModeratorActions {
+	ma.Text = strings.Replace(
+		ma.Text,
+		"ORDER BY",
+		"WHERE u.login_name = ?\nORDER BY",
+		1,
+	)
+
+	// insert before limit arg
+	ma.Args = append([]interface{}{login_name}, ma.Args...)
+
+	return ma
+}
+
+func (ma *ModeratorActions) Page(page int) *ModeratorActions {
+	if page < 1 {
+		return ma
+	}
+
+	// pop limit arg and replace with limit + 1
+	ma.Args = append(ma.Args[:len(ma.Args)-1], MODERATOR_ACTIONS_PAGE_LIMIT+1)
+
+	if page == 1 {
+		return ma
+	}
+
+	ma.Text = strings.Replace(ma.Text, "LIMIT ?", "LIMIT ? OFFSET ?", 1)
+	ma.Args = append(ma.Args, (page-1)*MODERATOR_ACTIONS_PAGE_LIMIT)
+
+	return ma
+}
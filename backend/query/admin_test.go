@@ -0,0 +1,33 @@
+package query
+
+import (
+	"testing"
+)
+
+func TestNewModeratorActions(t *testing.T) {
+	for _, actions_sql := range []*ModeratorActions{
+		NewModeratorActions(),
+		NewModeratorActions().Page(1),
+		NewModeratorActions().Page(2),
+		NewModeratorActions().FromModerator("jlk").Page(3),
+	} {
+		if actions_sql.Error != nil {
+			t.Fatal(actions_sql.Error)
+		}
+
+		rows, err := TestClient.Query(actions_sql.Text, actions_sql.Args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cols, err := rows.Columns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+
+		if len(cols) != 7 {
+			t.Fatalf("got %d columns, want 7", len(cols))
+		}
+	}
+}
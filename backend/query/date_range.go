@@ -0,0 +1,278 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	e "github.com/julianlk522/fitm/error"
+)
+
+// Date range bounds (from inclusive, to exclusive after expanding):
+//   - ISO dates cover their whole span, e.g., to=2023-03 includes all of
+//     March 2023 (YYYY, YYYY-MM, YYYY-MM-DD)
+//   - ISO datetimes (offset converted to server local time, else local
+//     time assumed, since submit_date is stored in local time)
+//   - now, today, yesterday, N days/weeks/months/years ago
+//   - from only: last N days/weeks/months/years (or last day/week/...)
+//
+// e.g., from=2023-03&to=2023-03, from=last 90 days
+
+const MAX_RELATIVE_DATE_AMOUNT = 10000
+
+// swapped in tests
+var date_range_now = time.Now
+
+var date_range_layouts = []struct {
+	Layout string
+	// span covered by a value in layout
+	Span func(time.Time) time.Time
+}{
+	{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+	{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	{"2006-01-02T15:04", func(t time.Time) time.Time { return t.Add(time.Minute) }},
+	{"2006-01-02 15:04", func(t time.Time) time.Time { return t.Add(time.Minute) }},
+	{"2006-01-02T15:04:05", func(t time.Time) time.Time { return t.Add(time.Second) }},
+	{"2006-01-02 15:04:05", func(t time.Time) time.Time { return t.Add(time.Second) }},
+	{time.RFC3339, func(t time.Time) time.Time { return t.Add(time.Second) }},
+}
+
+// clause comparing submit_date to from and/or to
+// (values formatted from parsed times so safe to inline)
+func GetDateRangeClause(from string, to string) (clause string, err error) {
+	if from == "" && to == "" {
+		return "", e.ErrNoDateRange
+	}
+
+	// (bounds formatted in local time to match submit_date)
+	now := date_range_now().In(time.Local)
+
+	var from_time, to_time time.Time
+	var conditions []string
+	if from != "" {
+		from_time, err = parseDateRangeBound(from, false, now)
+		if err != nil {
+			return "", e.ErrInvalidDate("from", from)
+		}
+		conditions = append(
+			conditions,
+			fmt.Sprintf("submit_date >= '%s'", from_time.Format("2006-01-02 15:04:05")),
+		)
+	}
+	if to != "" {
+		to_time, err = parseDateRangeBound(to, true, now)
+		if err != nil {
+			return "", e.ErrInvalidDate("to", to)
+		}
+		conditions = append(
+			conditions,
+			fmt.Sprintf("submit_date < '%s'", to_time.Format("2006-01-02 15:04:05")),
+		)
+	}
+
+	if from != "" && to != "" && !from_time.Before(to_time) {
+		return "", e.ErrInvalidDateRange
+	}
+
+	return strings.Join(conditions, " AND "), nil
+}
+
+// is_end: returned time is exclusive end of bound's span
+func parseDateRangeBound(bound string, is_end bool, now time.Time) (time.Time, error) {
+	bound = strings.TrimSpace(bound)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	words := strings.Fields(strings.ToLower(bound))
+	switch strings.Join(words, " ") {
+	case "now":
+		return now, nil
+	case "today":
+		if is_end {
+			return today.AddDate(0, 0, 1), nil
+		}
+		return today, nil
+	case "yesterday":
+		if is_end {
+			return today, nil
+		}
+		return today.AddDate(0, 0, -1), nil
+	}
+
+	// relative
+	switch {
+	// last N units / last unit
+	case len(words) >= 2 && len(words) <= 3 && words[0] == "last":
+		if is_end {
+			return time.Time{}, fmt.Errorf("last ... only valid for from")
+		}
+		amount := "1"
+		if len(words) == 3 {
+			amount = words[1]
+		}
+		return subtractDateUnits(now, amount, words[len(words)-1])
+	// N units ago
+	case len(words) == 3 && words[2] == "ago":
+		return subtractDateUnits(now, words[0], words[1])
+	}
+
+	// ISO
+	for _, l := range date_range_layouts {
+		t, err := time.ParseInLocation(l.Layout, bound, time.Local)
+		if err != nil {
+			continue
+		}
+
+		t = t.In(time.Local)
+		if is_end {
+			return l.Span(t), nil
+		}
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognized date")
+}
+
+func subtractDateUnits(now time.Time, amount string, unit string) (time.Time, error) {
+	n, err := strconv.Atoi(amount)
+	if err != nil || n < 1 || n > MAX_RELATIVE_DATE_AMOUNT {
+		return time.Time{}, fmt.Errorf("invalid amount")
+	}
+
+	switch strings.TrimSuffix(unit, "s") {
+	case "day":
+		return now.AddDate(0, 0, -n), nil
+	case "week":
+		return now.AddDate(0, 0, -7*n), nil
+	case "month":
+		return now.AddDate(0, -n, 0), nil
+	case "year":
+		return now.AddDate(-n, 0, 0), nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid unit")
+	}
+}
+
+func (l *TopLinks) DuringDateRange(from string, to string) *TopLinks {
+	clause, err := GetDateRangeClause(from, to)
+	if err != nil {
+		l.Error = err
+		return l
+	}
+
+	l.Text = strings.Replace(
+		l.Text,
+		LINKS_ORDER_BY,
+		"\n"+"AND "+clause+LINKS_ORDER_BY,
+		1,
+	)
+
+	return l
+}
+
+func (t *GlobalCatCounts) DuringDateRange(from string, to string) *GlobalCatCounts {
+	clause, err := GetDateRangeClause(from, to)
+	if err != nil {
+		t.Error = err
+		return t
+	}
+
+	t.Text = strings.Replace(
+		t.Text,
+		"FROM Links",
+		fmt.Sprintf(
+			`FROM Links
+			WHERE %s`,
+			clause,
+		),
+		1)
+
+	return t
+}
+
+func (c *Contributors) DuringDateRange(from string, to string) *Contributors {
+	clause, err := GetDateRangeClause(from, to)
+	if err != nil {
+		c.Error = err
+		return c
+	}
+
+	clause = strings.ReplaceAll(clause, "submit_date", "l.submit_date")
+
+	c.Text = strings.Replace(
+		c.Text,
+		"GROUP BY l.submitted_by",
+		"WHERE "+clause+"\n"+"GROUP BY l.submitted_by",
+		1)
+
+	return c
+}
+
+func (d *TopDomains) DuringDateRange(from string, to string) *TopDomains {
+	clause, err := GetDateRangeClause(from, to)
+	if err != nil {
+		d.Error = err
+		return d
+	}
+
+	clause = strings.ReplaceAll(clause, "submit_date", "l.submit_date")
+
+	d.Text = strings.Replace(
+		d.Text,
+		"GROUP BY l.domain",
+		"AND "+clause+"\n"+"GROUP BY l.domain",
+		1,
+	)
+
+	return d
+}
+
+// Tmap sections (by link submit date)
+func (q *TmapSubmitted) DuringDateRange(from string, to string) *TmapSubmitted {
+	q.Query = tmapDuringDateRange(q.Query, from, to)
+	return q
+}
+
+func (q *TmapCopied) DuringDateRange(from string, to string) *TmapCopied {
+	q.Query = tmapDuringDateRange(q.Query, from, to)
+	return q
+}
+
+func (q *TmapTagged) DuringDateRange(from string, to string) *TmapTagged {
+	q.Query = tmapDuringDateRange(q.Query, from, to)
+	return q
+}
+
+func (lc *TmapNSFWLinksCount) DuringDateRange(from string, to string) *TmapNSFWLinksCount {
+	clause, err := GetDateRangeClause(from, to)
+	if err != nil {
+		lc.Error = err
+		return lc
+	}
+
+	clause = strings.ReplaceAll(clause, "submit_date", "l.submit_date")
+
+	lc.Text = strings.TrimSuffix(lc.Text, ";") + "\n" + "AND " + clause + ";"
+
+	return lc
+}
+
+func tmapDuringDateRange(q *Query, from string, to string) *Query {
+	clause, err := GetDateRangeClause(from, to)
+	if err != nil {
+		q.Error = err
+		return q
+	}
+
+	clause = strings.ReplaceAll(clause, "submit_date", "l.submit_date")
+
+	q.Text = strings.Replace(
+		q.Text,
+		TMAP_ORDER_BY,
+		"\n"+"AND "+clause+TMAP_ORDER_BY,
+		1,
+	)
+
+	return q
+}
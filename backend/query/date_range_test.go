@@ -0,0 +1,253 @@
+package query
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetDateRangeClause(t *testing.T) {
+	default_now := date_range_now
+	date_range_now = func() time.Time { return time.Date(2024, 6, 15, 12, 30, 0, 0, time.UTC) }
+	defer func() { date_range_now = default_now }()
+
+	var test_ranges = []struct {
+		From  string
+		To    string
+		Want  string
+		Valid bool
+	}{
+		// whole spans
+		{"2023-03", "2023-03", "submit_date >= '2023-03-01 00:00:00' AND submit_date < '2023-04-01 00:00:00'", true},
+		{"2023", "", "submit_date >= '2023-01-01 00:00:00'", true},
+		{"", "2023-12-31", "submit_date < '2024-01-01 00:00:00'", true},
+		// datetimes
+		{"2024-01-01T10:00:00+02:00", "2024-01-01 12:00", "submit_date >= '2024-01-01 08:00:00' AND submit_date < '2024-01-01 12:01:00'", true},
+		{"2024-01-01T08:00:00Z", "2024-01-01 08:00:00", "submit_date >= '2024-01-01 08:00:00' AND submit_date < '2024-01-01 08:00:01'", true},
+		// relative
+		{"last 90 days", "", "submit_date >= '2024-03-17 12:30:00'", true},
+		{" Last  Week ", "now", "submit_date >= '2024-06-08 12:30:00' AND submit_date < '2024-06-15 12:30:00'", true},
+		{"2 months ago", "1 month ago", "submit_date >= '2024-04-15 12:30:00' AND submit_date < '2024-05-15 12:30:00'", true},
+		{"yesterday", "yesterday", "submit_date >= '2024-06-14 00:00:00' AND submit_date < '2024-06-15 00:00:00'", true},
+		{"today", "", "submit_date >= '2024-06-15 00:00:00'", true},
+		{"1 year ago", "today", "submit_date >= '2023-06-15 12:30:00' AND submit_date < '2024-06-16 00:00:00'", true},
+		// invalid
+		{"", "", "", false},
+		{"2023-13", "", "", false},
+		{"March 2023", "", "", false},
+		{"", "last 90 days", "", false},
+		{"last 0 days", "", "", false},
+		{"last 90 fortnights", "", "", false},
+		{"99999 days ago", "", "", false},
+		{"2024-01-01'; DROP TABLE Links; --", "", "", false},
+		// from not before to
+		{"2024-02", "2024-01", "", false},
+		{"2024-01-01 00:00:00", "2023-12-31", "", false},
+	}
+
+	for _, tr := range test_ranges {
+		clause, err := GetDateRangeClause(tr.From, tr.To)
+		if !tr.Valid {
+			if err == nil {
+				t.Fatalf("from %q to %q: expected error, got %s", tr.From, tr.To, clause)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("from %q to %q: %s", tr.From, tr.To, err)
+		} else if clause != tr.Want {
+			t.Fatalf("from %q to %q:\ngot  %s\nwant %s", tr.From, tr.To, clause, tr.Want)
+		}
+	}
+}
+
+// bounds match submit_date, which is stored in server local time
+func TestGetDateRangeClauseLocalTime(t *testing.T) {
+	default_local := time.Local
+	time.Local = time.FixedZone("UTC-5", -5*60*60)
+	defer func() { time.Local = default_local }()
+
+	default_now := date_range_now
+	// 2024-06-14 21:30 local
+	date_range_now = func() time.Time { return time.Date(2024, 6, 15, 2, 30, 0, 0, time.UTC) }
+	defer func() { date_range_now = default_now }()
+
+	var test_ranges = []struct {
+		From string
+		To   string
+		Want string
+	}{
+		{"2023-03", "2023-03", "submit_date >= '2023-03-01 00:00:00' AND submit_date < '2023-04-01 00:00:00'"},
+		{"2024-01-01T10:00:00+02:00", "2024-01-01 12:00", "submit_date >= '2024-01-01 03:00:00' AND submit_date < '2024-01-01 12:01:00'"},
+		{"today", "", "submit_date >= '2024-06-14 00:00:00'"},
+		{"yesterday", "yesterday", "submit_date >= '2024-06-13 00:00:00' AND submit_date < '2024-06-14 00:00:00'"},
+		{"1 day ago", "now", "submit_date >= '2024-06-13 21:30:00' AND submit_date < '2024-06-14 21:30:00'"},
+	}
+
+	for _, tr := range test_ranges {
+		clause, err := GetDateRangeClause(tr.From, tr.To)
+		if err != nil {
+			t.Fatalf("from %q to %q: %s", tr.From, tr.To, err)
+		} else if clause != tr.Want {
+			t.Fatalf("from %q to %q:\ngot  %s\nwant %s", tr.From, tr.To, clause, tr.Want)
+		}
+	}
+}
+
+func TestDuringDateRange(t *testing.T) {
+
+	// fixture links submitted 2024-01-01 (1), 2024-02-01 (2), 2024-03-01 (3)
+	var test_ranges = []struct {
+		From           string
+		To             string
+		WantLinkIDs    []string
+		WantCats       []string
+		WantSubmitters []string
+	}{
+		{"2024-02", "2024-02", []string{"2"}, []string{"go", "programming"}, []string{"test_req_login_name"}},
+		{"", "2024-01-31", []string{"1"}, []string{"flowers", "umvc3"}, []string{"jlk"}},
+		{"2024-01-01 00:00:01", "", []string{"2"}, []string{"go", "NSFW", "programming"}, []string{"test_req_login_name"}},
+	}
+
+	for _, tr := range test_ranges {
+		links_sql := NewTopLinks().DuringDateRange(tr.From, tr.To).AsSignedInUser(test_user_id)
+		if links_sql.Error != nil {
+			t.Fatal(links_sql.Error)
+		}
+		link_ids := scanFirstColumn(t, &links_sql.Query)
+		if strings.Join(link_ids, ",") != strings.Join(tr.WantLinkIDs, ",") {
+			t.Fatalf("from %q to %q: got links %v, want %v", tr.From, tr.To, link_ids, tr.WantLinkIDs)
+		}
+
+		cats_sql := NewTopGlobalCatCounts().DuringDateRange(tr.From, tr.To)
+		if cats_sql.Error != nil {
+			t.Fatal(cats_sql.Error)
+		}
+		cats := scanFirstColumn(t, cats_sql.Query)
+		if strings.Join(cats, ",") != strings.Join(tr.WantCats, ",") {
+			t.Fatalf("from %q to %q: got cats %v, want %v", tr.From, tr.To, cats, tr.WantCats)
+		}
+
+		contributors_sql := NewContributors().FromCats([]string{"go"}).DuringDateRange(tr.From, tr.To)
+		if tr.From == "" {
+			contributors_sql = NewContributors().DuringDateRange(tr.From, tr.To)
+		}
+		if contributors_sql.Error != nil {
+			t.Fatal(contributors_sql.Error)
+		}
+		rows, err := TestClient.Query(contributors_sql.Text, contributors_sql.Args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var submitters []string
+		for rows.Next() {
+			var count int
+			var submitter string
+			if err := rows.Scan(&count, &submitter); err != nil {
+				t.Fatal(err)
+			}
+			submitters = append(submitters, submitter)
+		}
+		rows.Close()
+		if strings.Join(submitters, ",") != strings.Join(tr.WantSubmitters, ",") {
+			t.Fatalf("from %q to %q: got contributors %v, want %v", tr.From, tr.To, submitters, tr.WantSubmitters)
+		}
+	}
+
+	if links_sql := NewTopLinks().DuringDateRange("soon", ""); links_sql.Error == nil {
+		t.Fatal("expected error for invalid from date")
+	}
+}
+
+func TestTmapDuringDateRange(t *testing.T) {
+
+	// jlk submitted link 1 (2024-01-01), copied link 2 (2024-02-01)
+	var test_ranges = []struct {
+		From          string
+		To            string
+		WantSubmitted []string
+		WantCopied    []string
+	}{
+		{"2024", "", []string{"1"}, []string{"2"}},
+		{"2024-01-15", "", []string{}, []string{"2"}},
+		{"", "2024-01-15", []string{"1"}, []string{}},
+	}
+
+	for _, tr := range test_ranges {
+		submitted_sql := NewTmapSubmitted(test_login_name).AsSignedInUser(test_user_id).DuringDateRange(tr.From, tr.To)
+		copied_sql := NewTmapCopied(test_login_name).DuringDateRange(tr.From, tr.To).NSFW()
+		tagged_sql := NewTmapTagged(test_login_name).DuringDateRange(tr.From, tr.To)
+		for _, q := range []*Query{submitted_sql.Query, copied_sql.Query, tagged_sql.Query} {
+			if q.Error != nil {
+				t.Fatal(q.Error)
+			}
+		}
+
+		submitted := scanFirstColumn(t, submitted_sql.Query)
+		copied := scanFirstColumn(t, copied_sql.Query)
+		scanFirstColumn(t, tagged_sql.Query)
+
+		if strings.Join(submitted, ",") != strings.Join(tr.WantSubmitted, ",") {
+			t.Fatalf("from %q to %q: got submitted %v, want %v", tr.From, tr.To, submitted, tr.WantSubmitted)
+		} else if strings.Join(copied, ",") != strings.Join(tr.WantCopied, ",") {
+			t.Fatalf("from %q to %q: got copied %v, want %v", tr.From, tr.To, copied, tr.WantCopied)
+		}
+	}
+}
+
+func TestTmapNSFWLinksCountDuringDateRange(t *testing.T) {
+
+	// "test_req_login_name" submitted NSFW link 3 (2024-03-01)
+	var test_ranges = []struct {
+		From      string
+		To        string
+		WantCount int
+	}{
+		{"2024-03", "", 1},
+		{"", "2024-02", 0},
+		{"2024", "2024", 1},
+	}
+
+	for _, tr := range test_ranges {
+		count_sql := NewTmapNSFWLinksCount("test_req_login_name").DuringDateRange(tr.From, tr.To)
+		if count_sql.Error != nil {
+			t.Fatal(count_sql.Error)
+		}
+
+		var count int
+		if err := TestClient.QueryRow(count_sql.Text, count_sql.Args...).Scan(&count); err != nil {
+			t.Fatal(err)
+		} else if count != tr.WantCount {
+			t.Fatalf("from %q to %q: got %d NSFW links, want %d", tr.From, tr.To, count, tr.WantCount)
+		}
+	}
+}
+
+func scanFirstColumn(t *testing.T, q *Query) []string {
+	t.Helper()
+
+	rows, err := TestClient.Query(q.Text, q.Args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := []string{}
+	for rows.Next() {
+		var value string
+		dest := []interface{}{&value}
+		for i := 1; i < len(cols); i++ {
+			dest = append(dest, new(interface{}))
+		}
+		if err := rows.Scan(dest...); err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, value)
+	}
+
+	return values
+}
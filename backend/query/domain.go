@@ -0,0 +1,247 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+const DOMAINS_PAGE_LIMIT = 20
+
+// Top Domains
+type TopDomains struct {
+	*Query
+}
+
+func NewTopDomains() *TopDomains {
+	return (&TopDomains{
+		Query: &Query{
+			Text: DOMAINS_BASE,
+			Args: []interface{}{DOMAINS_PAGE_LIMIT},
+		},
+	})
+}
+
+const DOMAINS_BASE_CTES = `WITH LikeCount AS (
+	SELECT link_id, COUNT(*) AS like_count
+	FROM "Link Likes"
+	GROUP BY link_id
+)`
+
+const DOMAINS_ORDER_BY = `
+ORDER BY link_count DESC, like_count DESC, l.domain ASC`
+
+// (domain NULL until backfilled, empty if URL has none)
+const DOMAINS_BASE = DOMAINS_BASE_CTES + `
+SELECT
+	l.domain,
+	count(l.id) AS link_count,
+	COALESCE(SUM(ll.like_count), 0) AS like_count
+FROM Links l
+LEFT JOIN LikeCount ll ON l.id = ll.link_id
+WHERE l.domain != ''
+AND l.id NOT IN (
+	SELECT link_id FROM global_cats_fts WHERE global_cats MATCH 'NSFW'
+)
+GROUP BY l.domain` +
+	DOMAINS_ORDER_BY + `
+LIMIT ?;`
+
+func (d *TopDomains) FromCats(cats []string) *TopDomains {
+	if len(cats) == 0 || cats[0] == "" {
+		d.Error = fmt.Errorf("no cats provided")
+		return d
+	}
+
+	// build match arg
+	EscapeCatsReservedChars(cats)
+	match_arg := strings.Join(cats, " AND ")
+
+	// append CTE
+	d.Text = strings.Replace(
+		d.Text,
+		DOMAINS_BASE_CTES,
+		DOMAINS_BASE_CTES+DOMAINS_CATS_CTE,
+		1,
+	)
+
+	// append join
+	d.Text = strings.Replace(
+		d.Text,
+		"FROM Links l",
+		"FROM Links l"+DOMAINS_CATS_JOIN,
+		1,
+	)
+
+	// prepend arg
+	d.Args = append([]interface{}{match_arg}, d.Args...)
+
+	return d
+}
+
+const DOMAINS_CATS_CTE = `,
+CatsFilter AS (
+	SELECT link_id
+	FROM global_cats_fts
+	WHERE global_cats MATCH ?
+)`
+
+const DOMAINS_CATS_JOIN = `
+INNER JOIN CatsFilter f ON l.id = f.link_id`
+
+func (d *TopDomains) DuringPeriod(period string) *TopDomains {
+	clause, err := GetPeriodClause(period)
+	if err != nil {
+		d.Error = err
+		return d
+	}
+
+	d.Text = strings.Replace(
+		d.Text,
+		"GROUP BY l.domain",
+		"AND l."+clause+"\n"+"GROUP BY l.domain",
+		1,
+	)
+
+	return d
+}
+
+func (d *TopDomains) SortBy(order_by string) *TopDomains {
+
+	// acceptable order_by values:
+	// links (default)
+	// likes
+	var updated_order string
+	switch order_by {
+	case "links":
+		return d
+	case "likes":
+		updated_order = "like_count DESC, link_count DESC, l.domain ASC"
+	default:
+		d.Error = fmt.Errorf("invalid order_by value")
+		return d
+	}
+
+	d.Text = strings.Replace(
+		d.Text,
+		DOMAINS_ORDER_BY,
+		`
+ORDER BY `+updated_order,
+		1,
+	)
+
+	return d
+}
+
+func (d *TopDomains) NSFW() *TopDomains {
+	d.Text = strings.Replace(
+		d.Text,
+		`
+AND l.id NOT IN (
+	SELECT link_id FROM global_cats_fts WHERE global_cats MATCH 'NSFW'
+)`,
+		"",
+		1,
+	)
+
+	return d
+}
+
+// Links from domain
+// (call after .FromCats since arg is prepended)
+func (l *TopLinks) FromDomain(domain string) *TopLinks {
+
+	// prepend CTE
+	l.Text = strings.Replace(
+		l.Text,
+		LINKS_BASE_CTES,
+		LINKS_BASE_CTES+LINKS_FROM_DOMAIN_CTE,
+		1,
+	)
+
+	// append join
+	l.Text = strings.Replace(
+		l.Text,
+		LINKS_BASE_JOINS,
+		LINKS_BASE_JOINS+LINKS_FROM_DOMAIN_JOIN,
+		1,
+	)
+
+	// prepend arg
+	l.Args = append([]interface{}{domain}, l.Args...)
+
+	return l
+}
+
+const LINKS_FROM_DOMAIN_CTE = `,
+DomainLinks AS (
+	SELECT id AS link_id
+	FROM Links
+	WHERE domain = ?
+)`
+
+const LINKS_FROM_DOMAIN_JOIN = `
+INNER JOIN DomainLinks dl ON l.id = dl.link_id`
+
+// Cats of domain's links
+// (call after .SubcatsOfCats, which expects LIMIT as first arg)
+func (t *GlobalCatCounts) FromDomain(domain string) *GlobalCatCounts {
+	t.Text = strings.Replace(
+		t.Text,
+		"GROUP BY global_cats",
+		`AND id IN (
+		SELECT id FROM Links WHERE domain = ?
+	)
+GROUP BY global_cats`,
+		1,
+	)
+
+	// insert arg before LIMIT
+	limit_arg := t.Args[len(t.Args)-1]
+	t.Args = append(t.Args[:len(t.Args)-1], domain, limit_arg)
+
+	return t
+}
+
+// Tmap links from domain
+// (call after .FromCats, which rebuilds args for submitted/copied)
+func (q *TmapSubmitted) FromDomain(domain string) *TmapSubmitted {
+	q.Query = tmapFromDomain(q.Query, domain)
+	return q
+}
+
+func (q *TmapCopied) FromDomain(domain string) *TmapCopied {
+	q.Query = tmapFromDomain(q.Query, domain)
+	return q
+}
+
+func (q *TmapTagged) FromDomain(domain string) *TmapTagged {
+	q.Query = tmapFromDomain(q.Query, domain)
+	return q
+}
+
+func tmapFromDomain(q *Query, domain string) *Query {
+	q.Text = strings.Replace(
+		q.Text,
+		TMAP_ORDER_BY,
+		TMAP_FROM_DOMAIN_WHERE+TMAP_ORDER_BY,
+		1,
+	)
+
+	// append arg
+	q.Args = append(q.Args, domain)
+
+	return q
+}
+
+const TMAP_FROM_DOMAIN_WHERE = `
+AND l.domain = ?`
+
+// (call after .FromCats, which inserts args before last login_name)
+func (lc *TmapNSFWLinksCount) FromDomain(domain string) *TmapNSFWLinksCount {
+	lc.Text = strings.TrimSuffix(lc.Text, ";") + TMAP_FROM_DOMAIN_WHERE + ";"
+
+	// append arg
+	lc.Args = append(lc.Args, domain)
+
+	return lc
+}
@@ -0,0 +1,232 @@
+package query
+
+import (
+	"slices"
+	"testing"
+)
+
+// domains otherwise set by startup backfill
+func setTestLinkDomains(t *testing.T) {
+	_, err := TestClient.Exec(
+		`UPDATE Links SET domain = CASE id
+			WHEN '1' THEN 'example.com'
+			WHEN '2' THEN 'github.com'
+			WHEN '3' THEN 'example.com'
+		END
+		WHERE id IN ('1', '2', '3');`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		TestClient.Exec(`UPDATE Links SET domain = NULL WHERE id IN ('1', '2', '3');`)
+	})
+}
+
+func TestTopDomains(t *testing.T) {
+	setTestLinkDomains(t)
+
+	var test_domains = []struct {
+		Cats    []string
+		Period  string
+		SortBy  string
+		NSFW    bool
+		Want    []string
+		WantErr bool
+	}{
+		// 1 link each: link 2 has a like
+		{nil, "", "", false, []string{"github.com", "example.com"}, false},
+		{nil, "", "likes", false, []string{"github.com", "example.com"}, false},
+		{[]string{"go"}, "", "", false, []string{"github.com"}, false},
+		{nil, "day", "", false, []string{}, false},
+		{nil, "", "", true, []string{"example.com", "github.com"}, false},
+		{nil, "", "likes", true, []string{"github.com", "example.com"}, false},
+		{nil, "", "poop", false, nil, true},
+		{nil, "poop", "", false, nil, true},
+	}
+
+	for _, td := range test_domains {
+		domains_sql := NewTopDomains()
+		if td.Cats != nil {
+			domains_sql = domains_sql.FromCats(td.Cats)
+		}
+		if td.Period != "" {
+			domains_sql = domains_sql.DuringPeriod(td.Period)
+		}
+		if td.SortBy != "" {
+			domains_sql = domains_sql.SortBy(td.SortBy)
+		}
+		if td.NSFW {
+			domains_sql = domains_sql.NSFW()
+		}
+
+		if td.WantErr {
+			if domains_sql.Error == nil {
+				t.Fatalf("%+v: expected error", td)
+			}
+			continue
+		} else if domains_sql.Error != nil {
+			t.Fatal(domains_sql.Error)
+		}
+
+		rows, err := TestClient.Query(domains_sql.Text, domains_sql.Args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var domains []string
+		var link_counts []int
+		for rows.Next() {
+			var domain string
+			var link_count, like_count int
+			if err := rows.Scan(&domain, &link_count, &like_count); err != nil {
+				t.Fatal(err)
+			}
+			domains = append(domains, domain)
+			link_counts = append(link_counts, link_count)
+		}
+		rows.Close()
+
+		if len(domains) != len(td.Want) {
+			t.Fatalf("%+v: got domains %v", td, domains)
+		}
+		for i := range domains {
+			if domains[i] != td.Want[i] {
+				t.Fatalf("%+v: got domains %v", td, domains)
+			}
+		}
+
+		// NSFW link 3 also from example.com
+		if td.NSFW && td.SortBy == "" && link_counts[0] != 2 {
+			t.Fatalf("got %d example.com links with NSFW, want 2", link_counts[0])
+		}
+	}
+}
+
+func TestTopLinksFromDomain(t *testing.T) {
+	setTestLinkDomains(t)
+
+	links_sql := NewTopLinks().
+		FromCats([]string{"umvc3"}).
+		FromDomain("example.com").
+		AsSignedInUser(test_user_id).
+		NSFW().
+		Page(1)
+	if links_sql.Error != nil {
+		t.Fatal(links_sql.Error)
+	}
+
+	rows, err := TestClient.Query(links_sql.Text, links_sql.Args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		dest := []interface{}{&id}
+		for i := 0; i < 12; i++ {
+			dest = append(dest, new(interface{}))
+		}
+		if err := rows.Scan(dest...); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	if len(ids) != 1 || ids[0] != "1" {
+		t.Fatalf("got links %v, want [1]", ids)
+	}
+}
+
+func TestGlobalCatCountsFromDomain(t *testing.T) {
+	setTestLinkDomains(t)
+
+	cats_sql := NewTopGlobalCatCounts().FromDomain("github.com").DuringPeriod("year").More()
+	if cats_sql.Error != nil {
+		t.Fatal(cats_sql.Error)
+	}
+
+	// fixture links older than a year
+	rows, err := TestClient.Query(cats_sql.Text, cats_sql.Args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows.Next() {
+		t.Fatal("got cats for period with no links")
+	}
+	rows.Close()
+
+	cats_sql = NewTopGlobalCatCounts().FromDomain("github.com")
+	rows, err = TestClient.Query(cats_sql.Text, cats_sql.Args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	cats := map[string]int{}
+	for rows.Next() {
+		var cat string
+		var count int
+		if err := rows.Scan(&cat, &count); err != nil {
+			t.Fatal(err)
+		}
+		cats[cat] = count
+	}
+
+	if len(cats) != 2 || cats["go"] != 1 || cats["programming"] != 1 {
+		t.Fatalf("got cats %v", cats)
+	}
+}
+
+func TestTmapFromDomain(t *testing.T) {
+	setTestLinkDomains(t)
+
+	// jlk submitted link 1 (example.com), copied link 2 (github.com)
+	var test_domains = []struct {
+		Domain        string
+		WantSubmitted []string
+		WantCopied    []string
+	}{
+		{"example.com", []string{"1"}, []string{}},
+		{"github.com", []string{}, []string{"2"}},
+		{"nowhere.com", []string{}, []string{}},
+	}
+
+	for _, td := range test_domains {
+		submitted_sql := NewTmapSubmitted(test_login_name).
+			FromCats([]string{"umvc3"}).
+			AsSignedInUser(test_user_id).
+			FromDomain(td.Domain)
+		copied_sql := NewTmapCopied(test_login_name).FromDomain(td.Domain)
+		tagged_sql := NewTmapTagged(test_login_name).
+			FromCats([]string{"go"}).
+			FromDomain(td.Domain)
+
+		if got := scanFirstColumn(t, submitted_sql.Query); !slices.Equal(got, td.WantSubmitted) {
+			t.Fatalf("%s: got submitted %v, want %v", td.Domain, got, td.WantSubmitted)
+		}
+		if got := scanFirstColumn(t, copied_sql.Query); !slices.Equal(got, td.WantCopied) {
+			t.Fatalf("%s: got copied %v, want %v", td.Domain, got, td.WantCopied)
+		}
+		// link 2 tagged by jlk but also copied
+		if got := scanFirstColumn(t, tagged_sql.Query); len(got) != 0 {
+			t.Fatalf("%s: got tagged %v", td.Domain, got)
+		}
+	}
+
+	// "test_req_login_name" submitted NSFW link 3 (example.com)
+	for domain, want_count := range map[string]int{"example.com": 1, "github.com": 0} {
+		count_sql := NewTmapNSFWLinksCount("test_req_login_name").
+			FromCats([]string{"NSFW"}).
+			FromDomain(domain)
+
+		var count int
+		if err := TestClient.QueryRow(count_sql.Text, count_sql.Args...).Scan(&count); err != nil {
+			t.Fatal(err)
+		} else if count != want_count {
+			t.Fatalf("%s: got %d NSFW links, want %d", domain, count, want_count)
+		}
+	}
+}
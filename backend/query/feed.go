@@ -0,0 +1,76 @@
+package query
+
+import (
+	"strings"
+)
+
+// TMAP ACTIVITY
+// links a user submitted or tagged, most recent activity first
+// (copies have no timestamp so are not included)
+type TmapActivity struct {
+	*Query
+}
+
+func NewTmapActivity(login_name string) *TmapActivity {
+	return &TmapActivity{
+		&Query{
+			Text: TMAP_ACTIVITY,
+			// login_name used in UserActivity (x2), PossibleUserCats,
+			// no NSFW where
+			Args: []interface{}{
+				login_name,
+				login_name,
+				login_name,
+				login_name,
+				LINKS_PAGE_LIMIT,
+			},
+		},
+	}
+}
+
+const TMAP_ACTIVITY = `WITH UserActivity AS (
+	SELECT id AS link_id, submit_date AS activity_date
+	FROM Links
+	WHERE submitted_by = ?
+	UNION ALL
+	SELECT link_id, last_updated AS activity_date
+	FROM Tags
+	WHERE submitted_by = ?
+),
+LatestActivity AS (
+	SELECT link_id, MAX(activity_date) AS activity_date
+	FROM UserActivity
+	GROUP BY link_id
+),` + POSSIBLE_USER_CATS_CTE + `
+SELECT
+	l.id,
+	l.url,
+	l.submitted_by,
+	COALESCE(puc.user_cats, l.global_cats, '') AS cats,
+	COALESCE(l.global_summary, '') AS summary,
+	COALESCE(l.img_url, '') AS img_url,
+	la.activity_date
+FROM LatestActivity la
+INNER JOIN Links l ON l.id = la.link_id
+LEFT JOIN PossibleUserCats puc ON l.id = puc.link_id` +
+	TMAP_ACTIVITY_NO_NSFW_CATS_WHERE + `
+ORDER BY la.activity_date DESC, l.id DESC
+LIMIT ?;`
+
+// either global or user's cats
+const TMAP_ACTIVITY_NO_NSFW_CATS_WHERE = `
+WHERE l.id NOT IN (
+	SELECT link_id FROM global_cats_fts WHERE global_cats MATCH 'NSFW'
+)
+AND l.id NOT IN (
+	SELECT link_id FROM user_cats_fts WHERE submitted_by = ? AND cats MATCH 'NSFW'
+)`
+
+func (ta *TmapActivity) NSFW() *TmapActivity {
+	ta.Text = strings.Replace(ta.Text, TMAP_ACTIVITY_NO_NSFW_CATS_WHERE, "", 1)
+
+	// remove no NSFW where login_name arg
+	ta.Args = append(ta.Args[:3], ta.Args[4:]...)
+
+	return ta
+}
@@ -0,0 +1,47 @@
+package query
+
+import (
+	"testing"
+)
+
+func TestNewTmapActivity(t *testing.T) {
+	// test_req_login_name submitted links 2 and 3 (NSFW, newer)
+	var test_activity = []struct {
+		NSFW        bool
+		WantLinkIDs []string
+	}{
+		{false, []string{"2"}},
+		{true, []string{"3", "2"}},
+	}
+
+	for _, ta := range test_activity {
+		activity_sql := NewTmapActivity("test_req_login_name")
+		if ta.NSFW {
+			activity_sql = activity_sql.NSFW()
+		}
+
+		rows, err := TestClient.Query(activity_sql.Text, activity_sql.Args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var link_ids []string
+		for rows.Next() {
+			var id, url, submitted_by, cats, summary, img_url, activity_date string
+			if err := rows.Scan(&id, &url, &submitted_by, &cats, &summary, &img_url, &activity_date); err != nil {
+				t.Fatal(err)
+			}
+			link_ids = append(link_ids, id)
+		}
+		rows.Close()
+
+		if len(link_ids) != len(ta.WantLinkIDs) {
+			t.Fatalf("NSFW %t: got links %v, want %v", ta.NSFW, link_ids, ta.WantLinkIDs)
+		}
+		for i, id := range ta.WantLinkIDs {
+			if link_ids[i] != id {
+				t.Fatalf("NSFW %t: got links %v, want %v", ta.NSFW, link_ids, ta.WantLinkIDs)
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package query
+
+import (
+	"strings"
+)
+
+// FOLLOWING FEED
+// links submitted, tagged or copied by users the given user follows,
+// by latest such activity
+type FollowingFeed struct {
+	*Query
+}
+
+func NewFollowingFeed(user_id string) *FollowingFeed {
+	return &FollowingFeed{
+		&Query{
+			Text: LINKS_BASE_CTES + FOLLOWING_FEED_CTES + LINKS_AUTH_CTES +
+				LINKS_BASE_FIELDS + LINKS_AUTH_FIELDS + FOLLOWING_FEED_FIELDS +
+				FOLLOWING_FEED_FROM +
+				LINKS_BASE_JOINS + LINKS_AUTH_JOINS +
+				LINKS_NO_NSFW_CATS_WHERE +
+				FOLLOWING_FEED_ORDER_BY +
+				LINKS_LIMIT,
+			// user_id used in FollowedUsers, IsLiked, IsCopied, UnmutedLinks
+			Args: []interface{}{user_id, user_id, user_id, user_id, LINKS_PAGE_LIMIT},
+		},
+	}
+}
+
+// tags by link submitters are covered by submissions
+// copies made before copy timestamps were recorded use submit date
+// (bare columns in LatestActivity come from the MAX row)
+const FOLLOWING_FEED_CTES = `,
+FollowedUsers AS (
+	SELECT u.id, u.login_name
+	FROM "User Follows" uf
+	INNER JOIN Users u ON u.id = uf.followed_id
+	WHERE uf.user_id = ?
+),
+FollowedActivity AS (
+	SELECT
+		l.id AS link_id,
+		l.submitted_by AS activity_by,
+		'submitted' AS activity,
+		l.submit_date AS activity_date
+	FROM Links l
+	INNER JOIN FollowedUsers fu ON fu.login_name = l.submitted_by
+	UNION ALL
+	SELECT t.link_id, t.submitted_by, 'tagged', t.last_updated
+	FROM Tags t
+	INNER JOIN FollowedUsers fu ON fu.login_name = t.submitted_by
+	INNER JOIN Links l ON l.id = t.link_id
+	WHERE l.submitted_by != t.submitted_by
+	UNION ALL
+	SELECT lc.link_id, fu.login_name, 'copied', COALESCE(lc.created, l.submit_date)
+	FROM "Link Copies" lc
+	INNER JOIN FollowedUsers fu ON fu.id = lc.user_id
+	INNER JOIN Links l ON l.id = lc.link_id
+),
+LatestActivity AS (
+	SELECT link_id, activity_by, activity, MAX(activity_date) AS activity_date
+	FROM FollowedActivity
+	GROUP BY link_id
+)`
+
+const FOLLOWING_FEED_FIELDS = `,
+	la.activity_by,
+	la.activity,
+	la.activity_date`
+
+const FOLLOWING_FEED_FROM = `
+FROM
+	LatestActivity la
+	INNER JOIN Links l ON l.id = la.link_id`
+
+const FOLLOWING_FEED_ORDER_BY = `
+ORDER BY la.activity_date DESC, l.id DESC`
+
+func (ff *FollowingFeed) NSFW() *FollowingFeed {
+	ff.Text = strings.Replace(ff.Text, LINKS_NO_NSFW_CATS_WHERE, "", 1)
+
+	return ff
+}
+
+func (ff *FollowingFeed) Page(page int) *FollowingFeed {
+	if page < 1 {
+		return ff
+	}
+
+	// pop limit arg and replace with limit + 1
+	ff.Args = append(ff.Args[:len(ff.Args)-1], LINKS_PAGE_LIMIT+1)
+
+	if page == 1 {
+		return ff
+	}
+
+	ff.Text = strings.Replace(ff.Text, "LIMIT ?", "LIMIT ? OFFSET ?", 1)
+	ff.Args = append(ff.Args, (page-1)*LINKS_PAGE_LIMIT)
+
+	return ff
+}
@@ -0,0 +1,75 @@
+package query
+
+import (
+	"testing"
+)
+
+func TestNewFollowingFeed(t *testing.T) {
+	// test_req_user_id follows jlk, who submitted link 1 and copied link 2
+	if _, err := TestClient.Exec(
+		`INSERT INTO "User Follows" VALUES ('follow_feed_test', ?, ?, '2024-01-01 00:00:00');`,
+		test_req_user_id,
+		test_user_id,
+	); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := TestClient.Exec(
+		`INSERT INTO "Link Copies" (id, link_id, user_id, created) VALUES ('follow_feed_test', '2', ?, '2025-01-01 00:00:00');`,
+		test_user_id,
+	); err != nil {
+		t.Fatal(err)
+	}
+	defer TestClient.Exec(`DELETE FROM "User Follows" WHERE id = 'follow_feed_test';`)
+	defer TestClient.Exec(`DELETE FROM "Link Copies" WHERE id = 'follow_feed_test';`)
+
+	feed_sql := NewFollowingFeed(test_req_user_id).Page(1)
+	rows, err := TestClient.Query(feed_sql.Text, feed_sql.Args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	type activity struct {
+		LinkID, ActivityBy, Activity string
+	}
+	var got []activity
+	for rows.Next() {
+		var a activity
+		var url, sb, sd, cats, summary, img_url, health_status, activity_date string
+		var summary_count, tag_count, like_count, is_liked, is_copied int
+		if err := rows.Scan(
+			&a.LinkID,
+			&url,
+			&sb,
+			&sd,
+			&cats,
+			&summary,
+			&summary_count,
+			&tag_count,
+			&like_count,
+			&img_url,
+			&health_status,
+			&is_liked,
+			&is_copied,
+			&a.ActivityBy,
+			&a.Activity,
+			&activity_date,
+		); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, a)
+	}
+
+	want := []activity{
+		{"2", test_login_name, "copied"},
+		{"1", test_login_name, "submitted"},
+	}
+	if len(got) < len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+	}
+}
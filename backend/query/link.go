@@ -54,7 +54,8 @@ SELECT
     COALESCE(s.summary_count, 0) AS summary_count,
     COALESCE(t.tag_count, 0) AS tag_count,
     COALESCE(ll.like_count, 0) AS like_count, 
-    COALESCE(l.img_url, '') AS img_url`
+    COALESCE(l.img_url, '') AS img_url,
+    ` + LINK_HEALTH_STATUS_FIELD
 
 const LINKS_FROM = `
 FROM
@@ -128,6 +129,93 @@ func (l *TopLinks) FromCats(cats []string) *TopLinks {
 	return l
 }
 
+// excludes links with any of cats in global_cats
+// (call after .FromCats since arg is prepended)
+func (l *TopLinks) WithoutCats(cats []string) *TopLinks {
+	if len(cats) == 0 {
+		return l
+	}
+
+	// build match arg
+	escaped_cats := make([]string, len(cats))
+	copy(escaped_cats, cats)
+	EscapeCatsReservedChars(escaped_cats)
+	match_arg := strings.Join(escaped_cats, " OR ")
+
+	// prepend CTE
+	l.Text = strings.Replace(
+		l.Text,
+		LINKS_BASE_CTES,
+		LINKS_BASE_CTES+LINKS_WITHOUT_CATS_CTE,
+		1,
+	)
+
+	// append join
+	l.Text = strings.Replace(
+		l.Text,
+		LINKS_BASE_JOINS,
+		LINKS_BASE_JOINS+LINKS_WITHOUT_CATS_JOIN,
+		1,
+	)
+
+	// prepend arg
+	l.Args = append([]interface{}{match_arg}, l.Args...)
+
+	return l
+}
+
+const LINKS_WITHOUT_CATS_CTE = `,
+LinksWithoutCats AS (
+	SELECT id AS link_id
+	FROM Links
+	WHERE id NOT IN (
+		SELECT link_id FROM global_cats_fts WHERE global_cats MATCH ?
+	)
+)`
+
+const LINKS_WITHOUT_CATS_JOIN = `
+INNER JOIN LinksWithoutCats wc ON l.id = wc.link_id`
+
+// "" if never checked
+const LINK_HEALTH_STATUS_FIELD = `COALESCE(
+		(SELECT status FROM "Link Health" WHERE link_id = l.id),
+		''
+	) AS health_status`
+
+// excludes links marked dead by health checks
+func (l *TopLinks) WithoutDeadLinks() *TopLinks {
+
+	// prepend CTE
+	l.Text = strings.Replace(
+		l.Text,
+		LINKS_BASE_CTES,
+		LINKS_BASE_CTES+LINKS_NOT_DEAD_CTE,
+		1,
+	)
+
+	// append join
+	l.Text = strings.Replace(
+		l.Text,
+		LINKS_BASE_JOINS,
+		LINKS_BASE_JOINS+LINKS_NOT_DEAD_JOIN,
+		1,
+	)
+
+	return l
+}
+
+const LINKS_NOT_DEAD_CTE = `,
+NotDeadLinks AS (
+	SELECT id AS link_id
+	FROM Links
+	WHERE id NOT IN (
+		SELECT link_id FROM "Link Health" WHERE status = 'dead'
+	)
+)`
+
+const LINKS_NOT_DEAD_JOIN = `
+INNER JOIN NotDeadLinks nd ON l.id = nd.link_id`
+
 func (l *TopLinks) DuringPeriod(period string) *TopLinks {
 	clause, err := GetPeriodClause(period)
 	if err != nil {
@@ -190,7 +278,7 @@ func (l *TopLinks) AsSignedInUser(req_user_id string) *TopLinks {
 	l.Text = auth_replacer.Replace(l.Text)
 
 	// prepend args
-	l.Args = append([]interface{}{req_user_id, req_user_id}, l.Args...)
+	l.Args = append([]interface{}{req_user_id, req_user_id, req_user_id}, l.Args...)
 
 	return l
 }
@@ -207,6 +295,11 @@ IsCopied AS (
 	FROM "Link Copies"
 	WHERE user_id = ?
 	GROUP BY link_id
+),
+UnmutedLinks AS (
+	SELECT id AS link_id
+	FROM Links
+	WHERE submitted_by NOT IN (` + MUTED_LOGIN_NAMES + `)
 )`
 
 const LINKS_AUTH_FIELDS = `,
@@ -215,7 +308,8 @@ const LINKS_AUTH_FIELDS = `,
 
 const LINKS_AUTH_JOINS = `
 	LEFT JOIN IsLiked il ON l.id = il.link_id
-	LEFT JOIN IsCopied ic ON l.id = ic.link_id`
+	LEFT JOIN IsCopied ic ON l.id = ic.link_id
+	INNER JOIN UnmutedLinks ul ON l.id = ul.link_id`
 
 func (l *TopLinks) NSFW() *TopLinks {
 
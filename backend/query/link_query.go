@@ -0,0 +1,410 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	e "github.com/julianlk522/fitm/error"
+	mutil "github.com/julianlk522/fitm/model/util"
+)
+
+// Link query syntax:
+//   - bare words / "quoted phrases" match cats
+//   - space or AND: both, OR: either, - or NOT: exclude, (...) to group
+//   - fields: cat:, submitter:, domain: (reduced to registrable domain,
+//     e.g., docs.github.com -> github.com; incl. subdomains),
+//     after: (inclusive), before: (exclusive) YYYY-MM-DD,
+//     likes: (optionally prefixed by >, >=, <, <= or =)
+//
+// e.g., (go OR rust) -beginner submitter:alice domain:github.com likes:>5
+
+const (
+	LINK_QUERY_MAX_LENGTH = 256
+	LINK_QUERY_MAX_DEPTH  = 10
+)
+
+const LINKS_MATCHING_QUERY_CTE = `,
+LinksMatchingQuery AS (
+	SELECT l.id AS link_id
+	FROM Links l
+	LEFT JOIN LikeCount ll ON l.id = ll.link_id
+	WHERE `
+
+const LINKS_MATCHING_QUERY_JOIN = `
+INNER JOIN LinksMatchingQuery mq ON l.id = mq.link_id`
+
+// filters links by link query q (see syntax above)
+// (call after .FromCats since args are prepended)
+func (l *TopLinks) MatchingQuery(q string) *TopLinks {
+	clause, err := parseLinkQuery(q)
+	if err != nil {
+		l.Error = err
+		return l
+	}
+
+	// prepend CTE
+	l.Text = strings.Replace(
+		l.Text,
+		LINKS_BASE_CTES,
+		LINKS_BASE_CTES+LINKS_MATCHING_QUERY_CTE+clause.Text+"\n)",
+		1,
+	)
+
+	// append join
+	l.Text = strings.Replace(
+		l.Text,
+		LINKS_BASE_JOINS,
+		LINKS_BASE_JOINS+LINKS_MATCHING_QUERY_JOIN,
+		1,
+	)
+
+	// prepend args
+	l.Args = append(clause.Args, l.Args...)
+
+	return l
+}
+
+// SQL condition on Links l (and LikeCount ll) with its args
+type linkQueryClause struct {
+	Text string
+	Args []interface{}
+}
+
+func parseLinkQuery(q string) (*linkQueryClause, error) {
+	tokens, err := lexLinkQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &linkQueryParser{tokens: tokens}
+	clause, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	// parseOr only stops early at unmatched )
+	if tok := p.peek(); tok.Kind != LINK_QUERY_EOF {
+		return nil, &e.QueryParseError{Pos: tok.Pos, Msg: "unexpected )"}
+	}
+
+	return clause, nil
+}
+
+type linkQueryTokenKind int
+
+const (
+	LINK_QUERY_EOF linkQueryTokenKind = iota
+	LINK_QUERY_TERM
+	LINK_QUERY_PHRASE
+	LINK_QUERY_AND
+	LINK_QUERY_OR
+	LINK_QUERY_NOT
+	LINK_QUERY_LPAREN
+	LINK_QUERY_RPAREN
+)
+
+type linkQueryToken struct {
+	Kind linkQueryTokenKind
+	// field name if TERM has one
+	Field string
+	Value string
+	// 1-based
+	Pos int
+}
+
+func lexLinkQuery(q string) ([]linkQueryToken, error) {
+	chars := []rune(q)
+	if len(chars) > LINK_QUERY_MAX_LENGTH {
+		return nil, &e.QueryParseError{
+			Pos: LINK_QUERY_MAX_LENGTH + 1,
+			Msg: fmt.Sprintf("query too long (max %d chars)", LINK_QUERY_MAX_LENGTH),
+		}
+	}
+
+	var tokens []linkQueryToken
+	i := 0
+	for i < len(chars) {
+		c := chars[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, linkQueryToken{Kind: LINK_QUERY_LPAREN, Pos: pos})
+			i++
+		case c == ')':
+			tokens = append(tokens, linkQueryToken{Kind: LINK_QUERY_RPAREN, Pos: pos})
+			i++
+		// - only negates when attached to what follows
+		case c == '-' && i+1 < len(chars) && !unicode.IsSpace(chars[i+1]) && chars[i+1] != ')':
+			tokens = append(tokens, linkQueryToken{Kind: LINK_QUERY_NOT, Pos: pos})
+			i++
+		case c == '"':
+			phrase, end, err := lexLinkQueryPhrase(chars, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, linkQueryToken{Kind: LINK_QUERY_PHRASE, Value: phrase, Pos: pos})
+			i = end
+		default:
+			start := i
+			for i < len(chars) && !unicode.IsSpace(chars[i]) && !strings.ContainsRune(`()"`, chars[i]) {
+				i++
+			}
+			word := string(chars[start:i])
+
+			switch word {
+			case "AND":
+				tokens = append(tokens, linkQueryToken{Kind: LINK_QUERY_AND, Pos: pos})
+				continue
+			case "OR":
+				tokens = append(tokens, linkQueryToken{Kind: LINK_QUERY_OR, Pos: pos})
+				continue
+			case "NOT":
+				tokens = append(tokens, linkQueryToken{Kind: LINK_QUERY_NOT, Pos: pos})
+				continue
+			}
+
+			field, value, has_field := strings.Cut(word, ":")
+			if !has_field {
+				tokens = append(tokens, linkQueryToken{Kind: LINK_QUERY_TERM, Value: word, Pos: pos})
+				continue
+			}
+
+			// quoted field value, e.g., cat:"rock climbing"
+			if value == "" && i < len(chars) && chars[i] == '"' {
+				phrase, end, err := lexLinkQueryPhrase(chars, i)
+				if err != nil {
+					return nil, err
+				}
+				value = phrase
+				i = end
+			}
+			tokens = append(tokens, linkQueryToken{
+				Kind:  LINK_QUERY_TERM,
+				Field: strings.ToLower(field),
+				Value: value,
+				Pos:   pos,
+			})
+		}
+	}
+
+	return append(tokens, linkQueryToken{Kind: LINK_QUERY_EOF, Pos: len(chars) + 1}), nil
+}
+
+// returns phrase in quotes starting at chars[start] and index after
+// closing quote
+func lexLinkQueryPhrase(chars []rune, start int) (string, int, error) {
+	for i := start + 1; i < len(chars); i++ {
+		if chars[i] == '"' {
+			return string(chars[start+1 : i]), i + 1, nil
+		}
+	}
+
+	return "", 0, &e.QueryParseError{Pos: start + 1, Msg: "unterminated quote"}
+}
+
+// or:      and (OR and)*
+// and:     unary ([AND] unary)*
+// unary:   (- | NOT) unary | primary
+// primary: ( or ) | term | phrase
+type linkQueryParser struct {
+	tokens []linkQueryToken
+	i      int
+	depth  int
+}
+
+func (p *linkQueryParser) peek() linkQueryToken {
+	return p.tokens[p.i]
+}
+
+func (p *linkQueryParser) next() linkQueryToken {
+	tok := p.tokens[p.i]
+	if tok.Kind != LINK_QUERY_EOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *linkQueryParser) parseOr() (*linkQueryClause, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().Kind == LINK_QUERY_OR {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = joinLinkQueryClauses(left, "OR", right)
+	}
+
+	return left, nil
+}
+
+func (p *linkQueryParser) parseAnd() (*linkQueryClause, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.peek().Kind {
+		case LINK_QUERY_EOF, LINK_QUERY_OR, LINK_QUERY_RPAREN:
+			return left, nil
+		case LINK_QUERY_AND:
+			p.next()
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = joinLinkQueryClauses(left, "AND", right)
+	}
+}
+
+func (p *linkQueryParser) parseUnary() (*linkQueryClause, error) {
+	if p.peek().Kind != LINK_QUERY_NOT {
+		return p.parsePrimary()
+	}
+
+	tok := p.next()
+	if p.depth++; p.depth > LINK_QUERY_MAX_DEPTH {
+		return nil, &e.QueryParseError{Pos: tok.Pos, Msg: "query nested too deeply"}
+	}
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	p.depth--
+
+	return &linkQueryClause{
+		Text: "NOT (" + operand.Text + ")",
+		Args: operand.Args,
+	}, nil
+}
+
+func (p *linkQueryParser) parsePrimary() (*linkQueryClause, error) {
+	tok := p.next()
+
+	switch tok.Kind {
+	case LINK_QUERY_LPAREN:
+		if p.depth++; p.depth > LINK_QUERY_MAX_DEPTH {
+			return nil, &e.QueryParseError{Pos: tok.Pos, Msg: "query nested too deeply"}
+		}
+		clause, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.Kind != LINK_QUERY_RPAREN {
+			return nil, &e.QueryParseError{Pos: tok.Pos, Msg: "unclosed ("}
+		}
+		p.depth--
+
+		return clause, nil
+	case LINK_QUERY_PHRASE:
+		return newLinkQueryCatClause(tok)
+	case LINK_QUERY_TERM:
+		return newLinkQueryTermClause(tok)
+	case LINK_QUERY_EOF:
+		return nil, &e.QueryParseError{Pos: tok.Pos, Msg: "unexpected end of query"}
+	case LINK_QUERY_RPAREN:
+		return nil, &e.QueryParseError{Pos: tok.Pos, Msg: "unexpected )"}
+	case LINK_QUERY_AND:
+		return nil, &e.QueryParseError{Pos: tok.Pos, Msg: "unexpected AND"}
+	default:
+		return nil, &e.QueryParseError{Pos: tok.Pos, Msg: "unexpected OR"}
+	}
+}
+
+func joinLinkQueryClauses(left *linkQueryClause, op string, right *linkQueryClause) *linkQueryClause {
+	return &linkQueryClause{
+		Text: "(" + left.Text + " " + op + " " + right.Text + ")",
+		Args: append(left.Args, right.Args...),
+	}
+}
+
+func newLinkQueryTermClause(tok linkQueryToken) (*linkQueryClause, error) {
+	if tok.Field != "" && tok.Value == "" {
+		return nil, &e.QueryParseError{
+			Pos: tok.Pos,
+			Msg: fmt.Sprintf("no value for %s:", tok.Field),
+		}
+	}
+
+	switch tok.Field {
+	case "", "cat":
+		return newLinkQueryCatClause(tok)
+	case "submitter":
+		return &linkQueryClause{
+			Text: "l.submitted_by = ?",
+			Args: []interface{}{tok.Value},
+		}, nil
+	case "domain":
+		// (same rule as domain param: any host widens to registrable domain)
+		domain, ok := mutil.NormalizeDomain(tok.Value)
+		if !ok {
+			return nil, &e.QueryParseError{Pos: tok.Pos, Msg: "invalid domain"}
+		}
+		return &linkQueryClause{
+			Text: "l.domain = ?",
+			Args: []interface{}{domain},
+		}, nil
+	case "after", "before":
+		date, err := time.Parse("2006-01-02", tok.Value)
+		if err != nil {
+			return nil, &e.QueryParseError{
+				Pos: tok.Pos,
+				Msg: fmt.Sprintf("invalid %s: date (want YYYY-MM-DD)", tok.Field),
+			}
+		}
+		op := ">="
+		if tok.Field == "before" {
+			op = "<"
+		}
+		return &linkQueryClause{
+			Text: "l.submit_date " + op + " ?",
+			Args: []interface{}{date.Format("2006-01-02")},
+		}, nil
+	case "likes":
+		op, count := "=", tok.Value
+		for _, prefix := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(count, prefix) {
+				op, count = prefix, strings.TrimPrefix(count, prefix)
+				break
+			}
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 0 {
+			return nil, &e.QueryParseError{Pos: tok.Pos, Msg: "invalid likes: count"}
+		}
+		return &linkQueryClause{
+			Text: "COALESCE(ll.like_count, 0) " + op + " ?",
+			Args: []interface{}{n},
+		}, nil
+	default:
+		return nil, &e.QueryParseError{
+			Pos: tok.Pos,
+			Msg: fmt.Sprintf("unknown field %q", tok.Field),
+		}
+	}
+}
+
+// cat passed as single FTS phrase so its chars can't alter MATCH
+func newLinkQueryCatClause(tok linkQueryToken) (*linkQueryClause, error) {
+	if strings.TrimSpace(tok.Value) == "" {
+		return nil, &e.QueryParseError{Pos: tok.Pos, Msg: "empty cat"}
+	}
+
+	return &linkQueryClause{
+		Text: "l.id IN (SELECT link_id FROM global_cats_fts WHERE global_cats MATCH ?)",
+		Args: []interface{}{QuoteFTSPhrase(tok.Value)},
+	}, nil
+}
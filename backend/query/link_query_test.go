@@ -0,0 +1,149 @@
+package query
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	e "github.com/julianlk522/fitm/error"
+)
+
+func TestParseLinkQueryErrors(t *testing.T) {
+	var test_queries = []struct {
+		Query string
+		Pos   int
+	}{
+		{"", 1},
+		{"   ", 4},
+		{"(go OR rust", 1},
+		{"go)", 3},
+		{"go OR", 6},
+		{"OR go", 1},
+		{"go AND AND rust", 8},
+		{`cat:"rock climbing`, 5},
+		{`"unterminated`, 1},
+		{"go poster:alice", 4},
+		{"submitter:", 1},
+		{"domain:github.com/golang", 1},
+		{"domain:%.com", 1},
+		{"after:2024-13-01", 1},
+		{"go before:yesterday", 4},
+		{"likes:>five", 1},
+		{"likes:-1", 1},
+		{`""`, 1},
+		{strings.Repeat("(", LINK_QUERY_MAX_DEPTH+1) + "go" + strings.Repeat(")", LINK_QUERY_MAX_DEPTH+1), LINK_QUERY_MAX_DEPTH + 1},
+		{strings.Repeat("a", LINK_QUERY_MAX_LENGTH+1), LINK_QUERY_MAX_LENGTH + 1},
+	}
+
+	for _, tq := range test_queries {
+		_, err := parseLinkQuery(tq.Query)
+		var parse_err *e.QueryParseError
+		if !errors.As(err, &parse_err) {
+			t.Fatalf("%q: expected parse error, got %v", tq.Query, err)
+		} else if parse_err.Pos != tq.Pos {
+			t.Fatalf("%q: got position %d (%s), want %d", tq.Query, parse_err.Pos, parse_err.Msg, tq.Pos)
+		}
+	}
+}
+
+func TestParseLinkQuery(t *testing.T) {
+	clause, err := parseLinkQuery(`(go OR "rock climbing") -beginner submitter:alice likes:>=5 after:2024-01-01`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want_text := "(((((" +
+		"l.id IN (SELECT link_id FROM global_cats_fts WHERE global_cats MATCH ?) OR " +
+		"l.id IN (SELECT link_id FROM global_cats_fts WHERE global_cats MATCH ?)) AND " +
+		"NOT (l.id IN (SELECT link_id FROM global_cats_fts WHERE global_cats MATCH ?))) AND " +
+		"l.submitted_by = ?) AND " +
+		"COALESCE(ll.like_count, 0) >= ?) AND " +
+		"l.submit_date >= ?)"
+	if clause.Text != want_text {
+		t.Fatalf("got text %s", clause.Text)
+	}
+
+	want_args := []interface{}{`"go"`, `"rock climbing"`, `"beginner"`, "alice", 5, "2024-01-01"}
+	if len(clause.Args) != len(want_args) {
+		t.Fatalf("got args %v, want %v", clause.Args, want_args)
+	}
+	for i := range want_args {
+		if clause.Args[i] != want_args[i] {
+			t.Fatalf("got args %v, want %v", clause.Args, want_args)
+		}
+	}
+
+	// AND binds tighter than OR
+	clause, err = parseLinkQuery("a b OR NOT c")
+	if err != nil {
+		t.Fatal(err)
+	} else if !strings.HasPrefix(clause.Text, "((l.id") || !strings.Contains(clause.Text, ") OR NOT (") {
+		t.Fatalf("got text %s", clause.Text)
+	}
+}
+
+func TestMatchingQuery(t *testing.T) {
+	setTestLinkDomains(t)
+
+	var test_queries = []struct {
+		Query    string
+		SignedIn bool
+		WantIDs  []string
+	}{
+		{"go OR umvc3", false, []string{"2", "1"}},
+		{"(go OR umvc3) -flowers", false, []string{"2"}},
+		{"NOT go", false, []string{"1"}},
+		{"submitter:jlk", false, []string{"1"}},
+		{"domain:github.com", false, []string{"2"}},
+		{"domain:example.com", false, []string{"1"}},
+		{"domain:example.com", true, []string{"1"}},
+		{"domain:GitHub.com", false, []string{"2"}},
+		{"domain:www.example.com", true, []string{"1"}},
+		{"domain:docs.github.com", false, []string{"2"}},
+		{"domain:hub.com", false, []string{}},
+		{"after:2024-02-01", false, []string{"2"}},
+		{"before:2024-02-01", false, []string{"1"}},
+		{"likes:>0", true, []string{"2"}},
+		{"likes:0", false, []string{"1"}},
+		// chars reserved by FTS match literally
+		{`"go OR umvc3"`, false, []string{}},
+	}
+
+	for _, tq := range test_queries {
+		links_sql := NewTopLinks().MatchingQuery(tq.Query)
+		if tq.SignedIn {
+			links_sql = links_sql.AsSignedInUser(test_user_id)
+		}
+		if links_sql.Error != nil {
+			t.Fatal(links_sql.Error)
+		}
+
+		rows, err := TestClient.Query(links_sql.Text, links_sql.Args...)
+		if err != nil {
+			t.Fatalf("%q: %s", tq.Query, err)
+		}
+		cols, err := rows.Columns()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := []string{}
+		for rows.Next() {
+			var id string
+			dest := make([]interface{}, len(cols))
+			dest[0] = &id
+			for i := 1; i < len(cols); i++ {
+				dest[i] = new(interface{})
+			}
+			if err := rows.Scan(dest...); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+
+		if strings.Join(ids, ",") != strings.Join(tq.WantIDs, ",") {
+			t.Fatalf("%q: got links %v, want %v", tq.Query, ids, tq.WantIDs)
+		}
+	}
+}
@@ -29,7 +29,7 @@ func TestNewTopLinks(t *testing.T) {
 
 	if len(cols) == 0 {
 		t.Fatal("no columns")
-	} else if len(cols) != 10 {
+	} else if len(cols) != 11 {
 		t.Fatal("too few columns")
 	}
 
@@ -46,6 +46,7 @@ func TestNewTopLinks(t *testing.T) {
 		{"tag_count"},
 		{"like_count"},
 		{"img_url"},
+		{"health_status"},
 	}
 
 	for i, col := range cols {
@@ -109,6 +110,111 @@ func TestFromCats(t *testing.T) {
 	}
 }
 
+func TestWithoutCats(t *testing.T) {
+	var test_cats = []struct {
+		FromCats    []string
+		WithoutCats []string
+	}{
+		{[]string{}, []string{"umvc3"}},
+		{[]string{}, []string{"umvc3", "c. viper"}},
+		{[]string{"flowers"}, []string{"umvc3"}},
+	}
+
+	for _, tc := range test_cats {
+		links_sql := NewTopLinks()
+		if len(tc.FromCats) > 0 {
+			links_sql = links_sql.FromCats(tc.FromCats)
+		}
+		links_sql = links_sql.WithoutCats(tc.WithoutCats).AsSignedInUser(test_user_id)
+		if links_sql.Error != nil {
+			t.Fatal(links_sql.Error)
+		}
+
+		rows, err := TestClient.Query(links_sql.Text, links_sql.Args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var l model.LinkSignedIn
+			if err := rows.Scan(
+				&l.ID,
+				&l.URL,
+				&l.SubmittedBy,
+				&l.SubmitDate,
+				&l.Cats,
+				&l.Summary,
+				&l.SummaryCount,
+				&l.TagCount,
+				&l.LikeCount,
+				&l.ImgURL,
+				&l.HealthStatus,
+				&l.IsLiked,
+				&l.IsCopied,
+			); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, cat := range strings.Split(l.Cats, ",") {
+				for _, without_cat := range tc.WithoutCats {
+					if strings.EqualFold(cat, without_cat) {
+						t.Fatalf("link %s has excluded cat %s", l.ID, cat)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestWithoutDeadLinks(t *testing.T) {
+	_, err := TestClient.Exec(
+		`INSERT INTO "Link Health" VALUES ('1', 'dead', 404, 4, '2024-01-01 00:00:00', '2024-01-08 00:00:00');`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer TestClient.Exec(`DELETE FROM "Link Health" WHERE link_id = '1';`)
+
+	links_sql := NewTopLinks().WithoutDeadLinks()
+	if links_sql.Error != nil {
+		t.Fatal(links_sql.Error)
+	}
+
+	rows, err := TestClient.Query(links_sql.Text, links_sql.Args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var num_links int
+	for rows.Next() {
+		var l model.Link
+		if err := rows.Scan(
+			&l.ID,
+			&l.URL,
+			&l.SubmittedBy,
+			&l.SubmitDate,
+			&l.Cats,
+			&l.Summary,
+			&l.SummaryCount,
+			&l.TagCount,
+			&l.LikeCount,
+			&l.ImgURL,
+			&l.HealthStatus,
+		); err != nil {
+			t.Fatal(err)
+		} else if l.ID == "1" || l.HealthStatus == "dead" {
+			t.Fatalf("dead link %s returned", l.ID)
+		}
+		num_links++
+	}
+
+	if num_links == 0 {
+		t.Fatal("no links returned")
+	}
+}
+
 func TestLinksDuringPeriod(t *testing.T) {
 	var test_periods = []struct {
 		Period string
@@ -194,6 +300,7 @@ func TestLinksSortBy(t *testing.T) {
 				&link.TagCount,
 				&link.LikeCount,
 				&link.ImgURL,
+				&link.HealthStatus,
 			)
 			if err != nil {
 				t.Fatal(err)
@@ -252,7 +359,7 @@ func TestAsSignedInUser(t *testing.T) {
 
 	if len(cols) == 0 {
 		t.Fatal("no columns")
-	} else if len(cols) != 12 {
+	} else if len(cols) != 13 {
 		t.Fatal("incorrect col count")
 	}
 
@@ -269,6 +376,7 @@ func TestAsSignedInUser(t *testing.T) {
 		{"tag_count"},
 		{"like_count"},
 		{"img_url"},
+		{"health_status"},
 		{"is_liked"},
 		{"is_copied"},
 	}
@@ -279,8 +387,8 @@ func TestAsSignedInUser(t *testing.T) {
 		}
 	}
 
-	// args should be test_user_id * 2, limit
-	var expected_args = []interface{}{test_user_id, test_user_id, LINKS_PAGE_LIMIT}
+	// args should be test_user_id * 3 (liked, copied, muted), limit
+	var expected_args = []interface{}{test_user_id, test_user_id, test_user_id, LINKS_PAGE_LIMIT}
 	for i, arg := range links_sql.Args {
 		if arg != expected_args[i] {
 			t.Fatalf("arg %d: got %v, want %v", i, arg, expected_args[i])
@@ -293,8 +401,8 @@ func TestAsSignedInUser(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// args should be test_user_id * 2, "go AND coding", limit
-	expected_args = []interface{}{test_user_id, test_user_id, "go AND coding", LINKS_PAGE_LIMIT}
+	// args should be test_user_id * 3, "go AND coding", limit
+	expected_args = []interface{}{test_user_id, test_user_id, test_user_id, "go AND coding", LINKS_PAGE_LIMIT}
 	for i, arg := range links_sql.Args {
 		if arg != expected_args[i] {
 			t.Fatalf("arg %d: got %v, want %v", i, arg, expected_args[i])
@@ -341,6 +449,7 @@ func TestNSFW(t *testing.T) {
 			&l.TagCount,
 			&l.LikeCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 			&l.IsLiked,
 			&l.IsCopied,
 		); err != nil {
@@ -374,6 +483,7 @@ func TestNSFW(t *testing.T) {
 			&l.TagCount,
 			&l.LikeCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 			&l.IsLiked,
 			&l.IsCopied,
 		); err != nil {
@@ -429,9 +539,9 @@ func TestPage(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// args should be test_user_id * 2, "go AND coding", limit, offset
+	// args should be test_user_id * 3, "go AND coding", limit, offset
 	// in that order
-	var expected_args = []interface{}{test_user_id, test_user_id, "go AND coding", LINKS_PAGE_LIMIT + 1, LINKS_PAGE_LIMIT}
+	var expected_args = []interface{}{test_user_id, test_user_id, test_user_id, "go AND coding", LINKS_PAGE_LIMIT + 1, LINKS_PAGE_LIMIT}
 
 	for i, arg := range links_sql.Args {
 		if arg != expected_args[i] {
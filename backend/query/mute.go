@@ -0,0 +1,45 @@
+package query
+
+import (
+	"strings"
+)
+
+// ids of users whose content is hidden from user with given id
+// (blocked users are muted too)
+const MUTED_USER_IDS = `SELECT muted_id FROM "User Mutes" WHERE user_id = ?`
+
+const MUTED_LOGIN_NAMES = `SELECT login_name FROM Users WHERE id IN (` + MUTED_USER_IDS + `)`
+
+// Mutes / blocks list
+type MutedUsers struct {
+	*Query
+}
+
+func NewMutedUsers(user_id string) *MutedUsers {
+	return &MutedUsers{
+		Query: &Query{
+			Text: MUTED_USERS_BASE,
+			Args: []interface{}{user_id},
+		},
+	}
+}
+
+const MUTED_USERS_BASE = `SELECT
+	u.login_name,
+	um.is_block,
+	um.created
+FROM "User Mutes" um
+INNER JOIN Users u ON u.id = um.muted_id
+WHERE um.user_id = ?
+ORDER BY um.created DESC;`
+
+func (mu *MutedUsers) BlockedOnly() *MutedUsers {
+	mu.Text = strings.Replace(
+		mu.Text,
+		"WHERE um.user_id = ?",
+		"WHERE um.user_id = ?\nAND um.is_block = 1",
+		1,
+	)
+
+	return mu
+}
@@ -0,0 +1,177 @@
+package query
+
+import (
+	"testing"
+)
+
+func TestMutedUsersHidden(t *testing.T) {
+	var muted_login_name string
+	err := TestClient.QueryRow(
+		"SELECT login_name FROM Users WHERE id = ?;",
+		test_req_user_id,
+	).Scan(&muted_login_name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = TestClient.Exec(
+		`INSERT INTO "User Mutes" VALUES (?,?,?,?,?);`,
+		"mute_test",
+		test_user_id,
+		test_req_user_id,
+		0,
+		"2024-01-01 00:00:00",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer TestClient.Exec(`DELETE FROM "User Mutes" WHERE id = ?;`, "mute_test")
+
+	// links
+	links_sql := NewTopLinks().AsSignedInUser(test_user_id).NSFW()
+	if links_sql.Error != nil {
+		t.Fatal(links_sql.Error)
+	}
+	rows, err := TestClient.Query(links_sql.Text, links_sql.Args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var links_count int
+	for rows.Next() {
+		var id, url, sb, sd, cats, summary, img_url, health_status string
+		var summary_count, tag_count, like_count, is_liked, is_copied int
+		if err := rows.Scan(&id, &url, &sb, &sd, &cats, &summary, &summary_count, &tag_count, &like_count, &img_url, &health_status, &is_liked, &is_copied); err != nil {
+			t.Fatal(err)
+		} else if sb == muted_login_name {
+			t.Fatalf("link %s from muted user %s not hidden", id, sb)
+		}
+		links_count++
+	}
+	rows.Close()
+
+	if links_count == 0 {
+		t.Fatal("expected links from unmuted users")
+	}
+
+	// summaries
+	summaries_sql := NewSummariesForLink("2").AsSignedInUser(test_user_id)
+	rows, err = TestClient.Query(summaries_sql.Text, summaries_sql.Args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id, text, ln, last_updated string
+		var like_count, is_liked int
+		if err := rows.Scan(&id, &text, &ln, &last_updated, &like_count, &is_liked); err != nil {
+			t.Fatal(err)
+		} else if ln == muted_login_name {
+			t.Fatalf("summary %s from muted user %s not hidden", id, ln)
+		}
+	}
+	rows.Close()
+
+	// tag rankings
+	rankings_sql := NewTagRankings("2").Public().AsSignedInUser(test_user_id)
+	rows, err = TestClient.Query(rankings_sql.Text, rankings_sql.Args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rankings_count int
+	for rows.Next() {
+		var overlap float32
+		var cats, sb, last_updated string
+		if err := rows.Scan(&overlap, &cats, &sb, &last_updated); err != nil {
+			t.Fatal(err)
+		} else if sb == muted_login_name {
+			t.Fatalf("tag from muted user %s not hidden", sb)
+		}
+		rankings_count++
+	}
+	rows.Close()
+
+	if rankings_count == 0 {
+		t.Fatal("expected tags from unmuted users")
+	}
+}
+
+func TestNewMutedUsers(t *testing.T) {
+	for _, muted_sql := range []*MutedUsers{
+		NewMutedUsers(test_user_id),
+		NewMutedUsers(test_user_id).BlockedOnly(),
+	} {
+		rows, err := TestClient.Query(muted_sql.Text, muted_sql.Args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cols, err := rows.Columns()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+
+		if len(cols) != 3 {
+			t.Fatalf("got %d columns, want 3", len(cols))
+		}
+	}
+}
+
+func TestMutedUsersHiddenInTmap(t *testing.T) {
+	// owner copies link 2 and tags NSFW link 3, both submitted by
+	// test_req_login_name
+	if _, err := TestClient.Exec(
+		`INSERT INTO Users (id, login_name, password, created) VALUES ('mute_tmap_owner', 'mute_tmap_owner', 'x', '2024-01-01');`,
+	); err != nil {
+		t.Fatal(err)
+	}
+	defer TestClient.Exec(`DELETE FROM Users WHERE id = 'mute_tmap_owner';`)
+	if _, err := TestClient.Exec(
+		`INSERT INTO "Link Copies" (id, link_id, user_id, created) VALUES ('mute_tmap_copy', '2', 'mute_tmap_owner', '2024-01-01 00:00:00');`,
+	); err != nil {
+		t.Fatal(err)
+	}
+	defer TestClient.Exec(`DELETE FROM "Link Copies" WHERE id = 'mute_tmap_copy';`)
+	if _, err := TestClient.Exec(
+		`INSERT INTO Tags (id, link_id, cats, submitted_by, last_updated) VALUES ('mute_tmap_tag', '3', 'NSFW,mute', 'mute_tmap_owner', '2024-01-01 00:00:00');`,
+	); err != nil {
+		t.Fatal(err)
+	}
+	defer TestClient.Exec(`DELETE FROM Tags WHERE id = 'mute_tmap_tag';`)
+
+	copied := func(login_name string) []string {
+		return scanFirstColumn(t, NewTmapCopied(login_name).AsSignedInUser(test_user_id).Query)
+	}
+	tagged := func(login_name string) []string {
+		return scanFirstColumn(t, NewTmapTagged(login_name).AsSignedInUser(test_user_id).NSFW().Query)
+	}
+
+	if got := copied("mute_tmap_owner"); len(got) != 1 || got[0] != "2" {
+		t.Fatalf("got copied %v before mute, want [2]", got)
+	} else if got := tagged("mute_tmap_owner"); len(got) != 1 || got[0] != "3" {
+		t.Fatalf("got tagged %v before mute, want [3]", got)
+	}
+
+	if _, err := TestClient.Exec(
+		`INSERT INTO "User Mutes" VALUES (?,?,?,?,?);`,
+		"mute_tmap_test",
+		test_user_id,
+		test_req_user_id,
+		0,
+		"2024-01-01 00:00:00",
+	); err != nil {
+		t.Fatal(err)
+	}
+	defer TestClient.Exec(`DELETE FROM "User Mutes" WHERE id = ?;`, "mute_tmap_test")
+
+	if got := copied("mute_tmap_owner"); len(got) != 0 {
+		t.Fatalf("got copied %v from muted user", got)
+	} else if got := tagged("mute_tmap_owner"); len(got) != 0 {
+		t.Fatalf("got tagged %v from muted user", got)
+	}
+
+	// own tmap unaffected: jlk copied link 2
+	if got := copied(test_login_name); len(got) != 1 || got[0] != "2" {
+		t.Fatalf("got own copied %v, want [2]", got)
+	}
+}
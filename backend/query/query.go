@@ -31,6 +31,11 @@ func GetPeriodClause(period string) (clause string, err error) {
 	return fmt.Sprintf("submit_date >= date('now', '-%d days')", days), nil
 }
 
+// FTS5 string: any chars inside match literally
+func QuoteFTSPhrase(phrase string) string {
+	return `"` + strings.ReplaceAll(phrase, `"`, `""`) + `"`
+}
+
 func EscapeCatsReservedChars(cats []string) {
 	for i := 0; i < len(cats); i++ {
 		cats[i] = SurroundReservedCharsWithDoubleQuotes(cats[i])
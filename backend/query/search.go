@@ -0,0 +1,170 @@
+package query
+
+import (
+	"strings"
+
+	e "github.com/julianlk522/fitm/error"
+)
+
+type SearchLinks struct {
+	Query
+}
+
+// Links matching all of terms' words in URL, summaries or cats
+// (words ending in * match as prefixes)
+func NewSearchLinks(terms string) *SearchLinks {
+	s := &SearchLinks{
+		Query: Query{
+			Text: LINKS_BASE_CTES +
+				SEARCH_MATCHES_CTE +
+				LINKS_BASE_FIELDS +
+				SEARCH_FIELDS +
+				LINKS_FROM +
+				SEARCH_JOIN +
+				LINKS_BASE_JOINS +
+				LINKS_NO_NSFW_CATS_WHERE +
+				SEARCH_ORDER_BY +
+				LINKS_LIMIT,
+		},
+	}
+
+	match_arg, err := SearchMatchArg(terms)
+	if err != nil {
+		s.Error = err
+		return s
+	}
+	s.Args = []interface{}{match_arg, LINKS_PAGE_LIMIT}
+
+	return s
+}
+
+// Quotes each word so FTS5 syntax chars in terms can't break query
+func SearchMatchArg(terms string) (string, error) {
+	var match_terms []string
+	for _, word := range strings.Fields(terms) {
+		is_prefix := strings.HasSuffix(word, "*")
+		word = strings.TrimRight(word, "*")
+		if word == "" {
+			continue
+		}
+
+		match_term := QuoteFTSPhrase(word)
+		if is_prefix {
+			match_term += "*"
+		}
+		match_terms = append(match_terms, match_term)
+	}
+
+	if len(match_terms) == 0 {
+		return "", e.ErrNoSearchTerms
+	}
+
+	return strings.Join(match_terms, " "), nil
+}
+
+// snippet markers replaced after HTML-escaping snippet
+const (
+	SEARCH_SNIPPET_MATCH_START = "\x02"
+	SEARCH_SNIPPET_MATCH_END   = "\x03"
+)
+
+// bm25 column weights: link_id (unindexed), url, summary, summaries, cats
+const SEARCH_MATCHES_CTE = `,
+SearchMatches AS (
+	SELECT
+		link_id,
+		bm25(link_search_fts, 0.0, 2.0, 3.0, 1.0, 4.0) AS search_rank,
+		snippet(link_search_fts, -1, char(2), char(3), '…', 16) AS snippet
+	FROM link_search_fts
+	WHERE link_search_fts MATCH ?
+)`
+
+const SEARCH_FIELDS = `,
+	sm.snippet`
+
+const SEARCH_JOIN = `
+INNER JOIN SearchMatches sm ON l.id = sm.link_id`
+
+// bm25 is negative (lower is better): likes boost it by up to 2x
+// (half of that at 10 likes)
+const SEARCH_ORDER_BY = `
+ORDER BY
+	sm.search_rank * (
+		1.0 + COALESCE(ll.like_count, 0) / (COALESCE(ll.like_count, 0) + 10.0)
+	),
+	l.id DESC`
+
+func (s *SearchLinks) DuringPeriod(period string) *SearchLinks {
+	clause, err := GetPeriodClause(period)
+	if err != nil {
+		s.Error = err
+		return s
+	}
+
+	s.Text = strings.Replace(
+		s.Text,
+		SEARCH_ORDER_BY,
+		"\n"+"AND "+clause+SEARCH_ORDER_BY,
+		1,
+	)
+
+	return s
+}
+
+func (s *SearchLinks) AsSignedInUser(req_user_id string) *SearchLinks {
+	auth_replacer := strings.NewReplacer(
+		LINKS_BASE_CTES, LINKS_BASE_CTES+LINKS_AUTH_CTES,
+		LINKS_BASE_FIELDS, LINKS_BASE_FIELDS+LINKS_AUTH_FIELDS,
+		LINKS_BASE_JOINS, LINKS_BASE_JOINS+LINKS_AUTH_JOINS,
+	)
+	s.Text = auth_replacer.Replace(s.Text)
+
+	// prepend args
+	s.Args = append([]interface{}{req_user_id, req_user_id, req_user_id}, s.Args...)
+
+	return s
+}
+
+func (s *SearchLinks) NSFW() *SearchLinks {
+
+	// remove NSFW clause
+	s.Text = strings.Replace(
+		s.Text,
+		LINKS_NO_NSFW_CATS_WHERE,
+		"",
+		1,
+	)
+
+	// replace .DuringPeriod clause AND with WHERE
+	s.Text = strings.Replace(
+		s.Text,
+		"AND submit_date",
+		"WHERE submit_date",
+		1,
+	)
+
+	return s
+}
+
+func (s *SearchLinks) Page(page int) *SearchLinks {
+	if page == 0 || s.Error != nil {
+		return s
+	}
+
+	// pop limit arg and replace with limit + 1
+	s.Args = append(s.Args[:len(s.Args)-1], LINKS_PAGE_LIMIT+1)
+
+	if page == 1 {
+		return s
+	}
+
+	s.Text = strings.Replace(
+		s.Text,
+		"LIMIT ?",
+		"LIMIT ? OFFSET ?",
+		1,
+	)
+	s.Args = append(s.Args, (page-1)*LINKS_PAGE_LIMIT)
+
+	return s
+}
@@ -0,0 +1,152 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/julianlk522/fitm/model"
+)
+
+func TestSearchMatchArg(t *testing.T) {
+	var test_terms = []struct {
+		Terms string
+		Want  string
+		Valid bool
+	}{
+		{"golang", `"golang"`, true},
+		{"  go   repo ", `"go" "repo"`, true},
+		{"prog*", `"prog"*`, true},
+		{`say "hi" OR -x`, `"say" """hi""" "OR" "-x"`, true},
+		{"", "", false},
+		{" * ", "", false},
+	}
+
+	for _, tt := range test_terms {
+		got, err := SearchMatchArg(tt.Terms)
+		if tt.Valid && err != nil {
+			t.Fatalf("%q: %s", tt.Terms, err)
+		} else if !tt.Valid && err == nil {
+			t.Fatalf("%q: expected error", tt.Terms)
+		} else if got != tt.Want {
+			t.Fatalf("%q: got %s, want %s", tt.Terms, got, tt.Want)
+		}
+	}
+}
+
+func TestSearchLinks(t *testing.T) {
+	var test_searches = []struct {
+		Terms    string
+		NSFW     bool
+		SignedIn bool
+		WantIDs  []string
+	}{
+		// URL
+		{"golang", false, false, []string{"2"}},
+		{"example.com", false, false, []string{"1"}},
+		// summary, cats, prefix
+		{"summary", false, true, []string{"1"}},
+		{"program*", false, true, []string{"2"}},
+		// NSFW hidden unless requested
+		{"nsfw", false, false, []string{}},
+		{"nsfw", true, false, []string{"3"}},
+		{"nothing_matches_this", false, false, []string{}},
+	}
+
+	for _, ts := range test_searches {
+		search_sql := NewSearchLinks(ts.Terms)
+		if ts.SignedIn {
+			search_sql = search_sql.AsSignedInUser(test_user_id)
+		}
+		if ts.NSFW {
+			search_sql = search_sql.NSFW()
+		}
+		search_sql = search_sql.Page(1)
+		if search_sql.Error != nil {
+			t.Fatal(search_sql.Error)
+		}
+
+		rows, err := TestClient.Query(search_sql.Text, search_sql.Args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids := []string{}
+		for rows.Next() {
+			var l model.LinkSignedIn
+			var snippet string
+			dest := []interface{}{
+				&l.ID,
+				&l.URL,
+				&l.SubmittedBy,
+				&l.SubmitDate,
+				&l.Cats,
+				&l.Summary,
+				&l.SummaryCount,
+				&l.TagCount,
+				&l.LikeCount,
+				&l.ImgURL,
+				&l.HealthStatus,
+			}
+			if ts.SignedIn {
+				dest = append(dest, &l.IsLiked, &l.IsCopied)
+			}
+			if err := rows.Scan(append(dest, &snippet)...); err != nil {
+				t.Fatal(err)
+			} else if snippet == "" {
+				t.Fatalf("%q: no snippet for link %s", ts.Terms, l.ID)
+			}
+			ids = append(ids, l.ID)
+		}
+		rows.Close()
+
+		if len(ids) != len(ts.WantIDs) {
+			t.Fatalf("%q: got links %v, want %v", ts.Terms, ids, ts.WantIDs)
+		}
+		for i := range ids {
+			if ids[i] != ts.WantIDs[i] {
+				t.Fatalf("%q: got links %v, want %v", ts.Terms, ids, ts.WantIDs)
+			}
+		}
+	}
+
+	if search_sql := NewSearchLinks("go").DuringPeriod("gobblety gook"); search_sql.Error == nil {
+		t.Fatal("expected error for invalid period")
+	}
+}
+
+func TestSearchIndexFollowsSummaries(t *testing.T) {
+	count := func(terms string) int {
+		search_sql := NewSearchLinks(terms).NSFW()
+		rows, err := TestClient.Query(search_sql.Text, search_sql.Args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+
+		var n int
+		for rows.Next() {
+			n++
+		}
+		return n
+	}
+
+	_, err := TestClient.Exec(
+		`INSERT INTO Summaries VALUES ('search_test', 'xylophonic remarks', '1', '13', '2024-01-02 00:00:00');`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := count("xylophonic"); n != 1 {
+		t.Fatalf("got %d results for new summary", n)
+	}
+
+	if _, err = TestClient.Exec(`DELETE FROM Summaries WHERE id = 'search_test';`); err != nil {
+		t.Fatal(err)
+	}
+	if n := count("xylophonic"); n != 0 {
+		t.Fatalf("got %d results for deleted summary", n)
+	}
+	// other summaries of link still indexed
+	if n := count("summary"); n != 1 {
+		t.Fatalf("got %d results for remaining summary", n)
+	}
+}
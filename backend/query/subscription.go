@@ -0,0 +1,98 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SUBSCRIPTION FEED
+// new links matching any of a user's cat subscriptions, newest first
+type SubscriptionFeed struct {
+	*Query
+}
+
+// subscription_cats[i] are cats of subscription with subscription_ids[i]
+func NewSubscriptionFeed(user_id string, subscription_ids []string, subscription_cats [][]string) *SubscriptionFeed {
+	sf := &SubscriptionFeed{&Query{}}
+	if len(subscription_ids) == 0 || len(subscription_ids) != len(subscription_cats) {
+		sf.Error = fmt.Errorf("no subscriptions provided")
+		return sf
+	}
+
+	// one MATCH per subscription, merged per link
+	var matches []string
+	for i, id := range subscription_ids {
+		cats := make([]string, len(subscription_cats[i]))
+		copy(cats, subscription_cats[i])
+		EscapeCatsReservedChars(cats)
+
+		matches = append(matches, `
+		SELECT link_id, ? AS subscription_id
+		FROM global_cats_fts
+		WHERE global_cats MATCH ?`)
+		sf.Args = append(sf.Args, id, strings.Join(cats, " AND "))
+	}
+
+	sf.Text = LINKS_BASE_CTES + `,
+SubscriptionMatches AS (
+	SELECT link_id, group_concat(subscription_id) AS subscription_ids
+	FROM (` + strings.Join(matches, "\n\t\tUNION ALL") + `
+	)
+	GROUP BY link_id
+)` + LINKS_AUTH_CTES +
+		LINKS_BASE_FIELDS + LINKS_AUTH_FIELDS + SUBSCRIPTION_FEED_FIELDS +
+		SUBSCRIPTION_FEED_FROM +
+		LINKS_BASE_JOINS + LINKS_AUTH_JOINS +
+		LINKS_NO_NSFW_CATS_WHERE +
+		SUBSCRIPTION_FEED_ORDER_BY +
+		LINKS_LIMIT
+
+	// user_id used in IsLiked, IsCopied, UnmutedLinks
+	sf.Args = append(sf.Args, user_id, user_id, user_id, LINKS_PAGE_LIMIT)
+
+	return sf
+}
+
+const SUBSCRIPTION_FEED_FIELDS = `,
+	sm.subscription_ids`
+
+const SUBSCRIPTION_FEED_FROM = `
+FROM
+	SubscriptionMatches sm
+	INNER JOIN Links l ON l.id = sm.link_id`
+
+const SUBSCRIPTION_FEED_ORDER_BY = `
+ORDER BY l.submit_date DESC, l.id DESC`
+
+// only links submitted after the one with given submit date / ID, oldest
+// first (to page forward without skipping any)
+// (call before .NSFW)
+func (sf *SubscriptionFeed) Since(submit_date string, link_id string) *SubscriptionFeed {
+	sf.Text = strings.Replace(
+		sf.Text,
+		SUBSCRIPTION_FEED_ORDER_BY,
+		`
+AND (l.submit_date, l.id) > (?, ?)
+ORDER BY l.submit_date ASC, l.id ASC`,
+		1,
+	)
+
+	// insert before limit arg, with limit + 1 to detect more
+	sf.Args = append(sf.Args[:len(sf.Args)-1], submit_date, link_id, LINKS_PAGE_LIMIT+1)
+
+	return sf
+}
+
+func (sf *SubscriptionFeed) NSFW() *SubscriptionFeed {
+	sf.Text = strings.Replace(sf.Text, LINKS_NO_NSFW_CATS_WHERE, "", 1)
+
+	// replace .Since clause AND with WHERE
+	sf.Text = strings.Replace(
+		sf.Text,
+		"AND (l.submit_date",
+		"WHERE (l.submit_date",
+		1,
+	)
+
+	return sf
+}
@@ -0,0 +1,74 @@
+package query
+
+import (
+	"testing"
+)
+
+func TestNewSubscriptionFeed(t *testing.T) {
+	if sf := NewSubscriptionFeed(test_user_id, nil, nil); sf.Error == nil {
+		t.Fatal("expected error for no subscriptions")
+	}
+
+	// link 1 has umvc3 and flowers; both subscriptions match it
+	feed_sql := NewSubscriptionFeed(
+		test_req_user_id,
+		[]string{"s1", "s2"},
+		[][]string{{"umvc3"}, {"flowers", "umvc3"}},
+	).NSFW()
+	rows, err := TestClient.Query(feed_sql.Text, feed_sql.Args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var link_1_subscriptions string
+	for rows.Next() {
+		var id, url, sb, sd, cats, summary, img_url, health_status, subscription_ids string
+		var summary_count, tag_count, like_count, is_liked, is_copied int
+		if err := rows.Scan(
+			&id,
+			&url,
+			&sb,
+			&sd,
+			&cats,
+			&summary,
+			&summary_count,
+			&tag_count,
+			&like_count,
+			&img_url,
+			&health_status,
+			&is_liked,
+			&is_copied,
+			&subscription_ids,
+		); err != nil {
+			t.Fatal(err)
+		}
+		if id == "1" {
+			if link_1_subscriptions != "" {
+				t.Fatal("expected link 1 once")
+			}
+			link_1_subscriptions = subscription_ids
+		}
+	}
+
+	if link_1_subscriptions != "s1,s2" && link_1_subscriptions != "s2,s1" {
+		t.Fatalf("got link 1 subscriptions %q, want s1 and s2", link_1_subscriptions)
+	}
+
+	// .Since then .NSFW
+	feed_sql = NewSubscriptionFeed(test_req_user_id, []string{"s1"}, [][]string{{"umvc3"}}).
+		Since("2024-01-01 00:00:00", "1").
+		NSFW()
+	var count int
+	rows, err = TestClient.Query(feed_sql.Text, feed_sql.Args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		count++
+	}
+	rows.Close()
+	if count != 0 {
+		t.Fatalf("got %d links after link 1, want 0", count)
+	}
+}
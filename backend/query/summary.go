@@ -151,7 +151,19 @@ const SUMMARIES_GBL = `
 GROUP BY sumid
 LIMIT ?;`
 
+// also hides summaries from users muted by user_id
 func (s *Summaries) AsSignedInUser(user_id string) *Summaries {
+	s.Text = strings.Replace(
+		s.Text,
+		`WHERE link_id = ?`,
+		`WHERE link_id = ?
+		AND submitted_by NOT IN (`+MUTED_USER_IDS+`)`,
+		1,
+	)
+
+	// insert muted user_id arg after link_id
+	s.Args = append([]interface{}{s.Args[0], user_id}, s.Args[1:]...)
+
 	s.Text = strings.Replace(
 		s.Text, 
 		SUMMARIES_BASE_FIELDS, 
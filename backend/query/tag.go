@@ -153,6 +153,21 @@ const TOP_OVERLAP_SCORES_PUBLIC_FIELDS = `,
 	Tags.submitted_by, 
 	last_updated`
 
+// hide tags from users muted by user_id
+func (o *TagRankings) AsSignedInUser(user_id string) *TagRankings {
+	o.Text = strings.Replace(
+		o.Text,
+		"WHERE link_id = ?",
+		"WHERE link_id = ?\nAND Tags.submitted_by NOT IN ("+MUTED_LOGIN_NAMES+")",
+		1,
+	)
+
+	// insert after link_id arg
+	o.Args = append([]interface{}{o.Args[0], user_id}, o.Args[1:]...)
+
+	return o
+}
+
 // Global Cat Counts
 type GlobalCatCounts struct {
 	*Query
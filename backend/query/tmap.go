@@ -18,12 +18,14 @@ func NewTmapProfile(login_name string) *TmapProfile {
 }
 
 const TMAP_PROFILE = `SELECT 
-	login_name, 
-	COALESCE(about,'') as about, 
-	COALESCE(pfp,'') as pfp, 
-	created
-FROM Users 
-WHERE login_name = ?;`
+	u.login_name, 
+	COALESCE(u.about,'') as about, 
+	COALESCE(u.pfp,'') as pfp, 
+	u.created,
+	(SELECT count(*) FROM "User Follows" WHERE followed_id = u.id) AS follower_count,
+	(SELECT count(*) FROM "User Follows" WHERE user_id = u.id) AS following_count
+FROM Users u
+WHERE u.login_name = ?;`
 
 // NSFW LINKS COUNT
 type TmapNSFWLinksCount struct {
@@ -214,16 +216,22 @@ func (q *TmapCopied) FromCats(cats []string) *TmapCopied {
 	return q
 }
 
+// (call after .FromCats, which rebuilds args)
 func (q *TmapCopied) AsSignedInUser(req_user_id string) *TmapCopied {
 	fields_replacer := strings.NewReplacer(
-		TMAP_BASE_CTES, TMAP_BASE_CTES+","+TMAP_AUTH_CTES,
+		TMAP_BASE_CTES, TMAP_BASE_CTES+","+TMAP_AUTH_CTES+TMAP_UNMUTED_CTE,
 		TMAP_BASE_FIELDS, TMAP_BASE_FIELDS+TMAP_AUTH_FIELDS,
-		COPIED_JOIN, COPIED_JOIN+TMAP_AUTH_JOINS,
+		COPIED_JOIN, COPIED_JOIN+TMAP_AUTH_JOINS+TMAP_UNMUTED_JOIN,
 	)
 	q.Text = fields_replacer.Replace(q.Text)
 
-	// prepend req_user_id arg * 2
-	q.Args = append([]interface{}{req_user_id, req_user_id}, q.Args...)
+	// insert auth args after UserCopies login_name arg
+	// (IsLiked, IsCopied, UnmutedLinks)
+	login_name := q.Args[0]
+	q.Args = append(
+		[]interface{}{login_name, req_user_id, req_user_id, req_user_id, req_user_id, login_name},
+		q.Args[1:]...,
+	)
 
 	return q
 }
@@ -332,14 +340,18 @@ func (q *TmapTagged) FromCats(cats []string) *TmapTagged {
 
 func (q *TmapTagged) AsSignedInUser(req_user_id string) *TmapTagged {
 	fields_replacer := strings.NewReplacer(
-		TMAP_BASE_CTES, TMAP_BASE_CTES+","+TMAP_AUTH_CTES,
+		TMAP_BASE_CTES, TMAP_BASE_CTES+","+TMAP_AUTH_CTES+TMAP_UNMUTED_CTE,
 		TAGGED_FIELDS, TAGGED_FIELDS+TMAP_AUTH_FIELDS,
-		TAGGED_JOINS, TAGGED_JOINS+TMAP_AUTH_JOINS,
+		TAGGED_JOINS, TAGGED_JOINS+TMAP_AUTH_JOINS+TMAP_UNMUTED_JOIN,
 	)
 	q.Text = fields_replacer.Replace(q.Text)
 
-	// prepend req_user_id arg * 2
-	q.Args = append([]interface{}{req_user_id, req_user_id}, q.Args...)
+	// prepend auth args (IsLiked, IsCopied, UnmutedLinks)
+	login_name := q.Args[0]
+	q.Args = append(
+		[]interface{}{req_user_id, req_user_id, req_user_id, req_user_id, login_name},
+		q.Args...,
+	)
 
 	return q
 }
@@ -513,7 +525,8 @@ SELECT
     COALESCE(sc.summary_count, 0) AS summary_count,
     COALESCE(lc.like_count, 0) AS like_count,
     COALESCE(tc.tag_count, 0) AS tag_count,
-    COALESCE(l.img_url, '') AS img_url`
+    COALESCE(l.img_url, '') AS img_url,
+    ` + LINK_HEALTH_STATUS_FIELD
 
 const TMAP_FROM = LINKS_FROM
 
@@ -548,6 +561,20 @@ const TMAP_AUTH_FIELDS = `,
 	COALESCE(is_liked,0) as is_liked, 
 	COALESCE(is_copied,0) as is_copied`
 
+// copied / tagged links submitted by users viewer muted or blocked are
+// hidden in others' tmaps (same as LINKS_AUTH_CTES), but not in own tmap
+// or data export
+const TMAP_UNMUTED_CTE = `,
+UnmutedLinks AS (
+	SELECT id AS link_id
+	FROM Links
+	WHERE submitted_by NOT IN (` + MUTED_LOGIN_NAMES + `)
+	OR ? = (SELECT id FROM Users WHERE login_name = ?)
+)`
+
+const TMAP_UNMUTED_JOIN = `
+	INNER JOIN UnmutedLinks ul ON l.id = ul.link_id`
+
 const TMAP_AUTH_JOINS = `
 	LEFT JOIN IsLiked il ON l.id = il.link_id
 	LEFT JOIN IsCopied ic ON l.id = ic.link_id`
\ No newline at end of file
@@ -27,6 +27,8 @@ func TestNewTmapProfile(t *testing.T) {
 		&profile.About,
 		&profile.PFP,
 		&profile.Created,
+		&profile.FollowerCount,
+		&profile.FollowingCount,
 	); err != nil && err != sql.ErrNoRows {
 		t.Fatal(err)
 	}
@@ -124,6 +126,7 @@ func TestNewTmapSubmitted(t *testing.T) {
 			&l.LikeCount,
 			&l.TagCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 		); err != nil {
 			t.Fatal(err)
 		} else if l.SubmittedBy != test_req_login_name {
@@ -176,6 +179,7 @@ func TestNewTmapSubmittedFromCats(t *testing.T) {
 			&l.LikeCount,
 			&l.TagCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 		); err != nil {
 			t.Fatal(err)
 		} else if !strings.Contains(l.Cats, test_cats[0]) || !strings.Contains(l.Cats, test_cats[1]) {
@@ -213,6 +217,7 @@ func TestNewTmapSubmittedAsSignedInUser(t *testing.T) {
 			&l.TagCount,
 			&l.LikeCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 			&l.IsLiked,
 			&l.IsCopied,
 		); err != nil {
@@ -244,6 +249,7 @@ func TestNewTmapSubmittedNSFW(t *testing.T) {
 			&l.LikeCount,
 			&l.TagCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 		); err != nil {
 			t.Fatal(err)
 		} else if strings.Contains(l.Cats, "NSFW") {
@@ -283,6 +289,7 @@ func TestNewTmapCopied(t *testing.T) {
 			&l.LikeCount,
 			&l.TagCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 		); err != nil {
 			t.Fatal(err)
 		} else if l.TagCount == 0 {
@@ -332,6 +339,7 @@ func TestNewTmapCopiedFromCats(t *testing.T) {
 			&l.LikeCount,
 			&l.TagCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 		); err != nil {
 			t.Fatal(err)
 		} else if !strings.Contains(l.Cats, test_cats[0]) || !strings.Contains(l.Cats, test_cats[1]) {
@@ -382,6 +390,7 @@ func TestNewTmapCopiedAsSignedInUser(t *testing.T) {
 			&l.TagCount,
 			&l.LikeCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 			&l.IsLiked,
 			&l.IsCopied,
 		); err != nil {
@@ -415,6 +424,7 @@ func TestNewTmapCopiedNSFW(t *testing.T) {
 			&l.LikeCount,
 			&l.TagCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 		); err != nil {
 			t.Fatal(err)
 		} else if strings.Contains(l.Cats, "NSFW") {
@@ -454,6 +464,7 @@ func TestNewTmapTagged(t *testing.T) {
 			&l.LikeCount,
 			&l.TagCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 		); err != nil {
 			t.Fatal(err)
 		} else if l.TagCount == 0 {
@@ -501,6 +512,7 @@ func TestNewTmapTaggedFromCats(t *testing.T) {
 			&l.LikeCount,
 			&l.TagCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 		); err != nil {
 			t.Fatal(err)
 		} else if !strings.Contains(l.Cats, test_cats[0]) || !strings.Contains(l.Cats, test_cats[1]) {
@@ -552,6 +564,7 @@ func TestNewTmapTaggedAsSignedInUser(t *testing.T) {
 			&l.TagCount,
 			&l.LikeCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 			&l.IsLiked,
 			&l.IsCopied,
 		); err != nil {
@@ -585,6 +598,7 @@ func TestNewTmapTaggedNSFW(t *testing.T) {
 			&l.LikeCount,
 			&l.TagCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 		); err != nil {
 			t.Fatal(err)
 		} else if strings.Contains(l.Cats, "NSFW") {
@@ -628,6 +642,7 @@ func TestFromUserOrGlobalCats(t *testing.T) {
 			&l.LikeCount,
 			&l.TagCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 		); err != nil {
 			t.Fatal(err)
 		} else if !strings.Contains(l.Cats, test_cats[0]) || !strings.Contains(l.Cats, test_cats[1]) {
@@ -663,6 +678,7 @@ func TestFromUserOrGlobalCats(t *testing.T) {
 			&l.LikeCount,
 			&l.TagCount,
 			&l.ImgURL,
+			&l.HealthStatus,
 		); err != nil {
 			t.Fatal(err)
 		} else if !strings.Contains(l.Cats, test_cats[0]) || !strings.Contains(l.Cats, test_cats[1]) {
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	e "github.com/julianlk522/fitm/error"
+)
+
+// shared by each BlobStore implementation's tests
+func testBlobStore(t *testing.T, store BlobStore) {
+	if err := store.Put("a.jpg", strings.NewReader("aaa"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("sub/b.jpg", strings.NewReader("bb"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := store.Get("a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "aaa" {
+		t.Fatalf("got %s, want aaa", data)
+	}
+
+	if _, err = store.Get("missing.jpg"); err != e.ErrBlobNotFound {
+		t.Fatalf("got error %v, want %v", err, e.ErrBlobNotFound)
+	}
+
+	if exists, err := store.Exists("sub/b.jpg"); err != nil || !exists {
+		t.Fatalf("expected sub/b.jpg to exist (err %v)", err)
+	}
+
+	blobs, err := store.List("")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(blobs) != 2 {
+		t.Fatalf("got %d blobs, want 2", len(blobs))
+	}
+	blobs, err = store.List("sub/")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(blobs) != 1 || blobs[0].Key != "sub/b.jpg" || blobs[0].Size != 2 {
+		t.Fatalf("got %+v, want sub/b.jpg (2 bytes)", blobs)
+	}
+
+	if err = store.Delete("a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	// idempotent
+	if err = store.Delete("a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := store.Exists("a.jpg"); exists {
+		t.Fatal("expected a.jpg to be deleted")
+	}
+
+	// unsafe keys
+	for _, key := range []string{"", "../a.jpg", "/etc/passwd", "sub/../../a.jpg", ".hidden", "a\\b.jpg"} {
+		if err := store.Put(key, strings.NewReader("x"), "image/jpeg"); err != e.ErrInvalidBlobKey {
+			t.Fatalf("key %q: got error %v, want %v", key, err, e.ErrInvalidBlobKey)
+		}
+		if _, err := store.Get(key); err != e.ErrInvalidBlobKey {
+			t.Fatalf("key %q: got error %v, want %v", key, err, e.ErrInvalidBlobKey)
+		}
+	}
+}
+
+func TestFSStore(t *testing.T) {
+	root := t.TempDir()
+	testBlobStore(t, NewFSStore(root))
+
+	// temp files from interrupted writes not listed
+	if err := os.WriteFile(filepath.Join(root, ".tmp-123"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := NewFSStore(root).List("")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(blobs) != 1 {
+		t.Fatalf("got %d blobs, want 1", len(blobs))
+	}
+
+	// missing root: empty
+	blobs, err = NewFSStore(filepath.Join(root, "nope")).List("")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(blobs) != 0 {
+		t.Fatalf("got %d blobs, want 0", len(blobs))
+	}
+}
@@ -0,0 +1,337 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	e "github.com/julianlk522/fitm/error"
+)
+
+const S3_DEFAULT_REGION = "us-east-1"
+const S3_REQUEST_TIMEOUT = 30 * time.Second
+
+// max chars of S3 error response included in returned errors
+const S3_ERROR_BODY_LIMIT = 512
+
+type S3Config struct {
+	// e.g. https://s3.us-east-1.amazonaws.com or MinIO / R2 URL
+	// (path-style requests: {Endpoint}/{Bucket}/{Prefix}{key})
+	Endpoint        string
+	Region          string
+	Bucket          string
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// Minimal S3-compatible client (PUT, GET, HEAD, DELETE, ListObjectsV2)
+// signed with AWS Signature Version 4
+type S3Store struct {
+	cfg    S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	switch {
+	case cfg.Endpoint == "":
+		return nil, e.ErrNoS3Endpoint
+	case cfg.Bucket == "":
+		return nil, e.ErrNoS3Bucket
+	case cfg.AccessKeyID == "" || cfg.SecretAccessKey == "":
+		return nil, e.ErrNoS3Credentials
+	}
+
+	if cfg.Region == "" {
+		cfg.Region = S3_DEFAULT_REGION
+	}
+	cfg.Endpoint = strings.TrimSuffix(cfg.Endpoint, "/")
+
+	return &S3Store{
+		cfg:    cfg,
+		client: &http.Client{Timeout: S3_REQUEST_TIMEOUT},
+		now:    time.Now,
+	}, nil
+}
+
+func (s *S3Store) objectURL(key string) string {
+	return s.cfg.Endpoint + "/" + s3URIEncode(s.cfg.Bucket, false) + "/" + s3URIEncode(s.cfg.Prefix+key, true)
+}
+
+func (s *S3Store) do(method string, url string, body []byte, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	payload_hash := sha256.Sum256(body)
+	SignS3Request(req, hex.EncodeToString(payload_hash[:]), s.cfg, s.now())
+
+	return s.client.Do(req)
+}
+
+func (s *S3Store) Put(key string, r io.Reader, content_type string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.do(http.MethodPut, s.objectURL(key), body, map[string]string{
+		"Content-Type": content_type,
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return s3Error(res, key)
+	}
+
+	return nil
+}
+
+func (s *S3Store) Get(key string) (io.ReadCloser, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+
+	res, err := s.do(http.MethodGet, s.objectURL(key), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res.Body, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, e.ErrBlobNotFound
+	default:
+		defer res.Body.Close()
+		return nil, s3Error(res, key)
+	}
+}
+
+func (s *S3Store) Exists(key string) (bool, error) {
+	if err := ValidateKey(key); err != nil {
+		return false, err
+	}
+
+	res, err := s.do(http.MethodHead, s.objectURL(key), nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, s3Error(res, key)
+	}
+}
+
+func (s *S3Store) Delete(key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+
+	res, err := s.do(http.MethodDelete, s.objectURL(key), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return s3Error(res, key)
+	}
+}
+
+type s3ListBucketResult struct {
+	XMLName  xml.Name `xml:"ListBucketResult"`
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// keys returned without S3Config.Prefix
+func (s *S3Store) List(prefix string) ([]BlobInfo, error) {
+	blobs := []BlobInfo{}
+	var continuation_token string
+
+	for {
+		params := url.Values{}
+		params.Set("list-type", "2")
+		params.Set("prefix", s.cfg.Prefix+prefix)
+		if continuation_token != "" {
+			params.Set("continuation-token", continuation_token)
+		}
+
+		list_url := s.cfg.Endpoint + "/" + s3URIEncode(s.cfg.Bucket, false) + "?" + params.Encode()
+		res, err := s.do(http.MethodGet, list_url, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode != http.StatusOK {
+			err = s3Error(res, prefix)
+			res.Body.Close()
+			return nil, err
+		}
+
+		var result s3ListBucketResult
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result.Contents {
+			blobs = append(blobs, BlobInfo{
+				Key:          strings.TrimPrefix(c.Key, s.cfg.Prefix),
+				Size:         c.Size,
+				LastModified: c.LastModified,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		continuation_token = result.NextContinuationToken
+	}
+
+	return blobs, nil
+}
+
+func s3Error(res *http.Response, key string) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, S3_ERROR_BODY_LIMIT))
+	return e.S3RequestFailed(res.Request.Method, key, res.StatusCode, string(body))
+}
+
+// SigV4
+// Signs host plus every header already set on req
+// (https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html)
+func SignS3Request(req *http.Request, payload_hash string, cfg S3Config, now time.Time) {
+	amz_date := now.UTC().Format("20060102T150405Z")
+	date := amz_date[:8]
+
+	req.Header.Set("x-amz-date", amz_date)
+	req.Header.Set("x-amz-content-sha256", payload_hash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+
+	header_names := make([]string, 0, len(headers))
+	for k := range headers {
+		header_names = append(header_names, k)
+	}
+	sort.Strings(header_names)
+
+	var canonical_headers strings.Builder
+	for _, k := range header_names {
+		canonical_headers.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed_headers := strings.Join(header_names, ";")
+
+	canonical_request := strings.Join([]string{
+		req.Method,
+		s3URIEncode(req.URL.Path, true),
+		s3CanonicalQuery(req.URL.Query()),
+		canonical_headers.String(),
+		signed_headers,
+		payload_hash,
+	}, "\n")
+
+	scope := date + "/" + cfg.Region + "/s3/aws4_request"
+	canonical_request_hash := sha256.Sum256([]byte(canonical_request))
+	string_to_sign := "AWS4-HMAC-SHA256\n" +
+		amz_date + "\n" +
+		scope + "\n" +
+		hex.EncodeToString(canonical_request_hash[:])
+
+	signing_key := hmacSHA256([]byte("AWS4"+cfg.SecretAccessKey), date)
+	signing_key = hmacSHA256(signing_key, cfg.Region)
+	signing_key = hmacSHA256(signing_key, "s3")
+	signing_key = hmacSHA256(signing_key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signing_key, string_to_sign))
+
+	req.Header.Set(
+		"Authorization",
+		"AWS4-HMAC-SHA256 Credential="+cfg.AccessKeyID+"/"+scope+
+			", SignedHeaders="+signed_headers+
+			", Signature="+signature,
+	)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func s3CanonicalQuery(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, k := range keys {
+		values := params[k]
+		sort.Strings(values)
+		for _, v := range values {
+			pairs = append(pairs, s3URIEncode(k, false)+"="+s3URIEncode(v, false))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// RFC 3986 unreserved chars kept, everything else percent-encoded
+// ("/" kept only in paths)
+func s3URIEncode(s string, is_path bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') ||
+			(c >= 'a' && c <= 'z') ||
+			(c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' ||
+			(c == '/' && is_path) {
+			b.WriteByte(c)
+		} else {
+			b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+		}
+	}
+
+	return b.String()
+}
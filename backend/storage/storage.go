@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	e "github.com/julianlk522/fitm/error"
+)
+
+const MAX_KEY_LENGTH = 512
+
+// slash-separated segments of [A-Za-z0-9_.-], each starting with a letter
+// or digit (no "..", no absolute paths, no hidden files)
+var key_regex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*(/[A-Za-z0-9][A-Za-z0-9_.-]*)*$`)
+
+type BlobInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+type BlobStore interface {
+	Put(key string, r io.Reader, content_type string) error
+	// e.ErrBlobNotFound if missing
+	Get(key string) (io.ReadCloser, error)
+	Exists(key string) (bool, error)
+	// no error if already missing
+	Delete(key string) error
+	List(prefix string) ([]BlobInfo, error)
+}
+
+func ValidateKey(key string) error {
+	if len(key) > MAX_KEY_LENGTH || !key_regex.MatchString(key) {
+		return e.ErrInvalidBlobKey
+	}
+
+	return nil
+}
+
+// Build store from env vars:
+// FITM_PFP_STORE: "fs" (default, files under fs_root) or "s3"
+// FITM_S3_ENDPOINT, FITM_S3_REGION, FITM_S3_BUCKET, FITM_S3_PREFIX,
+// FITM_S3_ACCESS_KEY_ID, FITM_S3_SECRET_ACCESS_KEY: S3-compatible store
+func NewBlobStoreFromEnv(fs_root string) (BlobStore, error) {
+	switch store := os.Getenv("FITM_PFP_STORE"); store {
+	case "", "fs":
+		return NewFSStore(fs_root), nil
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:        os.Getenv("FITM_S3_ENDPOINT"),
+			Region:          os.Getenv("FITM_S3_REGION"),
+			Bucket:          os.Getenv("FITM_S3_BUCKET"),
+			Prefix:          os.Getenv("FITM_S3_PREFIX"),
+			AccessKeyID:     os.Getenv("FITM_S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("FITM_S3_SECRET_ACCESS_KEY"),
+		})
+	default:
+		return nil, e.UnsupportedBlobStore(store)
+	}
+}
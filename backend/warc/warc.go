@@ -0,0 +1,184 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const VERSION = "WARC/1.1"
+
+const (
+	TYPE_WARCINFO = "warcinfo"
+	TYPE_RESPONSE = "response"
+)
+
+// Block: record content (for responses, full HTTP response message)
+type Record struct {
+	Type        string
+	ID          string
+	Date        time.Time
+	TargetURI   string
+	ContentType string
+	Block       []byte
+}
+
+func NewWarcinfoRecord(fields map[string]string, keys []string) *Record {
+	var block bytes.Buffer
+	for _, key := range keys {
+		fmt.Fprintf(&block, "%s: %s\r\n", key, fields[key])
+	}
+
+	return &Record{
+		Type:        TYPE_WARCINFO,
+		Date:        time.Now(),
+		ContentType: "application/warc-fields",
+		Block:       block.Bytes(),
+	}
+}
+
+// body read separately since resp.Body may be capped by caller
+func NewResponseRecord(target_uri string, resp *http.Response, body []byte, date time.Time) *Record {
+	var block bytes.Buffer
+	fmt.Fprintf(&block, "HTTP/1.1 %s\r\n", resp.Status)
+
+	// body already decompressed and de-chunked by client
+	header := resp.Header.Clone()
+	header.Del("Content-Encoding")
+	header.Del("Transfer-Encoding")
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	header.Write(&block)
+	block.WriteString("\r\n")
+	block.Write(body)
+
+	return &Record{
+		Type:        TYPE_RESPONSE,
+		Date:        date,
+		TargetURI:   target_uri,
+		ContentType: "application/http; msgtype=response",
+		Block:       block.Bytes(),
+	}
+}
+
+// Writes records as separate gzip members (.warc.gz) so each can be read
+// on its own from its offset
+type Writer struct {
+	w      io.Writer
+	offset int64
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Returns offset and length of record's gzip member.
+// ID set if empty
+func (w *Writer) WriteRecord(r *Record) (int64, int64, error) {
+	if r.ID == "" {
+		r.ID = "<urn:uuid:" + uuid.New().String() + ">"
+	}
+
+	var member bytes.Buffer
+	gz := gzip.NewWriter(&member)
+
+	header := fmt.Sprintf("%s\r\nWARC-Type: %s\r\nWARC-Record-ID: %s\r\nWARC-Date: %s\r\n",
+		VERSION,
+		r.Type,
+		r.ID,
+		r.Date.UTC().Format(time.RFC3339),
+	)
+	if r.TargetURI != "" {
+		header += "WARC-Target-URI: " + r.TargetURI + "\r\n"
+	}
+	header += fmt.Sprintf("WARC-Block-Digest: %s\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n",
+		blockDigest(r.Block),
+		r.ContentType,
+		len(r.Block),
+	)
+
+	if _, err := gz.Write([]byte(header)); err != nil {
+		return 0, 0, err
+	} else if _, err = gz.Write(r.Block); err != nil {
+		return 0, 0, err
+	} else if _, err = gz.Write([]byte("\r\n\r\n")); err != nil {
+		return 0, 0, err
+	} else if err = gz.Close(); err != nil {
+		return 0, 0, err
+	}
+
+	offset := w.offset
+	n, err := w.w.Write(member.Bytes())
+	w.offset += int64(n)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return offset, int64(n), nil
+}
+
+func blockDigest(block []byte) string {
+	sum := sha1.Sum(block)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// Reads record from start of a gzip member written by Writer
+func ReadRecord(r io.Reader) (*Record, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	gz.Multistream(false)
+
+	tp := textproto.NewReader(bufio.NewReader(gz))
+	version, err := tp.ReadLine()
+	if err != nil {
+		return nil, err
+	} else if version != VERSION {
+		return nil, fmt.Errorf("unsupported WARC version %q", version)
+	}
+
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid WARC Content-Length %q", header.Get("Content-Length"))
+	}
+
+	block := make([]byte, length)
+	if _, err = io.ReadFull(tp.R, block); err != nil {
+		return nil, err
+	}
+
+	date, _ := time.Parse(time.RFC3339, header.Get("WARC-Date"))
+
+	return &Record{
+		Type:        header.Get("WARC-Type"),
+		ID:          header.Get("WARC-Record-ID"),
+		Date:        date,
+		TargetURI:   header.Get("WARC-Target-URI"),
+		ContentType: header.Get("Content-Type"),
+		Block:       block,
+	}, nil
+}
+
+// Parses HTTP response in response record's block
+func (r *Record) HTTPResponse() (*http.Response, error) {
+	if r.Type != TYPE_RESPONSE {
+		return nil, fmt.Errorf("not a response record: %s", r.Type)
+	}
+
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(r.Block)), nil)
+}
@@ -0,0 +1,84 @@
+package warc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteAndReadRecords(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	info := NewWarcinfoRecord(map[string]string{"software": "FITM"}, []string{"software"})
+	if _, _, err := w.WriteRecord(info); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &http.Response{
+		Status: "200 OK",
+		Header: http.Header{
+			"Content-Type":     {"text/html"},
+			"Content-Encoding": {"gzip"},
+		},
+	}
+	body := []byte("<html>hi</html>")
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	offset, length, err := w.WriteRecord(NewResponseRecord("https://example.com/", resp, body, date))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// file is valid multi-member gzip
+	gz, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	} else if strings.Count(string(all), VERSION+"\r\n") != 2 {
+		t.Fatalf("expected 2 records, got:\n%s", all)
+	}
+
+	// response record read on its own
+	record, err := ReadRecord(bytes.NewReader(buf.Bytes()[offset : offset+length]))
+	if err != nil {
+		t.Fatal(err)
+	} else if record.Type != TYPE_RESPONSE ||
+		record.TargetURI != "https://example.com/" ||
+		!record.Date.Equal(date) ||
+		!strings.HasPrefix(record.ID, "<urn:uuid:") {
+		t.Fatalf("got record %+v", record)
+	}
+
+	http_resp, err := record.HTTPResponse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer http_resp.Body.Close()
+
+	got_body, err := io.ReadAll(http_resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	} else if string(got_body) != string(body) {
+		t.Fatalf("got body %q", got_body)
+	} else if http_resp.Header.Get("Content-Type") != "text/html" ||
+		http_resp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("got header %v", http_resp.Header)
+	}
+
+	// warcinfo record is not a response
+	record, err = ReadRecord(bytes.NewReader(buf.Bytes()[:offset]))
+	if err != nil {
+		t.Fatal(err)
+	} else if _, err = record.HTTPResponse(); err == nil {
+		t.Fatal("expected error for warcinfo record HTTP response")
+	} else if string(record.Block) != "software: FITM\r\n" {
+		t.Fatalf("got warcinfo block %q", record.Block)
+	}
+}